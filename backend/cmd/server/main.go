@@ -1,6 +1,9 @@
 package main
 
 import (
+    "crypto/tls"
+    "net/http"
+
     "engtools/backend/internal/config"
     "engtools/backend/internal/logger"
     "engtools/backend/internal/repository"
@@ -12,5 +15,11 @@ func main() {
     log := logger.New(cfg)
     db := repository.InitDB(cfg, log)
     r := router.New(cfg, log, db)
+    if cfg.TLSCertFile != "" && cfg.TLSKeyFile != "" {
+        // request (but never require) client certificates so the echo endpoint can show them
+        srv := &http.Server{Addr: cfg.ServerAddr, Handler: r, TLSConfig: &tls.Config{ClientAuth: tls.RequestClientCert}}
+        _ = srv.ListenAndServeTLS(cfg.TLSCertFile, cfg.TLSKeyFile)
+        return
+    }
     _ = r.Run(cfg.ServerAddr)
 }
\ No newline at end of file
@@ -0,0 +1,366 @@
+// Package asn1dump decodes DER into a tree of tag-length-value nodes for display, in the
+// spirit of `openssl asn1parse`.
+package asn1dump
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// MaxDepth bounds nesting so hostile input cannot exhaust the stack.
+const MaxDepth = 64
+
+// Node is one TLV element. Offsets are relative to the start of the input.
+type Node struct {
+	Offset      int    `json:"offset"`
+	HeaderLen   int    `json:"header_len"`
+	Length      int    `json:"length"` // content length; for indefinite length, up to the end-of-contents marker
+	Indefinite  bool   `json:"indefinite,omitempty"`
+	Class       string `json:"class"`
+	Tag         int    `json:"tag"`
+	Type        string `json:"type"`
+	Constructed bool   `json:"constructed"`
+	Value       string `json:"value,omitempty"`
+	Name        string `json:"name,omitempty"`
+	// Encapsulated is set when a BIT STRING or OCTET STRING holds DER itself, as with
+	// extension values and public keys; the children are then that nested structure.
+	Encapsulated bool    `json:"encapsulated,omitempty"`
+	Children     []*Node `json:"children,omitempty"`
+	// Error is set on the node whose decoding failed; its children are those decoded
+	// before the failure.
+	Error string `json:"error,omitempty"`
+}
+
+var classNames = [4]string{"universal", "application", "context", "private"}
+
+var universalNames = map[int]string{
+	0: "EOC", 1: "BOOLEAN", 2: "INTEGER", 3: "BIT STRING", 4: "OCTET STRING", 5: "NULL", 6: "OBJECT IDENTIFIER",
+	7: "ObjectDescriptor", 8: "EXTERNAL", 9: "REAL", 10: "ENUMERATED", 11: "EMBEDDED PDV", 12: "UTF8String",
+	13: "RELATIVE-OID", 14: "TIME", 16: "SEQUENCE", 17: "SET", 18: "NumericString", 19: "PrintableString",
+	20: "T61String", 21: "VideotexString", 22: "IA5String", 23: "UTCTime", 24: "GeneralizedTime",
+	25: "GraphicString", 26: "VisibleString", 27: "GeneralString", 28: "UniversalString", 30: "BMPString",
+}
+
+// DecodeError locates where decoding stopped.
+type DecodeError struct {
+	Offset int
+	Msg    string
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("offset %d: %s", e.Offset, e.Msg)
+}
+
+// Parse decodes a single DER element covering all of data.
+func Parse(data []byte) (*Node, error) {
+	d := decoder{data: data, strict: true}
+	n, next, err := d.parse(0, len(data), 0)
+	if err != nil {
+		return n, err
+	}
+	if next != len(data) {
+		return n, &DecodeError{Offset: next, Msg: "trailing data"}
+	}
+	return n, nil
+}
+
+// Result is the outcome of Decode.
+type Result struct {
+	Nodes       []*Node `json:"nodes"`
+	Consumed    int     `json:"consumed"`
+	Error       string  `json:"error,omitempty"`
+	ErrorOffset *int    `json:"error_offset,omitempty"`
+}
+
+// Decode is the tolerant counterpart of Parse for arbitrary blobs: it accepts BER
+// indefinite lengths and a sequence of top-level elements, and on malformed input returns
+// everything decoded up to the failure together with its offset.
+func Decode(data []byte) Result {
+	d := decoder{data: data}
+	var r Result
+	for off := 0; off < len(data); {
+		n, next, err := d.parse(off, len(data), 0)
+		if n != nil {
+			r.Nodes = append(r.Nodes, n)
+		}
+		if err != nil {
+			r.Error = err.Error()
+			if de, ok := err.(*DecodeError); ok {
+				r.ErrorOffset = &de.Offset
+			}
+			return r
+		}
+		off = next
+		r.Consumed = next
+	}
+	return r
+}
+
+type decoder struct {
+	data   []byte
+	strict bool // DER only
+}
+
+type header struct {
+	class, tag, hdrLen, length int
+	constructed, indefinite    bool
+	truncated                  bool // length runs past the end of the input (tolerant mode only)
+}
+
+func (d *decoder) readHeader(off, limit int) (header, error) {
+	var h header
+	data := d.data[:limit]
+	p := off
+	if p >= len(data) {
+		return h, &DecodeError{off, "unexpected end of data"}
+	}
+	b := data[p]
+	p++
+	h.class = int(b >> 6)
+	h.constructed = b&0x20 != 0
+	h.tag = int(b & 0x1f)
+	if h.tag == 0x1f {
+		h.tag = 0
+		for {
+			if p >= len(data) {
+				return h, &DecodeError{off, "truncated tag"}
+			}
+			b = data[p]
+			p++
+			if h.tag > 1<<23 {
+				return h, &DecodeError{off, "tag number too large"}
+			}
+			h.tag = h.tag<<7 | int(b&0x7f)
+			if b&0x80 == 0 {
+				break
+			}
+		}
+	}
+	if p >= len(data) {
+		return h, &DecodeError{p, "truncated length"}
+	}
+	b = data[p]
+	p++
+	switch {
+	case b < 0x80:
+		h.length = int(b)
+	case b == 0x80:
+		if d.strict {
+			return h, &DecodeError{p - 1, "indefinite length is not DER"}
+		}
+		if !h.constructed {
+			return h, &DecodeError{p - 1, "indefinite length on primitive encoding"}
+		}
+		h.indefinite = true
+	default:
+		n := int(b & 0x7f)
+		if n > 4 {
+			return h, &DecodeError{p - 1, "length too large"}
+		}
+		if p+n > len(data) {
+			return h, &DecodeError{p - 1, "truncated length"}
+		}
+		for i := 0; i < n; i++ {
+			h.length = h.length<<8 | int(data[p+i])
+		}
+		p += n
+	}
+	h.hdrLen = p - off
+	if !h.indefinite && h.length > len(data)-p {
+		if d.strict {
+			return h, &DecodeError{off, fmt.Sprintf("length %d exceeds remaining %d bytes", h.length, len(data)-p)}
+		}
+		h.truncated = true
+	}
+	return h, nil
+}
+
+// parse decodes the element at off, which must end by limit. It returns the node, the
+// offset following it and, on failure, the partially decoded node.
+func (d *decoder) parse(off, limit, depth int) (*Node, int, error) {
+	if depth > MaxDepth {
+		return nil, off, &DecodeError{off, "nesting too deep"}
+	}
+	h, err := d.readHeader(off, limit)
+	if err != nil {
+		return nil, off, err
+	}
+	n := &Node{Offset: off, HeaderLen: h.hdrLen, Length: h.length, Indefinite: h.indefinite,
+		Class: classNames[h.class], Tag: h.tag, Constructed: h.constructed}
+	if h.class == 0 {
+		n.Type = universalNames[h.tag]
+	}
+	if n.Type == "" {
+		n.Type = fmt.Sprintf("[%d]", h.tag)
+		if h.class != 2 {
+			n.Type = fmt.Sprintf("[%s %d]", strings.ToUpper(classNames[h.class]), h.tag)
+		}
+	}
+	start := off + h.hdrLen
+	if h.indefinite {
+		for p := start; ; {
+			if p+2 <= limit && d.data[p] == 0 && d.data[p+1] == 0 {
+				n.Length = p - start
+				return n, p + 2, nil
+			}
+			child, next, err := d.parse(p, limit, depth+1)
+			if child != nil {
+				n.Children = append(n.Children, child)
+			}
+			if err != nil {
+				if child == nil {
+					n.Error = err.Error()
+				}
+				return n, p, err
+			}
+			p = next
+		}
+	}
+	end := start + h.length
+	var truncErr error
+	if h.truncated {
+		// decode what is there so a cut-off blob still shows its structure
+		truncErr = &DecodeError{off, fmt.Sprintf("length %d exceeds remaining %d bytes", h.length, limit-start)}
+		n.Error = truncErr.Error()
+		end = limit
+	}
+	if h.constructed {
+		for p := start; p < end; {
+			child, next, err := d.parse(p, end, depth+1)
+			if child != nil {
+				n.Children = append(n.Children, child)
+			}
+			if err != nil {
+				if child == nil {
+					n.Error = err.Error()
+				}
+				return n, p, err
+			}
+			p = next
+		}
+		return n, end, truncErr
+	}
+	content := d.data[start:end]
+	n.Value, n.Name = primitiveValue(h, content)
+	if truncErr != nil {
+		return n, end, truncErr
+	}
+	// nested DER inside strings
+	if h.class == 0 && (h.tag == 3 || h.tag == 4) {
+		inner := content
+		if h.tag == 3 {
+			if len(content) == 0 || content[0] != 0 {
+				return n, end, nil
+			}
+			inner = content[1:]
+		}
+		if len(inner) > 1 && (inner[0] == 0x30 || inner[0] == 0x31) {
+			sub := decoder{data: d.data, strict: true}
+			child, next, err := sub.parse(end-len(inner), end, depth+1)
+			if err == nil && next == end {
+				n.Encapsulated = true
+				n.Children = []*Node{child}
+			}
+		}
+	}
+	return n, end, nil
+}
+
+func primitiveValue(h header, b []byte) (value, name string) {
+	if h.class != 0 {
+		if printable(b) {
+			return string(b), ""
+		}
+		return hex.EncodeToString(b), ""
+	}
+	switch h.tag {
+	case 1:
+		if len(b) == 1 {
+			return fmt.Sprint(b[0] != 0), ""
+		}
+	case 2, 10:
+		v := new(big.Int).SetBytes(b)
+		if len(b) > 0 && b[0]&0x80 != 0 {
+			v.Sub(v, new(big.Int).Lsh(big.NewInt(1), uint(len(b)*8)))
+		}
+		if len(b) > 8 {
+			return "0x" + strings.ToUpper(hex.EncodeToString(b)), ""
+		}
+		return v.String(), ""
+	case 3:
+		if len(b) > 0 {
+			return fmt.Sprintf("%d unused bits: %s", b[0], hex.EncodeToString(b[1:])), ""
+		}
+	case 5:
+		return "", ""
+	case 6:
+		if oid, err := decodeOID(b); err == nil {
+			return oid, OIDName(oid)
+		}
+	case 12, 18, 19, 20, 21, 22, 25, 26, 27:
+		if utf8.Valid(b) {
+			return string(b), ""
+		}
+	case 23, 24:
+		if t, err := parseTime(h.tag, string(b)); err == nil {
+			return t.UTC().Format(time.RFC3339), ""
+		}
+		return string(b), ""
+	case 30:
+		if len(b)%2 == 0 {
+			u := make([]uint16, len(b)/2)
+			for i := range u {
+				u[i] = uint16(b[2*i])<<8 | uint16(b[2*i+1])
+			}
+			return string(utf16.Decode(u)), ""
+		}
+	case 28:
+		if len(b)%4 == 0 {
+			var sb strings.Builder
+			for i := 0; i < len(b); i += 4 {
+				sb.WriteRune(rune(uint32(b[i])<<24 | uint32(b[i+1])<<16 | uint32(b[i+2])<<8 | uint32(b[i+3])))
+			}
+			return sb.String(), ""
+		}
+	}
+	return hex.EncodeToString(b), ""
+}
+
+func parseTime(tag int, s string) (time.Time, error) {
+	if tag == 23 {
+		for _, layout := range []string{"0601021504Z0700", "060102150405Z0700"} {
+			if t, err := time.Parse(layout, s); err == nil {
+				// RFC 5280: two-digit years 50-99 are 19xx, Go pivots at 69
+				if t.Year() >= 2050 {
+					t = t.AddDate(-100, 0, 0)
+				}
+				return t, nil
+			}
+		}
+		return time.Time{}, errors.New("bad UTCTime")
+	}
+	for _, layout := range []string{"20060102150405Z0700", "20060102150405.999999999Z0700"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, errors.New("bad GeneralizedTime")
+}
+
+func printable(b []byte) bool {
+	if len(b) == 0 || !utf8.Valid(b) {
+		return false
+	}
+	for _, r := range string(b) {
+		if r < 0x20 || r == 0x7f {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,32 @@
+package asn1dump
+
+import "testing"
+
+func TestDecode(t *testing.T) {
+	// SEQUENCE { OID 1.2.840.113549.1.1.11, NULL }
+	r := Decode([]byte{0x30, 0x0d, 0x06, 0x09, 0x2a, 0x86, 0x48, 0x86, 0xf7, 0x0d, 0x01, 0x01, 0x0b, 0x05, 0x00})
+	if r.Error != "" || len(r.Nodes) != 1 || len(r.Nodes[0].Children) != 2 {
+		t.Fatalf("unexpected result %+v", r)
+	}
+	if oid := r.Nodes[0].Children[0]; oid.Value != "1.2.840.113549.1.1.11" || oid.Name != "sha256WithRSAEncryption" {
+		t.Fatalf("oid decoded as %q (%q)", oid.Value, oid.Name)
+	}
+
+	// BER indefinite length
+	r = Decode([]byte{0x30, 0x80, 0x02, 0x01, 0x05, 0x00, 0x00})
+	if r.Error != "" || !r.Nodes[0].Indefinite || r.Nodes[0].Children[0].Value != "5" || r.Consumed != 7 {
+		t.Fatalf("indefinite length: %+v", r)
+	}
+	if _, err := Parse([]byte{0x30, 0x80, 0x02, 0x01, 0x05, 0x00, 0x00}); err == nil {
+		t.Fatal("Parse accepted indefinite length")
+	}
+
+	// second element claims more bytes than remain
+	r = Decode([]byte{0x30, 0x06, 0x02, 0x01, 0x01, 0x02, 0x05, 0x01})
+	if r.ErrorOffset == nil || *r.ErrorOffset != 5 {
+		t.Fatalf("want failure at offset 5, got %+v", r)
+	}
+	if seq := r.Nodes[0]; len(seq.Children) != 2 || seq.Children[1].Error == "" || seq.Children[1].Value != "1" {
+		t.Fatalf("truncated element not kept: %+v", seq)
+	}
+}
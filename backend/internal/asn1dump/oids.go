@@ -0,0 +1,259 @@
+package asn1dump
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+)
+
+// oidNames is the bundled OID registry, covering what turns up in certificates, keys,
+// CMS/PKCS#7, PKCS#12, OCSP and Kerberos. Names follow OpenSSL's long names where it has one.
+var oidNames = map[string]string{
+	// public key and signature algorithms
+	"1.2.840.113549.1.1.1":   "rsaEncryption",
+	"1.2.840.113549.1.1.4":   "md5WithRSAEncryption",
+	"1.2.840.113549.1.1.5":   "sha1WithRSAEncryption",
+	"1.2.840.113549.1.1.7":   "rsaesOaep",
+	"1.2.840.113549.1.1.8":   "mgf1",
+	"1.2.840.113549.1.1.10":  "rsassaPss",
+	"1.2.840.113549.1.1.11":  "sha256WithRSAEncryption",
+	"1.2.840.113549.1.1.12":  "sha384WithRSAEncryption",
+	"1.2.840.113549.1.1.13":  "sha512WithRSAEncryption",
+	"1.2.840.113549.1.1.14":  "sha224WithRSAEncryption",
+	"1.2.840.10040.4.1":      "dsaEncryption",
+	"1.2.840.10040.4.3":      "dsaWithSHA1",
+	"2.16.840.1.101.3.4.3.2": "dsaWithSHA256",
+	"1.2.840.10045.2.1":      "ecPublicKey",
+	"1.2.840.10045.3.1.7":    "prime256v1",
+	"1.3.132.0.10":           "secp256k1",
+	"1.3.132.0.34":           "secp384r1",
+	"1.3.132.0.35":           "secp521r1",
+	"1.3.36.3.3.2.8.1.1.7":   "brainpoolP256r1",
+	"1.3.36.3.3.2.8.1.1.11":  "brainpoolP384r1",
+	"1.3.36.3.3.2.8.1.1.13":  "brainpoolP512r1",
+	"1.2.840.10045.4.1":      "ecdsa-with-SHA1",
+	"1.2.840.10045.4.3.1":    "ecdsa-with-SHA224",
+	"1.2.840.10045.4.3.2":    "ecdsa-with-SHA256",
+	"1.2.840.10045.4.3.3":    "ecdsa-with-SHA384",
+	"1.2.840.10045.4.3.4":    "ecdsa-with-SHA512",
+	"1.3.101.110":            "X25519",
+	"1.3.101.111":            "X448",
+	"1.3.101.112":            "Ed25519",
+	"1.3.101.113":            "Ed448",
+	"1.2.156.10197.1.301":    "sm2",
+	"1.2.156.10197.1.501":    "SM2-with-SM3",
+	"1.2.156.10197.1.401":    "sm3",
+	// digests
+	"1.2.840.113549.2.5":      "md5",
+	"1.3.14.3.2.26":           "sha1",
+	"2.16.840.1.101.3.4.2.1":  "sha256",
+	"2.16.840.1.101.3.4.2.2":  "sha384",
+	"2.16.840.1.101.3.4.2.3":  "sha512",
+	"2.16.840.1.101.3.4.2.4":  "sha224",
+	"2.16.840.1.101.3.4.2.8":  "sha3-256",
+	"2.16.840.1.101.3.4.2.9":  "sha3-384",
+	"2.16.840.1.101.3.4.2.10": "sha3-512",
+	"1.2.840.113549.2.7":      "hmacWithSHA1",
+	"1.2.840.113549.2.9":      "hmacWithSHA256",
+	"1.2.840.113549.2.10":     "hmacWithSHA384",
+	"1.2.840.113549.2.11":     "hmacWithSHA512",
+	// ciphers and password-based encryption
+	"2.16.840.1.101.3.4.1.2":  "aes-128-cbc",
+	"2.16.840.1.101.3.4.1.6":  "aes-128-gcm",
+	"2.16.840.1.101.3.4.1.22": "aes-192-cbc",
+	"2.16.840.1.101.3.4.1.42": "aes-256-cbc",
+	"2.16.840.1.101.3.4.1.46": "aes-256-gcm",
+	"2.16.840.1.101.3.4.1.5":  "id-aes128-wrap",
+	"2.16.840.1.101.3.4.1.45": "id-aes256-wrap",
+	"1.2.840.113549.3.7":      "des-ede3-cbc",
+	"1.2.840.113549.3.2":      "rc2-cbc",
+	"1.2.840.113549.1.5.12":   "PBKDF2",
+	"1.2.840.113549.1.5.13":   "PBES2",
+	"1.2.840.113549.1.5.3":    "pbeWithMD5AndDES-CBC",
+	"1.2.840.113549.1.5.10":   "pbeWithSHA1AndDES-CBC",
+	"1.2.840.113549.1.12.1.3": "pbeWithSHA1And3-KeyTripleDES-CBC",
+	"1.2.840.113549.1.12.1.6": "pbeWithSHA1And40BitRC2-CBC",
+	"1.3.6.1.4.1.11591.4.11":  "id-scrypt",
+	// PKCS#7 / CMS
+	"1.2.840.113549.1.7.1":       "pkcs7-data",
+	"1.2.840.113549.1.7.2":       "pkcs7-signedData",
+	"1.2.840.113549.1.7.3":       "pkcs7-envelopedData",
+	"1.2.840.113549.1.7.5":       "pkcs7-digestData",
+	"1.2.840.113549.1.7.6":       "pkcs7-encryptedData",
+	"1.2.840.113549.1.9.16.1.2":  "id-ct-authData",
+	"1.2.840.113549.1.9.16.1.4":  "id-smime-ct-TSTInfo",
+	"1.2.840.113549.1.9.16.1.23": "id-ct-authEnvelopedData",
+	"1.2.840.113549.1.9.16.2.12": "signingCertificate",
+	"1.2.840.113549.1.9.16.2.47": "signingCertificateV2",
+	"1.2.840.113549.1.9.16.2.14": "timeStampToken",
+	// PKCS#9 attributes
+	"1.2.840.113549.1.9.1":    "emailAddress",
+	"1.2.840.113549.1.9.2":    "unstructuredName",
+	"1.2.840.113549.1.9.3":    "contentType",
+	"1.2.840.113549.1.9.4":    "messageDigest",
+	"1.2.840.113549.1.9.5":    "signingTime",
+	"1.2.840.113549.1.9.6":    "countersignature",
+	"1.2.840.113549.1.9.7":    "challengePassword",
+	"1.2.840.113549.1.9.14":   "extensionRequest",
+	"1.2.840.113549.1.9.15":   "smimeCapabilities",
+	"1.2.840.113549.1.9.20":   "friendlyName",
+	"1.2.840.113549.1.9.21":   "localKeyID",
+	"1.2.840.113549.1.9.22.1": "x509Certificate",
+	"1.2.840.113549.1.9.23.1": "x509Crl",
+	// PKCS#12 bags
+	"1.2.840.113549.1.12.10.1.1": "keyBag",
+	"1.2.840.113549.1.12.10.1.2": "pkcs8ShroudedKeyBag",
+	"1.2.840.113549.1.12.10.1.3": "certBag",
+	"1.2.840.113549.1.12.10.1.4": "crlBag",
+	"1.2.840.113549.1.12.10.1.5": "secretBag",
+	"1.2.840.113549.1.12.10.1.6": "safeContentsBag",
+	// attribute types
+	"2.5.4.3":                    "commonName",
+	"2.5.4.4":                    "surname",
+	"2.5.4.5":                    "serialNumber",
+	"2.5.4.6":                    "countryName",
+	"2.5.4.7":                    "localityName",
+	"2.5.4.8":                    "stateOrProvinceName",
+	"2.5.4.9":                    "streetAddress",
+	"2.5.4.10":                   "organizationName",
+	"2.5.4.11":                   "organizationalUnitName",
+	"2.5.4.12":                   "title",
+	"2.5.4.15":                   "businessCategory",
+	"2.5.4.17":                   "postalCode",
+	"2.5.4.42":                   "givenName",
+	"2.5.4.46":                   "dnQualifier",
+	"2.5.4.97":                   "organizationIdentifier",
+	"0.9.2342.19200300.100.1.1":  "userId",
+	"0.9.2342.19200300.100.1.25": "domainComponent",
+	"1.3.6.1.4.1.311.60.2.1.1":   "jurisdictionLocalityName",
+	"1.3.6.1.4.1.311.60.2.1.2":   "jurisdictionStateOrProvinceName",
+	"1.3.6.1.4.1.311.60.2.1.3":   "jurisdictionCountryName",
+	// certificate and CRL extensions
+	"2.5.29.9":                "subjectDirectoryAttributes",
+	"2.5.29.14":               "subjectKeyIdentifier",
+	"2.5.29.15":               "keyUsage",
+	"2.5.29.16":               "privateKeyUsagePeriod",
+	"2.5.29.17":               "subjectAltName",
+	"2.5.29.18":               "issuerAltName",
+	"2.5.29.19":               "basicConstraints",
+	"2.5.29.20":               "cRLNumber",
+	"2.5.29.21":               "cRLReason",
+	"2.5.29.23":               "holdInstructionCode",
+	"2.5.29.24":               "invalidityDate",
+	"2.5.29.27":               "deltaCRLIndicator",
+	"2.5.29.28":               "issuingDistributionPoint",
+	"2.5.29.29":               "certificateIssuer",
+	"2.5.29.30":               "nameConstraints",
+	"2.5.29.31":               "cRLDistributionPoints",
+	"2.5.29.32":               "certificatePolicies",
+	"2.5.29.32.0":             "anyPolicy",
+	"2.5.29.33":               "policyMappings",
+	"2.5.29.35":               "authorityKeyIdentifier",
+	"2.5.29.36":               "policyConstraints",
+	"2.5.29.37":               "extKeyUsage",
+	"2.5.29.37.0":             "anyExtendedKeyUsage",
+	"2.5.29.46":               "freshestCRL",
+	"2.5.29.54":               "inhibitAnyPolicy",
+	"1.3.6.1.5.5.7.1.1":       "authorityInfoAccess",
+	"1.3.6.1.5.5.7.1.3":       "qcStatements",
+	"1.3.6.1.5.5.7.1.11":      "subjectInfoAccess",
+	"1.3.6.1.5.5.7.1.24":      "tlsfeature",
+	"1.3.6.1.5.5.7.2.1":       "id-qt-cps",
+	"1.3.6.1.5.5.7.2.2":       "id-qt-unotice",
+	"1.3.6.1.5.5.7.48.1":      "ocsp",
+	"1.3.6.1.5.5.7.48.2":      "caIssuers",
+	"1.3.6.1.5.5.7.48.5":      "caRepository",
+	"1.3.6.1.4.1.11129.2.4.2": "signedCertificateTimestampList",
+	"1.3.6.1.4.1.11129.2.4.3": "ctPrecertificatePoison",
+	"1.3.6.1.4.1.11129.2.4.5": "ctOCSPSCTList",
+	"1.3.6.1.4.1.311.20.2":    "msCertificateTemplateName",
+	"1.3.6.1.4.1.311.21.7":    "msCertificateTemplate",
+	"1.3.6.1.4.1.311.21.10":   "msApplicationPolicies",
+	"1.3.6.1.4.1.311.20.2.3":  "msUPN",
+	"2.16.840.1.113730.1.1":   "nsCertType",
+	"2.16.840.1.113730.1.13":  "nsComment",
+	// extended key usages
+	"1.3.6.1.5.5.7.3.1":      "serverAuth",
+	"1.3.6.1.5.5.7.3.2":      "clientAuth",
+	"1.3.6.1.5.5.7.3.3":      "codeSigning",
+	"1.3.6.1.5.5.7.3.4":      "emailProtection",
+	"1.3.6.1.5.5.7.3.8":      "timeStamping",
+	"1.3.6.1.5.5.7.3.9":      "OCSPSigning",
+	"1.3.6.1.4.1.311.10.3.3": "msSGC",
+	"1.3.6.1.4.1.311.10.3.4": "msEFS",
+	"1.3.6.1.4.1.311.20.2.2": "msSmartcardLogin",
+	"1.3.6.1.5.2.3.4":        "pkinitClientAuth",
+	"1.3.6.1.5.2.3.5":        "pkinitKDC",
+	"2.16.840.1.113730.4.1":  "nsSGC",
+	// certificate policies
+	"2.23.140.1.1":            "ev-guidelines",
+	"2.23.140.1.2.1":          "domain-validated",
+	"2.23.140.1.2.2":          "organization-validated",
+	"2.23.140.1.2.3":          "individual-validated",
+	"2.23.140.1.4.1":          "code-signing-requirements",
+	"1.3.6.1.4.1.44947.1.1.1": "ISRG domain validated",
+	// OCSP
+	"1.3.6.1.5.5.7.48.1.1": "id-pkix-ocsp-basic",
+	"1.3.6.1.5.5.7.48.1.2": "id-pkix-ocsp-nonce",
+	"1.3.6.1.5.5.7.48.1.3": "id-pkix-ocsp-crl",
+	"1.3.6.1.5.5.7.48.1.4": "id-pkix-ocsp-response",
+	"1.3.6.1.5.5.7.48.1.5": "id-pkix-ocsp-nocheck",
+	"1.3.6.1.5.5.7.48.1.6": "id-pkix-ocsp-archive-cutoff",
+	"1.3.6.1.5.5.7.48.1.7": "id-pkix-ocsp-service-locator",
+	// Kerberos and SPNEGO
+	"1.2.840.113554.1.2.2":   "krb5",
+	"1.2.840.113554.1.2.2.3": "krb5-user-to-user",
+	"1.2.840.48018.1.2.2":    "ms-krb5",
+	"1.3.6.1.5.5.2":          "spnego",
+	"1.3.6.1.5.2.2":          "id-pkinit-san",
+	"1.3.6.1.5.2.3.1":        "id-pkinit-authData",
+	"1.3.6.1.5.2.3.2":        "id-pkinit-DHKeyData",
+	"1.3.6.1.5.2.3.3":        "id-pkinit-rkeyData",
+	"1.3.6.1.4.1.311.2.2.10": "NTLMSSP",
+	"1.3.6.1.4.1.311.2.2.30": "NEGOEX",
+	// time-stamping and code signing
+	"1.3.6.1.4.1.311.2.1.4":  "SPC_INDIRECT_DATA",
+	"1.3.6.1.4.1.311.2.1.12": "SPC_SP_OPUS_INFO",
+	"1.3.6.1.4.1.311.3.3.1":  "msTimestampToken",
+}
+
+// OIDName returns a short descriptive name for a dotted OID, or "" when unknown.
+func OIDName(oid string) string {
+	return oidNames[oid]
+}
+
+// decodeOID decodes the content octets of an OBJECT IDENTIFIER, supporting arcs of any
+// size (UUID-based OIDs under 2.25 exceed 64 bits).
+func decodeOID(b []byte) (string, error) {
+	if len(b) == 0 || b[len(b)-1]&0x80 != 0 {
+		return "", errors.New("malformed oid")
+	}
+	var arcs []string
+	v := new(big.Int)
+	first := true
+	for i, c := range b {
+		if v.Sign() == 0 && c == 0x80 && (i == 0 || b[i-1]&0x80 == 0) {
+			return "", errors.New("non-minimal oid encoding")
+		}
+		v.Lsh(v, 7)
+		v.Or(v, big.NewInt(int64(c&0x7f)))
+		if c&0x80 != 0 {
+			continue
+		}
+		if first {
+			first = false
+			switch {
+			case v.Cmp(big.NewInt(40)) < 0:
+				arcs = append(arcs, "0", v.String())
+			case v.Cmp(big.NewInt(80)) < 0:
+				arcs = append(arcs, "1", new(big.Int).Sub(v, big.NewInt(40)).String())
+			default:
+				arcs = append(arcs, "2", new(big.Int).Sub(v, big.NewInt(80)).String())
+			}
+		} else {
+			arcs = append(arcs, v.String())
+		}
+		v = new(big.Int)
+	}
+	return strings.Join(arcs, "."), nil
+}
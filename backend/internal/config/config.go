@@ -2,6 +2,8 @@ package config
 
 import (
     "os"
+    "strings"
+    "time"
 )
 
 type Config struct {
@@ -10,8 +12,36 @@ type Config struct {
     DBPath     string
     AllowOrigin string
     IPInfoToken string
+    // TrustedProxies are the CIDRs/IPs whose X-Forwarded-For/X-Real-IP headers are honoured
+    TrustedProxies []string
+    // TrustedPlatform names a CDN header to trust for the client IP, e.g. CF-Connecting-IP
+    TrustedPlatform string
+    TLSCertFile string
+    TLSKeyFile  string
+    // CAKeySecret seals private keys of server-side CAs; defaults to JWTSecret
+    CAKeySecret string
+    // MonitorTick is how often the certificate monitor looks for due endpoints; 0 disables it
+    MonitorTick time.Duration
+    // SMTP settings for monitor e-mail notifications
+    SMTPAddr     string
+    SMTPUser     string
+    SMTPPassword string
+    SMTPFrom     string
+    // CTLogListFile holds a CT log list (Chrome v3 JSON) loaded instead of the bundled one
+    // and rewritten when the list is updated through the API
+    CTLogListFile string
+    // CTSearchURL is a crt.sh compatible certificate search; a local stand-in works too
+    CTSearchURL string
+    // DNSResolver (host:port) answers DANE lookups; it should validate DNSSEC. Empty uses
+    // the first nameserver in /etc/resolv.conf
+    DNSResolver string
 }
 
+// defaultTrustedProxies trusts loopback only. Behind the bundled nginx (or any proxy on a
+// docker network) set TRUSTED_PROXIES to its address or subnet, otherwise every request
+// appears to come from the proxy; private ranges are never trusted implicitly.
+const defaultTrustedProxies = "127.0.0.0/8,::1/128"
+
 func Load() *Config {
     addr := os.Getenv("SERVER_ADDR")
     if addr == "" {
@@ -30,5 +60,48 @@ func Load() *Config {
         origin = "*"
     }
     ipinfo := os.Getenv("IPINFO_TOKEN")
-    return &Config{ServerAddr: addr, JWTSecret: secret, DBPath: db, AllowOrigin: origin, IPInfoToken: ipinfo}
+    caSecret := os.Getenv("CA_KEY_SECRET")
+    if caSecret == "" {
+        caSecret = secret
+    }
+    proxies := os.Getenv("TRUSTED_PROXIES")
+    if proxies == "" {
+        proxies = defaultTrustedProxies
+    }
+    tick := time.Minute
+    if v := os.Getenv("MONITOR_TICK"); v != "" {
+        if d, err := time.ParseDuration(v); err == nil {
+            tick = d
+        } else if v == "0" || strings.EqualFold(v, "off") {
+            tick = 0
+        }
+    }
+    ctSearch := os.Getenv("CT_SEARCH_URL")
+    if ctSearch == "" {
+        ctSearch = "https://crt.sh/"
+    }
+    return &Config{
+        ServerAddr: addr, JWTSecret: secret, DBPath: db, AllowOrigin: origin, IPInfoToken: ipinfo,
+        TrustedProxies: splitList(proxies), TrustedPlatform: os.Getenv("TRUSTED_PLATFORM"),
+        TLSCertFile: os.Getenv("TLS_CERT_FILE"), TLSKeyFile: os.Getenv("TLS_KEY_FILE"),
+        CAKeySecret: caSecret, MonitorTick: tick,
+        SMTPAddr: os.Getenv("SMTP_ADDR"), SMTPUser: os.Getenv("SMTP_USER"),
+        SMTPPassword: os.Getenv("SMTP_PASSWORD"), SMTPFrom: os.Getenv("SMTP_FROM"),
+        CTLogListFile: os.Getenv("CT_LOG_LIST"), CTSearchURL: ctSearch,
+        DNSResolver: os.Getenv("DNS_RESOLVER"),
+    }
+}
+
+// splitList parses a comma separated env value; "none" yields an empty list.
+func splitList(v string) []string {
+    if strings.EqualFold(strings.TrimSpace(v), "none") {
+        return nil
+    }
+    var out []string
+    for _, p := range strings.Split(v, ",") {
+        if p = strings.TrimSpace(p); p != "" {
+            out = append(out, p)
+        }
+    }
+    return out
 }
\ No newline at end of file
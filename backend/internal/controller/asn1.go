@@ -0,0 +1,111 @@
+package controller
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"strings"
+
+	"engtools/backend/internal/asn1dump"
+	"github.com/gin-gonic/gin"
+)
+
+type ASN1DecodeReq struct {
+	Input  string `json:"input"`
+	Format string `json:"format"` // pem, base64, hex or auto (default)
+}
+
+// ASN1Block is the decoded tree of one PEM block, or of the whole input otherwise.
+type ASN1Block struct {
+	Type   string `json:"type,omitempty"` // PEM block type
+	Length int    `json:"length"`
+	asn1dump.Result
+}
+
+// ASN1Decode dumps arbitrary BER/DER as a TLV tree. Malformed input is not rejected: the
+// response carries what decoded and the offset at which decoding failed.
+func ASN1Decode() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req ASN1DecodeReq
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": "invalid input"})
+			return
+		}
+		in := strings.TrimSpace(req.Input)
+		if in == "" {
+			c.JSON(400, gin.H{"error": "no input"})
+			return
+		}
+		format := strings.ToLower(req.Format)
+		if format == "" || format == "auto" {
+			format = detectBinaryFormat(in)
+		}
+		var blocks []ASN1Block
+		if format == "pem" {
+			data := []byte(in)
+			for {
+				var block *pem.Block
+				block, data = pem.Decode(data)
+				if block == nil {
+					break
+				}
+				blocks = append(blocks, ASN1Block{Type: block.Type, Length: len(block.Bytes), Result: asn1dump.Decode(block.Bytes)})
+			}
+			if len(blocks) == 0 {
+				c.JSON(400, gin.H{"error": "no pem blocks"})
+				return
+			}
+		} else {
+			der, err := decodeBinary(in, format)
+			if err != nil {
+				c.JSON(400, gin.H{"error": err.Error()})
+				return
+			}
+			blocks = append(blocks, ASN1Block{Length: len(der), Result: asn1dump.Decode(der)})
+		}
+		c.JSON(200, gin.H{"format": format, "blocks": blocks})
+	}
+}
+
+// detectBinaryFormat prefers hex over base64 since any even-length hex string is also
+// valid base64.
+func detectBinaryFormat(s string) string {
+	if strings.Contains(s, "-----BEGIN") {
+		return "pem"
+	}
+	if _, err := decodeBinary(s, "hex"); err == nil {
+		return "hex"
+	}
+	return "base64"
+}
+
+// decodeBinary accepts hex with optional whitespace, colons or a 0x prefix, and standard
+// or URL-safe base64 with or without padding.
+func decodeBinary(s, format string) ([]byte, error) {
+	clean := strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '\t', '\r', '\n':
+			return -1
+		}
+		return r
+	}, s)
+	switch format {
+	case "hex":
+		clean = strings.TrimPrefix(strings.TrimPrefix(clean, "0x"), "0X")
+		clean = strings.ReplaceAll(clean, ":", "")
+		b, err := hex.DecodeString(clean)
+		if err != nil {
+			return nil, errors.New("invalid hex")
+		}
+		return b, nil
+	case "base64":
+		for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+			if b, err := enc.DecodeString(clean); err == nil {
+				return b, nil
+			}
+		}
+		return nil, errors.New("invalid base64")
+	}
+	return nil, errors.New("unsupported format")
+}
@@ -0,0 +1,282 @@
+package controller
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"strings"
+
+	"engtools/backend/internal/pki"
+	"github.com/gin-gonic/gin"
+)
+
+type KeyMatchReq struct {
+	CertPEM string `json:"cert_pem"`
+	CSRPEM  string `json:"csr_pem"`
+	KeyPEM  string `json:"key_pem"`
+}
+
+// KeyMatch compares the public keys of a certificate, CSR and private key; any two of the
+// three are enough.
+func KeyMatch() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req KeyMatchReq
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": "invalid input"})
+			return
+		}
+		spki := map[string][]byte{}
+		if strings.TrimSpace(req.CertPEM) != "" {
+			certs, err := pki.ParseCertificatesPEM(req.CertPEM)
+			if err != nil {
+				c.JSON(400, gin.H{"error": "cert: " + err.Error()})
+				return
+			}
+			spki["cert"] = certs[0].RawSubjectPublicKeyInfo
+		}
+		if strings.TrimSpace(req.CSRPEM) != "" {
+			csr, err := parseCSRPEM(req.CSRPEM)
+			if err != nil {
+				c.JSON(400, gin.H{"error": "csr: " + err.Error()})
+				return
+			}
+			spki["csr"] = csr.RawSubjectPublicKeyInfo
+		}
+		if strings.TrimSpace(req.KeyPEM) != "" {
+			key, err := pki.ParsePrivateKeyPEM(req.KeyPEM)
+			if err != nil {
+				c.JSON(400, gin.H{"error": "key: " + err.Error()})
+				return
+			}
+			der, err := x509.MarshalPKIXPublicKey(key.Public())
+			if err != nil {
+				c.JSON(400, gin.H{"error": "key: " + err.Error()})
+				return
+			}
+			spki["key"] = der
+		}
+		if len(spki) < 2 {
+			c.JSON(400, gin.H{"error": "provide at least two of cert_pem, csr_pem and key_pem"})
+			return
+		}
+		hashes := gin.H{}
+		for k, v := range spki {
+			sum := sha256.Sum256(v)
+			hashes[k] = pki.HexColon(sum[:])
+		}
+		pairs := gin.H{}
+		match := true
+		for _, p := range [][2]string{{"cert", "key"}, {"csr", "key"}, {"cert", "csr"}} {
+			a, okA := spki[p[0]]
+			b, okB := spki[p[1]]
+			if !okA || !okB {
+				continue
+			}
+			eq := string(a) == string(b)
+			pairs[p[0]+"_"+p[1]] = eq
+			match = match && eq
+		}
+		c.JSON(200, gin.H{"match": match, "pairs": pairs, "spki_sha256": hashes})
+	}
+}
+
+type PKCS12ExportReq struct {
+	CertPEM      string `json:"cert_pem"` // leaf first; further certificates are the chain
+	ChainPEM     string `json:"chain_pem"`
+	KeyPEM       string `json:"key_pem"`
+	Password     string `json:"password"`
+	FriendlyName string `json:"friendly_name"`
+	Legacy       bool   `json:"legacy"`
+}
+
+// PKCS12Export returns base64 JSON, or the raw .p12 file with ?format=binary.
+func PKCS12Export() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req PKCS12ExportReq
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": "invalid input"})
+			return
+		}
+		certs, err := pki.ParseCertificatesPEM(req.CertPEM)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		if strings.TrimSpace(req.ChainPEM) != "" {
+			chain, err := pki.ParseCertificatesPEM(req.ChainPEM)
+			if err != nil {
+				c.JSON(400, gin.H{"error": "chain: " + err.Error()})
+				return
+			}
+			certs = append(certs, chain...)
+		}
+		var key crypto.Signer
+		if strings.TrimSpace(req.KeyPEM) != "" {
+			if key, err = pki.ParsePrivateKeyPEM(req.KeyPEM); err != nil {
+				c.JSON(400, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		opts := pki.PKCS12Options{Password: req.Password, FriendlyName: req.FriendlyName, Legacy: req.Legacy}
+		der, err := pki.EncodePKCS12(key, certs[0], certs[1:], opts)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		if c.Query("format") == "binary" {
+			name := req.FriendlyName
+			if name == "" {
+				name = "bundle"
+			}
+			c.Header("Content-Disposition", `attachment; filename="`+safeFilename(name)+`.p12"`)
+			c.Data(200, "application/x-pkcs12", der)
+			return
+		}
+		c.JSON(200, gin.H{"pkcs12_base64": base64.StdEncoding.EncodeToString(der), "certificates": len(certs), "has_key": key != nil})
+	}
+}
+
+func safeFilename(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == '_' || r == '.' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' {
+			return r
+		}
+		return '_'
+	}, s)
+}
+
+type PKCS12ImportReq struct {
+	PKCS12   string `json:"pkcs12_base64"`
+	Password string `json:"password"`
+}
+
+func PKCS12Import() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req PKCS12ImportReq
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": "invalid input"})
+			return
+		}
+		der, err := base64.StdEncoding.DecodeString(strings.TrimSpace(req.PKCS12))
+		if err != nil {
+			c.JSON(400, gin.H{"error": "invalid base64"})
+			return
+		}
+		p12, err := pki.DecodePKCS12(der, req.Password)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		out := gin.H{"friendly_name": p12.FriendlyName, "legacy_encryption": p12.Legacy}
+		if p12.Leaf != nil {
+			out["cert"] = pki.EncodeCertPEM(p12.Leaf)
+			out["subject"] = p12.Leaf.Subject.String()
+		}
+		chain := make([]string, 0, len(p12.Chain))
+		for _, crt := range p12.Chain {
+			chain = append(chain, pki.EncodeCertPEM(crt))
+		}
+		out["chain"] = chain
+		if p12.Key != nil {
+			keyPEM, err := pki.MarshalPrivateKeyPEM(p12.Key)
+			if err != nil {
+				c.JSON(400, gin.H{"error": err.Error()})
+				return
+			}
+			out["private_key"] = keyPEM
+			out["key_alg"], out["key_bits"], _ = pki.KeyDescription(p12.Key.Public())
+		}
+		c.JSON(200, out)
+	}
+}
+
+type PKCS7Req struct {
+	PEM    string `json:"pem"`
+	CRLPEM string `json:"crl_pem"`
+	DER    string `json:"der_base64"`
+}
+
+// PKCS7Export packs PEM certificates (and optionally CRLs) into a .p7b bundle.
+func PKCS7Export() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req PKCS7Req
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": "invalid input"})
+			return
+		}
+		certs, err := pki.ParseCertificatesPEM(req.PEM)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		var crls [][]byte
+		data := []byte(req.CRLPEM)
+		for {
+			var block *pem.Block
+			block, data = pem.Decode(data)
+			if block == nil {
+				break
+			}
+			if block.Type == "X509 CRL" {
+				if _, err := x509.ParseRevocationList(block.Bytes); err != nil {
+					c.JSON(400, gin.H{"error": "crl: " + err.Error()})
+					return
+				}
+				crls = append(crls, block.Bytes)
+			}
+		}
+		der, err := pki.EncodePKCS7(certs, crls)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{
+			"pkcs7_pem":  string(pem.EncodeToMemory(&pem.Block{Type: "PKCS7", Bytes: der})),
+			"der_base64": base64.StdEncoding.EncodeToString(der),
+		})
+	}
+}
+
+// PKCS7Import lists the certificates and CRLs of a .p7b given as PEM or base64 DER.
+func PKCS7Import() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req PKCS7Req
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": "invalid input"})
+			return
+		}
+		var der []byte
+		var err error
+		if strings.TrimSpace(req.PEM) != "" {
+			der, err = pki.PKCS7FromPEM(req.PEM)
+		} else if req.DER != "" {
+			der, err = base64.StdEncoding.DecodeString(req.DER)
+		} else {
+			c.JSON(400, gin.H{"error": "no input"})
+			return
+		}
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		certs, crls, err := pki.DecodePKCS7(der)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		outCerts := make([]gin.H, 0, len(certs))
+		var bundle strings.Builder
+		for _, crt := range certs {
+			p := pki.EncodeCertPEM(crt)
+			bundle.WriteString(p)
+			outCerts = append(outCerts, gin.H{"subject": crt.Subject.String(), "issuer": crt.Issuer.String(), "pem": p})
+		}
+		outCRLs := make([]string, 0, len(crls))
+		for _, crl := range crls {
+			outCRLs = append(outCRLs, string(pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: crl})))
+		}
+		c.JSON(200, gin.H{"certs": outCerts, "crls": outCRLs, "pem": bundle.String()})
+	}
+}
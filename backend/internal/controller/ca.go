@@ -0,0 +1,349 @@
+package controller
+
+import (
+	"crypto"
+	"crypto/x509"
+	"errors"
+	"strings"
+
+	"engtools/backend/internal/pki"
+	"engtools/backend/internal/repository/model"
+	"engtools/backend/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// KeySpec either supplies an existing private key or describes one to generate.
+type KeySpec struct {
+	KeyType string `json:"key_type"` // rsa/ec/ed25519
+	Bits    int    `json:"bits"`
+	Curve   string `json:"curve"`
+	KeyPEM  string `json:"key_pem"`
+}
+
+func (k KeySpec) signer() (key crypto.Signer, generated bool, err error) {
+	if strings.TrimSpace(k.KeyPEM) != "" {
+		key, err = pki.ParsePrivateKeyPEM(k.KeyPEM)
+		return key, false, err
+	}
+	key, err = pki.GenerateKey(k.KeyType, k.Bits, k.Curve)
+	return key, true, err
+}
+
+type SelfSignedReq struct {
+	pki.CertSpec
+	KeySpec
+}
+
+func SelfSigned() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req SelfSignedReq
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": "invalid input"})
+			return
+		}
+		key, generated, err := req.signer()
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		tpl, err := req.Template()
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		crt, err := pki.Issue(tpl, key.Public(), nil, key)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(200, issuedResponse(crt, nil, key, generated))
+	}
+}
+
+func issuedResponse(crt *x509.Certificate, chain []*x509.Certificate, key crypto.Signer, includeKey bool) gin.H {
+	out := gin.H{
+		"cert":       pki.EncodeCertPEM(crt),
+		"subject":    crt.Subject.String(),
+		"issuer":     crt.Issuer.String(),
+		"serial":     crt.SerialNumber.Text(16),
+		"not_before": crt.NotBefore,
+		"not_after":  crt.NotAfter,
+	}
+	if len(chain) > 0 {
+		pems := make([]string, 0, len(chain))
+		for _, ch := range chain {
+			pems = append(pems, pki.EncodeCertPEM(ch))
+		}
+		out["chain"] = pems
+	}
+	if includeKey && key != nil {
+		if keyPEM, err := pki.MarshalPrivateKeyPEM(key); err == nil {
+			out["private_key"] = keyPEM
+		}
+	}
+	return out
+}
+
+// issuerRef selects a signing CA either by stored name or by inline PEM.
+type issuerRef struct {
+	name    string
+	certPEM string
+	keyPEM  string
+}
+
+func (r issuerRef) load(cas *service.CAService) (*service.CA, []*x509.Certificate, error) {
+	if r.name != "" {
+		ca, err := cas.Get(r.name)
+		if err != nil {
+			return nil, nil, err
+		}
+		chain, err := cas.Chain(ca.Record)
+		if err != nil {
+			return nil, nil, err
+		}
+		return ca, chain, nil
+	}
+	if r.certPEM == "" || r.keyPEM == "" {
+		return nil, nil, nil
+	}
+	certs, err := pki.ParseCertificatesPEM(r.certPEM)
+	if err != nil {
+		return nil, nil, err
+	}
+	key, err := pki.ParsePrivateKeyPEM(r.keyPEM)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !pki.PublicKeysEqual(certs[0].PublicKey, key.Public()) {
+		return nil, nil, errors.New("issuer key does not match issuer certificate")
+	}
+	return &service.CA{Cert: certs[0], Key: key}, certs, nil
+}
+
+type CACreateReq struct {
+	pki.CertSpec
+	KeySpec
+	Name          string `json:"name"`
+	Description   string `json:"description"`
+	Store         bool   `json:"store"`
+	Parent        string `json:"parent"`
+	ParentCertPEM string `json:"parent_cert_pem"`
+	ParentKeyPEM  string `json:"parent_key_pem"`
+}
+
+// CACreate issues a root CA, or an intermediate when a parent is given, and optionally
+// stores it. Stored CA keys are not echoed back.
+func CACreate(cas *service.CAService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req CACreateReq
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": "invalid input"})
+			return
+		}
+		if req.Store && strings.TrimSpace(req.Name) == "" {
+			c.JSON(400, gin.H{"error": "name required to store the CA"})
+			return
+		}
+		parent, chain, err := issuerRef{req.Parent, req.ParentCertPEM, req.ParentKeyPEM}.load(cas)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		key, generated, err := req.signer()
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		req.IsCA = true
+		tpl, err := req.Template()
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		var crt *x509.Certificate
+		if parent == nil {
+			crt, err = pki.Issue(tpl, key.Public(), nil, key)
+		} else {
+			crt, err = pki.Issue(tpl, key.Public(), parent.Cert, parent.Key)
+		}
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		out := issuedResponse(crt, chain, key, generated && !req.Store)
+		if req.Store {
+			rec, err := cas.Create(req.Name, req.Description, crt, key, recordOf(parent))
+			if errors.Is(err, service.ErrCAExists) {
+				c.JSON(409, gin.H{"error": err.Error()})
+				return
+			}
+			if err != nil {
+				c.JSON(500, gin.H{"error": err.Error()})
+				return
+			}
+			out["stored"] = rec.Name
+		}
+		c.JSON(200, out)
+	}
+}
+
+func recordOf(ca *service.CA) *model.CertAuthority {
+	if ca == nil {
+		return nil
+	}
+	return ca.Record
+}
+
+type CAImportReq struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	CertPEM     string `json:"cert_pem"`
+	KeyPEM      string `json:"key_pem"`
+	Parent      string `json:"parent"`
+}
+
+// CAImport stores an existing CA certificate and key.
+func CAImport(cas *service.CAService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req CAImportReq
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": "invalid input"})
+			return
+		}
+		ca, _, err := issuerRef{certPEM: req.CertPEM, keyPEM: req.KeyPEM}.load(cas)
+		if err == nil && ca == nil {
+			err = errors.New("cert_pem and key_pem required")
+		}
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		var parent *service.CA
+		if req.Parent != "" {
+			if parent, err = cas.Get(req.Parent); err != nil {
+				c.JSON(400, gin.H{"error": err.Error()})
+				return
+			}
+			if err := ca.Cert.CheckSignatureFrom(parent.Cert); err != nil {
+				c.JSON(400, gin.H{"error": "certificate was not issued by " + req.Parent})
+				return
+			}
+		}
+		rec, err := cas.Create(req.Name, req.Description, ca.Cert, ca.Key, recordOf(parent))
+		if errors.Is(err, service.ErrCAExists) {
+			c.JSON(409, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"stored": rec.Name, "subject": rec.Subject})
+	}
+}
+
+func CAList(cas *service.CAService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		list, err := cas.List()
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		out := make([]gin.H, 0, len(list))
+		for _, ca := range list {
+			out = append(out, gin.H{"name": ca.Name, "description": ca.Description, "subject": ca.Subject, "serial": ca.Serial, "not_after": ca.NotAfter, "intermediate": ca.ParentID != nil, "created_at": ca.CreatedAt})
+		}
+		c.JSON(200, gin.H{"cas": out})
+	}
+}
+
+func CAGet(cas *service.CAService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ca, err := cas.Get(c.Param("name"))
+		if errors.Is(err, service.ErrCANotFound) {
+			c.JSON(404, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		chain, err := cas.Chain(ca.Record)
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		out := issuedResponse(ca.Cert, chain, nil, false)
+		out["name"], out["description"] = ca.Record.Name, ca.Record.Description
+		c.JSON(200, out)
+	}
+}
+
+func CADelete(cas *service.CAService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		err := cas.Delete(c.Param("name"))
+		if errors.Is(err, service.ErrCANotFound) {
+			c.JSON(404, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"deleted": c.Param("name")})
+	}
+}
+
+type CASignReq struct {
+	pki.CertSpec
+	CSRPEM    string `json:"csr_pem"`
+	CAName    string `json:"ca_name"`
+	CACertPEM string `json:"ca_cert_pem"`
+	CAKeyPEM  string `json:"ca_key_pem"`
+}
+
+// CASign issues a certificate for a CSR. Subject and SANs come from the CSR unless the
+// request overrides them; everything else follows the spec.
+func CASign(cas *service.CAService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req CASignReq
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": "invalid input"})
+			return
+		}
+		csr, err := parseCSRPEM(req.CSRPEM)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		if err := csr.CheckSignature(); err != nil {
+			c.JSON(400, gin.H{"error": "csr signature invalid: " + err.Error()})
+			return
+		}
+		ca, chain, err := issuerRef{req.CAName, req.CACertPEM, req.CAKeyPEM}.load(cas)
+		if err == nil && ca == nil {
+			err = errors.New("ca_name or ca_cert_pem and ca_key_pem required")
+		}
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		tpl, err := req.Template()
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		if req.NameSpec == (pki.NameSpec{}) {
+			tpl.Subject = csr.Subject
+		}
+		if len(req.DNS)+len(req.IP)+len(req.Email)+len(req.URI) == 0 {
+			tpl.DNSNames, tpl.IPAddresses, tpl.EmailAddresses, tpl.URIs = csr.DNSNames, csr.IPAddresses, csr.EmailAddresses, csr.URIs
+		}
+		crt, err := pki.Issue(tpl, csr.PublicKey, ca.Cert, ca.Key)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(200, issuedResponse(crt, chain, nil, false))
+	}
+}
@@ -1,51 +1,87 @@
 package controller
 
 import (
-	"crypto/ecdsa"
-	"crypto/elliptic"
-	"crypto/rand"
-	"crypto/rsa"
 	"crypto/sha256"
 	"crypto/tls"
 	"crypto/x509"
-	pkixpkg "crypto/x509/pkix"
+	"encoding/asn1"
 	"encoding/base64"
 	"encoding/pem"
-	"net"
-	"strconv"
+	"errors"
 	"strings"
 	"time"
 
+	"engtools/backend/internal/asn1dump"
+	"engtools/backend/internal/pki"
+	"engtools/backend/internal/tlsinspect"
 	"github.com/gin-gonic/gin"
 )
 
 type CertParseReq struct {
-	PEM string `json:"pem"`
-	DER string `json:"der_base64"`
+	PEM  string `json:"pem"`
+	DER  string `json:"der_base64"`
+	ASN1 bool   `json:"asn1"` // include the raw ASN.1 tree of each certificate
+}
+
+// PEMBlock reports a PEM block of the input that did not yield certificates.
+type PEMBlock struct {
+	Index int    `json:"index"`
+	Type  string `json:"type"`
+	Error string `json:"error,omitempty"`
+	Hint  string `json:"hint,omitempty"`
 }
 
-type CertInfo struct {
-	Subject           string    `json:"subject"`
-	Issuer            string    `json:"issuer"`
-	Serial            string    `json:"serial"`
-	NotBefore         time.Time `json:"not_before"`
-	NotAfter          time.Time `json:"not_after"`
-	DNS               []string  `json:"dns"`
-	IP                []string  `json:"ip"`
-	IsCA              bool      `json:"is_ca"`
-	SigAlg            string    `json:"sig_alg"`
-	KeyAlg            string    `json:"key_alg"`
-	KeyBits           int       `json:"key_bits"`
-	FingerprintSHA256 string    `json:"fingerprint_sha256"`
-	SPKI_SHA256       string    `json:"spki_sha256"`
-	KeyUsage          []string  `json:"key_usage"`
-	ExtKeyUsage       []string  `json:"ext_key_usage"`
-	OCSP              []string  `json:"ocsp"`
-	IssuerURLs        []string  `json:"issuer_urls"`
-	CRL               []string  `json:"crl"`
+var pemBlockHints = map[string]string{
+	"CERTIFICATE REQUEST":     "use /cert/csr/parse",
+	"NEW CERTIFICATE REQUEST": "use /cert/csr/parse",
+	"X509 CRL":                "certificate revocation list",
+	"PRIVATE KEY":             "private keys are not displayed",
+	"RSA PRIVATE KEY":         "private keys are not displayed",
+	"EC PRIVATE KEY":          "private keys are not displayed",
+	"ENCRYPTED PRIVATE KEY":   "private keys are not displayed",
+	"PUBLIC KEY":              "public key, not a certificate",
+}
+
+// parseCertBlocks collects certificates from every PEM block, including PKCS#7 bundles,
+// and describes the blocks it could not use.
+func parseCertBlocks(data []byte) ([]*x509.Certificate, []PEMBlock) {
+	var certs []*x509.Certificate
+	var skipped []PEMBlock
+	for i := 0; ; i++ {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		switch block.Type {
+		case "CERTIFICATE", "X509 CERTIFICATE", "TRUSTED CERTIFICATE":
+			// OpenSSL's TRUSTED CERTIFICATE appends trust settings after the certificate
+			var raw asn1.RawValue
+			if _, err := asn1.Unmarshal(block.Bytes, &raw); err != nil {
+				skipped = append(skipped, PEMBlock{Index: i, Type: block.Type, Error: err.Error()})
+				continue
+			}
+			crt, err := x509.ParseCertificate(raw.FullBytes)
+			if err != nil {
+				skipped = append(skipped, PEMBlock{Index: i, Type: block.Type, Error: err.Error()})
+				continue
+			}
+			certs = append(certs, crt)
+		case "PKCS7", "CMS":
+			p7, _, err := pki.DecodePKCS7(block.Bytes)
+			if err != nil {
+				skipped = append(skipped, PEMBlock{Index: i, Type: block.Type, Error: err.Error()})
+				continue
+			}
+			certs = append(certs, p7...)
+		default:
+			skipped = append(skipped, PEMBlock{Index: i, Type: block.Type, Hint: pemBlockHints[block.Type]})
+		}
+	}
+	return certs, skipped
 }
 
-func CertParse() gin.HandlerFunc {
+func CertParse(logs *pki.CTLogList) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req CertParseReq
 		if err := c.ShouldBindJSON(&req); err != nil {
@@ -53,280 +89,259 @@ func CertParse() gin.HandlerFunc {
 			return
 		}
 		var certs []*x509.Certificate
-		if req.PEM != "" {
-			data := []byte(req.PEM)
-			for {
-				var block *pem.Block
-				block, data = pem.Decode(data)
-				if block == nil {
-					break
-				}
-				if block.Type == "CERTIFICATE" {
-					crt, err := x509.ParseCertificate(block.Bytes)
-					if err == nil {
-						certs = append(certs, crt)
-					}
-				}
-			}
+		var skipped []PEMBlock
+		if strings.TrimSpace(req.PEM) != "" {
+			certs, skipped = parseCertBlocks([]byte(req.PEM))
 		} else if req.DER != "" {
-			b, err := base64.StdEncoding.DecodeString(req.DER)
+			b, err := base64.StdEncoding.DecodeString(strings.TrimSpace(req.DER))
 			if err != nil {
 				c.JSON(400, gin.H{"error": "invalid der"})
 				return
 			}
 			crt, err := x509.ParseCertificate(b)
-			if err != nil {
-				c.JSON(400, gin.H{"error": "parse failed"})
+			if err == nil {
+				certs = append(certs, crt)
+			} else if p7, _, p7err := pki.DecodePKCS7(b); p7err == nil {
+				certs = p7
+			} else {
+				c.JSON(400, gin.H{"error": "parse failed: " + err.Error()})
 				return
 			}
-			certs = append(certs, crt)
 		} else {
 			c.JSON(400, gin.H{"error": "no input"})
 			return
 		}
 		if len(certs) == 0 {
-			c.JSON(400, gin.H{"error": "no certificates"})
+			c.JSON(400, gin.H{"error": "no certificates", "skipped": skipped})
 			return
 		}
-		out := make([]CertInfo, 0, len(certs))
+		now := time.Now()
+		out := make([]pki.CertInfo, 0, len(certs))
 		for _, crt := range certs {
-			fp := sha256.Sum256(crt.Raw)
-			spki := sha256.Sum256(crt.RawSubjectPublicKeyInfo)
-			info := CertInfo{
-				Subject: crt.Subject.String(), Issuer: crt.Issuer.String(), Serial: crt.SerialNumber.String(),
-				NotBefore: crt.NotBefore, NotAfter: crt.NotAfter, IsCA: crt.IsCA, SigAlg: crt.SignatureAlgorithm.String(),
-				FingerprintSHA256: base64.StdEncoding.EncodeToString(fp[:]), SPKI_SHA256: base64.StdEncoding.EncodeToString(spki[:]),
-			}
-			for _, d := range crt.DNSNames {
-				info.DNS = append(info.DNS, d)
-			}
-			for _, ip := range crt.IPAddresses {
-				info.IP = append(info.IP, ip.String())
-			}
-			switch crt.PublicKeyAlgorithm {
-			case x509.RSA:
-				info.KeyAlg = "RSA"
-				if pk, ok := crt.PublicKey.(*rsa.PublicKey); ok {
-					info.KeyBits = pk.Size() * 8
-				}
-			case x509.ECDSA:
-				info.KeyAlg = "EC"
-			case x509.Ed25519:
-				info.KeyAlg = "Ed25519"
-			default:
-				info.KeyAlg = crt.PublicKeyAlgorithm.String()
-			}
-			// KeyUsage mapping
-			ku := crt.KeyUsage
-			var kus []string
-			if ku&x509.KeyUsageDigitalSignature != 0 {
-				kus = append(kus, "DigitalSignature")
-			}
-			if ku&x509.KeyUsageContentCommitment != 0 {
-				kus = append(kus, "ContentCommitment")
-			}
-			if ku&x509.KeyUsageKeyEncipherment != 0 {
-				kus = append(kus, "KeyEncipherment")
-			}
-			if ku&x509.KeyUsageDataEncipherment != 0 {
-				kus = append(kus, "DataEncipherment")
-			}
-			if ku&x509.KeyUsageKeyAgreement != 0 {
-				kus = append(kus, "KeyAgreement")
-			}
-			if ku&x509.KeyUsageCertSign != 0 {
-				kus = append(kus, "CertSign")
-			}
-			if ku&x509.KeyUsageCRLSign != 0 {
-				kus = append(kus, "CRLSign")
-			}
-			if ku&x509.KeyUsageEncipherOnly != 0 {
-				kus = append(kus, "EncipherOnly")
-			}
-			if ku&x509.KeyUsageDecipherOnly != 0 {
-				kus = append(kus, "DecipherOnly")
-			}
-			info.KeyUsage = kus
-			// ExtKeyUsage mapping
-			var ekus []string
-			for _, e := range crt.ExtKeyUsage {
-				switch e {
-				case x509.ExtKeyUsageAny:
-					ekus = append(ekus, "Any")
-				case x509.ExtKeyUsageServerAuth:
-					ekus = append(ekus, "ServerAuth")
-				case x509.ExtKeyUsageClientAuth:
-					ekus = append(ekus, "ClientAuth")
-				case x509.ExtKeyUsageCodeSigning:
-					ekus = append(ekus, "CodeSigning")
-				case x509.ExtKeyUsageEmailProtection:
-					ekus = append(ekus, "EmailProtection")
-				case x509.ExtKeyUsageIPSECTunnel:
-					ekus = append(ekus, "IPSECTunnel")
-				case x509.ExtKeyUsageIPSECUser:
-					ekus = append(ekus, "IPSECUser")
-				case x509.ExtKeyUsageTimeStamping:
-					ekus = append(ekus, "TimeStamping")
-				case x509.ExtKeyUsageOCSPSigning:
-					ekus = append(ekus, "OCSPSigning")
-				default:
-					ekus = append(ekus, "Unknown")
+			info := pki.NewCertInfo(crt, now)
+			// embedded SCTs can only be verified when the issuer is pasted along
+			logs.Annotate(info.SCTs, crt, issuerIn(crt, certs))
+			if req.ASN1 {
+				tree, err := asn1dump.Parse(crt.Raw)
+				if err != nil {
+					info.Warnings = append(info.Warnings, "asn1: "+err.Error())
 				}
+				info.ASN1 = tree
 			}
-			info.ExtKeyUsage = ekus
-			info.OCSP = crt.OCSPServer
-			info.IssuerURLs = crt.IssuingCertificateURL
-			info.CRL = crt.CRLDistributionPoints
 			out = append(out, info)
 		}
-		c.JSON(200, gin.H{"certs": out})
+		resp := gin.H{"certs": out}
+		if len(skipped) > 0 {
+			resp["skipped"] = skipped
+		}
+		c.JSON(200, resp)
+	}
+}
+
+// issuerIn returns the certificate of certs that signed crt, or nil.
+func issuerIn(crt *x509.Certificate, certs []*x509.Certificate) *x509.Certificate {
+	for _, c := range certs {
+		if c != crt && crt.CheckSignatureFrom(c) == nil {
+			return c
+		}
+	}
+	return nil
+}
+
+type CertDiffReq struct {
+	OldPEM string `json:"old_pem"` // certificate or chain, leaf first
+	NewPEM string `json:"new_pem"`
+}
+
+// CertDiff compares two certificates, typically before and after a renewal. When chains
+// are given the leaves are compared field by field and the rest by fingerprint.
+func CertDiff() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req CertDiffReq
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": "invalid input"})
+			return
+		}
+		oldCerts, _ := parseCertBlocks([]byte(req.OldPEM))
+		newCerts, _ := parseCertBlocks([]byte(req.NewPEM))
+		if len(oldCerts) == 0 || len(newCerts) == 0 {
+			c.JSON(400, gin.H{"error": "old_pem and new_pem must each contain a certificate"})
+			return
+		}
+		summary := func(crt *x509.Certificate) gin.H {
+			fp := sha256.Sum256(crt.Raw)
+			return gin.H{"subject": crt.Subject.String(), "issuer": crt.Issuer.String(), "serial": crt.SerialNumber.Text(16), "fingerprint_sha256": pki.HexColon(fp[:])}
+		}
+		out := gin.H{"old": summary(oldCerts[0]), "new": summary(newCerts[0]), "diff": pki.DiffCertificates(oldCerts[0], newCerts[0])}
+		if len(oldCerts) > 1 || len(newCerts) > 1 {
+			out["chain"] = pki.DiffChains(oldCerts[1:], newCerts[1:])
+		}
+		c.JSON(200, out)
 	}
 }
 
 type CertVerifyReq struct {
+	// PEM is the whole chain as one bundle, leaf first; ChainPEM is the older array form.
+	PEM        string   `json:"pem"`
 	ChainPEM   []string `json:"chain_pem"`
 	RootsPEM   []string `json:"roots_pem"`
 	ServerName string   `json:"server_name"`
+	// At verifies as of a point in time instead of now.
+	At        *time.Time `json:"at"`
+	KeyUsages []string   `json:"key_usages"` // extended key usages required of the chain; default serverAuth
+	FetchAIA  bool       `json:"fetch_aia"`  // download missing intermediates from caIssuers URLs
+	// CheckRevocation queries OCSP and CRLs for each non-root element of the chain.
+	CheckRevocation bool   `json:"check_revocation"`
+	OCSPURL         string `json:"ocsp_url"`
 }
 
-func CertVerify() gin.HandlerFunc {
+// CertVerify builds chains for the leaf and explains failures; fetcher downloads missing
+// intermediates when the request asks for it.
+func CertVerify(fetcher pki.IssuerFetcher) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req CertVerifyReq
 		if err := c.ShouldBindJSON(&req); err != nil {
 			c.JSON(400, gin.H{"error": "invalid input"})
 			return
 		}
-		if len(req.ChainPEM) == 0 {
+		if strings.TrimSpace(req.PEM) == "" && len(req.ChainPEM) == 0 {
 			c.JSON(400, gin.H{"error": "empty chain"})
 			return
 		}
-		parseOne := func(p string) (*x509.Certificate, error) {
-			data := []byte(p)
-			for {
-				var block *pem.Block
-				block, data = pem.Decode(data)
-				if block == nil {
-					break
-				}
-				if block.Type == "CERTIFICATE" {
-					return x509.ParseCertificate(block.Bytes)
-				}
-			}
-			return nil, x509.IncorrectPasswordError
-		}
-		leaf, err := parseOne(req.ChainPEM[0])
+		certs, err := pki.ParseCertificatesPEM(req.PEM + "\n" + strings.Join(req.ChainPEM, "\n"))
 		if err != nil {
-			c.JSON(400, gin.H{"error": "leaf parse failed"})
+			c.JSON(400, gin.H{"error": "chain: " + err.Error()})
 			return
 		}
-		inter := x509.NewCertPool()
-		for _, p := range req.ChainPEM[1:] {
-			inter.AppendCertsFromPEM([]byte(p))
-		}
-		var roots *x509.CertPool
+		opts := pki.VerifyOptions{Intermediates: certs[1:], DNSName: req.ServerName}
 		if len(req.RootsPEM) > 0 {
-			roots = x509.NewCertPool()
-			for _, p := range req.RootsPEM {
-				roots.AppendCertsFromPEM([]byte(p))
+			if opts.Roots, err = pki.ParseCertificatesPEM(strings.Join(req.RootsPEM, "\n")); err != nil {
+				c.JSON(400, gin.H{"error": "roots: " + err.Error()})
+				return
 			}
-		} else {
-			rp, _ := x509.SystemCertPool()
-			roots = rp
 		}
-		opts := x509.VerifyOptions{Intermediates: inter, Roots: roots}
-		if req.ServerName != "" {
-			opts.DNSName = req.ServerName
+		if req.At != nil {
+			opts.CurrentTime = *req.At
 		}
-		chains, err := leaf.Verify(opts)
-		if err != nil {
-			c.JSON(200, gin.H{"ok": false, "error": err.Error()})
-			return
+		if len(req.KeyUsages) > 0 {
+			ekus, unknown, err := pki.ParseExtKeyUsage(req.KeyUsages)
+			if err != nil {
+				c.JSON(400, gin.H{"error": err.Error()})
+				return
+			}
+			if len(unknown) > 0 {
+				c.JSON(400, gin.H{"error": "only named extended key usages can be required"})
+				return
+			}
+			opts.KeyUsages = ekus
+		}
+		if req.FetchAIA {
+			opts.Fetcher = fetcher
+		}
+		res := pki.Verify(c.Request.Context(), certs[0], opts)
+		out := gin.H{
+			"ok": res.OK, "chains": len(res.Chains), "verified_chains": res.Chains, "verified_at": res.VerifiedAt,
+			"error": res.Error, "reason": res.Reason, "failed_cert": res.FailedCert,
+			"fetched": res.Fetched, "diagnostics": res.Diagnostics,
+		}
+		if req.CheckRevocation && res.OK {
+			rc := &pki.RevocationChecker{Client: revocationClient, OCSPURL: req.OCSPURL}
+			chain := res.Raw[0]
+			var statuses []gin.H
+			revoked := false
+			for i := 0; i+1 < len(chain); i++ {
+				o := rc.CheckOCSP(c.Request.Context(), chain[i], chain[i+1])
+				l := rc.CheckCRL(c.Request.Context(), chain[i], chain[i+1])
+				revoked = revoked || o.Status == "revoked" || l.Status == "revoked"
+				statuses = append(statuses, gin.H{"subject": chain[i].Subject.String(), "serial": chain[i].SerialNumber.Text(16), "ocsp": o, "crl": l})
+			}
+			out["revocation"] = statuses
+			out["revoked"] = revoked
+			if revoked {
+				out["ok"] = false
+				out["reason"] = "revoked"
+			}
 		}
-		c.JSON(200, gin.H{"ok": true, "chains": len(chains)})
+		c.JSON(200, out)
 	}
 }
 
 type TLSInspectReq struct {
-	Host string `json:"host"`
-	Port int    `json:"port"`
+	Host          string `json:"host"`
+	Port          int    `json:"port"`
+	Address       string `json:"address"`     // connect here instead of resolving host
+	ServerName    string `json:"server_name"` // SNI and verification name, default host
+	NoSNI         bool   `json:"no_sni"`
+	StartTLS      string `json:"starttls"` // smtp, imap, pop3, ftp, ldap, xmpp, postgres or mysql
+	ClientCertPEM string `json:"client_cert_pem"`
+	ClientKeyPEM  string `json:"client_key_pem"`
+}
+
+func (r TLSInspectReq) options() (tlsinspect.Options, error) {
+	opts := tlsinspect.Options{
+		Host: r.Host, Port: r.Port, Address: r.Address, ServerName: r.ServerName, NoSNI: r.NoSNI, StartTLS: r.StartTLS,
+	}
+	if r.ClientCertPEM != "" || r.ClientKeyPEM != "" {
+		pair, err := tls.X509KeyPair([]byte(r.ClientCertPEM), []byte(r.ClientKeyPEM))
+		if err != nil {
+			return opts, errors.New("client certificate: " + err.Error())
+		}
+		opts.ClientCert = &pair
+	}
+	return opts, nil
 }
 
-func TLSInspect() gin.HandlerFunc {
+// TLSInspect reports the negotiated handshake and the presented chain of host:port.
+func TLSInspect(logs *pki.CTLogList) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req TLSInspectReq
 		if err := c.ShouldBindJSON(&req); err != nil {
 			c.JSON(400, gin.H{"error": "invalid input"})
 			return
 		}
-		if req.Host == "" {
-			c.JSON(400, gin.H{"error": "host required"})
+		opts, err := req.options()
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
 			return
 		}
-		if req.Port == 0 {
-			req.Port = 443
-		}
-		d := &net.Dialer{Timeout: 5 * time.Second}
-		var conn *tls.Conn
-		var err error
-		// Resolve IPs and try IPv4 first, then IPv6
-		ips, rErr := net.LookupIP(req.Host)
-		if rErr != nil {
-			c.JSON(400, gin.H{"error": rErr.Error()})
+		opts.CTLogs = logs
+		res, err := tlsinspect.Inspect(c.Request.Context(), opts)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
 			return
 		}
-		var errs []string
-		for _, ip := range ips {
-			network := "tcp"
-			if ip.To4() != nil {
-				network = "tcp4"
-			} else {
-				network = "tcp6"
-			}
-			addr := net.JoinHostPort(ip.String(), strconv.Itoa(req.Port))
-			conn, err = tls.DialWithDialer(d, network, addr, &tls.Config{ServerName: req.Host})
-			if err == nil {
-				break
-			}
-			errs = append(errs, ip.String()+": "+err.Error())
-		}
-		if conn == nil {
-			c.JSON(400, gin.H{"error": "dial failed: " + strings.Join(errs, "; ")})
+		c.JSON(200, res)
+	}
+}
+
+// TLSScan grades the server's TLS configuration. It opens a few dozen connections, one
+// per probed version and accepted suite.
+func TLSScan(logs *pki.CTLogList) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req TLSInspectReq
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": "invalid input"})
 			return
 		}
-		state := conn.ConnectionState()
-		conn.Close()
-		var infos []CertInfo
-		for _, crt := range state.PeerCertificates {
-			fp := sha256.Sum256(crt.Raw)
-			spki := sha256.Sum256(crt.RawSubjectPublicKeyInfo)
-			info := CertInfo{Subject: crt.Subject.String(), Issuer: crt.Issuer.String(), Serial: crt.SerialNumber.String(), NotBefore: crt.NotBefore, NotAfter: crt.NotAfter, IsCA: crt.IsCA, SigAlg: crt.SignatureAlgorithm.String(), FingerprintSHA256: base64.StdEncoding.EncodeToString(fp[:]), SPKI_SHA256: base64.StdEncoding.EncodeToString(spki[:])}
-			for _, d := range crt.DNSNames {
-				info.DNS = append(info.DNS, d)
-			}
-			for _, ip := range crt.IPAddresses {
-				info.IP = append(info.IP, ip.String())
-			}
-			infos = append(infos, info)
+		opts, err := req.options()
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
 		}
-		if len(infos) == 0 {
-			c.JSON(400, gin.H{"error": "no peer certificates"})
+		opts.CTLogs = logs
+		res, err := tlsinspect.Scan(c.Request.Context(), opts)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
 			return
 		}
-		c.JSON(200, gin.H{"tls_version": state.Version, "cipher_suite": state.CipherSuite, "certs": infos})
+		c.JSON(200, res)
 	}
 }
 
+// CSRReq generates a fresh key unless key_pem is given, so renewals can keep their key.
 type CSRReq struct {
-	CN      string   `json:"cn"`
-	O       string   `json:"o"`
-	OU      string   `json:"ou"`
-	L       string   `json:"l"`
-	ST      string   `json:"st"`
-	C       string   `json:"c"`
-	SAN_DNS []string `json:"san_dns"`
-	KeyType string   `json:"key_type"` // rsa/ec
-	Bits    int      `json:"bits"`
-	Curve   string   `json:"curve"`
+	pki.CSRSpec
+	KeySpec
 }
 
 func CSRGenerate() gin.HandlerFunc {
@@ -336,56 +351,27 @@ func CSRGenerate() gin.HandlerFunc {
 			c.JSON(400, gin.H{"error": "invalid input"})
 			return
 		}
-		var priv interface{}
-		var err error
-		if req.KeyType == "ec" {
-			curve := elliptic.P256()
-			if req.Curve == "P384" {
-				curve = elliptic.P384()
-			}
-			if req.Curve == "P521" {
-				curve = elliptic.P521()
-			}
-			priv, err = ecdsa.GenerateKey(curve, rand.Reader)
-		} else {
-			bits := req.Bits
-			if bits == 0 {
-				bits = 2048
-			}
-			priv, err = rsa.GenerateKey(rand.Reader, bits)
-		}
+		key, generated, err := req.signer()
 		if err != nil {
 			c.JSON(400, gin.H{"error": err.Error()})
 			return
 		}
-		template := x509.CertificateRequest{
-			Subject:  pkixName(req),
-			DNSNames: req.SAN_DNS,
-		}
-		csrDER, err := x509.CreateCertificateRequest(rand.Reader, &template, priv)
+		csrPEM, err := pki.CreateCSR(req.CSRSpec, key)
 		if err != nil {
 			c.JSON(400, gin.H{"error": err.Error()})
 			return
 		}
-		csrPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csrDER})
-		pk, err := x509.MarshalPKCS8PrivateKey(priv)
-		if err != nil {
-			c.JSON(400, gin.H{"error": err.Error()})
-			return
+		out := gin.H{"csr": csrPEM}
+		if generated {
+			keyPEM, err := pki.MarshalPrivateKeyPEM(key)
+			if err != nil {
+				c.JSON(400, gin.H{"error": err.Error()})
+				return
+			}
+			out["private_key"] = keyPEM
 		}
-		keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pk})
-		c.JSON(200, gin.H{"csr": string(csrPEM), "private_key": string(keyPEM)})
-	}
-}
-
-func pkixName(req CSRReq) pkixpkg.Name {
-	return pkixpkg.Name{CommonName: req.CN, Organization: nonEmpty(req.O), OrganizationalUnit: nonEmpty(req.OU), Locality: nonEmpty(req.L), Province: nonEmpty(req.ST), Country: nonEmpty(req.C)}
-}
-func nonEmpty(s string) []string {
-	if s == "" {
-		return nil
+		c.JSON(200, out)
 	}
-	return []string{s}
 }
 
 type ConvertReq struct {
@@ -0,0 +1,65 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/pem"
+	"net/http/httptest"
+	"testing"
+
+	"engtools/backend/internal/pki"
+	"engtools/backend/internal/pki/pkitest"
+	"github.com/gin-gonic/gin"
+)
+
+func certParse(t *testing.T, input string) (int, []pki.CertInfo, []PEMBlock) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/parse", CertParse(pki.NewCTLogList()))
+	body, _ := json.Marshal(CertParseReq{PEM: input})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("POST", "/parse", bytes.NewReader(body)))
+	var out struct {
+		Certs   []pki.CertInfo `json:"certs"`
+		Skipped []PEMBlock     `json:"skipped"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+	return w.Code, out.Certs, out.Skipped
+}
+
+func TestCertParseSkippedBlocks(t *testing.T) {
+	leaf := pkitest.Leaf(t, "a.test", nil)
+	keyPEM, err := pki.MarshalPrivateKeyPEM(leaf.Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leaf.Cert.Raw}))
+	garbage := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte{0x30, 0x03, 0x02, 0x01, 0x01}}))
+	custom := string(pem.EncodeToMemory(&pem.Block{Type: "SOMETHING ELSE", Bytes: []byte{1}}))
+
+	code, certs, skipped := certParse(t, keyPEM+certPEM+garbage+custom)
+	if code != 200 || len(certs) != 1 || certs[0].Subject != "CN=a.test" {
+		t.Fatalf("status %d, certs %+v", code, certs)
+	}
+	want := []PEMBlock{
+		{Index: 0, Type: "PRIVATE KEY", Hint: "private keys are not displayed"},
+		{Index: 2, Type: "CERTIFICATE"},
+		{Index: 3, Type: "SOMETHING ELSE"},
+	}
+	if len(skipped) != len(want) {
+		t.Fatalf("skipped %+v", skipped)
+	}
+	for i, b := range skipped {
+		if b.Index != want[i].Index || b.Type != want[i].Type || b.Hint != want[i].Hint || (b.Type == "CERTIFICATE") != (b.Error != "") {
+			t.Errorf("block %d: got %+v, want %+v", i, b, want[i])
+		}
+	}
+
+	code, certs, skipped = certParse(t, keyPEM)
+	if code != 400 || len(certs) != 0 || len(skipped) != 1 {
+		t.Errorf("key only: status %d, certs %d, skipped %+v", code, len(certs), skipped)
+	}
+}
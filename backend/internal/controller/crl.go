@@ -0,0 +1,266 @@
+package controller
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"math/big"
+	"strings"
+	"time"
+
+	"engtools/backend/internal/pki"
+	"engtools/backend/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// crlInput is a CRL given as PEM or base64 DER.
+type crlInput struct {
+	PEM string `json:"crl_pem"`
+	DER string `json:"crl_der_base64"`
+}
+
+func (in crlInput) parse() (*x509.RevocationList, error) {
+	switch {
+	case strings.TrimSpace(in.PEM) != "":
+		return pki.ParseCRL([]byte(in.PEM))
+	case in.DER != "":
+		der, err := base64.StdEncoding.DecodeString(strings.TrimSpace(in.DER))
+		if err != nil {
+			return nil, errors.New("invalid base64")
+		}
+		return pki.ParseCRL(der)
+	}
+	return nil, errors.New("no crl")
+}
+
+// checkCRLSignature adds signature_valid (and signature_error) to out when an issuer
+// certificate was supplied.
+func checkCRLSignature(out gin.H, crl *x509.RevocationList, issuerPEM string) error {
+	if strings.TrimSpace(issuerPEM) == "" {
+		return nil
+	}
+	issuers, err := pki.ParseCertificatesPEM(issuerPEM)
+	if err != nil {
+		return err
+	}
+	err = crl.CheckSignatureFrom(issuers[0])
+	out["signature_valid"] = err == nil
+	if err != nil {
+		out["signature_error"] = err.Error()
+	}
+	return nil
+}
+
+type CRLParseReq struct {
+	crlInput
+	IssuerPEM string `json:"issuer_pem"`
+}
+
+func CRLParse() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req CRLParseReq
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": "invalid input"})
+			return
+		}
+		crl, err := req.parse()
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		out := gin.H{"crl": pki.NewCRLInfo(crl, time.Now())}
+		if err := checkCRLSignature(out, crl, req.IssuerPEM); err != nil {
+			c.JSON(400, gin.H{"error": "issuer: " + err.Error()})
+			return
+		}
+		c.JSON(200, out)
+	}
+}
+
+type CRLCheckReq struct {
+	crlInput
+	CertPEM   string `json:"cert_pem"`
+	IssuerPEM string `json:"issuer_pem"`
+}
+
+// CRLCheck reports whether a certificate is listed on a CRL. issuer_match is false when
+// the CRL belongs to a different CA, in which case the answer says nothing.
+func CRLCheck() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req CRLCheckReq
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": "invalid input"})
+			return
+		}
+		crl, err := req.parse()
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		certs, err := pki.ParseCertificatesPEM(req.CertPEM)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "cert: " + err.Error()})
+			return
+		}
+		crt := certs[0]
+		info := pki.NewCRLInfo(crl, time.Now())
+		entry := pki.FindRevoked(crl, crt.SerialNumber)
+		out := gin.H{
+			"serial":       crt.SerialNumber.Text(16),
+			"revoked":      entry != nil,
+			"issuer_match": bytes.Equal(crt.RawIssuer, crl.RawIssuer),
+			"crl_status":   info.Status,
+			"this_update":  info.ThisUpdate,
+			"next_update":  info.NextUpdate,
+		}
+		if entry != nil {
+			out["entry"] = entry
+		}
+		if err := checkCRLSignature(out, crl, req.IssuerPEM); err != nil {
+			c.JSON(400, gin.H{"error": "issuer: " + err.Error()})
+			return
+		}
+		c.JSON(200, out)
+	}
+}
+
+type CARevokeReq struct {
+	Serial    string     `json:"serial"` // hex; alternatively cert_pem
+	CertPEM   string     `json:"cert_pem"`
+	Reason    string     `json:"reason"` // RFC 5280 name or code
+	RevokedAt *time.Time `json:"revoked_at"`
+}
+
+func CARevoke(cas *service.CAService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req CARevokeReq
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": "invalid input"})
+			return
+		}
+		ca, err := cas.Get(c.Param("name"))
+		if errors.Is(err, service.ErrCANotFound) {
+			c.JSON(404, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		reason, err := pki.ParseReason(req.Reason)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		var serial *big.Int
+		if strings.TrimSpace(req.CertPEM) != "" {
+			certs, err := pki.ParseCertificatesPEM(req.CertPEM)
+			if err != nil {
+				c.JSON(400, gin.H{"error": "cert: " + err.Error()})
+				return
+			}
+			if err := certs[0].CheckSignatureFrom(ca.Cert); err != nil {
+				c.JSON(400, gin.H{"error": "certificate was not issued by this ca"})
+				return
+			}
+			serial = certs[0].SerialNumber
+		} else if serial, err = pki.ParseSerial(req.Serial); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		at := time.Now()
+		if req.RevokedAt != nil {
+			at = *req.RevokedAt
+		}
+		rc, err := cas.Revoke(ca.Record.Name, serial, reason, at)
+		if errors.Is(err, service.ErrRevoked) {
+			c.JSON(409, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"serial": rc.Serial, "reason": pki.ReasonName(rc.ReasonCode), "revoked_at": rc.RevokedAt})
+	}
+}
+
+func CAUnrevoke(cas *service.CAService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		serial, err := pki.ParseSerial(c.Param("serial"))
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		err = cas.Unrevoke(c.Param("name"), serial)
+		if errors.Is(err, service.ErrCANotFound) || errors.Is(err, service.ErrNotRevoked) {
+			c.JSON(404, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"message": "ok"})
+	}
+}
+
+func CARevocations(cas *service.CAService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ca, err := cas.Get(c.Param("name"))
+		if errors.Is(err, service.ErrCANotFound) {
+			c.JSON(404, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		revoked, err := cas.Revocations(ca.Record)
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		out := make([]gin.H, 0, len(revoked))
+		for _, r := range revoked {
+			out = append(out, gin.H{"serial": r.Serial, "reason": pki.ReasonName(r.ReasonCode), "revoked_at": r.RevokedAt})
+		}
+		c.JSON(200, gin.H{"revoked": out})
+	}
+}
+
+type CACRLReq struct {
+	ValidityHours int `json:"validity_hours"` // default one week
+}
+
+// CACRL signs a fresh CRL for a stored CA; each call consumes a new CRL number.
+func CACRL(cas *service.CAService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req CACRLReq
+		if c.Request.ContentLength > 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(400, gin.H{"error": "invalid input"})
+				return
+			}
+		}
+		if req.ValidityHours <= 0 {
+			req.ValidityHours = 7 * 24
+		}
+		der, err := cas.GenerateCRL(c.Param("name"), time.Duration(req.ValidityHours)*time.Hour)
+		if errors.Is(err, service.ErrCANotFound) {
+			c.JSON(404, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		crl, err := x509.ParseRevocationList(der)
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"crl_pem": pki.EncodeCRLPEM(der), "der_base64": base64.StdEncoding.EncodeToString(der), "crl": pki.NewCRLInfo(crl, time.Now())})
+	}
+}
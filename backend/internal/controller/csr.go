@@ -0,0 +1,112 @@
+package controller
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"strings"
+
+	"engtools/backend/internal/pki"
+	"github.com/gin-gonic/gin"
+)
+
+type CSRParseReq struct {
+	PEM    string `json:"pem"`
+	DER    string `json:"der_base64"`
+	KeyPEM string `json:"key_pem"`
+}
+
+type CSRInfo struct {
+	Subject        string                   `json:"subject"`
+	DNS            []string                 `json:"dns"`
+	IP             []string                 `json:"ip"`
+	Email          []string                 `json:"email"`
+	URI            []string                 `json:"uri"`
+	SigAlg         string                   `json:"sig_alg"`
+	SignatureValid bool                     `json:"signature_valid"`
+	SignatureError string                   `json:"signature_error,omitempty"`
+	KeyAlg         string                   `json:"key_alg"`
+	KeyBits        int                      `json:"key_bits"`
+	KeyCurve       string                   `json:"key_curve,omitempty"`
+	SPKI_SHA256    string                   `json:"spki_sha256"`
+	Requested      *pki.RequestedExtensions `json:"requested"`
+	Extensions     []pki.Extension          `json:"extensions"`
+	PEM            string                   `json:"pem"`
+}
+
+func CSRParse() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req CSRParseReq
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": "invalid input"})
+			return
+		}
+		var csr *x509.CertificateRequest
+		var err error
+		if strings.TrimSpace(req.PEM) != "" {
+			csr, err = parseCSRPEM(req.PEM)
+		} else if req.DER != "" {
+			var b []byte
+			if b, err = base64.StdEncoding.DecodeString(req.DER); err == nil {
+				csr, err = x509.ParseCertificateRequest(b)
+			}
+		} else {
+			c.JSON(400, gin.H{"error": "no input"})
+			return
+		}
+		if err != nil {
+			c.JSON(400, gin.H{"error": "parse failed: " + err.Error()})
+			return
+		}
+		out := gin.H{"csr": newCSRInfo(csr)}
+		if strings.TrimSpace(req.KeyPEM) != "" {
+			key, err := pki.ParsePrivateKeyPEM(req.KeyPEM)
+			if err != nil {
+				c.JSON(400, gin.H{"error": err.Error()})
+				return
+			}
+			out["key_match"] = pki.PublicKeysEqual(csr.PublicKey, key.Public())
+		}
+		c.JSON(200, out)
+	}
+}
+
+func newCSRInfo(csr *x509.CertificateRequest) *CSRInfo {
+	spki := sha256.Sum256(csr.RawSubjectPublicKeyInfo)
+	info := &CSRInfo{
+		Subject: csr.Subject.String(), DNS: csr.DNSNames, Email: csr.EmailAddresses,
+		SigAlg: csr.SignatureAlgorithm.String(), SPKI_SHA256: pki.HexColon(spki[:]),
+		Extensions: pki.DescribeExtensions(csr.Extensions),
+		PEM:        string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csr.Raw})),
+	}
+	for _, ip := range csr.IPAddresses {
+		info.IP = append(info.IP, ip.String())
+	}
+	for _, u := range csr.URIs {
+		info.URI = append(info.URI, u.String())
+	}
+	info.KeyAlg, info.KeyBits, info.KeyCurve = pki.KeyDescription(csr.PublicKey)
+	if err := csr.CheckSignature(); err != nil {
+		info.SignatureError = err.Error()
+	} else {
+		info.SignatureValid = true
+	}
+	info.Requested = pki.DecodeRequestedExtensions(csr.Extensions)
+	return info
+}
+
+func parseCSRPEM(s string) (*x509.CertificateRequest, error) {
+	data := []byte(s)
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return nil, errors.New("no certificate request found")
+		}
+		if block.Type == "CERTIFICATE REQUEST" || block.Type == "NEW CERTIFICATE REQUEST" {
+			return x509.ParseCertificateRequest(block.Bytes)
+		}
+	}
+}
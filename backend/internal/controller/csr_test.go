@@ -0,0 +1,51 @@
+package controller
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"engtools/backend/internal/pki"
+	"engtools/backend/internal/pki/pkitest"
+	"github.com/gin-gonic/gin"
+)
+
+func TestCSRParseMalformedExtension(t *testing.T) {
+	key := pkitest.Key(t, "ec")
+	eku := []byte{0x30, 0x0a, 0x06, 0x08, 0x2b, 0x06, 0x01, 0x05, 0x05, 0x07, 0x03, 0x01} // serverAuth
+	der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: "a.test"},
+		DNSNames: []string{"a.test"},
+		ExtraExtensions: []pkix.Extension{
+			{Id: pki.OIDExtKeyUsage, Critical: true, Value: []byte{0x03, 0x05, 0x00}},
+			{Id: pki.OIDExtExtKeyUsage, Value: eku},
+		},
+	}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/csr", CSRParse())
+	body, _ := json.Marshal(CSRParseReq{DER: base64.StdEncoding.EncodeToString(der)})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("POST", "/csr", bytes.NewReader(body)))
+	if w.Code != 200 {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	var out struct {
+		CSR CSRInfo `json:"csr"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+	req := out.CSR.Requested
+	if req == nil || req.Errors["keyUsage"] == "" || len(req.ExtKeyUsage) != 1 || !out.CSR.SignatureValid || len(out.CSR.DNS) != 1 {
+		t.Errorf("csr %+v, requested %+v", out.CSR, req)
+	}
+}
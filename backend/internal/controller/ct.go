@@ -0,0 +1,70 @@
+package controller
+
+import (
+	"errors"
+	"io"
+	"os"
+
+	"engtools/backend/internal/config"
+	"engtools/backend/internal/pki"
+	"engtools/backend/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// maxCTLogList bounds an uploaded log list; the Chrome list is a few hundred KB.
+const maxCTLogList = 8 << 20
+
+// CTLogs lists the logs SCTs are checked against.
+func CTLogs(logs *pki.CTLogList) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(200, logs.Info())
+	}
+}
+
+// CTLogsUpdate replaces the log list with the request body, a list in the Chrome v3
+// format such as https://www.gstatic.com/ct/log_list/v3/log_list.json. The list is
+// saved to CT_LOG_LIST when configured so it survives restarts.
+func CTLogsUpdate(cfg *config.Config, logs *pki.CTLogList) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		data, err := io.ReadAll(io.LimitReader(c.Request.Body, maxCTLogList+1))
+		if err != nil || len(data) > maxCTLogList {
+			c.JSON(400, gin.H{"error": "invalid input"})
+			return
+		}
+		if err := logs.Load(data, "upload"); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		info := logs.Info()
+		out := gin.H{"version": info.Version, "count": info.Count, "loaded_at": info.LoadedAt}
+		if cfg.CTLogListFile != "" {
+			if err := os.WriteFile(cfg.CTLogListFile, data, 0o644); err != nil {
+				out["save_error"] = err.Error()
+			} else {
+				out["saved"] = cfg.CTLogListFile
+			}
+		}
+		c.JSON(200, out)
+	}
+}
+
+// CTSearch lists the certificates logged for a domain using the configured search source.
+func CTSearch(ct *service.CTSearchService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		domain := c.Query("domain")
+		if domain == "" {
+			c.JSON(400, gin.H{"error": "domain required"})
+			return
+		}
+		entries, err := ct.Search(c.Request.Context(), domain, c.Query("subdomains") == "true", c.Query("exclude_expired") == "true")
+		if err != nil {
+			status := 502
+			if errors.Is(err, service.ErrInvalidDomain) {
+				status = 400
+			}
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"domain": domain, "count": len(entries), "certs": entries})
+	}
+}
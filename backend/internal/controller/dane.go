@@ -0,0 +1,118 @@
+package controller
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"strings"
+
+	"engtools/backend/internal/pki"
+	"engtools/backend/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+type TLSAGenerateReq struct {
+	PEM   string `json:"pem"`   // certificate (leaf first), public key or private key
+	Host  string `json:"host"`  // optional; names the records _port._proto.host
+	Port  int    `json:"port"`  // default 443
+	Proto string `json:"proto"` // default tcp
+}
+
+func validTLSAProto(p string) bool {
+	switch strings.ToLower(p) {
+	case "", "tcp", "udp", "sctp":
+		return true
+	}
+	return false
+}
+
+// tlsaInput finds the certificate or, failing that, the public key to generate records
+// for. The certificate is nil when only a key was given.
+func tlsaInput(s string) (*x509.Certificate, []byte, error) {
+	if certs, _ := parseCertBlocks([]byte(s)); len(certs) > 0 {
+		return certs[0], certs[0].RawSubjectPublicKeyInfo, nil
+	}
+	if block, _ := pem.Decode([]byte(s)); block != nil && block.Type == "PUBLIC KEY" {
+		if _, err := x509.ParsePKIXPublicKey(block.Bytes); err != nil {
+			return nil, nil, err
+		}
+		return nil, block.Bytes, nil
+	}
+	key, err := pki.ParsePrivateKeyPEM(s)
+	if err != nil {
+		return nil, nil, err
+	}
+	spki, err := x509.MarshalPKIXPublicKey(key.Public())
+	return nil, spki, err
+}
+
+// TLSAGenerate lists TLSA records for every usage, selector and matching type. 3 1 1
+// (DANE-EE, SPKI, SHA-256) survives renewals that keep the key and is the usual choice.
+func TLSAGenerate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req TLSAGenerateReq
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": "invalid input"})
+			return
+		}
+		if !validTLSAProto(req.Proto) {
+			c.JSON(400, gin.H{"error": "proto must be tcp, udp or sctp"})
+			return
+		}
+		cert, spki, err := tlsaInput(req.PEM)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "pem must hold a certificate, public key or private key"})
+			return
+		}
+		if req.Port == 0 {
+			req.Port = 443
+		}
+		owner := ""
+		if req.Host = sanitizeHost(req.Host); req.Host != "" {
+			owner = pki.TLSAOwner(req.Host, req.Port, req.Proto)
+		}
+		out := gin.H{"name": owner, "records": pki.GenerateTLSA(owner, cert, spki)}
+		if cert != nil {
+			out["subject"] = cert.Subject.String()
+		}
+		c.JSON(200, out)
+	}
+}
+
+type TLSAVerifyReq struct {
+	TLSInspectReq
+	Proto string `json:"proto"` // default tcp
+}
+
+// TLSAVerify fetches the TLSA records of the endpoint and reports which of them match the
+// chain it serves. For SMTP pass port 25 and starttls smtp.
+func TLSAVerify(dane *service.DANEService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req TLSAVerifyReq
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": "invalid input"})
+			return
+		}
+		if strings.TrimSpace(req.Host) == "" && strings.TrimSpace(req.ServerName) == "" {
+			c.JSON(400, gin.H{"error": "host required"})
+			return
+		}
+		if !validTLSAProto(req.Proto) {
+			c.JSON(400, gin.H{"error": "proto must be tcp, udp or sctp"})
+			return
+		}
+		opts, err := req.options()
+		if err == nil {
+			err = opts.Validate()
+		}
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		res, err := dane.Verify(c.Request.Context(), opts, req.Proto)
+		if err != nil {
+			c.JSON(502, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(200, res)
+	}
+}
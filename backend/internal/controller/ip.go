@@ -0,0 +1,475 @@
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/csv"
+	"io"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"engtools/backend/internal/config"
+	"engtools/backend/internal/ipcalc"
+	"engtools/backend/internal/pki"
+	"engtools/backend/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+type IPBulkReq struct {
+	IPs         []string `json:"ips"`
+	Text        string   `json:"text"`
+	Format      string   `json:"format"` // json/csv
+	ReverseDNS  bool     `json:"reverse_dns"`
+	Geo         *bool    `json:"geo"`
+	Concurrency int      `json:"concurrency"`
+}
+
+// IPGeoBulk enriches many addresses at once. The body is either JSON (IPBulkReq) or a raw
+// text/plain log blob from which every IP literal is extracted.
+// POST /api/v1/tools/ip/geo/bulk?format=csv
+func IPGeoBulk(geo *service.GeoService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req IPBulkReq
+		if strings.HasPrefix(c.ContentType(), "text/") {
+			b, err := io.ReadAll(io.LimitReader(c.Request.Body, 16<<20))
+			if err != nil {
+				c.JSON(400, gin.H{"error": "invalid input"})
+				return
+			}
+			req.Text = string(b)
+			req.ReverseDNS = c.Query("reverse_dns") == "true"
+		} else if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": "invalid input"})
+			return
+		}
+		if f := c.Query("format"); f != "" {
+			req.Format = f
+		}
+		ips := req.IPs
+		if req.Text != "" {
+			ips = append(ips, service.ExtractIPs(req.Text)...)
+		}
+		if len(ips) == 0 {
+			c.JSON(400, gin.H{"error": "no ips"})
+			return
+		}
+		opts := service.BulkOptions{Concurrency: req.Concurrency, ReverseDNS: req.ReverseDNS, Geo: req.Geo == nil || *req.Geo}
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 2*time.Minute)
+		defer cancel()
+		res := geo.BulkLookup(ctx, ips, opts)
+		if strings.EqualFold(req.Format, "csv") {
+			c.Header("Content-Type", "text/csv; charset=utf-8")
+			c.Header("Content-Disposition", `attachment; filename="ip-bulk.csv"`)
+			c.Status(200)
+			writeBulkCSV(c.Writer, res)
+			return
+		}
+		c.JSON(200, res)
+	}
+}
+
+func writeBulkCSV(w io.Writer, res *service.BulkResult) {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"ip", "version", "count", "class", "private", "bogon", "ptr", "country_code", "country", "region", "city", "asn", "org", "latitude", "longitude", "error"})
+	for _, it := range res.Items {
+		row := []string{it.IP, strconv.Itoa(it.Version), strconv.Itoa(it.Count), it.Class, strconv.FormatBool(it.Private), strconv.FormatBool(it.Bogon), strings.Join(it.PTR, " ")}
+		if g := it.Geo; g != nil {
+			row = append(row, g.CountryCode, g.Country, g.Region, g.City, g.ASN, g.Org, strconv.FormatFloat(g.Latitude, 'f', -1, 64), strconv.FormatFloat(g.Longitude, 'f', -1, 64))
+		} else {
+			row = append(row, "", "", "", "", "", "", "", "")
+		}
+		row = append(row, it.Error)
+		_ = cw.Write(row)
+	}
+	cw.Flush()
+}
+
+// IPClassify checks addresses against the IANA special-purpose registries offline.
+// GET /api/v1/tools/ip/classify?ip=100.64.1.1&ip=2002:c000:204::1
+func IPClassify() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ips := c.QueryArray("ip")
+		if len(ips) == 0 {
+			ips = []string{c.ClientIP()}
+		}
+		out := make([]*ipcalc.Classification, 0, len(ips))
+		for _, s := range ips {
+			a, err := ipcalc.ParseAny(s)
+			if err != nil {
+				c.JSON(400, gin.H{"error": "invalid ip " + strconv.Quote(s)})
+				return
+			}
+			out = append(out, ipcalc.Classify(a))
+		}
+		if len(out) == 1 {
+			c.JSON(200, out[0])
+			return
+		}
+		c.JSON(200, gin.H{"results": out})
+	}
+}
+
+// IPSpecialRegistry lists the bundled special-purpose registry rows.
+// GET /api/v1/tools/ip/registry
+func IPSpecialRegistry() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(200, gin.H{"entries": ipcalc.SpecialRegistry()})
+	}
+}
+
+// IPConvert renders an address given in dotted, integer, hex or binary form in all of them.
+// GET /api/v1/tools/ip/convert?value=3232235777
+func IPConvert() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		v := c.Query("value")
+		if v == "" {
+			c.JSON(400, gin.H{"error": "value required"})
+			return
+		}
+		res, err := ipcalc.Convert(v)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(200, res)
+	}
+}
+
+type ForwardHop struct {
+	Source   string `json:"source"`
+	Value    string `json:"value"`
+	IP       string `json:"ip,omitempty"`
+	Valid    bool   `json:"valid"`
+	Trusted  bool   `json:"trusted"`
+	Category string `json:"category,omitempty"`
+}
+
+// platformHeader names the header gin takes the client IP from for TRUSTED_PLATFORM, using
+// the same aliases as the router.
+func platformHeader(platform string) string {
+	switch strings.ToLower(platform) {
+	case "":
+		return ""
+	case "cloudflare":
+		return gin.PlatformCloudflare
+	case "google", "appengine":
+		return gin.PlatformGoogleAppEngine
+	}
+	return platform
+}
+
+// IPEcho reflects the request back as the server perceived it: client IP resolution, the
+// forwarding chain with per-hop trust, headers, protocol and TLS details.
+// ANY /api/v1/tools/ip/echo
+func IPEcho(cfg *config.Config) gin.HandlerFunc {
+	trusted, _ := ipcalc.ParsePrefixes(cfg.TrustedProxies)
+	isTrusted := func(a netip.Addr) bool {
+		for _, p := range trusted {
+			if p.Contains(a.Unmap()) {
+				return true
+			}
+		}
+		return false
+	}
+	hop := func(source, value string) ForwardHop {
+		h := ForwardHop{Source: source, Value: value}
+		v := strings.Trim(strings.TrimSpace(value), `"`)
+		if ap, err := netip.ParseAddrPort(v); err == nil {
+			v = ap.Addr().String()
+		} else if strings.HasPrefix(v, "[") {
+			v = strings.TrimSuffix(strings.TrimPrefix(v, "["), "]")
+		}
+		if a, err := netip.ParseAddr(v); err == nil {
+			a = a.Unmap()
+			h.IP, h.Valid, h.Trusted = a.String(), true, isTrusted(a)
+			h.Category = ipcalc.Classify(a).Category
+		}
+		return h
+	}
+	return func(c *gin.Context) {
+		r := c.Request
+		// hops ordered from the original client to the peer that connected to us
+		chain := make([]ForwardHop, 0)
+		for _, v := range r.Header.Values("X-Forwarded-For") {
+			for _, p := range strings.Split(v, ",") {
+				if p = strings.TrimSpace(p); p != "" {
+					chain = append(chain, hop("x-forwarded-for", p))
+				}
+			}
+		}
+		forwarded := make([]map[string]string, 0)
+		for _, v := range r.Header.Values("Forwarded") {
+			for _, elem := range strings.Split(v, ",") {
+				m := map[string]string{}
+				for _, pair := range strings.Split(elem, ";") {
+					k, val, ok := strings.Cut(strings.TrimSpace(pair), "=")
+					if ok {
+						m[strings.ToLower(k)] = strings.Trim(val, `"`)
+					}
+				}
+				if len(m) > 0 {
+					forwarded = append(forwarded, m)
+				}
+				if f, ok := m["for"]; ok && len(r.Header.Values("X-Forwarded-For")) == 0 {
+					chain = append(chain, hop("forwarded", f))
+				}
+			}
+		}
+		remote := hop("remote_addr", r.RemoteAddr)
+		chain = append(chain, remote)
+		single := gin.H{}
+		for _, h := range []string{"X-Real-IP", "CF-Connecting-IP", "True-Client-IP", "X-Client-IP", "Fastly-Client-IP", "X-Forwarded-Proto", "X-Forwarded-Host", "X-Forwarded-Port", "Via"} {
+			if v := r.Header.Get(h); v != "" {
+				single[strings.ToLower(h)] = v
+			}
+		}
+		headers := map[string][]string{}
+		for k, v := range r.Header {
+			switch k {
+			case "Authorization", "Proxy-Authorization", "Cookie", "X-Api-Key":
+				v = []string{"<redacted>"}
+			}
+			headers[k] = v
+		}
+		spoofable := make([]string, 0)
+		if !remote.Trusted && len(chain) > 1 {
+			spoofable = append(spoofable, "forwarding headers were sent by an untrusted peer and were ignored")
+		}
+		if h := platformHeader(cfg.TrustedPlatform); h != "" && r.Header.Get(h) != "" {
+			spoofable = append(spoofable, "client IP was taken from the "+h+" header because a trusted platform is configured; it is only safe if the platform overwrites it")
+		}
+		for _, h := range chain[:len(chain)-1] {
+			if !h.Valid {
+				spoofable = append(spoofable, "invalid address in chain: "+h.Value)
+			}
+		}
+		res := gin.H{
+			"client_ip":        c.ClientIP(),
+			"remote_addr":      r.RemoteAddr,
+			"remote_trusted":   remote.Trusted,
+			"trusted_proxies":  cfg.TrustedProxies,
+			"trusted_platform": cfg.TrustedPlatform,
+			"chain":            chain,
+			"forwarded":        forwarded,
+			"proxy_headers":    single,
+			"warnings":         spoofable,
+			"method":           r.Method,
+			"host":             r.Host,
+			"uri":              r.RequestURI,
+			"proto":            r.Proto,
+			"content_length":   r.ContentLength,
+			"headers":          headers,
+			"tls":              echoTLS(r.TLS),
+		}
+		c.JSON(200, res)
+	}
+}
+
+func echoTLS(st *tls.ConnectionState) gin.H {
+	if st == nil {
+		return nil
+	}
+	out := gin.H{
+		"version":     tls.VersionName(st.Version),
+		"cipher":      tls.CipherSuiteName(st.CipherSuite),
+		"sni":         st.ServerName,
+		"alpn":        st.NegotiatedProtocol,
+		"resumed":     st.DidResume,
+		"ech":         st.ECHAccepted,
+		"client_cert": nil,
+	}
+	if len(st.PeerCertificates) > 0 {
+		crt := st.PeerCertificates[0]
+		fp := sha256.Sum256(crt.Raw)
+		out["client_cert"] = gin.H{
+			"subject":            crt.Subject.String(),
+			"issuer":             crt.Issuer.String(),
+			"serial":             crt.SerialNumber.String(),
+			"not_before":         crt.NotBefore,
+			"not_after":          crt.NotAfter,
+			"fingerprint_sha256": pki.HexColon(fp[:]),
+			"chain_length":       len(st.PeerCertificates),
+		}
+	}
+	return out
+}
+
+type CIDRReq struct {
+	CIDR      string   `json:"cidr"`
+	CIDRs     []string `json:"cidrs"`
+	Other     []string `json:"other"`
+	IPs       []string `json:"ips"`
+	PrefixLen int      `json:"prefix_len"`
+	Count     int      `json:"count"`
+	Start     string   `json:"start"`
+	End       string   `json:"end"`
+}
+
+// CIDRInfo returns network, broadcast, host range, counts and masks for one or more prefixes.
+// POST /api/v1/tools/ip/cidr/info
+func CIDRInfo() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req CIDRReq
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": "invalid input"})
+			return
+		}
+		inputs := req.CIDRs
+		if req.CIDR != "" {
+			inputs = append([]string{req.CIDR}, inputs...)
+		}
+		if len(inputs) == 0 {
+			c.JSON(400, gin.H{"error": "cidr required"})
+			return
+		}
+		out := make([]*ipcalc.CIDRInfo, 0, len(inputs))
+		for _, in := range inputs {
+			info, err := ipcalc.Info(in)
+			if err != nil {
+				c.JSON(400, gin.H{"error": "invalid prefix " + strconv.Quote(in)})
+				return
+			}
+			out = append(out, info)
+		}
+		c.JSON(200, gin.H{"results": out})
+	}
+}
+
+// CIDRSubnet splits a prefix either into /prefix_len subnets or into at least count subnets.
+// POST /api/v1/tools/ip/cidr/subnet
+func CIDRSubnet() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req CIDRReq
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": "invalid input"})
+			return
+		}
+		p, err := ipcalc.ParsePrefix(req.CIDR)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "invalid cidr"})
+			return
+		}
+		if req.PrefixLen == 0 && req.Count <= 0 {
+			c.JSON(400, gin.H{"error": "prefix_len or count required"})
+			return
+		}
+		subs, err := ipcalc.Subnet(p, req.PrefixLen, req.Count)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"cidr": p.Masked().String(), "prefix_len": subs[0].Bits(), "count": len(subs), "subnets": prefixStrings(subs)})
+	}
+}
+
+// CIDRAggregate merges a list of prefixes into the minimal covering set (supernetting).
+// POST /api/v1/tools/ip/cidr/aggregate
+func CIDRAggregate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req CIDRReq
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": "invalid input"})
+			return
+		}
+		ps, err := ipcalc.ParsePrefixes(req.CIDRs)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		agg := ipcalc.Aggregate(ps)
+		c.JSON(200, gin.H{"input_count": len(ps), "count": len(agg), "cidrs": prefixStrings(agg)})
+	}
+}
+
+// CIDRFromRange converts an inclusive start-end address range into prefixes.
+// POST /api/v1/tools/ip/cidr/range
+func CIDRFromRange() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req CIDRReq
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": "invalid input"})
+			return
+		}
+		start, err1 := netip.ParseAddr(strings.TrimSpace(req.Start))
+		end, err2 := netip.ParseAddr(strings.TrimSpace(req.End))
+		if err1 != nil || err2 != nil {
+			c.JSON(400, gin.H{"error": "invalid start or end address"})
+			return
+		}
+		ps, err := ipcalc.RangeToCIDRs(start, end)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"count": len(ps), "cidrs": prefixStrings(ps)})
+	}
+}
+
+// CIDROverlap lists overlapping prefixes between cidrs and other, or within cidrs when
+// other is omitted.
+// POST /api/v1/tools/ip/cidr/overlap
+func CIDROverlap() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req CIDRReq
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": "invalid input"})
+			return
+		}
+		a, err := ipcalc.ParsePrefixes(req.CIDRs)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		var b []netip.Prefix
+		if len(req.Other) > 0 {
+			if b, err = ipcalc.ParsePrefixes(req.Other); err != nil {
+				c.JSON(400, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		ov := ipcalc.Overlaps(a, b)
+		c.JSON(200, gin.H{"overlap": len(ov) > 0, "pairs": ov})
+	}
+}
+
+// CIDRContains tests which prefixes contain each of the given addresses.
+// POST /api/v1/tools/ip/cidr/contains
+func CIDRContains() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req CIDRReq
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": "invalid input"})
+			return
+		}
+		ps, err := ipcalc.ParsePrefixes(req.CIDRs)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		addrs := make([]netip.Addr, 0, len(req.IPs))
+		for _, s := range req.IPs {
+			a, err := netip.ParseAddr(strings.TrimSpace(s))
+			if err != nil {
+				c.JSON(400, gin.H{"error": "invalid ip " + strconv.Quote(s)})
+				return
+			}
+			addrs = append(addrs, a)
+		}
+		if len(ps) == 0 || len(addrs) == 0 {
+			c.JSON(400, gin.H{"error": "cidrs and ips required"})
+			return
+		}
+		c.JSON(200, gin.H{"results": ipcalc.Contains(ps, addrs)})
+	}
+}
+
+func prefixStrings(ps []netip.Prefix) []string {
+	out := make([]string, 0, len(ps))
+	for _, p := range ps {
+		out = append(out, p.String())
+	}
+	return out
+}
@@ -0,0 +1,119 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"engtools/backend/internal/config"
+	"github.com/gin-gonic/gin"
+)
+
+type echoResp struct {
+	ClientIP      string              `json:"client_ip"`
+	RemoteTrusted bool                `json:"remote_trusted"`
+	Chain         []ForwardHop        `json:"chain"`
+	Warnings      []string            `json:"warnings"`
+	Headers       map[string][]string `json:"headers"`
+}
+
+func echo(t *testing.T, remote string, headers map[string]string) echoResp {
+	t.Helper()
+	return echoPlatform(t, "", remote, headers)
+}
+
+func echoPlatform(t *testing.T, platform, remote string, headers map[string]string) echoResp {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	cfg := &config.Config{TrustedProxies: []string{"10.0.0.0/8", "::1/128"}, TrustedPlatform: platform}
+	r := gin.New()
+	if err := r.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		t.Fatal(err)
+	}
+	r.TrustedPlatform = platformHeader(platform)
+	r.GET("/echo", IPEcho(cfg))
+	req := httptest.NewRequest("GET", "/echo", nil)
+	req.RemoteAddr = remote
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != 200 {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	var out echoResp
+	if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestIPEchoTrustedProxy(t *testing.T) {
+	out := echo(t, "10.0.0.5:41000", map[string]string{"X-Forwarded-For": "203.0.113.7, 10.0.0.9", "Authorization": "Bearer secret", "Proxy-Authorization": "Basic c2VjcmV0", "X-Api-Key": "k", "Cookie": "s=1"})
+	if out.ClientIP != "203.0.113.7" || !out.RemoteTrusted || len(out.Warnings) != 0 {
+		t.Errorf("client %s trusted %v warnings %v", out.ClientIP, out.RemoteTrusted, out.Warnings)
+	}
+	want := []ForwardHop{
+		{Source: "x-forwarded-for", Value: "203.0.113.7", IP: "203.0.113.7", Valid: true, Category: "documentation"},
+		{Source: "x-forwarded-for", Value: "10.0.0.9", IP: "10.0.0.9", Valid: true, Trusted: true, Category: "private"},
+		{Source: "remote_addr", Value: "10.0.0.5:41000", IP: "10.0.0.5", Valid: true, Trusted: true, Category: "private"},
+	}
+	if len(out.Chain) != len(want) {
+		t.Fatalf("chain %+v", out.Chain)
+	}
+	for i, h := range out.Chain {
+		if h.Source != want[i].Source || h.IP != want[i].IP || h.Valid != want[i].Valid || h.Trusted != want[i].Trusted || h.Category != want[i].Category {
+			t.Errorf("hop %d: got %+v, want %+v", i, h, want[i])
+		}
+	}
+	for _, h := range []string{"Authorization", "Proxy-Authorization", "X-Api-Key", "Cookie"} {
+		if got := out.Headers[h]; len(got) != 1 || got[0] != "<redacted>" {
+			t.Errorf("%s header echoed: %v", h, got)
+		}
+	}
+}
+
+func TestIPEchoUntrustedPeer(t *testing.T) {
+	out := echo(t, "198.51.100.1:5555", map[string]string{"X-Forwarded-For": "1.2.3.4"})
+	if out.ClientIP != "198.51.100.1" || out.RemoteTrusted {
+		t.Errorf("spoofed forwarding header honoured: client %s trusted %v", out.ClientIP, out.RemoteTrusted)
+	}
+	if len(out.Warnings) != 1 || !strings.Contains(out.Warnings[0], "untrusted peer") {
+		t.Errorf("warnings %v", out.Warnings)
+	}
+
+	out = echo(t, "[::1]:8080", map[string]string{"X-Forwarded-For": "unknown, 192.0.2.10"})
+	if out.ClientIP != "192.0.2.10" || !out.RemoteTrusted {
+		t.Errorf("client %s trusted %v", out.ClientIP, out.RemoteTrusted)
+	}
+	if len(out.Warnings) != 1 || !strings.Contains(out.Warnings[0], "unknown") || out.Chain[0].Valid {
+		t.Errorf("invalid hop not reported: %v %+v", out.Warnings, out.Chain)
+	}
+
+	out = echo(t, "10.1.2.3:1000", map[string]string{"Forwarded": `for="[2001:db8::1]:443";proto=https`})
+	if len(out.Chain) != 2 || out.Chain[0].Source != "forwarded" || out.Chain[0].IP != "2001:db8::1" {
+		t.Errorf("forwarded chain %+v", out.Chain)
+	}
+}
+
+func TestIPEchoTrustedPlatform(t *testing.T) {
+	out := echoPlatform(t, "cloudflare", "198.51.100.1:5555", map[string]string{"CF-Connecting-IP": "203.0.113.9"})
+	if out.ClientIP != "203.0.113.9" {
+		t.Errorf("client %s", out.ClientIP)
+	}
+	if len(out.Warnings) != 1 || !strings.Contains(out.Warnings[0], "CF-Connecting-IP") {
+		t.Errorf("warnings %v", out.Warnings)
+	}
+
+	out = echoPlatform(t, "X-Edge-Client", "198.51.100.1:5555", map[string]string{"X-Edge-Client": "203.0.113.10"})
+	if out.ClientIP != "203.0.113.10" || len(out.Warnings) != 1 || !strings.Contains(out.Warnings[0], "X-Edge-Client") {
+		t.Errorf("client %s warnings %v", out.ClientIP, out.Warnings)
+	}
+
+	out = echoPlatform(t, "cloudflare", "198.51.100.1:5555", nil)
+	if out.ClientIP != "198.51.100.1" || len(out.Warnings) != 0 {
+		t.Errorf("client %s warnings %v", out.ClientIP, out.Warnings)
+	}
+}
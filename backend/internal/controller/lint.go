@@ -0,0 +1,74 @@
+package controller
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"strings"
+	"time"
+
+	"engtools/backend/internal/pki"
+	"github.com/gin-gonic/gin"
+)
+
+type CertLintResult struct {
+	Subject  string         `json:"subject"`
+	Serial   string         `json:"serial"`
+	Kind     string         `json:"kind"`
+	Findings []pki.Finding  `json:"findings"`
+	Counts   map[string]int `json:"counts"`
+}
+
+// CertLint runs the CA/B Forum and RFC 5280 checks over every certificate of the input,
+// which is accepted in the same forms as CertParse.
+func CertLint() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req CertParseReq
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": "invalid input"})
+			return
+		}
+		var certs []*x509.Certificate
+		var skipped []PEMBlock
+		if strings.TrimSpace(req.PEM) != "" {
+			certs, skipped = parseCertBlocks([]byte(req.PEM))
+		} else if req.DER != "" {
+			b, err := base64.StdEncoding.DecodeString(strings.TrimSpace(req.DER))
+			if err != nil {
+				c.JSON(400, gin.H{"error": "invalid der"})
+				return
+			}
+			crt, err := x509.ParseCertificate(b)
+			if err != nil {
+				c.JSON(400, gin.H{"error": "parse failed: " + err.Error()})
+				return
+			}
+			certs = append(certs, crt)
+		} else {
+			c.JSON(400, gin.H{"error": "no input"})
+			return
+		}
+		if len(certs) == 0 {
+			c.JSON(400, gin.H{"error": "no certificates", "skipped": skipped})
+			return
+		}
+		now := time.Now()
+		total := map[string]int{pki.LintError: 0, pki.LintWarning: 0, pki.LintNotice: 0}
+		out := make([]CertLintResult, 0, len(certs))
+		for _, crt := range certs {
+			r := CertLintResult{
+				Subject: crt.Subject.String(), Serial: crt.SerialNumber.Text(16), Kind: pki.LintKind(crt),
+				Findings: pki.Lint(crt, now), Counts: map[string]int{pki.LintError: 0, pki.LintWarning: 0, pki.LintNotice: 0},
+			}
+			for _, f := range r.Findings {
+				r.Counts[f.Severity]++
+				total[f.Severity]++
+			}
+			out = append(out, r)
+		}
+		resp := gin.H{"certs": out, "summary": total, "pass": total[pki.LintError] == 0}
+		if len(skipped) > 0 {
+			resp["skipped"] = skipped
+		}
+		c.JSON(200, resp)
+	}
+}
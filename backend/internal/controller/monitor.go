@@ -0,0 +1,263 @@
+package controller
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"engtools/backend/internal/repository/model"
+	"engtools/backend/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+type MonitorEndpointReq struct {
+	Name            string `json:"name"`
+	Host            string `json:"host"`
+	Port            int    `json:"port"`
+	Address         string `json:"address"`
+	ServerName      string `json:"server_name"`
+	StartTLS        string `json:"starttls"`
+	IntervalMinutes int    `json:"interval_minutes"`
+	Thresholds      []int  `json:"thresholds"` // days before expiry, default 30,14,7,3,1
+	Enabled         *bool  `json:"enabled"`    // default true
+}
+
+func (r MonitorEndpointReq) model() *model.MonitoredEndpoint {
+	days := make([]string, len(r.Thresholds))
+	for i, d := range r.Thresholds {
+		days[i] = strconv.Itoa(d)
+	}
+	return &model.MonitoredEndpoint{
+		Name: r.Name, Host: r.Host, Port: r.Port, Address: r.Address, ServerName: r.ServerName, StartTLS: r.StartTLS,
+		IntervalMinutes: r.IntervalMinutes, Thresholds: strings.Join(days, ","), Enabled: r.Enabled == nil || *r.Enabled,
+	}
+}
+
+func endpointJSON(ep *model.MonitoredEndpoint) gin.H {
+	thresholds, _ := service.ParseThresholds(ep.Thresholds)
+	return gin.H{
+		"name": ep.Name, "host": ep.Host, "port": ep.Port, "address": ep.Address, "server_name": ep.ServerName,
+		"starttls": ep.StartTLS, "interval_minutes": ep.IntervalMinutes, "thresholds": thresholds, "enabled": ep.Enabled,
+		"status": ep.Status, "last_checked_at": ep.LastCheckedAt, "last_error": ep.LastError,
+		"fingerprint_sha256": ep.Fingerprint, "subject": ep.Subject, "not_after": ep.NotAfter,
+		"days_remaining": ep.DaysRemaining, "verify_ok": ep.VerifyOK, "created_at": ep.CreatedAt,
+	}
+}
+
+func checkJSON(chk *model.CertCheck) gin.H {
+	return gin.H{
+		"checked_at": chk.CheckedAt, "status": chk.Status, "error": chk.Error, "ip": chk.IP,
+		"fingerprint_sha256": chk.Fingerprint, "subject": chk.Subject, "issuer": chk.Issuer, "serial": chk.Serial,
+		"not_after": chk.NotAfter, "days_remaining": chk.DaysRemaining, "verify_ok": chk.VerifyOK,
+		"verify_error": chk.VerifyError, "changed": chk.Changed,
+	}
+}
+
+// monitorError maps service errors to a status: unknown names are 404, name clashes 409
+// and anything else a validation failure.
+func monitorError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrEndpointNotFound), errors.Is(err, service.ErrChannelNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrEndpointExists), errors.Is(err, service.ErrChannelExists):
+		c.JSON(409, gin.H{"error": err.Error()})
+	default:
+		c.JSON(400, gin.H{"error": err.Error()})
+	}
+}
+
+func MonitorEndpointList(mon *service.MonitorService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		list, err := mon.ListEndpoints()
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		out := make([]gin.H, 0, len(list))
+		for i := range list {
+			out = append(out, endpointJSON(&list[i]))
+		}
+		c.JSON(200, gin.H{"endpoints": out})
+	}
+}
+
+// MonitorEndpointCreate registers an endpoint; the scheduler checks it on its next tick.
+func MonitorEndpointCreate(mon *service.MonitorService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req MonitorEndpointReq
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": "invalid input"})
+			return
+		}
+		ep := req.model()
+		if err := mon.CreateEndpoint(ep); err != nil {
+			monitorError(c, err)
+			return
+		}
+		c.JSON(200, endpointJSON(ep))
+	}
+}
+
+func MonitorEndpointGet(mon *service.MonitorService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ep, err := mon.GetEndpoint(c.Param("name"))
+		if err != nil {
+			monitorError(c, err)
+			return
+		}
+		c.JSON(200, endpointJSON(ep))
+	}
+}
+
+// MonitorEndpointUpdate replaces an endpoint's configuration; its history is kept.
+func MonitorEndpointUpdate(mon *service.MonitorService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req MonitorEndpointReq
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": "invalid input"})
+			return
+		}
+		if req.Name == "" {
+			req.Name = c.Param("name")
+		}
+		ep, err := mon.UpdateEndpoint(c.Param("name"), req.model())
+		if err != nil {
+			monitorError(c, err)
+			return
+		}
+		c.JSON(200, endpointJSON(ep))
+	}
+}
+
+func MonitorEndpointDelete(mon *service.MonitorService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := mon.DeleteEndpoint(c.Param("name")); err != nil {
+			monitorError(c, err)
+			return
+		}
+		c.JSON(200, gin.H{"deleted": c.Param("name")})
+	}
+}
+
+// MonitorEndpointCheck checks an endpoint immediately and reports the events it raised.
+func MonitorEndpointCheck(mon *service.MonitorService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		out, err := mon.Check(c.Request.Context(), c.Param("name"))
+		if errors.Is(err, service.ErrEndpointNotFound) {
+			c.JSON(404, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		events, failed := out.Events, out.NotifyErrors
+		if events == nil {
+			events = []service.MonitorEvent{}
+		}
+		if failed == nil {
+			failed = []string{}
+		}
+		c.JSON(200, gin.H{"endpoint": endpointJSON(out.Endpoint), "check": checkJSON(out.Check), "events": events, "notify_errors": failed})
+	}
+}
+
+// MonitorEndpointHistory lists recent checks, newest first; changes=true keeps only the
+// checks that saw a new certificate.
+func MonitorEndpointHistory(mon *service.MonitorService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+		if err != nil || limit < 1 || limit > 1000 {
+			c.JSON(400, gin.H{"error": "limit must be between 1 and 1000"})
+			return
+		}
+		list, err := mon.History(c.Param("name"), limit, c.Query("changes") == "true")
+		if err != nil {
+			monitorError(c, err)
+			return
+		}
+		out := make([]gin.H, 0, len(list))
+		for i := range list {
+			out = append(out, checkJSON(&list[i]))
+		}
+		c.JSON(200, gin.H{"checks": out})
+	}
+}
+
+type MonitorChannelReq struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"`   // webhook, slack, feishu, dingtalk or email
+	Target  string `json:"target"` // URL, or comma separated addresses for email
+	Secret  string `json:"secret"` // signing secret of Feishu and DingTalk bots
+	Enabled *bool  `json:"enabled"`
+}
+
+func MonitorChannelList(mon *service.MonitorService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		list, err := mon.ListChannels()
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		out := make([]gin.H, 0, len(list))
+		for _, ch := range list {
+			// the target URL of a chat bot carries its token, so only the host is shown
+			out = append(out, gin.H{"name": ch.Name, "type": ch.Type, "target": redactTarget(ch.Type, ch.Target), "signed": ch.Secret != "", "enabled": ch.Enabled, "created_at": ch.CreatedAt})
+		}
+		c.JSON(200, gin.H{"channels": out})
+	}
+}
+
+func redactTarget(typ, target string) string {
+	if typ == "email" {
+		return target
+	}
+	scheme, rest, ok := strings.Cut(target, "://")
+	if !ok {
+		return "***"
+	}
+	host, _, _ := strings.Cut(rest, "/")
+	return scheme + "://" + host + "/***"
+}
+
+func MonitorChannelCreate(mon *service.MonitorService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req MonitorChannelReq
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": "invalid input"})
+			return
+		}
+		ch := &model.NotificationChannel{Name: req.Name, Type: req.Type, Target: req.Target, Secret: req.Secret, Enabled: req.Enabled == nil || *req.Enabled}
+		if err := mon.CreateChannel(ch); err != nil {
+			monitorError(c, err)
+			return
+		}
+		c.JSON(200, gin.H{"stored": ch.Name, "type": ch.Type})
+	}
+}
+
+func MonitorChannelDelete(mon *service.MonitorService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := mon.DeleteChannel(c.Param("name")); err != nil {
+			monitorError(c, err)
+			return
+		}
+		c.JSON(200, gin.H{"deleted": c.Param("name")})
+	}
+}
+
+// MonitorChannelTest sends a test notification; delivery failures are upstream errors.
+func MonitorChannelTest(mon *service.MonitorService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		err := mon.TestChannel(c.Request.Context(), c.Param("name"))
+		if errors.Is(err, service.ErrChannelNotFound) {
+			c.JSON(404, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(502, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"sent": true})
+	}
+}
@@ -0,0 +1,180 @@
+package controller
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"engtools/backend/internal/pki"
+	"github.com/gin-gonic/gin"
+)
+
+// revocationClient is shared by the OCSP and CRL lookups.
+var revocationClient = &http.Client{Timeout: 10 * time.Second}
+
+type OCSPReq struct {
+	CertPEM   string `json:"cert_pem"`   // leaf, optionally followed by its issuer
+	IssuerPEM string `json:"issuer_pem"` // required unless cert_pem carries the issuer
+	Hash      string `json:"hash"`       // CertID hash, default sha1
+	URL       string `json:"url"`        // overrides the responder from the certificate
+	Method    string `json:"method"`     // POST (default) or GET
+}
+
+func (r OCSPReq) certs() (crt, issuer *x509.Certificate, err error) {
+	certs, err := pki.ParseCertificatesPEM(r.CertPEM)
+	if err != nil {
+		return nil, nil, errors.New("cert: " + err.Error())
+	}
+	crt = certs[0]
+	if strings.TrimSpace(r.IssuerPEM) != "" {
+		issuers, err := pki.ParseCertificatesPEM(r.IssuerPEM)
+		if err != nil {
+			return nil, nil, errors.New("issuer: " + err.Error())
+		}
+		return crt, issuers[0], nil
+	}
+	if len(certs) > 1 {
+		return crt, certs[1], nil
+	}
+	return nil, nil, errors.New("issuer_pem required")
+}
+
+// OCSPRequest builds the DER request a client would send for cert_pem.
+func OCSPRequest() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req OCSPReq
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": "invalid input"})
+			return
+		}
+		crt, issuer, err := req.certs()
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		hash, err := pki.ParseOCSPHash(req.Hash)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		der, parsed, err := pki.CreateOCSPRequest(crt, issuer, hash)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{
+			"request":            pki.NewOCSPRequestInfo(parsed),
+			"request_der_base64": base64.StdEncoding.EncodeToString(der),
+			"urls":               crt.OCSPServer,
+		})
+	}
+}
+
+// OCSPCheck sends a request to the responder and parses the answer.
+func OCSPCheck(logs *pki.CTLogList) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req OCSPReq
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": "invalid input"})
+			return
+		}
+		crt, issuer, err := req.certs()
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		hash, err := pki.ParseOCSPHash(req.Hash)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		url := req.URL
+		if url == "" {
+			if len(crt.OCSPServer) == 0 {
+				c.JSON(400, gin.H{"error": "certificate has no ocsp url; pass url"})
+				return
+			}
+			url = crt.OCSPServer[0]
+		}
+		der, parsed, err := pki.CreateOCSPRequest(crt, issuer, hash)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		raw, err := pki.SendOCSP(c.Request.Context(), revocationClient, url, der, strings.EqualFold(req.Method, "GET"))
+		if err != nil {
+			c.JSON(502, gin.H{"error": err.Error(), "url": url})
+			return
+		}
+		info, err := pki.ParseOCSPResponse(raw, crt, issuer)
+		if err != nil {
+			c.JSON(502, gin.H{"error": "bad ocsp response: " + err.Error(), "url": url, "response_der_base64": base64.StdEncoding.EncodeToString(raw)})
+			return
+		}
+		logs.Annotate(info.SCTs, crt, issuer)
+		c.JSON(200, gin.H{
+			"url":                 url,
+			"request":             pki.NewOCSPRequestInfo(parsed),
+			"response":            info,
+			"response_der_base64": base64.StdEncoding.EncodeToString(raw),
+		})
+	}
+}
+
+type OCSPParseReq struct {
+	Response  string `json:"response"` // base64 DER or PEM
+	CertPEM   string `json:"cert_pem"`
+	IssuerPEM string `json:"issuer_pem"`
+}
+
+// OCSPParse decodes a pasted response; with issuer_pem the signature is checked too.
+func OCSPParse(logs *pki.CTLogList) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req OCSPParseReq
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": "invalid input"})
+			return
+		}
+		in := strings.TrimSpace(req.Response)
+		var der []byte
+		if block, _ := pem.Decode([]byte(in)); block != nil {
+			der = block.Bytes
+		} else {
+			var err error
+			if der, err = decodeBinary(in, "base64"); err != nil {
+				c.JSON(400, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		var crt, issuer *x509.Certificate
+		if strings.TrimSpace(req.CertPEM) != "" {
+			certs, err := pki.ParseCertificatesPEM(req.CertPEM)
+			if err != nil {
+				c.JSON(400, gin.H{"error": "cert: " + err.Error()})
+				return
+			}
+			crt = certs[0]
+		}
+		if strings.TrimSpace(req.IssuerPEM) != "" {
+			issuers, err := pki.ParseCertificatesPEM(req.IssuerPEM)
+			if err != nil {
+				c.JSON(400, gin.H{"error": "issuer: " + err.Error()})
+				return
+			}
+			issuer = issuers[0]
+		}
+		info, err := pki.ParseOCSPResponse(der, crt, issuer)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		if crt != nil {
+			logs.Annotate(info.SCTs, crt, issuer)
+		}
+		c.JSON(200, gin.H{"response": info})
+	}
+}
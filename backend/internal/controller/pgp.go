@@ -0,0 +1,146 @@
+package controller
+
+import (
+	"engtools/backend/internal/pgp"
+	"github.com/gin-gonic/gin"
+)
+
+// PGPKeygen generates an OpenPGP key pair, returned armored and described as PGPKeyParse
+// would.
+func PGPKeygen() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req pgp.GenerateSpec
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": "invalid input"})
+			return
+		}
+		kp, err := pgp.Generate(req)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(200, kp)
+	}
+}
+
+type PGPKeyReq struct {
+	Key string `json:"key"` // one or more armored public or private key blocks
+}
+
+// PGPKeyParse lists user IDs, subkeys, expiries, capabilities and fingerprints.
+func PGPKeyParse() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req PGPKeyReq
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": "invalid input"})
+			return
+		}
+		certs, err := pgp.Inspect(req.Key)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"count": len(certs), "keys": certs})
+	}
+}
+
+type PGPEncryptReq struct {
+	pgp.EncryptOptions
+	Message string `json:"message"`
+}
+
+func PGPEncrypt() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req PGPEncryptReq
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": "invalid input"})
+			return
+		}
+		out, err := pgp.Encrypt(req.Message, req.EncryptOptions)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"message": out})
+	}
+}
+
+type PGPDecryptReq struct {
+	pgp.DecryptOptions
+	Message string `json:"message"` // armored PGP MESSAGE
+}
+
+func PGPDecrypt() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req PGPDecryptReq
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": "invalid input"})
+			return
+		}
+		res, err := pgp.Decrypt(req.Message, req.DecryptOptions)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(200, res)
+	}
+}
+
+type PGPSignReq struct {
+	pgp.SignOptions
+	Message  string `json:"message"`
+	Detached bool   `json:"detached"` // default clear-signed
+	Text     bool   `json:"text"`     // detached only: canonical text rather than binary signature
+}
+
+func PGPSign() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req PGPSignReq
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": "invalid input"})
+			return
+		}
+		var out string
+		var err error
+		if req.Detached {
+			out, err = pgp.DetachSign(req.Message, req.Text, req.SignOptions)
+		} else {
+			out, err = pgp.ClearSign(req.Message, req.SignOptions)
+		}
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"signature": out})
+	}
+}
+
+type PGPVerifyReq struct {
+	Message   string `json:"message"`   // clear-signed message, or the signed data with signature
+	Signature string `json:"signature"` // armored detached signature
+	Keys      string `json:"keys"`      // armored public keys of the possible signers
+}
+
+// PGPVerify checks a clear-signed message or a detached signature. A bad signature or an
+// unknown signer is a 200 with valid false.
+func PGPVerify() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req PGPVerifyReq
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": "invalid input"})
+			return
+		}
+		var v *pgp.Verification
+		var err error
+		if req.Signature != "" {
+			v, err = pgp.VerifyDetached(req.Message, req.Signature, req.Keys)
+		} else {
+			v, err = pgp.VerifyClearSigned(req.Message, req.Keys)
+		}
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(200, v)
+	}
+}
@@ -0,0 +1,173 @@
+package controller
+
+import (
+	"errors"
+	"strings"
+
+	"engtools/backend/internal/sshinspect"
+	"engtools/backend/internal/sshkey"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/ssh"
+)
+
+// SSHKeygen generates a key pair; the private key is in OpenSSH format, encrypted when a
+// passphrase is given.
+func SSHKeygen() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req sshkey.GenerateSpec
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": "invalid input"})
+			return
+		}
+		kp, err := sshkey.Generate(req)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(200, kp)
+	}
+}
+
+type SSHKeyReq struct {
+	Key        string `json:"key"` // public key, certificate or private key
+	Passphrase string `json:"passphrase"`
+}
+
+// SSHKeyParse fingerprints a public key or certificate, or the public half of a private key.
+func SSHKeyParse() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req SSHKeyReq
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": "invalid input"})
+			return
+		}
+		if pub, comment, err := sshkey.ParsePublicKey(req.Key); err == nil {
+			c.JSON(200, gin.H{"private": false, "key": sshkey.Describe(pub, comment)})
+			return
+		}
+		if !strings.Contains(req.Key, "PRIVATE KEY-----") {
+			c.JSON(400, gin.H{"error": "not an OpenSSH public key, certificate or private key"})
+			return
+		}
+		signer, err := sshkey.ParsePrivateKey(req.Key, req.Passphrase)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"private": true, "key": sshkey.Describe(signer.PublicKey(), "")})
+	}
+}
+
+type SSHFileReq struct {
+	Text string `json:"text"`
+	Host string `json:"host"` // known_hosts only: report which entries apply to host[:port]
+}
+
+func SSHAuthorizedKeys() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req SSHFileReq
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": "invalid input"})
+			return
+		}
+		entries := sshkey.ParseAuthorizedKeys(req.Text)
+		c.JSON(200, gin.H{"count": len(entries), "entries": entries})
+	}
+}
+
+func SSHKnownHosts() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req SSHFileReq
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": "invalid input"})
+			return
+		}
+		entries := sshkey.ParseKnownHosts(req.Text, strings.TrimSpace(req.Host))
+		c.JSON(200, gin.H{"count": len(entries), "entries": entries})
+	}
+}
+
+type SSHCertReq struct {
+	Cert string `json:"cert"` // e.g. the contents of id_ed25519-cert.pub
+}
+
+func SSHCertParse() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req SSHCertReq
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": "invalid input"})
+			return
+		}
+		cert, comment, err := sshkey.ParseCertificate(req.Cert)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(200, sshkey.Describe(cert, comment))
+	}
+}
+
+type SSHCertSignReq struct {
+	sshkey.CertSpec
+	PublicKey    string `json:"public_key"`
+	CAKey        string `json:"ca_key"` // private key in OpenSSH or PEM format
+	CAPassphrase string `json:"ca_passphrase"`
+}
+
+// SSHCertSign issues a user or host certificate for public_key, like ssh-keygen -s.
+func SSHCertSign() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req SSHCertSignReq
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": "invalid input"})
+			return
+		}
+		pub, comment, err := sshkey.ParsePublicKey(req.PublicKey)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "public_key: " + err.Error()})
+			return
+		}
+		ca, err := sshkey.ParsePrivateKey(req.CAKey, req.CAPassphrase)
+		if err != nil {
+			if !errors.Is(err, sshkey.ErrPassphraseRequired) {
+				err = errors.New("ca_key: " + err.Error())
+			}
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		cert, err := sshkey.Sign(pub, ca, req.CertSpec)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		info := sshkey.Describe(cert, comment)
+		c.JSON(200, gin.H{"certificate": strings.TrimSpace(string(ssh.MarshalAuthorizedKey(cert))), "info": info})
+	}
+}
+
+type SSHScanReq struct {
+	Host string `json:"host"`
+	Port int    `json:"port"` // default 22
+}
+
+// SSHScan reports the server's banner, offered algorithms and every host key with its
+// fingerprints, for checking them out of band before the first connection.
+func SSHScan() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req SSHScanReq
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": "invalid input"})
+			return
+		}
+		if req.Port < 0 || req.Port > 65535 {
+			c.JSON(400, gin.H{"error": "invalid port"})
+			return
+		}
+		res, err := sshinspect.Scan(c.Request.Context(), sshinspect.Options{Host: strings.TrimSpace(req.Host), Port: req.Port})
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(200, res)
+	}
+}
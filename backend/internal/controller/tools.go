@@ -15,6 +15,7 @@ import (
     "net/url"
     "github.com/miekg/dns"
     "regexp"
+    "strconv"
     "strings"
     "time"
 )
@@ -88,6 +89,34 @@ func IPGeo(geo *service.GeoService) gin.HandlerFunc {
     }
 }
 
+// CountryLookup returns ISO 3166-1 metadata for a code or name, or the full list when q is empty
+// GET /api/v1/tools/country?q=CN  |  ?continent=EU&eu=true
+func CountryLookup() gin.HandlerFunc {
+    return func(c *gin.Context) {
+        q := strings.TrimSpace(c.Query("q"))
+        if q != "" {
+            res, ok := service.LookupCountry(q)
+            if !ok { c.JSON(404, gin.H{"error": "country not found"}); return }
+            c.JSON(200, res)
+            return
+        }
+        continent := strings.ToUpper(strings.TrimSpace(c.Query("continent")))
+        var eu *bool
+        if v := strings.TrimSpace(c.Query("eu")); v != "" {
+            b, err := strconv.ParseBool(v)
+            if err != nil { c.JSON(400, gin.H{"error": "eu must be true or false"}); return }
+            eu = &b
+        }
+        out := make([]service.Country, 0)
+        for _, ct := range service.Countries() {
+            if continent != "" && ct.Continent != continent { continue }
+            if eu != nil && ct.EU != *eu { continue }
+            out = append(out, ct)
+        }
+        c.JSON(200, gin.H{"countries": out, "count": len(out)})
+    }
+}
+
 // DNSResolve resolves common DNS record types and returns a normalized answer list
 // GET /api/v1/tools/dns/resolve?name=example.com&type=A
 func DNSResolve() gin.HandlerFunc {
@@ -0,0 +1,36 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCountryLookupEUFilter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/country", CountryLookup())
+	count := func(query string) (int, int) {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", "/country?"+query, nil))
+		var out struct {
+			Count int `json:"count"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &out)
+		return w.Code, out.Count
+	}
+	if code, n := count("eu=true"); code != 200 || n != 27 {
+		t.Errorf("eu=true: status %d, %d countries", code, n)
+	}
+	if code, n := count("eu=1"); code != 200 || n != 27 {
+		t.Errorf("eu=1: status %d, %d countries", code, n)
+	}
+	if code, n := count("continent=eu&eu=FALSE"); code != 200 || n == 0 {
+		t.Errorf("non-EU Europe: status %d, %d countries", code, n)
+	}
+	if code, _ := count("eu=yes"); code != 400 {
+		t.Errorf("eu=yes: status %d", code)
+	}
+}
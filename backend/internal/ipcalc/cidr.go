@@ -0,0 +1,310 @@
+// Package ipcalc implements IPv4/IPv6 address and prefix arithmetic on top of net/netip.
+package ipcalc
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"math/bits"
+	"net/netip"
+	"sort"
+	"strings"
+)
+
+// MaxSubnets bounds how many prefixes Subnet and RangeToCIDRs may return.
+const MaxSubnets = 65536
+
+// ParsePrefix accepts "10.0.0.0/8", a host address with a prefix ("10.1.2.3/8") or a bare
+// address, which is treated as a single-host prefix.
+func ParsePrefix(s string) (netip.Prefix, error) {
+	s = strings.TrimSpace(s)
+	if !strings.Contains(s, "/") {
+		a, err := netip.ParseAddr(s)
+		if err != nil {
+			return netip.Prefix{}, err
+		}
+		a = a.Unmap()
+		return netip.PrefixFrom(a, a.BitLen()), nil
+	}
+	p, err := netip.ParsePrefix(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	if p.Addr().Is4In6() {
+		bits := p.Bits() - 96
+		if bits < 0 {
+			return netip.Prefix{}, fmt.Errorf("%s: prefix too short for an IPv4-mapped address", s)
+		}
+		p = netip.PrefixFrom(p.Addr().Unmap(), bits)
+	}
+	return p, nil
+}
+
+// ParsePrefixes parses and masks a list of prefixes, reporting the first invalid entry.
+func ParsePrefixes(in []string) ([]netip.Prefix, error) {
+	out := make([]netip.Prefix, 0, len(in))
+	for _, s := range in {
+		if strings.TrimSpace(s) == "" {
+			continue
+		}
+		p, err := ParsePrefix(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid prefix %q", s)
+		}
+		out = append(out, p.Masked())
+	}
+	return out, nil
+}
+
+type CIDRInfo struct {
+	Input      string `json:"input"`
+	CIDR       string `json:"cidr"`
+	Version    int    `json:"version"`
+	PrefixLen  int    `json:"prefix_len"`
+	Network    string `json:"network"`
+	Broadcast  string `json:"broadcast,omitempty"`
+	LastAddr   string `json:"last_address"`
+	FirstHost  string `json:"first_host"`
+	LastHost   string `json:"last_host"`
+	Total      string `json:"total"`
+	Usable     string `json:"usable"`
+	Netmask    string `json:"netmask"`
+	Wildcard   string `json:"wildcard"`
+	HostBits   int    `json:"host_bits"`
+	IsHostAddr bool   `json:"is_host_address"`
+}
+
+// Info describes the network a prefix denotes. For IPv4 the network and broadcast
+// addresses are excluded from the host range except for /31 (RFC 3021) and /32; IPv6 has
+// no broadcast so every address is usable.
+func Info(input string) (*CIDRInfo, error) {
+	p, err := ParsePrefix(input)
+	if err != nil {
+		return nil, err
+	}
+	m := p.Masked()
+	is4 := m.Addr().Is4()
+	width := m.Addr().BitLen()
+	hostBits := width - m.Bits()
+	first, last := prefixRange(m)
+	mask := lowMask(width).and(lowMask(hostBits).not())
+	info := &CIDRInfo{
+		Input:      input,
+		CIDR:       m.String(),
+		Version:    6,
+		PrefixLen:  m.Bits(),
+		Network:    m.Addr().String(),
+		LastAddr:   u128ToAddr(last, is4).String(),
+		Total:      pow2(hostBits).String(),
+		Netmask:    u128ToAddr(mask, is4).String(),
+		Wildcard:   u128ToAddr(lowMask(hostBits), is4).String(),
+		HostBits:   hostBits,
+		IsHostAddr: p.Addr() != m.Addr(),
+	}
+	firstHost, lastHost := first, last
+	usable := pow2(hostBits)
+	if is4 {
+		info.Version = 4
+		info.Broadcast = info.LastAddr
+		if hostBits >= 2 {
+			firstHost, lastHost = first.addOne(), last.sub(u128{lo: 1})
+			usable.Sub(usable, big.NewInt(2))
+		}
+	}
+	info.FirstHost = u128ToAddr(firstHost, is4).String()
+	info.LastHost = u128ToAddr(lastHost, is4).String()
+	info.Usable = usable.String()
+	return info, nil
+}
+
+func prefixRange(p netip.Prefix) (first, last u128) {
+	first = addrToU128(p.Addr())
+	last = first.or(lowMask(p.Addr().BitLen() - p.Bits()))
+	return first, last
+}
+
+// Subnet splits p into subnets of length newBits. When count > 0 the length is instead
+// chosen as the smallest split yielding at least count subnets.
+func Subnet(p netip.Prefix, newBits, count int) ([]netip.Prefix, error) {
+	p = p.Masked()
+	width := p.Addr().BitLen()
+	if count > MaxSubnets {
+		return nil, fmt.Errorf("count must be at most %d", MaxSubnets)
+	}
+	if count > 0 {
+		newBits = p.Bits() + bits.Len(uint(count-1))
+	}
+	if newBits < p.Bits() || newBits > width {
+		return nil, fmt.Errorf("new prefix length must be between %d and %d", p.Bits(), width)
+	}
+	if newBits-p.Bits() > 16 {
+		return nil, fmt.Errorf("splitting /%d into /%d yields more than %d subnets", p.Bits(), newBits, MaxSubnets)
+	}
+	n := 1 << (newBits - p.Bits())
+	step := lowMask(width - newBits).addOne()
+	cur := addrToU128(p.Addr())
+	out := make([]netip.Prefix, 0, n)
+	for i := 0; i < n; i++ {
+		out = append(out, netip.PrefixFrom(u128ToAddr(cur, p.Addr().Is4()), newBits))
+		cur = cur.add(step)
+	}
+	return out, nil
+}
+
+// RangeToCIDRs returns the minimal list of prefixes exactly covering [start, end].
+func RangeToCIDRs(start, end netip.Addr) ([]netip.Prefix, error) {
+	start, end = start.Unmap(), end.Unmap()
+	if start.Is4() != end.Is4() {
+		return nil, errors.New("start and end must be the same address family")
+	}
+	if end.Less(start) {
+		return nil, errors.New("end must not be lower than start")
+	}
+	return rangeToCIDRs(addrToU128(start), addrToU128(end), start.Is4(), MaxSubnets)
+}
+
+func rangeToCIDRs(lo, hi u128, is4 bool, limit int) ([]netip.Prefix, error) {
+	width := 128
+	if is4 {
+		width = 32
+	}
+	var out []netip.Prefix
+	for lo.cmp(hi) <= 0 {
+		if len(out) >= limit {
+			return nil, fmt.Errorf("range needs more than %d prefixes", limit)
+		}
+		host := lo.trailingZeros()
+		if host > width {
+			host = width
+		}
+		// shrink until the block fits inside the remaining range
+		span := hi.sub(lo)
+		for host > 0 && lowMask(host).cmp(span) > 0 {
+			host--
+		}
+		out = append(out, netip.PrefixFrom(u128ToAddr(lo, is4), width-host))
+		next := lo.or(lowMask(host))
+		if next.cmp(lowMask(width)) == 0 {
+			break
+		}
+		lo = next.addOne()
+	}
+	return out, nil
+}
+
+type addrRange struct {
+	lo, hi u128
+}
+
+// Aggregate collapses a list of prefixes into the minimal equivalent set, merging
+// duplicates, contained prefixes and adjacent siblings. IPv4 results precede IPv6.
+func Aggregate(in []netip.Prefix) []netip.Prefix {
+	var out []netip.Prefix
+	for _, is4 := range []bool{true, false} {
+		var rs []addrRange
+		for _, p := range in {
+			p = p.Masked()
+			if p.Addr().Is4() != is4 {
+				continue
+			}
+			lo, hi := prefixRange(p)
+			rs = append(rs, addrRange{lo, hi})
+		}
+		for _, r := range mergeRanges(rs) {
+			ps, _ := rangeToCIDRs(r.lo, r.hi, is4, int(^uint(0)>>1))
+			out = append(out, ps...)
+		}
+	}
+	return out
+}
+
+func mergeRanges(rs []addrRange) []addrRange {
+	if len(rs) == 0 {
+		return nil
+	}
+	sort.Slice(rs, func(i, j int) bool { return rs[i].lo.cmp(rs[j].lo) < 0 })
+	merged := []addrRange{rs[0]}
+	for _, r := range rs[1:] {
+		cur := &merged[len(merged)-1]
+		// adjacent ranges merge too, unless cur already ends at the top of the space
+		if r.lo.cmp(cur.hi) <= 0 || (cur.hi.cmp(lowMask(128)) != 0 && r.lo.cmp(cur.hi.addOne()) == 0) {
+			if r.hi.cmp(cur.hi) > 0 {
+				cur.hi = r.hi
+			}
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+type Overlap struct {
+	A      string `json:"a"`
+	B      string `json:"b"`
+	Shared string `json:"shared"`
+	// Relation is "equal", "a_contains_b", "b_contains_a"; prefixes can only nest.
+	Relation string `json:"relation"`
+}
+
+// Overlaps reports every pair (x from a, y from b) that shares addresses. When b is nil the
+// pairs are searched within a itself.
+func Overlaps(a, b []netip.Prefix) []Overlap {
+	out := make([]Overlap, 0)
+	self := b == nil
+	if self {
+		b = a
+	}
+	for i, x := range a {
+		x = x.Masked()
+		for j, y := range b {
+			if self && j <= i {
+				continue
+			}
+			y = y.Masked()
+			if !x.Overlaps(y) {
+				continue
+			}
+			o := Overlap{A: x.String(), B: y.String()}
+			switch {
+			case x.Bits() == y.Bits():
+				o.Relation, o.Shared = "equal", x.String()
+			case x.Bits() < y.Bits():
+				o.Relation, o.Shared = "a_contains_b", y.String()
+			default:
+				o.Relation, o.Shared = "b_contains_a", x.String()
+			}
+			out = append(out, o)
+		}
+	}
+	return out
+}
+
+type Membership struct {
+	IP      string   `json:"ip"`
+	Member  bool     `json:"member"`
+	Matches []string `json:"matches"`
+	Longest string   `json:"longest_match,omitempty"`
+}
+
+// Contains tests each address against the prefix list and reports all matches plus the
+// longest (most specific) one, mirroring route lookup semantics.
+func Contains(prefixes []netip.Prefix, addrs []netip.Addr) []Membership {
+	out := make([]Membership, 0, len(addrs))
+	for _, a := range addrs {
+		a = a.Unmap()
+		m := Membership{IP: a.String(), Matches: []string{}}
+		best := -1
+		for _, p := range prefixes {
+			if p.Contains(a) {
+				m.Matches = append(m.Matches, p.Masked().String())
+				if p.Bits() > best {
+					best = p.Bits()
+					m.Longest = p.Masked().String()
+				}
+			}
+		}
+		m.Member = best >= 0
+		out = append(out, m)
+	}
+	return out
+}
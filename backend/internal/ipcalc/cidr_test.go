@@ -0,0 +1,82 @@
+package ipcalc
+
+import (
+	"math"
+	"net/netip"
+	"testing"
+)
+
+func TestInfo(t *testing.T) {
+	i, err := Info("192.168.1.77/26")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i.Network != "192.168.1.64" || i.Broadcast != "192.168.1.127" || i.FirstHost != "192.168.1.65" || i.Usable != "62" || i.Netmask != "255.255.255.192" || i.Wildcard != "0.0.0.63" || !i.IsHostAddr {
+		t.Fatalf("unexpected v4 info: %+v", i)
+	}
+	i, err = Info("2001:db8::/126")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i.LastHost != "2001:db8::3" || i.Total != "4" || i.Usable != "4" || i.Netmask != "ffff:ffff:ffff:ffff:ffff:ffff:ffff:fffc" {
+		t.Fatalf("unexpected v6 info: %+v", i)
+	}
+	if i, _ := Info("::/0"); i.Total != "340282366920938463463374607431768211456" {
+		t.Fatalf("unexpected ::/0 total %s", i.Total)
+	}
+}
+
+func TestSubnet(t *testing.T) {
+	ps, err := Subnet(netip.MustParsePrefix("10.0.0.0/24"), 0, 3)
+	if err != nil || len(ps) != 4 || ps[3].String() != "10.0.0.192/26" {
+		t.Fatalf("unexpected subnets %v %v", ps, err)
+	}
+	ps, err = Subnet(netip.MustParsePrefix("2001:db8::/32"), 34, 0)
+	if err != nil || len(ps) != 4 || ps[1].String() != "2001:db8:4000::/34" {
+		t.Fatalf("unexpected v6 subnets %v %v", ps, err)
+	}
+	ps, err = Subnet(netip.MustParsePrefix("10.0.0.0/8"), 0, MaxSubnets)
+	if err != nil || len(ps) != MaxSubnets || ps[0].Bits() != 24 {
+		t.Fatalf("max subnets: %d %v", len(ps), err)
+	}
+	for _, count := range []int{MaxSubnets + 1, 1<<62 + 1, math.MaxInt} {
+		if _, err := Subnet(netip.MustParsePrefix("2001:db8::/32"), 0, count); err == nil {
+			t.Errorf("count %d accepted", count)
+		}
+	}
+}
+
+func TestAggregateAndRange(t *testing.T) {
+	in, _ := ParsePrefixes([]string{"10.0.0.0/25", "10.0.0.128/25", "10.0.1.0/24", "10.0.0.5", "192.168.0.0/16", "2001:db8::/33", "2001:db8:8000::/33"})
+	got := Aggregate(in)
+	want := []string{"10.0.0.0/23", "192.168.0.0/16", "2001:db8::/32"}
+	if len(got) != len(want) {
+		t.Fatalf("aggregate: %v", got)
+	}
+	for i := range want {
+		if got[i].String() != want[i] {
+			t.Fatalf("aggregate: %v", got)
+		}
+	}
+	rs, err := RangeToCIDRs(netip.MustParseAddr("10.0.0.1"), netip.MustParseAddr("10.0.0.10"))
+	if err != nil || len(rs) != 5 || rs[2].String() != "10.0.0.4/30" || rs[4].String() != "10.0.0.10/32" {
+		t.Fatalf("range: %v %v", rs, err)
+	}
+	rs, _ = RangeToCIDRs(netip.MustParseAddr("0.0.0.0"), netip.MustParseAddr("255.255.255.255"))
+	if len(rs) != 1 || rs[0].String() != "0.0.0.0/0" {
+		t.Fatalf("full range: %v", rs)
+	}
+}
+
+func TestOverlapsAndContains(t *testing.T) {
+	a, _ := ParsePrefixes([]string{"10.0.0.0/8", "172.16.0.0/12"})
+	b, _ := ParsePrefixes([]string{"10.1.0.0/16", "192.168.0.0/16"})
+	ov := Overlaps(a, b)
+	if len(ov) != 1 || ov[0].Relation != "a_contains_b" {
+		t.Fatalf("overlaps: %+v", ov)
+	}
+	m := Contains(append(a, b...), []netip.Addr{netip.MustParseAddr("10.1.2.3"), netip.MustParseAddr("8.8.8.8")})
+	if !m[0].Member || m[0].Longest != "10.1.0.0/16" || m[1].Member {
+		t.Fatalf("contains: %+v", m)
+	}
+}
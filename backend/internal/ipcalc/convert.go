@@ -0,0 +1,132 @@
+package ipcalc
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// ParseAny parses an address written in any of the notations Convert produces: dotted
+// or colon notation, a decimal integer, 0x-prefixed hex, or binary (dotted octets or a
+// plain 32/128 digit string).
+func ParseAny(s string) (netip.Addr, error) {
+	s = strings.TrimSpace(s)
+	if a, err := netip.ParseAddr(s); err == nil {
+		return a, nil
+	}
+	digits := strings.ReplaceAll(s, ".", "")
+	if strings.Trim(digits, "01") == "" && (len(digits) == 32 || len(digits) == 128) {
+		return fromBig(mustBig(digits, 2), len(digits) == 32)
+	}
+	low := strings.ToLower(s)
+	if strings.HasPrefix(low, "0x") {
+		h := strings.ReplaceAll(low[2:], ":", "")
+		n, ok := new(big.Int).SetString(h, 16)
+		if !ok {
+			return netip.Addr{}, errors.New("invalid hex address")
+		}
+		return fromBig(n, len(h) <= 8)
+	}
+	if n, ok := new(big.Int).SetString(s, 10); ok {
+		return fromBig(n, n.BitLen() <= 32)
+	}
+	return netip.Addr{}, fmt.Errorf("unrecognized address %q", s)
+}
+
+func mustBig(s string, base int) *big.Int {
+	n, _ := new(big.Int).SetString(s, base)
+	return n
+}
+
+func fromBig(n *big.Int, is4 bool) (netip.Addr, error) {
+	if n.Sign() < 0 || n.BitLen() > 128 {
+		return netip.Addr{}, errors.New("address out of range")
+	}
+	var b [16]byte
+	n.FillBytes(b[:])
+	if is4 {
+		return netip.AddrFrom4([4]byte{b[12], b[13], b[14], b[15]}), nil
+	}
+	return netip.AddrFrom16(b), nil
+}
+
+type Conversions struct {
+	Input      string `json:"input"`
+	Version    int    `json:"version"`
+	Compressed string `json:"compressed"`
+	Expanded   string `json:"expanded"`
+	Integer    string `json:"integer"`
+	Hex        string `json:"hex"`
+	Binary     string `json:"binary"`
+	Octal      string `json:"octal,omitempty"`
+	IPv4Mapped string `json:"ipv4_mapped,omitempty"`
+	IPv4       string `json:"ipv4,omitempty"`
+	SixToFour  string `json:"6to4_prefix,omitempty"`
+	NAT64      string `json:"nat64,omitempty"`
+	ReverseDNS string `json:"reverse_dns"`
+}
+
+// Convert renders an address in every common notation.
+func Convert(input string) (*Conversions, error) {
+	a, err := ParseAny(input)
+	if err != nil {
+		return nil, err
+	}
+	a = a.WithZone("")
+	out := &Conversions{Input: input, Version: 6, ReverseDNS: reverseName(a)}
+	n := new(big.Int).SetBytes(a.AsSlice())
+	out.Integer = n.String()
+	if a.Is4() {
+		b := a.As4()
+		out.Version = 4
+		out.Compressed = a.String()
+		out.Expanded = fmt.Sprintf("%03d.%03d.%03d.%03d", b[0], b[1], b[2], b[3])
+		out.Hex = fmt.Sprintf("0x%08x", binary.BigEndian.Uint32(b[:]))
+		out.Binary = fmt.Sprintf("%08b.%08b.%08b.%08b", b[0], b[1], b[2], b[3])
+		out.Octal = fmt.Sprintf("0%o.0%o.0%o.0%o", b[0], b[1], b[2], b[3])
+		out.IPv4Mapped = netip.AddrFrom16(a.As16()).String()
+		out.SixToFour = netip.PrefixFrom(netip.AddrFrom16([16]byte{0x20, 0x02, b[0], b[1], b[2], b[3]}), 48).String()
+		out.NAT64 = netip.AddrFrom16([16]byte{0, 0x64, 0xff, 0x9b, 12: b[0], b[1], b[2], b[3]}).String()
+		return out, nil
+	}
+	b := a.As16()
+	out.Compressed = a.String()
+	out.Expanded = a.StringExpanded()
+	out.Hex = "0x" + fmt.Sprintf("%032x", n)
+	groups := make([]string, 8)
+	for i := range groups {
+		groups[i] = fmt.Sprintf("%016b", binary.BigEndian.Uint16(b[i*2:]))
+	}
+	out.Binary = strings.Join(groups, ":")
+	if e := embeddedIPv4(a); e != nil {
+		out.IPv4 = e.IPv4
+	}
+	return out, nil
+}
+
+func reverseName(a netip.Addr) string {
+	var sb strings.Builder
+	if a.Is4() {
+		b := a.As4()
+		for i := 3; i >= 0; i-- {
+			sb.WriteString(strconv.Itoa(int(b[i])))
+			sb.WriteByte('.')
+		}
+		sb.WriteString("in-addr.arpa.")
+		return sb.String()
+	}
+	b := a.As16()
+	const hexd = "0123456789abcdef"
+	for i := 15; i >= 0; i-- {
+		sb.WriteByte(hexd[b[i]&0xf])
+		sb.WriteByte('.')
+		sb.WriteByte(hexd[b[i]>>4])
+		sb.WriteByte('.')
+	}
+	sb.WriteString("ip6.arpa.")
+	return sb.String()
+}
@@ -0,0 +1,236 @@
+package ipcalc
+
+import (
+	"encoding/binary"
+	"net/netip"
+	"sort"
+)
+
+// SpecialEntry is one row of the IANA IPv4/IPv6 Special-Purpose Address Registries
+// (RFC 6890), plus the multicast blocks which live in their own registries.
+type SpecialEntry struct {
+	Prefix     string `json:"prefix"`
+	Name       string `json:"name"`
+	Category   string `json:"category"`
+	RFC        string `json:"rfc"`
+	Source     bool   `json:"source"`
+	Dest       bool   `json:"destination"`
+	Forwarding bool   `json:"forwardable"`
+	Global     bool   `json:"globally_reachable"`
+	Reserved   bool   `json:"reserved_by_protocol"`
+
+	prefix netip.Prefix
+}
+
+func special(prefix, name, category, rfc string, src, dst, fwd, global, reserved bool) SpecialEntry {
+	return SpecialEntry{Prefix: prefix, Name: name, Category: category, RFC: rfc, Source: src, Dest: dst, Forwarding: fwd, Global: global, Reserved: reserved, prefix: netip.MustParsePrefix(prefix)}
+}
+
+var specialRegistry = []SpecialEntry{
+	// IPv4, https://www.iana.org/assignments/iana-ipv4-special-registry
+	special("0.0.0.0/8", "This network", "reserved", "RFC 791", true, false, false, false, true),
+	special("0.0.0.0/32", "This host on this network", "unspecified", "RFC 1122", true, false, false, false, true),
+	special("10.0.0.0/8", "Private-Use", "private", "RFC 1918", true, true, true, false, false),
+	special("100.64.0.0/10", "Shared Address Space", "cgnat", "RFC 6598", true, true, true, false, false),
+	special("127.0.0.0/8", "Loopback", "loopback", "RFC 1122", false, false, false, false, true),
+	special("169.254.0.0/16", "Link Local", "link-local", "RFC 3927", true, true, false, false, true),
+	special("172.16.0.0/12", "Private-Use", "private", "RFC 1918", true, true, true, false, false),
+	special("192.0.0.0/24", "IETF Protocol Assignments", "reserved", "RFC 6890", false, false, false, false, false),
+	special("192.0.0.0/29", "IPv4 Service Continuity Prefix", "reserved", "RFC 7335", true, true, true, false, false),
+	special("192.0.0.8/32", "IPv4 dummy address", "reserved", "RFC 7600", true, false, false, false, false),
+	special("192.0.0.9/32", "Port Control Protocol Anycast", "anycast", "RFC 7723", true, true, true, true, false),
+	special("192.0.0.10/32", "Traversal Using Relays around NAT Anycast", "anycast", "RFC 8155", true, true, true, true, false),
+	special("192.0.0.170/32", "NAT64/DNS64 Discovery", "reserved", "RFC 8880", false, false, false, false, true),
+	special("192.0.0.171/32", "NAT64/DNS64 Discovery", "reserved", "RFC 8880", false, false, false, false, true),
+	special("192.0.2.0/24", "Documentation (TEST-NET-1)", "documentation", "RFC 5737", false, false, false, false, false),
+	special("192.31.196.0/24", "AS112-v4", "anycast", "RFC 7535", true, true, true, true, false),
+	special("192.52.193.0/24", "AMT", "anycast", "RFC 7450", true, true, true, true, false),
+	special("192.88.99.0/24", "Deprecated (6to4 Relay Anycast)", "reserved", "RFC 7526", false, false, false, false, false),
+	special("192.88.99.2/32", "6a44-relay anycast address", "anycast", "RFC 6751", true, true, true, false, false),
+	special("192.168.0.0/16", "Private-Use", "private", "RFC 1918", true, true, true, false, false),
+	special("192.175.48.0/24", "Direct Delegation AS112 Service", "anycast", "RFC 7534", true, true, true, true, false),
+	special("198.18.0.0/15", "Benchmarking", "benchmarking", "RFC 2544", true, true, true, false, false),
+	special("198.51.100.0/24", "Documentation (TEST-NET-2)", "documentation", "RFC 5737", false, false, false, false, false),
+	special("203.0.113.0/24", "Documentation (TEST-NET-3)", "documentation", "RFC 5737", false, false, false, false, false),
+	special("224.0.0.0/4", "Multicast", "multicast", "RFC 5771", false, true, true, true, false),
+	special("224.0.0.0/24", "Local Network Control Block", "multicast", "RFC 5771", false, true, false, false, false),
+	special("233.252.0.0/24", "MCAST-TEST-NET", "documentation", "RFC 6676", false, false, false, false, false),
+	special("239.0.0.0/8", "Administratively Scoped Block", "multicast", "RFC 2365", false, true, true, false, false),
+	special("240.0.0.0/4", "Reserved", "reserved", "RFC 1112", false, false, false, false, true),
+	special("255.255.255.255/32", "Limited Broadcast", "broadcast", "RFC 919", false, true, false, false, true),
+
+	// IPv6, https://www.iana.org/assignments/iana-ipv6-special-registry
+	special("::/128", "Unspecified Address", "unspecified", "RFC 4291", true, false, false, false, true),
+	special("::1/128", "Loopback Address", "loopback", "RFC 4291", false, false, false, false, true),
+	special("::ffff:0:0/96", "IPv4-mapped Address", "ipv4-mapped", "RFC 4291", false, false, false, false, true),
+	special("64:ff9b::/96", "IPv4-IPv6 Translat.", "nat64", "RFC 6052", true, true, true, true, false),
+	special("64:ff9b:1::/48", "IPv4-IPv6 Translat.", "nat64", "RFC 8215", true, true, true, false, false),
+	special("100::/64", "Discard-Only Address Block", "reserved", "RFC 6666", true, true, true, false, false),
+	special("100:0:0:1::/64", "Dummy IPv6 Prefix", "reserved", "RFC 9780", true, false, false, false, false),
+	special("2001::/23", "IETF Protocol Assignments", "reserved", "RFC 2928", false, false, false, false, false),
+	special("2001::/32", "TEREDO", "teredo", "RFC 4380", true, true, true, true, false),
+	special("2001:1::1/128", "Port Control Protocol Anycast", "anycast", "RFC 7723", true, true, true, true, false),
+	special("2001:1::2/128", "Traversal Using Relays around NAT Anycast", "anycast", "RFC 8155", true, true, true, true, false),
+	special("2001:1::3/128", "DNS-SD Service Registration Protocol Anycast", "anycast", "RFC 9665", true, true, true, true, false),
+	special("2001:2::/48", "Benchmarking", "benchmarking", "RFC 5180", true, true, true, false, false),
+	special("2001:3::/32", "AMT", "anycast", "RFC 7450", true, true, true, true, false),
+	special("2001:4:112::/48", "AS112-v6", "anycast", "RFC 7535", true, true, true, true, false),
+	special("2001:10::/28", "Deprecated (previously ORCHID)", "reserved", "RFC 4843", false, false, false, false, false),
+	special("2001:20::/28", "ORCHIDv2", "reserved", "RFC 7343", true, true, true, true, false),
+	special("2001:30::/28", "Drone Remote ID Protocol Entity Tags (DETs) Prefix", "reserved", "RFC 9374", true, true, true, true, false),
+	special("2001:db8::/32", "Documentation", "documentation", "RFC 3849", false, false, false, false, false),
+	special("2002::/16", "6to4", "6to4", "RFC 3056", true, true, true, true, false),
+	special("2620:4f:8000::/48", "Direct Delegation AS112 Service", "anycast", "RFC 7534", true, true, true, true, false),
+	special("3fff::/20", "Documentation", "documentation", "RFC 9637", false, false, false, false, false),
+	special("5f00::/16", "Segment Routing (SRv6) SIDs", "reserved", "RFC 9602", true, true, true, false, false),
+	special("fc00::/7", "Unique-Local", "private", "RFC 4193", true, true, true, false, false),
+	special("fe80::/10", "Link-Local Unicast", "link-local", "RFC 4291", true, true, false, false, true),
+	special("ff00::/8", "Multicast", "multicast", "RFC 4291", false, true, true, true, false),
+}
+
+type Embedded struct {
+	Type string `json:"type"` // teredo/6to4/nat64/ipv4-mapped/ipv4-compatible/isatap
+	IPv4 string `json:"ipv4"`
+	// Teredo only
+	Server string `json:"server,omitempty"`
+	Port   int    `json:"port,omitempty"`
+	Flags  string `json:"flags,omitempty"`
+	Cone   bool   `json:"cone,omitempty"`
+}
+
+type Classification struct {
+	IP            string         `json:"ip"`
+	Version       int            `json:"version"`
+	Category      string         `json:"category"`
+	Global        bool           `json:"globally_reachable"`
+	Bogon         bool           `json:"bogon"`
+	Private       bool           `json:"private"`
+	Loopback      bool           `json:"loopback"`
+	LinkLocal     bool           `json:"link_local"`
+	Multicast     bool           `json:"multicast"`
+	CGNAT         bool           `json:"cgnat"`
+	Documentation bool           `json:"documentation"`
+	Reserved      bool           `json:"reserved"`
+	Matches       []SpecialEntry `json:"registry"`
+	Embedded      *Embedded      `json:"embedded,omitempty"`
+}
+
+// Classify looks an address up in the special-purpose registries. Matches are ordered most
+// specific first and the most specific entry decides the category and reachability. An
+// address matching no entry is a public (global unicast) address.
+func Classify(a netip.Addr) *Classification {
+	a = a.WithZone("")
+	orig := a
+	if a.Is4In6() {
+		a = a.Unmap()
+	}
+	c := &Classification{IP: orig.String(), Version: 6, Category: "public", Global: true, Matches: []SpecialEntry{}}
+	if orig.Is4() {
+		c.Version = 4
+	}
+	for _, e := range specialRegistry {
+		if e.prefix.Contains(orig) || (orig != a && e.prefix.Contains(a)) {
+			c.Matches = append(c.Matches, e)
+		}
+	}
+	// rank IPv4 rows as if they were IPv4-mapped so both families sort on one scale
+	rank := func(e SpecialEntry) int {
+		if e.prefix.Addr().Is4() {
+			return e.prefix.Bits() + 96
+		}
+		return e.prefix.Bits()
+	}
+	sort.SliceStable(c.Matches, func(i, j int) bool { return rank(c.Matches[i]) > rank(c.Matches[j]) })
+	if len(c.Matches) > 0 {
+		best := c.Matches[0]
+		c.Category, c.Global = best.Category, best.Global
+	}
+	for _, e := range c.Matches {
+		switch e.Category {
+		case "private":
+			c.Private = true
+		case "loopback":
+			c.Loopback = true
+		case "link-local":
+			c.LinkLocal = true
+		case "multicast":
+			c.Multicast = true
+		case "cgnat":
+			c.CGNAT = true
+		case "documentation":
+			c.Documentation = true
+		case "reserved", "unspecified", "broadcast", "benchmarking":
+			c.Reserved = true
+		}
+	}
+	c.Embedded = embeddedIPv4(orig)
+	// translation prefixes are reachable only if the embedded IPv4 address is
+	if c.Embedded != nil && orig.Is6() && c.Global {
+		if inner, err := netip.ParseAddr(c.Embedded.IPv4); err == nil && c.Embedded.Type != "isatap" {
+			c.Global = Classify(inner).Global
+		}
+	}
+	c.Bogon = !c.Global || c.Multicast
+	return c
+}
+
+var (
+	teredoPrefix = netip.MustParsePrefix("2001::/32")
+	sixToFour    = netip.MustParsePrefix("2002::/16")
+	nat64WKP     = netip.MustParsePrefix("64:ff9b::/96")
+	nat64Local   = netip.MustParsePrefix("64:ff9b:1::/48")
+)
+
+// embeddedIPv4 extracts an IPv4 address carried inside an IPv6 address by a transition
+// mechanism. Local-use NAT64 prefixes are assumed to use the /96 layout of RFC 6052.
+func embeddedIPv4(a netip.Addr) *Embedded {
+	if !a.Is6() {
+		return nil
+	}
+	b := a.As16()
+	v4 := func(off int) string { return netip.AddrFrom4([4]byte{b[off], b[off+1], b[off+2], b[off+3]}).String() }
+	switch {
+	case a.Is4In6():
+		return &Embedded{Type: "ipv4-mapped", IPv4: v4(12)}
+	case teredoPrefix.Contains(a):
+		flags := binary.BigEndian.Uint16(b[8:10])
+		client := [4]byte{b[12] ^ 0xff, b[13] ^ 0xff, b[14] ^ 0xff, b[15] ^ 0xff}
+		return &Embedded{
+			Type:   "teredo",
+			IPv4:   netip.AddrFrom4(client).String(),
+			Server: v4(4),
+			Port:   int(binary.BigEndian.Uint16(b[10:12]) ^ 0xffff),
+			Flags:  "0x" + hex4(flags),
+			Cone:   flags&0x8000 != 0,
+		}
+	case sixToFour.Contains(a):
+		return &Embedded{Type: "6to4", IPv4: v4(2)}
+	case nat64WKP.Contains(a), nat64Local.Contains(a):
+		return &Embedded{Type: "nat64", IPv4: v4(12)}
+	case isZero(b[:12]) && !isZero(b[12:14]):
+		// ::a.b.c.d, deprecated by RFC 4291; skip :: and ::1 style addresses
+		return &Embedded{Type: "ipv4-compatible", IPv4: v4(12)}
+	case (b[8]|0x02) == 0x02 && b[9] == 0 && b[10] == 0x5e && b[11] == 0xfe:
+		return &Embedded{Type: "isatap", IPv4: v4(12)}
+	}
+	return nil
+}
+
+func isZero(b []byte) bool {
+	for _, x := range b {
+		if x != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func hex4(v uint16) string {
+	const digits = "0123456789abcdef"
+	return string([]byte{digits[v>>12], digits[v>>8&0xf], digits[v>>4&0xf], digits[v&0xf]})
+}
+
+// SpecialRegistry returns a copy of the bundled registry rows.
+func SpecialRegistry() []SpecialEntry {
+	return append([]SpecialEntry(nil), specialRegistry...)
+}
@@ -0,0 +1,61 @@
+package ipcalc
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestClassify(t *testing.T) {
+	cases := []struct {
+		ip, category string
+		global       bool
+	}{
+		{"10.0.0.1", "private", false},
+		{"100.64.1.1", "cgnat", false},
+		{"127.0.0.1", "loopback", false},
+		{"192.0.2.10", "documentation", false},
+		{"192.0.0.9", "anycast", true},
+		{"8.8.8.8", "public", true},
+		{"fe80::1", "link-local", false},
+		{"fd00::1", "private", false},
+		{"::ffff:192.168.1.1", "private", false},
+		{"2606:4700::1111", "public", true},
+	}
+	for _, tc := range cases {
+		c := Classify(netip.MustParseAddr(tc.ip))
+		if c.Category != tc.category || c.Global != tc.global {
+			t.Errorf("%s: got %s/%v, want %s/%v", tc.ip, c.Category, c.Global, tc.category, tc.global)
+		}
+	}
+}
+
+func TestEmbeddedIPv4(t *testing.T) {
+	// RFC 4380 example: server 65.54.227.120, client 192.0.2.45:40000, cone
+	c := Classify(netip.MustParseAddr("2001:0:4136:e378:8000:63bf:3fff:fdd2"))
+	e := c.Embedded
+	if e == nil || e.Type != "teredo" || e.Server != "65.54.227.120" || e.IPv4 != "192.0.2.45" || e.Port != 40000 || !e.Cone {
+		t.Fatalf("teredo: %+v", e)
+	}
+	if c.Global {
+		t.Fatal("teredo client in documentation space should not be global")
+	}
+	if e := Classify(netip.MustParseAddr("2002:c000:0204::1")).Embedded; e == nil || e.IPv4 != "192.0.2.4" {
+		t.Fatalf("6to4: %+v", e)
+	}
+	if e := Classify(netip.MustParseAddr("64:ff9b::808:808")).Embedded; e == nil || e.Type != "nat64" || e.IPv4 != "8.8.8.8" {
+		t.Fatalf("nat64: %+v", e)
+	}
+}
+
+func TestConvert(t *testing.T) {
+	for _, in := range []string{"192.168.1.1", "3232235777", "0xc0a80101", "11000000.10101000.00000001.00000001"} {
+		c, err := Convert(in)
+		if err != nil || c.Compressed != "192.168.1.1" || c.Integer != "3232235777" || c.IPv4Mapped != "::ffff:192.168.1.1" {
+			t.Fatalf("%s: %+v %v", in, c, err)
+		}
+	}
+	c, err := Convert("2001:db8::1")
+	if err != nil || c.Expanded != "2001:0db8:0000:0000:0000:0000:0000:0001" || c.ReverseDNS[:4] != "1.0." {
+		t.Fatalf("v6: %+v %v", c, err)
+	}
+}
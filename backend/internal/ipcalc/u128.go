@@ -0,0 +1,97 @@
+package ipcalc
+
+import (
+	"encoding/binary"
+	"math/big"
+	"math/bits"
+	"net/netip"
+)
+
+// u128 is a 128-bit unsigned integer used for address arithmetic. IPv4 addresses occupy
+// the low 32 bits so both families share the same code paths.
+type u128 struct {
+	hi, lo uint64
+}
+
+func addrToU128(a netip.Addr) u128 {
+	if a.Is4() {
+		b := a.As4()
+		return u128{lo: uint64(binary.BigEndian.Uint32(b[:]))}
+	}
+	b := a.As16()
+	return u128{hi: binary.BigEndian.Uint64(b[:8]), lo: binary.BigEndian.Uint64(b[8:])}
+}
+
+func u128ToAddr(u u128, is4 bool) netip.Addr {
+	if is4 {
+		var b [4]byte
+		binary.BigEndian.PutUint32(b[:], uint32(u.lo))
+		return netip.AddrFrom4(b)
+	}
+	var b [16]byte
+	binary.BigEndian.PutUint64(b[:8], u.hi)
+	binary.BigEndian.PutUint64(b[8:], u.lo)
+	return netip.AddrFrom16(b)
+}
+
+func (u u128) cmp(v u128) int {
+	switch {
+	case u.hi < v.hi:
+		return -1
+	case u.hi > v.hi:
+		return 1
+	case u.lo < v.lo:
+		return -1
+	case u.lo > v.lo:
+		return 1
+	}
+	return 0
+}
+
+func (u u128) add(v u128) u128 {
+	lo, carry := bits.Add64(u.lo, v.lo, 0)
+	hi, _ := bits.Add64(u.hi, v.hi, carry)
+	return u128{hi, lo}
+}
+
+func (u u128) sub(v u128) u128 {
+	lo, borrow := bits.Sub64(u.lo, v.lo, 0)
+	hi, _ := bits.Sub64(u.hi, v.hi, borrow)
+	return u128{hi, lo}
+}
+
+func (u u128) addOne() u128 { return u.add(u128{lo: 1}) }
+
+func (u u128) and(v u128) u128 { return u128{u.hi & v.hi, u.lo & v.lo} }
+
+func (u u128) or(v u128) u128 { return u128{u.hi | v.hi, u.lo | v.lo} }
+
+func (u u128) not() u128 { return u128{^u.hi, ^u.lo} }
+
+func (u u128) trailingZeros() int {
+	if u.lo != 0 {
+		return bits.TrailingZeros64(u.lo)
+	}
+	if u.hi != 0 {
+		return 64 + bits.TrailingZeros64(u.hi)
+	}
+	return 128
+}
+
+// lowMask returns a value with the n least significant bits set.
+func lowMask(n int) u128 {
+	switch {
+	case n <= 0:
+		return u128{}
+	case n >= 128:
+		return u128{^uint64(0), ^uint64(0)}
+	case n >= 64:
+		return u128{hi: (uint64(1) << (n - 64)) - 1, lo: ^uint64(0)}
+	}
+	return u128{lo: (uint64(1) << n) - 1}
+}
+
+// pow2 returns 2^n as a big.Int; n may be 128 for the whole IPv6 space.
+func pow2(n int) *big.Int {
+	return new(big.Int).Lsh(big.NewInt(1), uint(n))
+}
@@ -0,0 +1,476 @@
+// Package pgp inspects OpenPGP keys and encrypts, decrypts, signs and verifies armored
+// messages.
+//
+// Everything uses github.com/ProtonMail/go-crypto/openpgp, which implements RSA, DSA,
+// ElGamal, ECDSA, ECDH and EdDSA, including gpg's ed25519 and cv25519 keys, as well as
+// the RFC 9580 Ed25519, X25519, Ed448 and X448 keys. Inspection also decodes the packets
+// itself, for the key details and for keys the library cannot load, which it reports as
+// unverified.
+package pgp
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+)
+
+// Key is a primary key or subkey. Expiry, capabilities and revocation come from the
+// newest self-signature, which is only trusted as far as Certificate.Verified says.
+type Key struct {
+	Fingerprint string     `json:"fingerprint"`
+	KeyID       string     `json:"key_id"`
+	Version     int        `json:"version"`
+	Algorithm   string     `json:"algorithm"`
+	Bits        int        `json:"bits,omitempty"`
+	Curve       string     `json:"curve,omitempty"`
+	Created     time.Time  `json:"created"`
+	Expires     *time.Time `json:"expires,omitempty"`
+	Expired     bool       `json:"expired"`
+	Revoked     bool       `json:"revoked"`
+	// Capabilities lists certify, sign, encrypt and authenticate; Usage is the same in
+	// gpg's letters, e.g. SC.
+	Capabilities []string `json:"capabilities"`
+	Usage        string   `json:"usage"`
+	// Secret is how a secret key block holds this key: plain, encrypted, stub (secret
+	// part removed, e.g. an offline primary key) or card.
+	Secret string `json:"secret,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+type UserID struct {
+	ID      string `json:"id"`
+	Name    string `json:"name,omitempty"`
+	Email   string `json:"email,omitempty"`
+	Comment string `json:"comment,omitempty"`
+	Primary bool   `json:"primary"`
+	Revoked bool   `json:"revoked"`
+	// Hash is the digest algorithm of the newest self-signature.
+	Hash string `json:"hash,omitempty"`
+	// Certifications counts signatures on this user ID by other keys.
+	Certifications int `json:"certifications"`
+}
+
+// Certificate is one transferable public or secret key. Verified says the openpgp library
+// checked its self-signatures, binding signatures and revocations. Otherwise user IDs,
+// capabilities and expiry are only what the packets claim, as with gpg --list-packets.
+type Certificate struct {
+	Secret     bool     `json:"secret"`
+	Verified   bool     `json:"verified"`
+	Primary    Key      `json:"primary"`
+	UserIDs    []UserID `json:"user_ids"`
+	Subkeys    []Key    `json:"subkeys"`
+	Attributes int      `json:"attributes,omitempty"` // photo IDs
+	Warnings   []string `json:"warnings"`
+}
+
+// Inspect lists the keys in every armored key block of text.
+func Inspect(text string) ([]Certificate, error) {
+	blocks, err := decodeArmor(text)
+	if err != nil {
+		return nil, err
+	}
+	var certs []Certificate
+	for _, b := range blocks {
+		if b.Type != "PGP PUBLIC KEY BLOCK" && b.Type != "PGP PRIVATE KEY BLOCK" {
+			return nil, fmt.Errorf("expected a key block, got %s", b.Type)
+		}
+		packets, err := readPackets(b.Body)
+		if err != nil && len(packets) == 0 {
+			return nil, err
+		}
+		// the library leaves out any key with a bad self-signature, binding or revocation
+		verified, _ := openpgp.ReadKeyRing(bytes.NewReader(b.Body))
+		for _, cs := range splitCerts(packets) {
+			certs = append(certs, cs.certificate(verified, time.Now()))
+		}
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("no keys found")
+	}
+	return certs, nil
+}
+
+// keyState gathers the signatures on a key or user ID until they are evaluated.
+type keyState struct {
+	m    keyMaterial
+	sigs []*sigInfo
+}
+
+type uidState struct {
+	id   string
+	sigs []*sigInfo
+	// others counts certifications by other keys that are not in sigs
+	others int
+}
+
+// certState is the packets of one transferable key.
+type certState struct {
+	primary *keyState
+	uids    []*uidState
+	subs    []*keyState
+	attrs   int
+}
+
+func splitCerts(packets []rawPacket) []*certState {
+	var certs []*certState
+	var c *certState
+	// sigs is where the next signature packet belongs
+	var sigs *[]*sigInfo
+	for _, p := range packets {
+		if c == nil && p.tag != tagPublicKey && p.tag != tagSecretKey {
+			continue
+		}
+		switch p.tag {
+		case tagPublicKey, tagSecretKey:
+			c = &certState{primary: &keyState{m: parseKey(p.body, p.tag == tagSecretKey)}}
+			certs = append(certs, c)
+			sigs = &c.primary.sigs
+		case tagPublicSubkey, tagSecretSubkey:
+			s := &keyState{m: parseKey(p.body, p.tag == tagSecretSubkey)}
+			c.subs = append(c.subs, s)
+			sigs = &s.sigs
+		case tagUserID:
+			u := &uidState{id: string(p.body)}
+			c.uids = append(c.uids, u)
+			sigs = &u.sigs
+		case tagUserAttr:
+			c.attrs++
+			sigs = nil
+		case tagSignature:
+			if sigs == nil {
+				continue
+			}
+			if s, err := parseSignature(p.body); err == nil {
+				*sigs = append(*sigs, s)
+			}
+		}
+	}
+	return certs
+}
+
+// keys returns the primary key and the subkeys.
+func (c *certState) keys() []keyMaterial {
+	keys := []keyMaterial{c.primary.m}
+	for _, s := range c.subs {
+		keys = append(keys, s.m)
+	}
+	return keys
+}
+
+// certificate describes c from the signatures the library verified when el holds it, and
+// from its packets otherwise.
+func (c *certState) certificate(el openpgp.EntityList, now time.Time) Certificate {
+	for _, e := range el {
+		if !bytes.Equal(e.PrimaryKey.Fingerprint, c.primary.m.fpr) {
+			continue
+		}
+		cert := buildCertificate(c.verified(e), now)
+		cert.Verified = true
+		for _, u := range c.uids {
+			if _, ok := e.Identities[u.id]; !ok {
+				cert.Warnings = append(cert.Warnings, fmt.Sprintf("user ID %q has no self-signature and is ignored", u.id))
+			}
+		}
+		return cert
+	}
+	cert := buildCertificate(c, now)
+	cert.Warnings = append(cert.Warnings, "self-signatures could not be verified; user IDs, capabilities and expiry are as the key claims them")
+	return cert
+}
+
+// verified rebuilds c with only the signatures the library kept in e, all of which it
+// checked when it read the key.
+func (c *certState) verified(e *openpgp.Entity) *certState {
+	pm := c.primary.m
+	v := &certState{primary: &keyState{m: pm}, attrs: c.attrs}
+	for _, s := range e.Revocations {
+		v.primary.sigs = append(v.primary.sigs, verifiedSig(s, pm))
+	}
+	if e.SelfSignature != nil {
+		v.primary.sigs = append(v.primary.sigs, verifiedSig(e.SelfSignature, pm))
+	}
+	seen := map[string]bool{}
+	for _, u := range c.uids {
+		id, ok := e.Identities[u.id]
+		if !ok || seen[u.id] {
+			continue
+		}
+		seen[u.id] = true
+		vu := &uidState{id: u.id}
+		for _, s := range id.Signatures {
+			switch {
+			case s.CheckKeyIdOrFingerprint(e.PrimaryKey):
+				vu.sigs = append(vu.sigs, verifiedSig(s, pm))
+			case s.SigType != packet.SigTypeCertificationRevocation:
+				vu.others++
+			}
+		}
+		v.uids = append(v.uids, vu)
+	}
+	for _, s := range c.subs {
+		vs := &keyState{m: s.m}
+		for _, sub := range e.Subkeys {
+			if !bytes.Equal(sub.PublicKey.Fingerprint, s.m.fpr) {
+				continue
+			}
+			vs.sigs = append(vs.sigs, verifiedSig(sub.Sig, pm))
+			for _, r := range sub.Revocations {
+				vs.sigs = append(vs.sigs, verifiedSig(r, pm))
+			}
+		}
+		v.subs = append(v.subs, vs)
+	}
+	return v
+}
+
+var hashIDs = map[crypto.Hash]byte{
+	crypto.MD5: 1, crypto.SHA1: 2, crypto.RIPEMD160: 3, crypto.SHA256: 8, crypto.SHA384: 9,
+	crypto.SHA512: 10, crypto.SHA224: 11, crypto.SHA3_256: 12, crypto.SHA3_512: 14,
+}
+
+// verifiedSig converts a signature the library checked as made by primary.
+func verifiedSig(s *packet.Signature, primary keyMaterial) *sigInfo {
+	v := &sigInfo{
+		version: s.Version, typ: byte(s.SigType), algo: byte(s.PubKeyAlgo), hash: hashIDs[s.Hash],
+		created: s.CreationTime.UTC(), issuerFpr: primary.fpr,
+		primary: s.IsPrimaryId != nil && *s.IsPrimaryId,
+	}
+	if s.KeyLifetimeSecs != nil {
+		v.keyExpiry = *s.KeyLifetimeSecs
+	}
+	if s.FlagsValid {
+		var f byte
+		for _, b := range []struct {
+			set  bool
+			flag byte
+		}{
+			{s.FlagCertify, flagCertify}, {s.FlagSign, flagSign}, {s.FlagEncryptCommunications, flagEncryptComms},
+			{s.FlagEncryptStorage, flagEncryptStore}, {s.FlagAuthenticate, flagAuthenticate},
+		} {
+			if b.set {
+				f |= b.flag
+			}
+		}
+		v.flags = &f
+	}
+	return v
+}
+
+// newest returns the newest signature of one of types made by k.
+func newest(sigs []*sigInfo, k keyMaterial, types ...byte) *sigInfo {
+	var best *sigInfo
+	for _, s := range sigs {
+		if s.by(k) && slices.Contains(types, s.typ) && (best == nil || s.created.After(best.created)) {
+			best = s
+		}
+	}
+	return best
+}
+
+// Signature types (RFC 9580 section 5.2.1).
+const (
+	sigGenericCert    = 0x10
+	sigPersonaCert    = 0x11
+	sigCasualCert     = 0x12
+	sigPositiveCert   = 0x13
+	sigSubkeyBinding  = 0x18
+	sigDirectKey      = 0x1f
+	sigKeyRevocation  = 0x20
+	sigSubkeyRevoke   = 0x28
+	sigCertRevocation = 0x30
+)
+
+var certTypes = []byte{sigGenericCert, sigPersonaCert, sigCasualCert, sigPositiveCert}
+
+func buildCertificate(cs *certState, now time.Time) Certificate {
+	primary, uids, subs := cs.primary, cs.uids, cs.subs
+	pm := primary.m
+	c := Certificate{Secret: pm.secret != "", UserIDs: []UserID{}, Subkeys: []Key{}, Attributes: cs.attrs, Warnings: []string{}}
+
+	// the primary key's properties come from its primary user ID's self-signature or
+	// else a direct key signature
+	var primarySig, firstSig *sigInfo
+	selfSigs := make([]*sigInfo, len(uids))
+	for i, u := range uids {
+		uid := parseUserID(u.id)
+		self := newest(u.sigs, pm, certTypes...)
+		selfSigs[i] = self
+		uid.Certifications = u.others
+		for _, s := range u.sigs {
+			if !s.by(pm) && slices.Contains(certTypes, s.typ) {
+				uid.Certifications++
+			}
+		}
+		if rev := newest(u.sigs, pm, sigCertRevocation); rev != nil && (self == nil || !rev.created.Before(self.created)) {
+			uid.Revoked = true
+		}
+		if self != nil {
+			uid.Hash = hashName(self.hash)
+			if self.hash == 1 || self.hash == 2 {
+				c.Warnings = append(c.Warnings, fmt.Sprintf("self-signature on %q uses %s", u.id, uid.Hash))
+			}
+			if firstSig == nil && !uid.Revoked {
+				firstSig = self
+			}
+			if self.primary && !uid.Revoked && (primarySig == nil || self.created.After(primarySig.created)) {
+				primarySig = self
+			}
+		}
+		c.UserIDs = append(c.UserIDs, uid)
+	}
+	if primarySig == nil {
+		primarySig = firstSig
+	}
+	for i := range c.UserIDs {
+		c.UserIDs[i].Primary = primarySig != nil && selfSigs[i] == primarySig
+	}
+	if primarySig == nil {
+		primarySig = newest(primary.sigs, pm, sigDirectKey)
+	}
+	c.Primary = describeKey(pm, primarySig, true, now)
+	c.Primary.Revoked = newest(primary.sigs, pm, sigKeyRevocation) != nil
+
+	for _, s := range subs {
+		binding := newest(s.sigs, pm, sigSubkeyBinding)
+		k := describeKey(s.m, binding, false, now)
+		k.Revoked = newest(s.sigs, pm, sigSubkeyRevoke) != nil
+		if binding == nil && k.Error == "" {
+			k.Error = "no binding signature"
+		}
+		c.Subkeys = append(c.Subkeys, k)
+	}
+	c.Warnings = append(c.Warnings, warnings(c)...)
+	return c
+}
+
+func parseUserID(id string) UserID {
+	// Name (Comment) <email>
+	u := UserID{ID: id}
+	name := id
+	if i := strings.LastIndex(name, "<"); i >= 0 && strings.HasSuffix(name, ">") {
+		u.Email = name[i+1 : len(name)-1]
+		name = strings.TrimSpace(name[:i])
+	}
+	if i := strings.Index(name, " ("); i >= 0 && strings.HasSuffix(name, ")") {
+		u.Comment = name[i+2 : len(name)-1]
+		name = name[:i]
+	}
+	u.Name = name
+	return u
+}
+
+// Key flags (RFC 9580 section 5.2.3.29).
+const (
+	flagCertify      = 0x01
+	flagSign         = 0x02
+	flagEncryptComms = 0x04
+	flagEncryptStore = 0x08
+	flagAuthenticate = 0x20
+)
+
+func describeKey(m keyMaterial, sig *sigInfo, primary bool, now time.Time) Key {
+	k := Key{
+		Version: m.version, Algorithm: algoName(m.algo), Bits: m.bits, Curve: m.curve,
+		Created: m.created, Secret: m.secret, Error: m.err, Capabilities: []string{},
+	}
+	if m.fpr != nil {
+		k.Fingerprint = strings.ToUpper(hex.EncodeToString(m.fpr))
+		k.KeyID = strings.ToUpper(hex.EncodeToString(m.keyID))
+	}
+	if m.validity > 0 {
+		exp := m.created.AddDate(0, 0, m.validity)
+		k.Expires = &exp
+	}
+	var flags byte
+	if sig != nil && sig.flags != nil {
+		flags = *sig.flags
+	} else {
+		// without key flags the algorithm decides
+		switch m.algo {
+		case 1, 20:
+			flags = flagSign | flagEncryptComms
+		case 2, 16, 18, 25, 26:
+			flags = flagEncryptComms
+		case 3, 17, 19, 22, 27, 28:
+			flags = flagSign
+		}
+		if primary && flags&flagSign != 0 {
+			flags |= flagCertify
+		}
+	}
+	for _, f := range []struct {
+		flag   byte
+		name   string
+		letter string
+	}{
+		{flagCertify, "certify", "C"},
+		{flagSign, "sign", "S"},
+		{flagEncryptComms | flagEncryptStore, "encrypt", "E"},
+		{flagAuthenticate, "authenticate", "A"},
+	} {
+		if flags&f.flag != 0 {
+			k.Capabilities = append(k.Capabilities, f.name)
+			k.Usage += f.letter
+		}
+	}
+	if sig != nil && sig.keyExpiry > 0 {
+		exp := m.created.Add(time.Duration(sig.keyExpiry) * time.Second)
+		k.Expires = &exp
+	}
+	k.Expired = k.Expires != nil && k.Expires.Before(now)
+	return k
+}
+
+// usableHere lists the algorithms the openpgp library can sign or encrypt with.
+var usableHere = map[string]bool{
+	"RSA": true, "RSA (encrypt only)": true, "RSA (sign only)": true, "DSA": true, "ElGamal": true,
+	"ECDSA": true, "ECDH": true, "EdDSA": true, "Ed25519": true, "X25519": true, "Ed448": true, "X448": true,
+}
+
+func warnings(c Certificate) []string {
+	var w []string
+	p := c.Primary
+	if p.Revoked {
+		w = append(w, "primary key is revoked")
+	}
+	if p.Expired {
+		w = append(w, "primary key expired on "+p.Expires.Format("2006-01-02"))
+	}
+	if len(c.UserIDs) == 0 {
+		w = append(w, "no user IDs")
+	}
+	encrypt, sign := false, false
+	for _, k := range append([]Key{p}, c.Subkeys...) {
+		if k.Error != "" {
+			continue
+		}
+		if k.Algorithm == "DSA" {
+			w = append(w, fmt.Sprintf("key %s is DSA, which is deprecated", k.KeyID))
+		}
+		if (strings.HasPrefix(k.Algorithm, "RSA") || strings.HasPrefix(k.Algorithm, "ElGamal")) && k.Bits < 2048 {
+			w = append(w, fmt.Sprintf("key %s is %d-bit %s", k.KeyID, k.Bits, k.Algorithm))
+		}
+		if !k.Revoked && !k.Expired && !p.Revoked && !p.Expired {
+			encrypt = encrypt || slices.Contains(k.Capabilities, "encrypt")
+			sign = sign || slices.Contains(k.Capabilities, "sign")
+		}
+		if !usableHere[k.Algorithm] {
+			w = append(w, fmt.Sprintf("key %s is %s, which can be inspected but not used for encryption or signing here", k.KeyID, k.Algorithm))
+		}
+	}
+	if !encrypt {
+		w = append(w, "no valid encryption key")
+	}
+	if !sign {
+		w = append(w, "no valid signing key")
+	}
+	return w
+}
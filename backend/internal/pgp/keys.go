@@ -0,0 +1,309 @@
+package pgp
+
+import (
+	"bytes"
+	"crypto"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	pgperrors "github.com/ProtonMail/go-crypto/openpgp/errors"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/ProtonMail/go-crypto/openpgp/s2k"
+)
+
+var (
+	// ErrPassphraseRequired is returned for an encrypted private key given without a passphrase.
+	ErrPassphraseRequired = errors.New("private key is encrypted, passphrase required")
+	ErrBadPassphrase      = errors.New("incorrect passphrase")
+	// ErrUnsupported is returned for keys and messages using algorithms that can only be
+	// inspected, such as ElGamal sign and encrypt keys or unknown curves.
+	ErrUnsupported = errors.New("unsupported OpenPGP algorithm")
+)
+
+const inspectOnly = "such keys can only be inspected"
+
+// readKeyRing reads the keys of every armored key block in text.
+func readKeyRing(text string) (openpgp.EntityList, error) {
+	blocks, err := decodeArmor(text)
+	if err != nil {
+		return nil, err
+	}
+	var el openpgp.EntityList
+	for _, b := range blocks {
+		if b.Type != openpgp.PublicKeyType && b.Type != openpgp.PrivateKeyType {
+			return nil, fmt.Errorf("expected a key block, got %s", b.Type)
+		}
+		keys, err := openpgp.ReadKeyRing(bytes.NewReader(b.Body))
+		if err != nil {
+			return nil, libError(err)
+		}
+		packets, err := readPackets(b.Body)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key block: %w", err)
+		}
+		// ReadKeyRing skips keys it cannot parse or verify; a missing recipient must not go
+		// unnoticed
+		for _, c := range splitCerts(packets) {
+			pm := c.primary.m
+			if !slices.ContainsFunc(keys, func(e *openpgp.Entity) bool { return bytes.Equal(e.PrimaryKey.Fingerprint, pm.fpr) }) {
+				if usableHere[algoName(pm.algo)] && pm.err == "" {
+					return nil, fmt.Errorf("key %X has an invalid or missing self-signature", pm.keyID)
+				}
+				return nil, fmt.Errorf("%w: key %X is %s; %s", ErrUnsupported, pm.keyID, algoName(pm.algo), inspectOnly)
+			}
+			for _, k := range c.keys() {
+				if err := checkS2K(k.s2k); err != nil {
+					return nil, fmt.Errorf("key %X: %w", k.keyID, err)
+				}
+			}
+		}
+		el = append(el, keys...)
+	}
+	return el, nil
+}
+
+// libError names the cause of the openpgp library's unsupported feature errors.
+func libError(err error) error {
+	var ue pgperrors.UnsupportedError
+	if errors.As(err, &ue) {
+		return fmt.Errorf("%w: %s; %s", ErrUnsupported, string(ue), inspectOnly)
+	}
+	return err
+}
+
+// unlock decrypts the private keys of el with passphrase.
+func unlock(el openpgp.EntityList, passphrase string) error {
+	for _, e := range el {
+		keys := []*packet.PrivateKey{e.PrivateKey}
+		for _, s := range e.Subkeys {
+			keys = append(keys, s.PrivateKey)
+		}
+		for _, k := range keys {
+			if k == nil || !k.Encrypted || k.Dummy() {
+				continue
+			}
+			if passphrase == "" {
+				return ErrPassphraseRequired
+			}
+			if err := k.Decrypt([]byte(passphrase)); err != nil {
+				if errors.As(err, new(pgperrors.UnsupportedError)) {
+					return libError(err)
+				}
+				return ErrBadPassphrase
+			}
+		}
+	}
+	return nil
+}
+
+// readPrivateKey reads one secret key and decrypts it.
+func readPrivateKey(text, passphrase string) (*openpgp.Entity, error) {
+	el, err := readKeyRing(text)
+	if err != nil {
+		return nil, err
+	}
+	if len(el) != 1 {
+		return nil, fmt.Errorf("expected one private key, got %d keys", len(el))
+	}
+	if el[0].PrivateKey == nil {
+		return nil, errors.New("not a private key")
+	}
+	if err := unlock(el, passphrase); err != nil {
+		return nil, err
+	}
+	return el[0], nil
+}
+
+// signingKey picks the key of e to sign with as the library does: the newest valid
+// signing subkey, else the primary key.
+func signingKey(e *openpgp.Entity, now time.Time) (*packet.PrivateKey, error) {
+	k, ok := e.SigningKey(now)
+	if !ok {
+		return nil, fmt.Errorf("key %X has no valid signing key", e.PrimaryKey.Fingerprint)
+	}
+	if k.PrivateKey == nil || k.PrivateKey.Dummy() {
+		return nil, fmt.Errorf("key %X has no secret signing key", e.PrimaryKey.Fingerprint)
+	}
+	return k.PrivateKey, nil
+}
+
+// curveHash is SHA-256, or the hash matching the curve for P-384 and P-521, which gpg
+// requires for ECDSA.
+func curveHash(curve packet.Curve) crypto.Hash {
+	switch curve {
+	case packet.CurveNistP384:
+		return crypto.SHA384
+	case packet.CurveNistP521:
+		return crypto.SHA512
+	}
+	return crypto.SHA256
+}
+
+func defaultHash(pk *packet.PublicKey) crypto.Hash {
+	if pk.PubKeyAlgo != packet.PubKeyAlgoECDSA {
+		return crypto.SHA256
+	}
+	curve, _ := pk.Curve()
+	return curveHash(curve)
+}
+
+var hashes = map[string]crypto.Hash{"sha256": crypto.SHA256, "sha384": crypto.SHA384, "sha512": crypto.SHA512}
+
+func selectHash(name string, pk *packet.PublicKey) (crypto.Hash, error) {
+	if name == "" {
+		return defaultHash(pk), nil
+	}
+	h, ok := hashes[strings.ToLower(strings.ReplaceAll(name, "-", ""))]
+	if !ok {
+		return 0, fmt.Errorf("unsupported hash %q, use sha256, sha384 or sha512", name)
+	}
+	return h, nil
+}
+
+// GenerateSpec selects the key to generate. By default the encryption subkey matches
+// the primary key as gpg creates them: RSA of the same size for RSA, ECDH on the same
+// curve for ECDSA and Curve25519 (cv25519) for Ed25519. Subkey picks another one.
+type GenerateSpec struct {
+	Name      string `json:"name"`
+	Email     string `json:"email"`
+	Comment   string `json:"comment"`
+	Algorithm string `json:"algorithm"` // rsa (default), ecdsa or ed25519
+	Bits      int    `json:"bits"`      // RSA size: 2048, 3072 (default) or 4096
+	Curve     string `json:"curve"`     // ecdsa and ecdh: P-256 (default), P-384 or P-521
+	Subkey    string `json:"subkey"`    // rsa, ecdh or cv25519; empty matches the primary key
+	// SignOnly leaves out the encryption subkey, e.g. for a release signing key.
+	SignOnly    bool   `json:"sign_only"`
+	ExpiresDays int    `json:"expires_days"` // 0 never expires
+	Passphrase  string `json:"passphrase"`   // encrypts the secret keys (iterated SHA-256 S2K, AES-256)
+}
+
+type KeyPair struct {
+	Certificate
+	PublicKey  string `json:"public_key"`
+	PrivateKey string `json:"private_key"`
+	Encrypted  bool   `json:"encrypted"`
+}
+
+// s2kCount is the largest iteration count S2K can encode.
+const s2kCount = 65011712
+
+var nistCurves = map[string]packet.Curve{"P256": packet.CurveNistP256, "P384": packet.CurveNistP384, "P521": packet.CurveNistP521}
+
+// Generate creates a certify and sign primary key with one user ID and, unless
+// SignOnly, an encryption subkey.
+func Generate(spec GenerateSpec) (*KeyPair, error) {
+	if spec.Name == "" && spec.Email == "" {
+		return nil, errors.New("name or email required")
+	}
+	if packet.NewUserId(spec.Name, spec.Comment, spec.Email) == nil {
+		return nil, errors.New(`name, comment and email must not contain "()<>" or NUL`)
+	}
+	if spec.ExpiresDays < 0 || spec.ExpiresDays > 36500 {
+		return nil, errors.New("expires_days must be between 0 and 36500")
+	}
+	if spec.Bits == 0 {
+		spec.Bits = 3072
+	}
+	if spec.Bits != 2048 && spec.Bits != 3072 && spec.Bits != 4096 {
+		return nil, errors.New("bits must be 2048, 3072 or 4096")
+	}
+	curveName := strings.ToUpper(strings.ReplaceAll(spec.Curve, "-", ""))
+	if curveName == "" {
+		curveName = "P256"
+	}
+	curve, ok := nistCurves[curveName]
+	if !ok {
+		return nil, errors.New("curve must be P-256, P-384 or P-521")
+	}
+	now := time.Now().Truncate(time.Second)
+	config := &packet.Config{
+		RSABits:       spec.Bits,
+		DefaultHash:   crypto.SHA256,
+		DefaultCipher: packet.CipherAES256,
+		Time:          func() time.Time { return now },
+		S2KConfig:     &s2k.Config{S2KMode: s2k.IteratedSaltedS2K, Hash: crypto.SHA256, S2KCount: s2kCount},
+	}
+	if spec.ExpiresDays > 0 {
+		config.KeyLifetimeSecs = uint32(spec.ExpiresDays) * 86400
+	}
+	switch strings.ToLower(spec.Algorithm) {
+	case "", "rsa":
+		config.Algorithm = packet.PubKeyAlgoRSA
+	case "ecdsa", "ec":
+		config.Algorithm, config.Curve, config.DefaultHash = packet.PubKeyAlgoECDSA, curve, curveHash(curve)
+	case "ed25519", "eddsa":
+		config.Algorithm, config.Curve = packet.PubKeyAlgoEdDSA, packet.Curve25519
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %q, use rsa, ecdsa or ed25519", spec.Algorithm)
+	}
+	var sub *packet.Config
+	if spec.Subkey != "" && !spec.SignOnly {
+		c := *config
+		switch strings.ToLower(spec.Subkey) {
+		case "rsa":
+			c.Algorithm = packet.PubKeyAlgoRSA
+		case "ecdh":
+			c.Algorithm, c.Curve = packet.PubKeyAlgoECDH, curve
+		case "cv25519", "curve25519", "x25519":
+			c.Algorithm, c.Curve = packet.PubKeyAlgoECDH, packet.Curve25519
+		default:
+			return nil, fmt.Errorf("unsupported subkey %q, use rsa, ecdh or cv25519", spec.Subkey)
+		}
+		sub = &c
+	}
+
+	e, err := openpgp.NewEntity(spec.Name, spec.Comment, spec.Email, config)
+	if err != nil {
+		return nil, libError(err)
+	}
+	if spec.SignOnly || sub != nil {
+		e.Subkeys = nil
+	}
+	if sub != nil {
+		if err := e.AddEncryptionSubkey(sub); err != nil {
+			return nil, libError(err)
+		}
+	}
+	if spec.Passphrase != "" {
+		if err := e.EncryptPrivateKeys([]byte(spec.Passphrase), config); err != nil {
+			return nil, err
+		}
+	}
+
+	var pub, priv bytes.Buffer
+	if err := writeArmored(&pub, openpgp.PublicKeyType, e.Serialize); err != nil {
+		return nil, err
+	}
+	err = writeArmored(&priv, openpgp.PrivateKeyType, func(w io.Writer) error {
+		return e.SerializePrivateWithoutSigning(w, config)
+	})
+	if err != nil {
+		return nil, err
+	}
+	certs, err := Inspect(priv.String())
+	if err != nil {
+		return nil, err
+	}
+	return &KeyPair{Certificate: certs[0], PublicKey: pub.String(), PrivateKey: priv.String(), Encrypted: spec.Passphrase != ""}, nil
+}
+
+func writeArmored(buf *bytes.Buffer, typ string, write func(io.Writer) error) error {
+	w, err := armor.Encode(buf, typ, nil)
+	if err != nil {
+		return err
+	}
+	if err := write(w); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	buf.WriteByte('\n')
+	return nil
+}
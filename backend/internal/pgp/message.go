@@ -0,0 +1,404 @@
+package pgp
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/clearsign"
+	pgperrors "github.com/ProtonMail/go-crypto/openpgp/errors"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+)
+
+// SignOptions selects the secret key to sign with. Hash is sha256, sha384 or sha512;
+// by default SHA-256, or the curve's size for ECDSA.
+type SignOptions struct {
+	Key        string `json:"key"`
+	Passphrase string `json:"passphrase"`
+	Hash       string `json:"hash"`
+}
+
+func (o SignOptions) signer() (*openpgp.Entity, *packet.PrivateKey, *packet.Config, error) {
+	e, err := readPrivateKey(o.Key, o.Passphrase)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	now := time.Now()
+	key, err := signingKey(e, now)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	h, err := selectHash(o.Hash, &key.PublicKey)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return e, key, &packet.Config{DefaultHash: h, Time: func() time.Time { return now }, SigningKeyId: key.KeyId}, nil
+}
+
+// ClearSign wraps message in a -----BEGIN PGP SIGNED MESSAGE----- block.
+func ClearSign(message string, opts SignOptions) (string, error) {
+	_, key, config, err := opts.signer()
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	w, err := clearsign.Encode(&buf, key, config)
+	if err != nil {
+		return "", libError(err)
+	}
+	if _, err := io.WriteString(w, message); err != nil {
+		return "", err
+	}
+	if err := w.Close(); err != nil {
+		return "", libError(err)
+	}
+	buf.WriteByte('\n')
+	return buf.String(), nil
+}
+
+// DetachSign returns an armored signature over message. Text signatures hash the message
+// with CRLF line endings so that they verify whatever the line endings it is checked with.
+func DetachSign(message string, text bool, opts SignOptions) (string, error) {
+	e, _, config, err := opts.signer()
+	if err != nil {
+		return "", err
+	}
+	sign := openpgp.DetachSign
+	if text {
+		sign = openpgp.DetachSignText
+	}
+	var buf bytes.Buffer
+	err = writeArmored(&buf, openpgp.SignatureType, func(w io.Writer) error {
+		return sign(w, e, strings.NewReader(message), config)
+	})
+	if err != nil {
+		return "", libError(err)
+	}
+	return buf.String(), nil
+}
+
+// Verification is the outcome of checking a signature. KeyID is the issuer the
+// signature names; Fingerprint and UserID are filled in when the key is known.
+type Verification struct {
+	Valid       bool      `json:"valid"`
+	KeyID       string    `json:"key_id"`
+	Fingerprint string    `json:"fingerprint,omitempty"` // of the signing key or subkey
+	Primary     string    `json:"primary_fingerprint,omitempty"`
+	UserID      string    `json:"user_id,omitempty"`
+	Created     time.Time `json:"created"`
+	Hash        string    `json:"hash"`
+	Type        string    `json:"type"` // binary or text
+	Error       string    `json:"error,omitempty"`
+	// Message is the signed text of a clear-signed message.
+	Message string `json:"message,omitempty"`
+}
+
+// VerifyClearSigned checks a clear-signed message against the armored public keys.
+func VerifyClearSigned(signed, keys string) (*Verification, error) {
+	b, _ := clearsign.Decode([]byte(strings.TrimLeft(signed, " \t\r\n")))
+	if b == nil {
+		return nil, errors.New("no -----BEGIN PGP SIGNED MESSAGE----- block found")
+	}
+	sig, err := io.ReadAll(b.ArmoredSignature.Body)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signature armor: %w", err)
+	}
+	v, err := verify(b.Bytes, sig, keys)
+	if err != nil {
+		return nil, err
+	}
+	v.Message = string(b.Plaintext)
+	return v, nil
+}
+
+// VerifyDetached checks an armored detached signature over message.
+func VerifyDetached(message, signature, keys string) (*Verification, error) {
+	blocks, err := decodeArmor(signature)
+	if err != nil {
+		return nil, err
+	}
+	if blocks[0].Type != openpgp.SignatureType {
+		return nil, fmt.Errorf("expected a signature, got %s", blocks[0].Type)
+	}
+	return verify([]byte(message), blocks[0].Body, keys)
+}
+
+func verify(signed, sig []byte, keys string) (*Verification, error) {
+	p, err := packet.Read(bytes.NewReader(sig))
+	if err != nil {
+		return nil, libError(err)
+	}
+	s, ok := p.(*packet.Signature)
+	if !ok {
+		return nil, errors.New("not a signature")
+	}
+	if s.IssuerKeyId == nil {
+		return nil, errors.New("signature names no issuer")
+	}
+	issuer := *s.IssuerKeyId
+	v := &Verification{KeyID: fmt.Sprintf("%016X", issuer), Created: s.CreationTime, Hash: hashString(s.Hash), Type: sigTypeName(s.SigType)}
+	el, err := readKeyRing(keys)
+	if err != nil {
+		return nil, fmt.Errorf("keys: %w", err)
+	}
+	e, err := openpgp.CheckDetachedSignature(el, bytes.NewReader(signed), bytes.NewReader(sig), nil)
+	switch {
+	case err == nil:
+		v.Valid = true
+		describeSigner(v, e, issuer)
+	case e != nil:
+		// the signature matches, but the key or signature has expired or been revoked
+		v.Error = "good signature, but " + err.Error()
+		describeSigner(v, e, issuer)
+	case errors.Is(err, pgperrors.ErrUnknownIssuer):
+		v.Error = "signed by key " + v.KeyID + ", which is not among the given keys"
+	case errors.As(err, new(pgperrors.SignatureError)):
+		v.Error = "BAD signature: " + err.Error()
+		for _, k := range el.KeysById(issuer) {
+			describeSigner(v, k.Entity, issuer)
+		}
+	default:
+		return nil, libError(err)
+	}
+	return v, nil
+}
+
+// describeSigner fills in the key of e that made the signature.
+func describeSigner(v *Verification, e *openpgp.Entity, id uint64) {
+	v.Primary = fmt.Sprintf("%X", e.PrimaryKey.Fingerprint)
+	v.Fingerprint = v.Primary
+	for _, s := range e.Subkeys {
+		if s.PublicKey.KeyId == id {
+			v.Fingerprint = fmt.Sprintf("%X", s.PublicKey.Fingerprint)
+		}
+	}
+	v.UserID = primaryUserID(e)
+}
+
+// primaryUserID is the user ID marked primary, else the first in sort order.
+func primaryUserID(e *openpgp.Entity) string {
+	var first string
+	for name, id := range e.Identities {
+		if id.SelfSignature.IsPrimaryId != nil && *id.SelfSignature.IsPrimaryId {
+			return name
+		}
+		if first == "" || name < first {
+			first = name
+		}
+	}
+	return first
+}
+
+func hashString(h crypto.Hash) string {
+	return strings.ReplaceAll(h.String(), "-", "")
+}
+
+func sigTypeName(t packet.SignatureType) string {
+	switch t {
+	case packet.SigTypeBinary:
+		return "binary"
+	case packet.SigTypeText:
+		return "text"
+	}
+	return fmt.Sprintf("0x%02x", uint8(t))
+}
+
+// EncryptOptions gives either the recipients' public keys or a passphrase for
+// symmetric encryption. The message is signed when SignKey is set, which needs
+// recipients.
+type EncryptOptions struct {
+	Recipients     string `json:"recipients"`
+	Passphrase     string `json:"passphrase"`
+	SignKey        string `json:"sign_key"`
+	SignPassphrase string `json:"sign_passphrase"`
+	Filename       string `json:"filename"`
+}
+
+// Encrypt returns message as an armored PGP MESSAGE, encrypted with AES-256.
+func Encrypt(message string, opts EncryptOptions) (string, error) {
+	if (opts.Recipients == "") == (opts.Passphrase == "") {
+		return "", errors.New("give either recipients or a passphrase")
+	}
+	config := &packet.Config{DefaultCipher: packet.CipherAES256, DefaultHash: crypto.SHA256}
+	hints := &openpgp.FileHints{FileName: opts.Filename, IsBinary: !utf8.ValidString(message)}
+	var to openpgp.EntityList
+	var signer *openpgp.Entity
+	var err error
+	if opts.Passphrase != "" && opts.SignKey != "" {
+		return "", errors.New("passphrase encrypted messages cannot be signed here")
+	}
+	if opts.Recipients != "" {
+		if to, err = readKeyRing(opts.Recipients); err != nil {
+			return "", fmt.Errorf("recipients: %w", err)
+		}
+	}
+	if opts.SignKey != "" {
+		if signer, err = readPrivateKey(opts.SignKey, opts.SignPassphrase); err != nil {
+			return "", fmt.Errorf("sign_key: %w", err)
+		}
+		key, err := signingKey(signer, time.Now())
+		if err != nil {
+			return "", err
+		}
+		config.DefaultHash = defaultHash(&key.PublicKey)
+	}
+	var buf bytes.Buffer
+	err = writeArmored(&buf, "PGP MESSAGE", func(aw io.Writer) error {
+		var w io.WriteCloser
+		var err error
+		if opts.Passphrase != "" {
+			w, err = openpgp.SymmetricallyEncrypt(aw, []byte(opts.Passphrase), hints, config)
+		} else {
+			w, err = openpgp.Encrypt(aw, to, signer, hints, config)
+		}
+		if err != nil {
+			return libError(err)
+		}
+		if _, err := io.WriteString(w, message); err != nil {
+			return err
+		}
+		return w.Close()
+	})
+	if err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// DecryptOptions gives the recipient's secret key and its passphrase, or only the
+// passphrase of a symmetrically encrypted message. VerifyKeys are public keys to check
+// an inline signature with.
+type DecryptOptions struct {
+	Key        string `json:"key"`
+	Passphrase string `json:"passphrase"`
+	VerifyKeys string `json:"verify_keys"`
+}
+
+// Decrypted is a decrypted message. Message is base64 when Encoding says so, for
+// content that is not UTF-8.
+type Decrypted struct {
+	Message       string        `json:"message"`
+	Encoding      string        `json:"encoding"` // utf-8 or base64
+	Filename      string        `json:"filename,omitempty"`
+	EncryptedTo   []string      `json:"encrypted_to"` // key IDs
+	Symmetric     bool          `json:"symmetric"`
+	DecryptedWith string        `json:"decrypted_with,omitempty"`
+	Signed        bool          `json:"signed"`
+	Signature     *Verification `json:"signature,omitempty"`
+}
+
+// maxMessageSize bounds a decrypted message, compressed or not.
+const maxMessageSize = 16 << 20
+
+// Decrypt decrypts an armored PGP MESSAGE and checks its signature, if any.
+func Decrypt(message string, opts DecryptOptions) (*Decrypted, error) {
+	blocks, err := decodeArmor(message)
+	if err != nil {
+		return nil, err
+	}
+	if blocks[0].Type != "PGP MESSAGE" {
+		return nil, fmt.Errorf("expected a PGP MESSAGE, got %s", blocks[0].Type)
+	}
+	body := blocks[0].Body
+	res := &Decrypted{EncryptedTo: []string{}}
+	packets, err := readPackets(body)
+	if err != nil {
+		return nil, fmt.Errorf("invalid message: %w", err)
+	}
+	for _, p := range packets {
+		switch {
+		case p.tag == tagPKESK && len(p.body) >= 9 && p.body[0] == 3:
+			res.EncryptedTo = append(res.EncryptedTo, fmt.Sprintf("%X", p.body[1:9]))
+		case p.tag == tagSKESK:
+			res.Symmetric = true
+			if err := checkS2K(skeskS2K(p.body)); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	var el openpgp.EntityList
+	if opts.Key != "" {
+		if el, err = readKeyRing(opts.Key); err != nil {
+			return nil, fmt.Errorf("key: %w", err)
+		}
+		if err := unlock(el, opts.Passphrase); err != nil {
+			return nil, err
+		}
+	}
+	if opts.VerifyKeys != "" {
+		vk, err := readKeyRing(opts.VerifyKeys)
+		if err != nil {
+			return nil, fmt.Errorf("verify_keys: %w", err)
+		}
+		el = append(el, vk...)
+	}
+	tried := false
+	prompt := func(_ []openpgp.Key, symmetric bool) ([]byte, error) {
+		switch {
+		case !symmetric:
+			return nil, ErrPassphraseRequired
+		case opts.Passphrase == "":
+			return nil, errors.New("message is passphrase encrypted, passphrase required")
+		case tried:
+			return nil, ErrBadPassphrase
+		}
+		tried = true
+		return []byte(opts.Passphrase), nil
+	}
+	limit := int64(maxMessageSize)
+	md, err := openpgp.ReadMessage(bytes.NewReader(body), el, prompt, &packet.Config{MaxDecompressedMessageSize: &limit})
+	if errors.Is(err, pgperrors.ErrKeyIncorrect) {
+		return nil, fmt.Errorf("message is encrypted to %s; no secret key given for them", strings.Join(res.EncryptedTo, ", "))
+	}
+	if err != nil {
+		return nil, libError(err)
+	}
+	plain, err := io.ReadAll(io.LimitReader(md.UnverifiedBody, maxMessageSize+1))
+	if err != nil {
+		return nil, libError(err)
+	}
+	if len(plain) > maxMessageSize {
+		return nil, fmt.Errorf("decrypted message is larger than %d MiB", maxMessageSize>>20)
+	}
+	if md.DecryptedWith.PublicKey != nil {
+		res.DecryptedWith = fmt.Sprintf("%016X", md.DecryptedWith.PublicKey.KeyId)
+	}
+	if md.LiteralData != nil {
+		res.Filename = md.LiteralData.FileName
+	}
+	res.Message, res.Encoding = string(plain), "utf-8"
+	if !utf8.Valid(plain) {
+		res.Message, res.Encoding = base64.StdEncoding.EncodeToString(plain), "base64"
+	}
+	res.Signed = md.IsSigned
+	if !md.IsSigned {
+		if md.SignatureError != nil {
+			return nil, fmt.Errorf("integrity check failed: %w", md.SignatureError)
+		}
+		return res, nil
+	}
+	v := &Verification{KeyID: fmt.Sprintf("%016X", md.SignedByKeyId)}
+	if s := md.Signature; s != nil {
+		v.Created, v.Hash, v.Type = s.CreationTime, hashString(s.Hash), sigTypeName(s.SigType)
+	}
+	switch {
+	case md.SignedBy == nil:
+		v.Error = "signed by key " + v.KeyID + ", which is not among the given keys"
+	case md.SignatureError != nil:
+		v.Error = "BAD signature: " + md.SignatureError.Error()
+		describeSigner(v, md.SignedBy.Entity, md.SignedByKeyId)
+	default:
+		v.Valid = true
+		describeSigner(v, md.SignedBy.Entity, md.SignedByKeyId)
+	}
+	res.Signature = v
+	return res, nil
+}
@@ -0,0 +1,555 @@
+package pgp
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+)
+
+// Packet tags (RFC 9580 section 5).
+const (
+	tagPKESK        = 1
+	tagSignature    = 2
+	tagSKESK        = 3
+	tagSecretKey    = 5
+	tagPublicKey    = 6
+	tagSecretSubkey = 7
+	tagUserID       = 13
+	tagPublicSubkey = 14
+	tagUserAttr     = 17
+)
+
+type rawPacket struct {
+	tag  int
+	body []byte
+}
+
+// readPackets splits b into packets, joining partial body lengths.
+func readPackets(b []byte) ([]rawPacket, error) {
+	var out []rawPacket
+	for len(b) > 0 {
+		hdr := b[0]
+		if hdr&0x80 == 0 {
+			return out, errors.New("invalid packet header")
+		}
+		var p rawPacket
+		if hdr&0x40 == 0 { // legacy format
+			p.tag = int(hdr>>2) & 0x0f
+			var n int
+			switch hdr & 3 {
+			case 0:
+				if len(b) < 2 {
+					return out, io.ErrUnexpectedEOF
+				}
+				n, b = int(b[1]), b[2:]
+			case 1:
+				if len(b) < 3 {
+					return out, io.ErrUnexpectedEOF
+				}
+				n, b = int(binary.BigEndian.Uint16(b[1:])), b[3:]
+			case 2:
+				if len(b) < 5 {
+					return out, io.ErrUnexpectedEOF
+				}
+				n, b = int(binary.BigEndian.Uint32(b[1:])), b[5:]
+			default: // indeterminate, runs to the end
+				n, b = len(b)-1, b[1:]
+			}
+			if n > len(b) {
+				return out, io.ErrUnexpectedEOF
+			}
+			p.body, b = b[:n], b[n:]
+			out = append(out, p)
+			continue
+		}
+		p.tag = int(hdr & 0x3f)
+		b = b[1:]
+		for {
+			n, partial, rest, err := newLength(b)
+			if err != nil {
+				return out, err
+			}
+			if n > len(rest) {
+				return out, io.ErrUnexpectedEOF
+			}
+			p.body, b = append(p.body, rest[:n]...), rest[n:]
+			if !partial {
+				break
+			}
+		}
+		out = append(out, p)
+	}
+	return out, nil
+}
+
+func newLength(b []byte) (n int, partial bool, rest []byte, err error) {
+	if len(b) < 1 {
+		return 0, false, nil, io.ErrUnexpectedEOF
+	}
+	switch l := b[0]; {
+	case l < 192:
+		return int(l), false, b[1:], nil
+	case l < 224:
+		if len(b) < 2 {
+			return 0, false, nil, io.ErrUnexpectedEOF
+		}
+		return (int(l)-192)<<8 + int(b[1]) + 192, false, b[2:], nil
+	case l < 255:
+		return 1 << (l & 0x1f), true, b[1:], nil
+	default:
+		if len(b) < 5 {
+			return 0, false, nil, io.ErrUnexpectedEOF
+		}
+		return int(binary.BigEndian.Uint32(b[1:])), false, b[5:], nil
+	}
+}
+
+type armorBlock struct {
+	Type string
+	Body []byte
+}
+
+// decodeArmor decodes every ASCII armored block in text.
+func decodeArmor(text string) ([]armorBlock, error) {
+	var blocks []armorBlock
+	for {
+		i := strings.Index(text, "-----BEGIN PGP ")
+		if i < 0 {
+			break
+		}
+		text = text[i:]
+		b, err := armor.Decode(strings.NewReader(text))
+		if err != nil {
+			return nil, fmt.Errorf("invalid armor: %w", err)
+		}
+		body, err := io.ReadAll(b.Body)
+		if err != nil {
+			return nil, fmt.Errorf("invalid armor: %w", err)
+		}
+		blocks = append(blocks, armorBlock{Type: b.Type, Body: body})
+		end := strings.Index(text, "-----END PGP ")
+		if end < 0 {
+			break
+		}
+		text = text[end+len("-----END PGP "):]
+	}
+	if len(blocks) == 0 {
+		return nil, errors.New("no ASCII armored OpenPGP data found")
+	}
+	return blocks, nil
+}
+
+// keyMaterial is what the packets say about one primary key or subkey.
+type keyMaterial struct {
+	version int
+	created time.Time
+	algo    byte
+	bits    int
+	curve   string
+	fpr     []byte
+	keyID   []byte
+	// validity is the v3 expiry in days
+	validity int
+	secret   string
+	// s2k is the S2K specifier protecting the secret key material, if any
+	s2k []byte
+	err string
+}
+
+// publicKeyAlgos names the public key algorithms of RFC 4880, RFC 6637 and RFC 9580.
+var publicKeyAlgos = map[byte]string{
+	1: "RSA", 2: "RSA (encrypt only)", 3: "RSA (sign only)", 16: "ElGamal", 17: "DSA", 18: "ECDH",
+	19: "ECDSA", 20: "ElGamal (sign and encrypt)", 22: "EdDSA", 25: "X25519", 26: "X448",
+	27: "Ed25519", 28: "Ed448",
+}
+
+func algoName(a byte) string {
+	if n, ok := publicKeyAlgos[a]; ok {
+		return n
+	}
+	return fmt.Sprintf("unknown (%d)", a)
+}
+
+// curves maps the hex encoded OIDs of RFC 6637 and RFC 9580 to names and sizes.
+var curves = map[string]struct {
+	name string
+	bits int
+}{
+	"2a8648ce3d030107":     {"NIST P-256", 256},
+	"2b81040022":           {"NIST P-384", 384},
+	"2b81040023":           {"NIST P-521", 521},
+	"2b8104000a":           {"secp256k1", 256},
+	"2b2403030208010107":   {"brainpoolP256r1", 256},
+	"2b240303020801010b":   {"brainpoolP384r1", 384},
+	"2b240303020801010d":   {"brainpoolP512r1", 512},
+	"2b06010401da470f01":   {"Ed25519", 255},
+	"2b060104019755010501": {"Curve25519", 255},
+	"2b6571":               {"Ed448", 448},
+	"2b656f":               {"X448", 448},
+}
+
+// parseKey decodes a public or secret key packet body.
+func parseKey(body []byte, secret bool) keyMaterial {
+	k := keyMaterial{}
+	if len(body) < 6 {
+		k.err = "truncated key packet"
+		return k
+	}
+	k.version = int(body[0])
+	k.created = time.Unix(int64(binary.BigEndian.Uint32(body[1:5])), 0).UTC()
+	var material []byte
+	var pubLen int
+	switch k.version {
+	case 2, 3:
+		if len(body) < 8 {
+			k.err = "truncated key packet"
+			return k
+		}
+		k.validity = int(binary.BigEndian.Uint16(body[5:7]))
+		k.algo, material = body[7], body[8:]
+		n, e, err := readMPI(material)
+		if err != nil {
+			k.err = err.Error()
+			return k
+		}
+		if len(n) < 10 {
+			k.err = "invalid RSA modulus"
+			return k
+		}
+		k.bits = bitLen(n)
+		_, rest, err := readMPI(e)
+		if err != nil {
+			k.err = err.Error()
+			return k
+		}
+		exp := e[2 : len(e)-len(rest)]
+		h := md5.Sum(append(append([]byte{}, n[2:]...), exp...))
+		k.fpr = h[:]
+		k.keyID = n[len(n)-8:]
+		pubLen = len(body) - len(rest)
+	case 4:
+		k.algo, material = body[5], body[6:]
+		n, err := k.parseMaterial(material)
+		if err != nil {
+			// an unknown algorithm's public fields run to the end of a public key packet
+			k.err = err.Error()
+			if err != errUnknownAlgo || secret {
+				return k
+			}
+		}
+		pubLen = 6 + n
+		h := sha1.Sum(append([]byte{0x99, byte(pubLen >> 8), byte(pubLen)}, body[:pubLen]...))
+		k.fpr = h[:]
+		k.keyID = k.fpr[12:]
+	case 5, 6:
+		if len(body) < 10 {
+			k.err = "truncated key packet"
+			return k
+		}
+		k.algo = body[5]
+		n := int(binary.BigEndian.Uint32(body[6:10]))
+		if n > len(body)-10 {
+			k.err = "truncated key packet"
+			return k
+		}
+		if _, err := k.parseMaterial(body[10 : 10+n]); err != nil {
+			k.err = err.Error()
+		}
+		pubLen = 10 + n
+		prefix := byte(0x9a)
+		if k.version == 6 {
+			prefix = 0x9b
+		}
+		hdr := []byte{prefix, 0, 0, 0, 0}
+		binary.BigEndian.PutUint32(hdr[1:], uint32(pubLen))
+		h := sha256.Sum256(append(hdr, body[:pubLen]...))
+		k.fpr = h[:]
+		k.keyID = k.fpr[:8]
+	default:
+		k.err = fmt.Sprintf("unsupported key packet version %d", k.version)
+		return k
+	}
+	if secret {
+		k.secret, k.s2k = secretState(k.version, body[pubLen:])
+	}
+	return k
+}
+
+var errUnknownAlgo = errors.New("unknown public key algorithm")
+
+// parseMaterial reads the public key fields of algo and returns their length.
+func (k *keyMaterial) parseMaterial(b []byte) (int, error) {
+	var mpis int
+	switch k.algo {
+	case 1, 2, 3:
+		mpis = 2
+	case 17:
+		mpis = 4
+	case 16, 20:
+		mpis = 3
+	case 18, 19, 22:
+		oid, rest, err := readOID(b)
+		if err != nil {
+			return 0, err
+		}
+		if c, ok := curves[oid]; ok {
+			k.curve, k.bits = c.name, c.bits
+		} else {
+			k.curve = "OID " + oid
+		}
+		if _, rest, err = readMPI(rest); err != nil {
+			return 0, err
+		}
+		if k.algo == 18 { // KDF parameters
+			if len(rest) < 1 || int(rest[0]) >= len(rest) {
+				return 0, errors.New("truncated ECDH parameters")
+			}
+			rest = rest[1+int(rest[0]):]
+		}
+		return len(b) - len(rest), nil
+	case 25, 26, 27, 28:
+		n := map[byte]int{25: 32, 26: 56, 27: 32, 28: 57}[k.algo]
+		k.bits = map[byte]int{25: 255, 26: 448, 27: 255, 28: 448}[k.algo]
+		if len(b) < n {
+			return 0, errors.New("truncated key material")
+		}
+		return n, nil
+	default:
+		return len(b), errUnknownAlgo
+	}
+	rest := b
+	for i := 0; i < mpis; i++ {
+		var m []byte
+		var err error
+		if m, rest, err = readMPI(rest); err != nil {
+			return 0, err
+		}
+		if i == 0 {
+			k.bits = bitLen(m)
+		}
+	}
+	return len(b) - len(rest), nil
+}
+
+// secretState reports how the secret key material that follows the public fields is
+// stored: plain, encrypted, stub (no secret, e.g. an offline primary key) or card. For
+// encrypted keys it also returns the S2K specifier.
+func secretState(version int, b []byte) (string, []byte) {
+	if len(b) < 1 {
+		return "stub", nil
+	}
+	usage := b[0]
+	if usage == 0 {
+		return "plain", nil
+	}
+	b = b[1:]
+	if version >= 5 && len(b) > 0 {
+		b = b[1:] // length of the following fields
+	}
+	if usage != 253 && usage != 254 && usage != 255 {
+		return "encrypted", nil // legacy: usage is the cipher, the key is the MD5 of the passphrase
+	}
+	skip := 1 // cipher
+	if usage == 253 {
+		skip++ // AEAD mode
+	}
+	if version == 6 {
+		skip++ // S2K specifier length
+	}
+	if len(b) <= skip {
+		return "encrypted", nil
+	}
+	if len(b) > skip+5 && b[skip] == 101 && string(b[skip+2:skip+5]) == "GNU" {
+		switch b[skip+5] {
+		case 1:
+			return "stub", nil
+		case 2:
+			return "card", nil
+		}
+	}
+	return "encrypted", b[skip:]
+}
+
+// skeskS2K returns the S2K specifier of a symmetric-key encrypted session key packet.
+func skeskS2K(body []byte) []byte {
+	switch {
+	case len(body) > 2 && body[0] == 4: // version, cipher
+		return body[2:]
+	case len(body) > 3 && body[0] == 5: // version, cipher, AEAD mode
+		return body[3:]
+	case len(body) > 5 && body[0] == 6: // version, length, cipher, AEAD mode, S2K length
+		return body[5:]
+	}
+	return nil
+}
+
+// Argon2 S2K limits. RFC 9580 recommends 2 GiB of memory, or 64 MiB with three passes
+// where that is too much, as it is for a shared server. go-crypto accepts up to 2^31 KiB.
+const (
+	maxArgon2MemoryExp = 16 // 2^16 KiB
+	maxArgon2Passes    = 8
+)
+
+// checkS2K rejects an Argon2 S2K specifier (RFC 9580 section 3.7.1.4) that asks for
+// more than the limits, before the library derives a key with it.
+func checkS2K(s2k []byte) error {
+	if len(s2k) < 20 || s2k[0] != 4 {
+		return nil
+	}
+	passes, memExp := s2k[17], s2k[19]
+	if memExp > maxArgon2MemoryExp || passes > maxArgon2Passes {
+		return fmt.Errorf("Argon2 S2K asks for %d passes over 2^%d KiB of memory; at most %d passes over 2^%d KiB are accepted",
+			passes, memExp, maxArgon2Passes, maxArgon2MemoryExp)
+	}
+	return nil
+}
+
+func readMPI(b []byte) (mpi, rest []byte, err error) {
+	if len(b) < 2 {
+		return nil, nil, errors.New("truncated MPI")
+	}
+	n := (int(binary.BigEndian.Uint16(b)) + 7) / 8
+	if len(b) < 2+n {
+		return nil, nil, errors.New("truncated MPI")
+	}
+	return b[:2+n], b[2+n:], nil
+}
+
+// bitLen is the bit count an MPI declares.
+func bitLen(mpi []byte) int {
+	return int(binary.BigEndian.Uint16(mpi))
+}
+
+func readOID(b []byte) (string, []byte, error) {
+	if len(b) < 1 || b[0] == 0 || b[0] == 0xff || int(b[0]) >= len(b) {
+		return "", nil, errors.New("invalid curve OID")
+	}
+	n := int(b[0])
+	return hex.EncodeToString(b[1 : 1+n]), b[1+n:], nil
+}
+
+// sigInfo is what a signature packet says. Key flags, expiry and the primary user ID
+// flag are only taken from the hashed area.
+type sigInfo struct {
+	version   int
+	typ       byte
+	algo      byte
+	hash      byte
+	created   time.Time
+	keyExpiry uint32
+	flags     *byte
+	primary   bool
+	issuer    []byte // key ID
+	issuerFpr []byte
+}
+
+func parseSignature(body []byte) (*sigInfo, error) {
+	if len(body) < 1 {
+		return nil, errors.New("truncated signature")
+	}
+	s := &sigInfo{version: int(body[0])}
+	switch s.version {
+	case 2, 3:
+		if len(body) < 19 || body[1] != 5 {
+			return nil, errors.New("truncated signature")
+		}
+		s.typ = body[2]
+		s.created = time.Unix(int64(binary.BigEndian.Uint32(body[3:7])), 0).UTC()
+		s.issuer = body[7:15]
+		s.algo, s.hash = body[15], body[16]
+		return s, nil
+	case 4, 5, 6:
+	default:
+		return nil, fmt.Errorf("unsupported signature version %d", s.version)
+	}
+	if len(body) < 6 {
+		return nil, errors.New("truncated signature")
+	}
+	s.typ, s.algo, s.hash = body[1], body[2], body[3]
+	b := body[4:]
+	for hashed := true; ; hashed = false {
+		var n int
+		if s.version == 6 {
+			if len(b) < 4 {
+				return nil, errors.New("truncated signature")
+			}
+			n, b = int(binary.BigEndian.Uint32(b)), b[4:]
+		} else {
+			if len(b) < 2 {
+				return nil, errors.New("truncated signature")
+			}
+			n, b = int(binary.BigEndian.Uint16(b)), b[2:]
+		}
+		if n > len(b) {
+			return nil, errors.New("truncated signature")
+		}
+		if err := s.parseSubpackets(b[:n], hashed); err != nil {
+			return nil, err
+		}
+		b = b[n:]
+		if !hashed {
+			break
+		}
+	}
+	return s, nil
+}
+
+func (s *sigInfo) parseSubpackets(b []byte, hashed bool) error {
+	for len(b) > 0 {
+		n, _, rest, err := newLength(b)
+		if err != nil || n < 1 || n > len(rest) || b[0] >= 224 && b[0] < 255 {
+			return errors.New("invalid signature subpacket")
+		}
+		typ, data := rest[0]&0x7f, rest[1:n]
+		b = rest[n:]
+		switch {
+		case typ == 2 && len(data) == 4:
+			s.created = time.Unix(int64(binary.BigEndian.Uint32(data)), 0).UTC()
+		case typ == 16 && len(data) == 8:
+			s.issuer = data
+		case typ == 33 && len(data) > 1:
+			s.issuerFpr = data[1:]
+		case !hashed:
+		case typ == 9 && len(data) == 4:
+			s.keyExpiry = binary.BigEndian.Uint32(data)
+		case typ == 25 && len(data) == 1:
+			s.primary = data[0] != 0
+		case typ == 27 && len(data) >= 1:
+			f := data[0]
+			s.flags = &f
+		}
+	}
+	return nil
+}
+
+// by reports whether the signature claims to be made by the key with the given
+// fingerprint and key ID. Signatures naming no issuer are taken as made by it.
+func (s *sigInfo) by(k keyMaterial) bool {
+	if s.issuerFpr != nil {
+		return bytes.Equal(s.issuerFpr, k.fpr)
+	}
+	return s.issuer == nil || bytes.Equal(s.issuer, k.keyID)
+}
+
+// hashAlgos names the hash algorithm IDs of RFC 4880 and RFC 9580.
+var hashAlgos = map[byte]string{
+	1: "MD5", 2: "SHA1", 3: "RIPEMD160", 8: "SHA256", 9: "SHA384", 10: "SHA512", 11: "SHA224",
+	12: "SHA3-256", 14: "SHA3-512",
+}
+
+func hashName(h byte) string {
+	if n, ok := hashAlgos[h]; ok {
+		return n
+	}
+	return fmt.Sprintf("unknown (%d)", h)
+}
@@ -0,0 +1,171 @@
+package pki
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// reasonNames are the RFC 5280 CRLReason codes; 7 is unused.
+var reasonNames = map[int]string{
+	0: "unspecified", 1: "keyCompromise", 2: "cACompromise", 3: "affiliationChanged", 4: "superseded",
+	5: "cessationOfOperation", 6: "certificateHold", 8: "removeFromCRL", 9: "privilegeWithdrawn", 10: "aACompromise",
+}
+
+// ReasonName returns the RFC 5280 name of a CRL reason code.
+func ReasonName(code int) string {
+	if n, ok := reasonNames[code]; ok {
+		return n
+	}
+	return "unknown(" + strconv.Itoa(code) + ")"
+}
+
+// ParseReason accepts a reason code or its name in any case; "" means unspecified.
+func ParseReason(s string) (int, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+	if n, err := strconv.Atoi(s); err == nil {
+		if _, ok := reasonNames[n]; ok {
+			return n, nil
+		}
+		return 0, errors.New("unknown revocation reason: " + s)
+	}
+	for code, name := range reasonNames {
+		if strings.EqualFold(name, s) {
+			return code, nil
+		}
+	}
+	return 0, errors.New("unknown revocation reason: " + s)
+}
+
+// ParseSerial parses a certificate serial in hex, as displayed by openssl and returned by
+// the CA endpoints; colons, spaces and a 0x prefix are allowed.
+func ParseSerial(s string) (*big.Int, error) {
+	s = strings.NewReplacer(":", "", " ", "").Replace(strings.TrimSpace(s))
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X")
+	// SetString would take a sign; serials are positive and written without one
+	if s == "" || s[0] == '-' || s[0] == '+' {
+		return nil, errors.New("invalid serial: expected hex")
+	}
+	n, ok := new(big.Int).SetString(s, 16)
+	if !ok {
+		return nil, errors.New("invalid serial: expected hex")
+	}
+	return n, nil
+}
+
+// ParseCRL decodes a CRL given as PEM (X509 CRL block) or DER.
+func ParseCRL(data []byte) (*x509.RevocationList, error) {
+	if block, _ := pem.Decode(data); block != nil {
+		if block.Type != "X509 CRL" {
+			return nil, errors.New("expected X509 CRL pem block, got " + block.Type)
+		}
+		data = block.Bytes
+	}
+	return x509.ParseRevocationList(data)
+}
+
+type RevokedEntry struct {
+	Serial     string      `json:"serial"` // hex, as elsewhere in the CA endpoints
+	RevokedAt  time.Time   `json:"revoked_at"`
+	ReasonCode int         `json:"reason_code"`
+	Reason     string      `json:"reason"`
+	Extensions []Extension `json:"extensions,omitempty"`
+}
+
+type CRLInfo struct {
+	Issuer         string         `json:"issuer"`
+	Number         string         `json:"number,omitempty"`
+	ThisUpdate     time.Time      `json:"this_update"`
+	NextUpdate     *time.Time     `json:"next_update,omitempty"`
+	Status         string         `json:"status"` // current, expired or not_yet_valid
+	SigAlg         string         `json:"sig_alg"`
+	AuthorityKeyID string         `json:"authority_key_id,omitempty"`
+	RevokedCount   int            `json:"revoked_count"`
+	Revoked        []RevokedEntry `json:"revoked"`
+	Extensions     []Extension    `json:"extensions"`
+}
+
+func newRevokedEntry(e x509.RevocationListEntry) RevokedEntry {
+	return RevokedEntry{
+		Serial: e.SerialNumber.Text(16), RevokedAt: e.RevocationTime,
+		ReasonCode: e.ReasonCode, Reason: ReasonName(e.ReasonCode),
+		Extensions: DescribeExtensions(e.Extensions),
+	}
+}
+
+// NewCRLInfo describes crl; now determines whether it is current.
+func NewCRLInfo(crl *x509.RevocationList, now time.Time) CRLInfo {
+	info := CRLInfo{
+		Issuer: crl.Issuer.String(), ThisUpdate: crl.ThisUpdate, SigAlg: crl.SignatureAlgorithm.String(),
+		AuthorityKeyID: HexColon(crl.AuthorityKeyId), RevokedCount: len(crl.RevokedCertificateEntries),
+		Revoked: make([]RevokedEntry, 0, len(crl.RevokedCertificateEntries)), Extensions: DescribeExtensions(crl.Extensions),
+	}
+	if crl.Number != nil {
+		info.Number = crl.Number.String()
+	}
+	if !crl.NextUpdate.IsZero() {
+		next := crl.NextUpdate
+		info.NextUpdate = &next
+	}
+	switch {
+	case now.Before(crl.ThisUpdate):
+		info.Status = "not_yet_valid"
+	case info.NextUpdate != nil && now.After(crl.NextUpdate):
+		info.Status = "expired"
+	default:
+		info.Status = "current"
+	}
+	for _, e := range crl.RevokedCertificateEntries {
+		info.Revoked = append(info.Revoked, newRevokedEntry(e))
+	}
+	return info
+}
+
+// FindRevoked returns the CRL entry for serial, or nil when it is not listed.
+func FindRevoked(crl *x509.RevocationList, serial *big.Int) *RevokedEntry {
+	for _, e := range crl.RevokedCertificateEntries {
+		if e.SerialNumber.Cmp(serial) == 0 {
+			entry := newRevokedEntry(e)
+			return &entry
+		}
+	}
+	return nil
+}
+
+// CreateCRL signs a CRL listing entries. Go requires the issuer to carry a subject key
+// identifier; for CAs without one it is derived from the key, as RFC 5280 method 1 does.
+func CreateCRL(issuer *x509.Certificate, key crypto.Signer, entries []x509.RevocationListEntry, number *big.Int, thisUpdate, nextUpdate time.Time) ([]byte, error) {
+	if issuer.KeyUsage != 0 && issuer.KeyUsage&x509.KeyUsageCRLSign == 0 {
+		return nil, errors.New("issuer key usage does not include cRLSign")
+	}
+	if len(issuer.SubjectKeyId) == 0 {
+		ski, err := SubjectKeyID(issuer.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+		cp := *issuer
+		cp.SubjectKeyId = ski
+		issuer = &cp
+	}
+	tpl := &x509.RevocationList{
+		RevokedCertificateEntries: entries,
+		Number:                    number,
+		ThisUpdate:                thisUpdate,
+		NextUpdate:                nextUpdate,
+	}
+	return x509.CreateRevocationList(rand.Reader, tpl, issuer, key)
+}
+
+// EncodeCRLPEM wraps DER in an X509 CRL block.
+func EncodeCRLPEM(der []byte) string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: der}))
+}
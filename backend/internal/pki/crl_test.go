@@ -0,0 +1,23 @@
+package pki
+
+import "testing"
+
+func TestParseSerial(t *testing.T) {
+	for in, want := range map[string]string{
+		"0a":                "a",
+		"0x1F":              "1f",
+		"01:23:ab":          "123ab",
+		" 7f 00 ":           "7f00",
+		"00ff00ff00ff00ff0": "ff00ff00ff00ff0",
+	} {
+		n, err := ParseSerial(in)
+		if err != nil || n.Text(16) != want {
+			t.Errorf("%q: %v, %v", in, n, err)
+		}
+	}
+	for _, in := range []string{"", "0x", "-5", "+5", "0x-5", "xyz", "1.5"} {
+		if n, err := ParseSerial(in); err == nil {
+			t.Errorf("%q accepted as %v", in, n)
+		}
+	}
+}
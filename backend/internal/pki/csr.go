@@ -0,0 +1,269 @@
+package pki
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// AttributeSpec is one attribute of a relative distinguished name; Type is a short name
+// such as "OU" or a dotted OID.
+type AttributeSpec struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// ExtensionSpec is a caller-supplied extension. The value is DER given as hex or base64,
+// or a plain string which is encoded as a UTF8String.
+type ExtensionSpec struct {
+	OID         string `json:"oid"`
+	Critical    bool   `json:"critical"`
+	ValueHex    string `json:"value_hex"`
+	ValueBase64 string `json:"value_base64"`
+	ValueString string `json:"value_string"`
+}
+
+// CSRSpec describes a certificate signing request.
+type CSRSpec struct {
+	NameSpec
+	SANSpec
+	// RDNs are appended after the flat subject fields; each inner list becomes one
+	// (possibly multi-valued) RDN.
+	RDNs        [][]AttributeSpec `json:"rdns"`
+	KeyUsage    []string          `json:"key_usage"`
+	ExtKeyUsage []string          `json:"ext_key_usage"`
+	MustStaple  bool              `json:"must_staple"`
+	IsCA        bool              `json:"is_ca"`
+	PathLen     *int              `json:"path_len"`
+	Extensions  []ExtensionSpec   `json:"extensions"`
+	SigAlg      string            `json:"sig_alg"`
+}
+
+var attributeTypes = map[string]asn1.ObjectIdentifier{
+	"CN":           {2, 5, 4, 3},
+	"SN":           {2, 5, 4, 4},
+	"SERIALNUMBER": {2, 5, 4, 5},
+	"C":            {2, 5, 4, 6},
+	"L":            {2, 5, 4, 7},
+	"ST":           {2, 5, 4, 8},
+	"STREET":       {2, 5, 4, 9},
+	"O":            {2, 5, 4, 10},
+	"OU":           {2, 5, 4, 11},
+	"TITLE":        {2, 5, 4, 12},
+	"POSTALCODE":   {2, 5, 4, 17},
+	"GN":           {2, 5, 4, 42},
+	"GIVENNAME":    {2, 5, 4, 42},
+	"INITIALS":     {2, 5, 4, 43},
+	"PSEUDONYM":    {2, 5, 4, 65},
+	"DC":           {0, 9, 2342, 19200300, 100, 1, 25},
+	"UID":          {0, 9, 2342, 19200300, 100, 1, 1},
+	"EMAIL":        {1, 2, 840, 113549, 1, 9, 1},
+	"EMAILADDRESS": {1, 2, 840, 113549, 1, 9, 1},
+}
+
+// ia5Attributes must be encoded as IA5String rather than Printable/UTF8String.
+var ia5Attributes = map[string]bool{"0.9.2342.19200300.100.1.25": true, "1.2.840.113549.1.9.1": true}
+
+func (a AttributeSpec) attribute() (pkix.AttributeTypeAndValue, error) {
+	oid, ok := attributeTypes[strings.ToUpper(strings.TrimSpace(a.Type))]
+	if !ok {
+		var err error
+		if oid, err = ParseOID(a.Type); err != nil {
+			return pkix.AttributeTypeAndValue{}, fmt.Errorf("unknown attribute type %q", a.Type)
+		}
+	}
+	atv := pkix.AttributeTypeAndValue{Type: oid, Value: a.Value}
+	if ia5Attributes[oid.String()] {
+		atv.Value = asn1.RawValue{Tag: asn1.TagIA5String, Bytes: []byte(a.Value)}
+	}
+	return atv, nil
+}
+
+func (e ExtensionSpec) extension() (pkix.Extension, error) {
+	oid, err := ParseOID(e.OID)
+	if err != nil {
+		return pkix.Extension{}, err
+	}
+	ext := pkix.Extension{Id: oid, Critical: e.Critical}
+	switch {
+	case e.ValueHex != "":
+		ext.Value, err = hex.DecodeString(strings.NewReplacer(":", "", " ", "").Replace(e.ValueHex))
+	case e.ValueBase64 != "":
+		ext.Value, err = base64.StdEncoding.DecodeString(e.ValueBase64)
+	default:
+		ext.Value, err = asn1.MarshalWithParams(e.ValueString, "utf8")
+	}
+	if err != nil {
+		return pkix.Extension{}, fmt.Errorf("extension %s: invalid value", e.OID)
+	}
+	return ext, nil
+}
+
+// Template converts the spec into a request template, encoding the usage extensions
+// itself because x509.CreateCertificateRequest only handles SANs.
+func (s CSRSpec) Template() (*x509.CertificateRequest, error) {
+	tpl := &x509.CertificateRequest{Subject: s.NameSpec.Name()}
+	if err := s.SANSpec.apply(&tpl.DNSNames, &tpl.IPAddresses, &tpl.EmailAddresses, &tpl.URIs); err != nil {
+		return nil, err
+	}
+	if len(s.RDNs) > 0 {
+		seq := tpl.Subject.ToRDNSequence()
+		for _, rdn := range s.RDNs {
+			var set pkix.RelativeDistinguishedNameSET
+			for _, a := range rdn {
+				atv, err := a.attribute()
+				if err != nil {
+					return nil, err
+				}
+				set = append(set, atv)
+			}
+			if len(set) > 0 {
+				seq = append(seq, set)
+			}
+		}
+		raw, err := asn1.Marshal(seq)
+		if err != nil {
+			return nil, err
+		}
+		tpl.RawSubject = raw
+	}
+
+	ku, err := ParseKeyUsage(s.KeyUsage)
+	if err != nil {
+		return nil, err
+	}
+	if ku != 0 {
+		ext, err := KeyUsageExtension(ku)
+		if err != nil {
+			return nil, err
+		}
+		tpl.ExtraExtensions = append(tpl.ExtraExtensions, ext)
+	}
+	if len(s.ExtKeyUsage) > 0 {
+		var oids []asn1.ObjectIdentifier
+		for _, n := range s.ExtKeyUsage {
+			ekus, unknown, err := ParseExtKeyUsage([]string{n})
+			if err != nil {
+				return nil, err
+			}
+			if len(ekus) > 0 {
+				unknown = append(unknown, extKeyUsageOID(ekus[0]))
+			}
+			oids = append(oids, unknown...)
+		}
+		val, err := asn1.Marshal(oids)
+		if err != nil {
+			return nil, err
+		}
+		tpl.ExtraExtensions = append(tpl.ExtraExtensions, pkix.Extension{Id: OIDExtExtKeyUsage, Value: val})
+	}
+	if s.IsCA || s.PathLen != nil {
+		bc := struct {
+			IsCA    bool `asn1:"optional"`
+			PathLen int  `asn1:"optional,default:-1"`
+		}{IsCA: s.IsCA, PathLen: -1}
+		if s.PathLen != nil {
+			if !s.IsCA || *s.PathLen < 0 {
+				return nil, errors.New("path_len requires is_ca and must not be negative")
+			}
+			bc.PathLen = *s.PathLen
+		}
+		val, err := asn1.Marshal(bc)
+		if err != nil {
+			return nil, err
+		}
+		tpl.ExtraExtensions = append(tpl.ExtraExtensions, pkix.Extension{Id: OIDExtBasicConstraints, Critical: true, Value: val})
+	}
+	if s.MustStaple {
+		// TLS feature status_request (RFC 7633)
+		val, _ := asn1.Marshal([]int{5})
+		tpl.ExtraExtensions = append(tpl.ExtraExtensions, pkix.Extension{Id: OIDExtTLSFeature, Value: val})
+	}
+	for _, e := range s.Extensions {
+		ext, err := e.extension()
+		if err != nil {
+			return nil, err
+		}
+		for _, have := range tpl.ExtraExtensions {
+			if have.Id.Equal(ext.Id) {
+				return nil, fmt.Errorf("extension %s given twice", e.OID)
+			}
+		}
+		tpl.ExtraExtensions = append(tpl.ExtraExtensions, ext)
+	}
+	if s.SigAlg != "" {
+		if tpl.SignatureAlgorithm, err = ParseSignatureAlgorithm(s.SigAlg); err != nil {
+			return nil, err
+		}
+	}
+	return tpl, nil
+}
+
+// KeyUsageExtension encodes ku as a critical key usage extension.
+func KeyUsageExtension(ku x509.KeyUsage) (pkix.Extension, error) {
+	var b [2]byte
+	n := 0
+	for i := 0; i < 9; i++ {
+		if ku&(1<<i) != 0 {
+			b[i/8] |= 0x80 >> (i % 8)
+			n = i + 1
+		}
+	}
+	bytes := b[:(n+7)/8]
+	val, err := asn1.Marshal(asn1.BitString{Bytes: bytes, BitLength: n})
+	return pkix.Extension{Id: OIDExtKeyUsage, Critical: true, Value: val}, err
+}
+
+func extKeyUsageOID(e x509.ExtKeyUsage) asn1.ObjectIdentifier {
+	for o, v := range extKeyUsageOIDs {
+		if v == e {
+			oid, _ := ParseOID(o)
+			return oid
+		}
+	}
+	return nil
+}
+
+var signatureAlgorithms = []x509.SignatureAlgorithm{
+	x509.SHA256WithRSA, x509.SHA384WithRSA, x509.SHA512WithRSA,
+	x509.SHA256WithRSAPSS, x509.SHA384WithRSAPSS, x509.SHA512WithRSAPSS,
+	x509.ECDSAWithSHA256, x509.ECDSAWithSHA384, x509.ECDSAWithSHA512,
+	x509.PureEd25519, x509.SHA1WithRSA, x509.ECDSAWithSHA1,
+}
+
+// ParseSignatureAlgorithm accepts the names x509.SignatureAlgorithm prints ("SHA256-RSA",
+// "ECDSA-SHA384", "SHA256-RSAPSS", "Ed25519") as well as OpenSSL spellings such as
+// "sha256WithRSAEncryption", ignoring case and separators.
+func ParseSignatureAlgorithm(name string) (x509.SignatureAlgorithm, error) {
+	norm := func(s string) string {
+		return strings.NewReplacer("with", "", "encryption", "").Replace(normalizeUsage(s))
+	}
+	want := norm(name)
+	for _, a := range signatureAlgorithms {
+		if norm(a.String()) == want {
+			return a, nil
+		}
+	}
+	return x509.UnknownSignatureAlgorithm, fmt.Errorf("unsupported signature algorithm %q", name)
+}
+
+// CreateCSR signs the request described by spec with key and returns it PEM encoded.
+func CreateCSR(spec CSRSpec, key crypto.Signer) (string, error) {
+	tpl, err := spec.Template()
+	if err != nil {
+		return "", err
+	}
+	der, err := x509.CreateCertificateRequest(rand.Reader, tpl, key)
+	if err != nil {
+		return "", err
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der})), nil
+}
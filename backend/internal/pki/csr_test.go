@@ -0,0 +1,120 @@
+package pki
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"net"
+	"testing"
+)
+
+func TestParseSignatureAlgorithm(t *testing.T) {
+	for in, want := range map[string]x509.SignatureAlgorithm{
+		"SHA256-RSA":              x509.SHA256WithRSA,
+		"sha256WithRSAEncryption": x509.SHA256WithRSA,
+		"ecdsa-with-SHA384":       x509.ECDSAWithSHA384,
+		"sha512-rsapss":           x509.SHA512WithRSAPSS,
+		"ed25519":                 x509.PureEd25519,
+	} {
+		got, err := ParseSignatureAlgorithm(in)
+		if err != nil || got != want {
+			t.Errorf("%s: got %v, %v", in, got, err)
+		}
+	}
+}
+
+func TestCreateCSRRoundTrip(t *testing.T) {
+	rsaKey, err := GenerateKey("rsa", 2048, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPEM, err := MarshalPrivateKeyPEM(rsaKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	supplied, err := ParsePrivateKeyPEM(keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edKey, err := GenerateKey("ed25519", 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec := CSRSpec{
+		NameSpec: NameSpec{CN: "www.example.com", O: "Example"},
+		SANSpec:  SANSpec{DNS: []string{"www.example.com"}, IP: []string{"192.0.2.1"}},
+		RDNs: [][]AttributeSpec{
+			{{Type: "OU", Value: "Web"}, {Type: "UID", Value: "42"}},
+			{{Type: "DC", Value: "example"}},
+		},
+		KeyUsage:    []string{"digitalSignature", "keyEncipherment"},
+		ExtKeyUsage: []string{"serverAuth", "1.3.6.1.4.1.99999.1"},
+		MustStaple:  true,
+		Extensions: []ExtensionSpec{
+			{OID: "1.3.6.1.4.1.99999.2", ValueString: "hello"},
+			{OID: "1.3.6.1.4.1.99999.3", Critical: true, ValueHex: "05:00"},
+		},
+	}
+	for name, key := range map[string]crypto.Signer{"supplied rsa": supplied, "ed25519": edKey} {
+		out, err := CreateCSR(spec, key)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		block, _ := pem.Decode([]byte(out))
+		if block == nil || block.Type != "CERTIFICATE REQUEST" {
+			t.Fatalf("%s: not a csr: %q", name, out)
+		}
+		csr, err := x509.ParseCertificateRequest(block.Bytes)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if err := csr.CheckSignature(); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+		if !PublicKeysEqual(csr.PublicKey, key.Public()) {
+			t.Errorf("%s: csr carries a different key", name)
+		}
+		if len(csr.DNSNames) != 1 || len(csr.IPAddresses) != 1 || !csr.IPAddresses[0].Equal(net.ParseIP("192.0.2.1")) {
+			t.Errorf("%s: sans %v %v", name, csr.DNSNames, csr.IPAddresses)
+		}
+
+		var rdns pkix.RDNSequence
+		if _, err := asn1.Unmarshal(csr.RawSubject, &rdns); err != nil {
+			t.Fatal(err)
+		}
+		// O and CN from the flat fields, then the two requested RDNs
+		if len(rdns) != 4 || len(rdns[2]) != 2 || len(rdns[3]) != 1 {
+			t.Fatalf("%s: subject %v", name, rdns)
+		}
+		if ou := rdns[2][0]; !ou.Type.Equal(asn1.ObjectIdentifier{2, 5, 4, 11}) || ou.Value != "Web" {
+			t.Errorf("%s: multi-valued rdn %v", name, rdns[2])
+		}
+		if dc := rdns[3][0]; !dc.Type.Equal(asn1.ObjectIdentifier{0, 9, 2342, 19200300, 100, 1, 25}) || dc.Value != "example" {
+			t.Errorf("%s: dc %v", name, dc)
+		}
+
+		req := DecodeRequestedExtensions(csr.Extensions)
+		if len(req.KeyUsage) != 2 || len(req.ExtKeyUsage) != 2 || !req.MustStaple || len(req.Errors) != 0 {
+			t.Errorf("%s: requested %+v", name, req)
+		}
+		custom := map[string]pkix.Extension{}
+		for _, e := range csr.Extensions {
+			custom[e.Id.String()] = e
+		}
+		var s string
+		if _, err := asn1.Unmarshal(custom["1.3.6.1.4.1.99999.2"].Value, &s); err != nil || s != "hello" {
+			t.Errorf("%s: string extension %q, %v", name, s, err)
+		}
+		if e := custom["1.3.6.1.4.1.99999.3"]; !e.Critical || !bytes.Equal(e.Value, []byte{5, 0}) {
+			t.Errorf("%s: hex extension %+v", name, e)
+		}
+	}
+
+	spec.Extensions = append(spec.Extensions, ExtensionSpec{OID: OIDExtTLSFeature.String(), ValueHex: "3003020105"})
+	if _, err := CreateCSR(spec, edKey); err == nil {
+		t.Error("duplicate extension accepted")
+	}
+}
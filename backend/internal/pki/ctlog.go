@@ -0,0 +1,308 @@
+package pki
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	_ "embed"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/cryptobyte"
+	casn1 "golang.org/x/crypto/cryptobyte/asn1"
+)
+
+// bundledCTLogs is the log list compiled into the binary: a snapshot of
+// https://www.gstatic.com/ct/log_list/v3/log_list.json. Refresh it by running go generate
+// and committing the result, so that builds are reproducible and never fetch it.
+// CT_LOG_LIST or the log list endpoint replace it at run time with a newer copy.
+//
+//go:generate go run gen_ctlogs.go
+//go:embed data/ct_log_list.json
+var bundledCTLogs []byte
+
+// CTLog is one Certificate Transparency log.
+type CTLog struct {
+	Description string `json:"description"`
+	Operator    string `json:"operator"`
+	LogID       string `json:"log_id"` // base64 SHA-256 of Key
+	Key         string `json:"key"`    // base64 DER SubjectPublicKeyInfo
+	URL         string `json:"url,omitempty"`
+	State       string `json:"state,omitempty"` // usable, qualified, readonly, retired, pending or rejected
+	pub         crypto.PublicKey
+}
+
+// CTLogList maps log IDs to logs. It is safe for concurrent use and can be replaced at
+// run time when a newer list is published.
+type CTLogList struct {
+	mu       sync.RWMutex
+	logs     map[string]*CTLog
+	version  string
+	source   string
+	loadedAt time.Time
+}
+
+// NewCTLogList returns the bundled list.
+func NewCTLogList() *CTLogList {
+	l := &CTLogList{}
+	if err := l.Load(bundledCTLogs, "bundled"); err != nil {
+		panic("bundled ct log list: " + err.Error())
+	}
+	return l
+}
+
+// ctLogListJSON is the subset of the Chrome v3 log list schema that is used.
+type ctLogListJSON struct {
+	Version   string `json:"version"`
+	Operators []struct {
+		Name string      `json:"name"`
+		Logs []ctLogJSON `json:"logs"`
+		// static CT API logs; they sign SCTs the same way
+		TiledLogs []ctLogJSON `json:"tiled_logs"`
+	} `json:"operators"`
+}
+
+type ctLogJSON struct {
+	Description   string                     `json:"description"`
+	LogID         string                     `json:"log_id"`
+	Key           string                     `json:"key"`
+	URL           string                     `json:"url"`
+	SubmissionURL string                     `json:"submission_url"`
+	State         map[string]json.RawMessage `json:"state"`
+}
+
+// ParseCTLogList decodes a log list in the Chrome v3 format. Every key must hash to its
+// log ID, which catches truncated or hand-edited lists.
+func ParseCTLogList(data []byte) ([]CTLog, string, error) {
+	var doc ctLogListJSON
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, "", fmt.Errorf("malformed log list: %w", err)
+	}
+	var out []CTLog
+	for _, op := range doc.Operators {
+		for _, l := range append(op.Logs, op.TiledLogs...) {
+			der, err := base64.StdEncoding.DecodeString(l.Key)
+			if err != nil {
+				return nil, "", fmt.Errorf("log %q: invalid key encoding", l.Description)
+			}
+			pub, err := x509.ParsePKIXPublicKey(der)
+			if err != nil {
+				return nil, "", fmt.Errorf("log %q: %w", l.Description, err)
+			}
+			id := sha256.Sum256(der)
+			if base64.StdEncoding.EncodeToString(id[:]) != l.LogID {
+				return nil, "", fmt.Errorf("log %q: log_id does not match its key", l.Description)
+			}
+			log := CTLog{Description: l.Description, Operator: op.Name, LogID: l.LogID, Key: l.Key, URL: l.URL, pub: pub}
+			if log.URL == "" {
+				log.URL = l.SubmissionURL
+			}
+			for state := range l.State {
+				log.State = state
+			}
+			out = append(out, log)
+		}
+	}
+	return out, doc.Version, nil
+}
+
+// Load replaces the list with data; the previous list stays in place on error.
+func (l *CTLogList) Load(data []byte, source string) error {
+	logs, version, err := ParseCTLogList(data)
+	if err != nil {
+		return err
+	}
+	m := make(map[string]*CTLog, len(logs))
+	for i := range logs {
+		m[logs[i].LogID] = &logs[i]
+	}
+	l.mu.Lock()
+	l.logs, l.version, l.source, l.loadedAt = m, version, source, time.Now().UTC()
+	l.mu.Unlock()
+	return nil
+}
+
+// Lookup returns the log with the base64 log ID, or nil.
+func (l *CTLogList) Lookup(id string) *CTLog {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.logs[id]
+}
+
+// CTLogListInfo describes the loaded list.
+type CTLogListInfo struct {
+	Version  string    `json:"version"`
+	Source   string    `json:"source"`
+	LoadedAt time.Time `json:"loaded_at"`
+	Count    int       `json:"count"`
+	Logs     []CTLog   `json:"logs"`
+}
+
+func (l *CTLogList) Info() CTLogListInfo {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	info := CTLogListInfo{Version: l.version, Source: l.source, LoadedAt: l.loadedAt, Count: len(l.logs), Logs: []CTLog{}}
+	for _, log := range l.logs {
+		info.Logs = append(info.Logs, *log)
+	}
+	sort.Slice(info.Logs, func(i, j int) bool {
+		a, b := info.Logs[i], info.Logs[j]
+		return a.Operator < b.Operator || a.Operator == b.Operator && a.Description < b.Description
+	})
+	return info
+}
+
+// Annotate names the log of each SCT and checks its signature. leaf is the certificate
+// the SCTs belong to; issuer is needed for embedded SCTs, which sign the precertificate.
+func (l *CTLogList) Annotate(scts []SCT, leaf, issuer *x509.Certificate) {
+	for i := range scts {
+		sct := &scts[i]
+		log := l.Lookup(sct.LogID)
+		switch {
+		case sct.Version != 1:
+			sct.VerifyError = fmt.Sprintf("unsupported sct version %d", sct.Version)
+			continue
+		case log == nil:
+			sct.VerifyError = "log not in the loaded log list"
+			continue
+		}
+		sct.LogName, sct.LogOperator = log.Description, log.Operator
+		if sct.Source == "embedded" && issuer == nil {
+			sct.VerifyError = "issuer certificate needed to verify an embedded sct"
+			continue
+		}
+		err := VerifySCT(sct, leaf, issuer, log.pub)
+		ok := err == nil
+		sct.Verified = &ok
+		if err != nil {
+			sct.VerifyError = err.Error()
+		}
+	}
+}
+
+// VerifySCT checks the log signature of sct over leaf (RFC 6962 section 3.2).
+func VerifySCT(sct *SCT, leaf, issuer *x509.Certificate, pub crypto.PublicKey) error {
+	if sct.HashAlg != "sha256" {
+		return errors.New("unsupported sct hash " + sct.HashAlg)
+	}
+	sig, err := base64.StdEncoding.DecodeString(sct.Signature)
+	if err != nil {
+		return err
+	}
+	signed, err := sctSignedData(sct, leaf, issuer)
+	if err != nil {
+		return err
+	}
+	digest := sha256.Sum256(signed)
+	switch k := pub.(type) {
+	case *ecdsa.PublicKey:
+		if sct.SigAlg != "ecdsa" || !ecdsa.VerifyASN1(k, digest[:], sig) {
+			return errors.New("signature does not verify")
+		}
+	case *rsa.PublicKey:
+		if sct.SigAlg != "rsa" || rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], sig) != nil {
+			return errors.New("signature does not verify")
+		}
+	default:
+		return fmt.Errorf("unsupported log key %T", pub)
+	}
+	return nil
+}
+
+// sctSignedData serializes what the log signed. Embedded SCTs cover the precertificate,
+// rebuilt from the leaf by dropping the SCT list and bound to the issuer's key; SCTs
+// from TLS and OCSP cover the leaf itself.
+func sctSignedData(sct *SCT, leaf, issuer *x509.Certificate) ([]byte, error) {
+	exts, err := hex.DecodeString(sct.Extensions)
+	if err != nil {
+		return nil, err
+	}
+	b := cryptobyte.NewBuilder(nil)
+	b.AddUint8(0) // v1
+	b.AddUint8(0) // certificate_timestamp
+	b.AddUint64(uint64(sct.Timestamp.UnixMilli()))
+	if sct.Source == "embedded" {
+		tbs, err := precertTBS(leaf.RawTBSCertificate)
+		if err != nil {
+			return nil, err
+		}
+		keyHash := sha256.Sum256(issuer.RawSubjectPublicKeyInfo)
+		b.AddUint16(1) // precert_entry
+		b.AddBytes(keyHash[:])
+		b.AddUint24LengthPrefixed(func(b *cryptobyte.Builder) { b.AddBytes(tbs) })
+	} else {
+		b.AddUint16(0) // x509_entry
+		b.AddUint24LengthPrefixed(func(b *cryptobyte.Builder) { b.AddBytes(leaf.Raw) })
+	}
+	b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) { b.AddBytes(exts) })
+	return b.Bytes()
+}
+
+// precertTBS removes the SCT list extension from a TBSCertificate, which yields the
+// TBSCertificate the log signed for a precertificate issued by the final issuer.
+func precertTBS(tbs []byte) ([]byte, error) {
+	in := cryptobyte.String(tbs)
+	var body cryptobyte.String
+	if !in.ReadASN1(&body, casn1.SEQUENCE) {
+		return nil, errors.New("malformed tbs certificate")
+	}
+	extsTag := casn1.Tag(3).Constructed().ContextSpecific()
+	b := cryptobyte.NewBuilder(nil)
+	b.AddASN1(casn1.SEQUENCE, func(b *cryptobyte.Builder) {
+		for !body.Empty() {
+			var elem cryptobyte.String
+			var tag casn1.Tag
+			if !body.ReadAnyASN1Element(&elem, &tag) {
+				b.SetError(errors.New("malformed tbs certificate"))
+				return
+			}
+			if tag != extsTag {
+				b.AddBytes(elem)
+				continue
+			}
+			var wrapped, exts cryptobyte.String
+			if !elem.ReadASN1(&wrapped, extsTag) || !wrapped.ReadASN1(&exts, casn1.SEQUENCE) {
+				b.SetError(errors.New("malformed extensions"))
+				return
+			}
+			b.AddASN1(extsTag, func(b *cryptobyte.Builder) {
+				b.AddASN1(casn1.SEQUENCE, func(b *cryptobyte.Builder) {
+					for !exts.Empty() {
+						var ext, inner cryptobyte.String
+						var oid asn1.ObjectIdentifier
+						if !exts.ReadASN1Element(&ext, casn1.SEQUENCE) {
+							b.SetError(errors.New("malformed extension"))
+							return
+						}
+						inner = ext
+						if !inner.ReadASN1(&inner, casn1.SEQUENCE) || !inner.ReadASN1ObjectIdentifier(&oid) {
+							b.SetError(errors.New("malformed extension"))
+							return
+						}
+						if !oid.Equal(OIDExtSCTList) {
+							b.AddBytes(ext)
+						}
+					}
+				})
+			})
+		}
+	})
+	out, err := b.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	if bytes.Equal(out, tbs) {
+		return nil, errors.New("certificate has no embedded sct list")
+	}
+	return out, nil
+}
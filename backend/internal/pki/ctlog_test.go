@@ -0,0 +1,89 @@
+package pki_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"fmt"
+	"testing"
+	"time"
+
+	"engtools/backend/internal/pki"
+	"engtools/backend/internal/pki/pkitest"
+	"golang.org/x/crypto/cryptobyte"
+)
+
+// sctExtension serializes one SCT as a certificate SCT list extension.
+func sctExtension(logID []byte, ts time.Time, sig []byte) pkix.Extension {
+	b := cryptobyte.NewBuilder(nil)
+	b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+			b.AddUint8(0)
+			b.AddBytes(logID)
+			b.AddUint64(uint64(ts.UnixMilli()))
+			b.AddUint16(0)
+			b.AddUint8(4) // sha256
+			b.AddUint8(3) // ecdsa
+			b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) { b.AddBytes(sig) })
+		})
+	})
+	value, _ := asn1.Marshal(b.BytesOrPanic())
+	return pkix.Extension{Id: pki.OIDExtSCTList, Value: value}
+}
+
+func TestEmbeddedSCT(t *testing.T) {
+	logKey := pkitest.Key(t, "ec")
+	logDER, _ := x509.MarshalPKIXPublicKey(logKey.Public())
+	logID := sha256.Sum256(logDER)
+	list := fmt.Sprintf(`{"version":"test","operators":[{"name":"Test","logs":[{"description":"Test Log","log_id":%q,"key":%q,"url":"https://ct.test/","state":{"usable":{}}}]}]}`,
+		base64.StdEncoding.EncodeToString(logID[:]), base64.StdEncoding.EncodeToString(logDER))
+	logs := &pki.CTLogList{}
+	if err := logs.Load([]byte(list), "test"); err != nil {
+		t.Fatal(err)
+	}
+	if err := logs.Load([]byte(`{"operators":[{"name":"x","logs":[{"log_id":"AAAA","key":"`+base64.StdEncoding.EncodeToString(logDER)+`"}]}]}`), "bad"); err == nil {
+		t.Fatal("log id mismatch accepted")
+	}
+
+	issuer := pkitest.CA(t, "CA", nil)
+	ca, key := issuer.Cert, pkitest.Key(t, "ec")
+	ts := time.Now().Truncate(time.Millisecond)
+	notAfter := ts.Add(24 * time.Hour)
+	// the precertificate and the final certificate must match apart from the SCT list
+	spec := pki.CertSpec{NameSpec: pki.NameSpec{CN: "ct.test"}, SANSpec: pki.SANSpec{DNS: []string{"ct.test"}}, Serial: "0123456789abcdef", NotBefore: &ts, NotAfter: &notAfter}
+	withSCT := func(sig []byte) func(*x509.Certificate) {
+		return func(tpl *x509.Certificate) {
+			tpl.ExtraExtensions = []pkix.Extension{sctExtension(logID[:], ts, sig)}
+		}
+	}
+
+	// the log signs the precertificate, which is the final certificate minus the SCT list
+	placeholder := pkitest.Issue(t, spec, key, issuer, withSCT([]byte{0})).Cert
+	sct := &pki.SCT{Source: "embedded", Timestamp: ts}
+	signed, err := pki.SCTSignedData(sct, placeholder, ca)
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := sha256.Sum256(signed)
+	sig, _ := ecdsa.SignASN1(rand.Reader, logKey.(*ecdsa.PrivateKey), digest[:])
+	leaf := pkitest.Issue(t, spec, key, issuer, withSCT(sig)).Cert
+
+	scts := pki.NewCertInfo(leaf, time.Now()).SCTs
+	logs.Annotate(scts, leaf, ca)
+	if len(scts) != 1 || scts[0].LogName != "Test Log" || scts[0].Verified == nil || !*scts[0].Verified {
+		t.Fatalf("embedded sct not verified: %+v", scts)
+	}
+	// the same SCT presented over TLS covers the whole certificate and must fail
+	scts[0].Source, scts[0].Verified = "tls", nil
+	logs.Annotate(scts, leaf, ca)
+	if scts[0].Verified == nil || *scts[0].Verified {
+		t.Fatalf("tls sct verified against the wrong entry: %+v", scts[0])
+	}
+	if info := pki.NewCTLogList().Info(); info.Source != "bundled" || info.Version == "" {
+		t.Errorf("bundled list %+v", info)
+	}
+}
@@ -0,0 +1,231 @@
+package pki
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// TLSA certificate usages, selectors and matching types (RFC 6698, RFC 7218 names).
+const (
+	TLSAUsagePKIXTA = 0
+	TLSAUsagePKIXEE = 1
+	TLSAUsageDANETA = 2
+	TLSAUsageDANEEE = 3
+
+	TLSASelectorCert = 0
+	TLSASelectorSPKI = 1
+
+	TLSAMatchFull   = 0
+	TLSAMatchSHA256 = 1
+	TLSAMatchSHA512 = 2
+)
+
+var (
+	tlsaUsageNames    = []string{"PKIX-TA", "PKIX-EE", "DANE-TA", "DANE-EE"}
+	tlsaSelectorNames = []string{"Cert", "SPKI"}
+	tlsaMatchNames    = []string{"Full", "SHA2-256", "SHA2-512"}
+)
+
+func tlsaName(names []string, v uint8) string {
+	if int(v) < len(names) {
+		return names[v]
+	}
+	return fmt.Sprintf("unassigned(%d)", v)
+}
+
+// TLSA is one TLSA record. Data is lower-case hex.
+type TLSA struct {
+	Usage            uint8  `json:"usage"`
+	Selector         uint8  `json:"selector"`
+	MatchingType     uint8  `json:"matching_type"`
+	Data             string `json:"data"`
+	UsageName        string `json:"usage_name"`
+	SelectorName     string `json:"selector_name"`
+	MatchingTypeName string `json:"matching_type_name"`
+	// Record is the zone file line, or just the RDATA without an owner name.
+	Record string `json:"record"`
+}
+
+// NewTLSA fills in the names and zone file line of a record. owner may be empty.
+func NewTLSA(owner string, usage, selector, matchingType uint8, data []byte) TLSA {
+	t := TLSA{
+		Usage: usage, Selector: selector, MatchingType: matchingType, Data: hex.EncodeToString(data),
+		UsageName: tlsaName(tlsaUsageNames, usage), SelectorName: tlsaName(tlsaSelectorNames, selector),
+		MatchingTypeName: tlsaName(tlsaMatchNames, matchingType),
+	}
+	t.Record = fmt.Sprintf("%d %d %d %s", usage, selector, matchingType, t.Data)
+	if owner != "" {
+		t.Record = fmt.Sprintf("%s IN TLSA %s", strings.TrimSuffix(owner, ".")+".", t.Record)
+	}
+	return t
+}
+
+// TLSAOwner is the name TLSA records for a service live at, e.g. _25._tcp.mail.example.com.
+func TLSAOwner(host string, port int, proto string) string {
+	if proto == "" {
+		proto = "tcp"
+	}
+	return fmt.Sprintf("_%d._%s.%s", port, strings.ToLower(proto), strings.TrimSuffix(host, "."))
+}
+
+// TLSAData computes the association data for a selector and matching type. cert may be
+// nil when only the public key is known, in which case only the SPKI selector works.
+func TLSAData(cert *x509.Certificate, spki []byte, selector, matchingType uint8) ([]byte, error) {
+	var content []byte
+	switch selector {
+	case TLSASelectorCert:
+		if cert == nil {
+			return nil, errors.New("selector 0 needs the full certificate")
+		}
+		content = cert.Raw
+	case TLSASelectorSPKI:
+		if cert != nil {
+			spki = cert.RawSubjectPublicKeyInfo
+		}
+		content = spki
+	default:
+		return nil, fmt.Errorf("unsupported selector %d", selector)
+	}
+	switch matchingType {
+	case TLSAMatchFull:
+		return content, nil
+	case TLSAMatchSHA256:
+		sum := sha256.Sum256(content)
+		return sum[:], nil
+	case TLSAMatchSHA512:
+		sum := sha512.Sum512(content)
+		return sum[:], nil
+	}
+	return nil, fmt.Errorf("unsupported matching type %d", matchingType)
+}
+
+// GenerateTLSA lists the records for every usage, selector and matching type combination.
+// With only a public key (cert nil) the certificate selector is left out.
+func GenerateTLSA(owner string, cert *x509.Certificate, spki []byte) []TLSA {
+	var out []TLSA
+	for usage := uint8(0); usage <= TLSAUsageDANEEE; usage++ {
+		for selector := uint8(0); selector <= TLSASelectorSPKI; selector++ {
+			for mt := uint8(0); mt <= TLSAMatchSHA512; mt++ {
+				data, err := TLSAData(cert, spki, selector, mt)
+				if err != nil {
+					continue
+				}
+				out = append(out, NewTLSA(owner, usage, selector, mt, data))
+			}
+		}
+	}
+	return out
+}
+
+// Matches reports whether the record's data is that of crt.
+func (t TLSA) Matches(crt *x509.Certificate) (bool, error) {
+	want, err := hex.DecodeString(t.Data)
+	if err != nil {
+		return false, errors.New("malformed association data")
+	}
+	got, err := TLSAData(crt, nil, t.Selector, t.MatchingType)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(want, got), nil
+}
+
+// TLSAMatch is the outcome of checking one record against a served chain.
+type TLSAMatch struct {
+	TLSA
+	Matched bool `json:"matched"`
+	// Cert is the certificate the data matched, by position in the presented chain; -1
+	// is a trust anchor the server did not send, a system root or one in the record.
+	Cert        *int   `json:"cert,omitempty"`
+	CertSubject string `json:"cert_subject,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// MatchTLSA checks a record against the chain a server presented, leaf first, following
+// RFC 7671: EE usages match the leaf only, TA usages an issuer. The PKIX usages also need
+// the chain to validate; verified holds the PKIX validated chains, if any. For DANE-TA
+// the leaf must chain to the matched certificate and carry name.
+func MatchTLSA(t TLSA, presented []*x509.Certificate, verified [][]*x509.Certificate, name string) TLSAMatch {
+	m := TLSAMatch{TLSA: t}
+	if len(presented) == 0 {
+		m.Error = "no certificates presented"
+		return m
+	}
+	leaf := presented[0]
+	found := func(i int, crt *x509.Certificate) {
+		m.Matched, m.Cert, m.CertSubject = true, &i, crt.Subject.String()
+	}
+	check := func(crt *x509.Certificate) bool {
+		ok, err := t.Matches(crt)
+		if err != nil {
+			m.Error = err.Error()
+		}
+		return ok
+	}
+	switch t.Usage {
+	case TLSAUsagePKIXEE, TLSAUsageDANEEE:
+		if t.Usage == TLSAUsagePKIXEE && len(verified) == 0 {
+			m.Error = "chain does not pass PKIX validation"
+		} else if check(leaf) {
+			found(0, leaf)
+		}
+	case TLSAUsagePKIXTA:
+		if len(verified) == 0 {
+			m.Error = "chain does not pass PKIX validation"
+			return m
+		}
+		for _, chain := range verified {
+			for _, crt := range chain[1:] {
+				if check(crt) {
+					found(indexOf(presented, crt), crt)
+					return m
+				}
+			}
+		}
+	case TLSAUsageDANETA:
+		anchors := presented[1:]
+		// a full certificate record may carry a trust anchor the server does not send
+		if t.Selector == TLSASelectorCert && t.MatchingType == TLSAMatchFull {
+			if raw, err := hex.DecodeString(t.Data); err == nil {
+				if crt, err := x509.ParseCertificate(raw); err == nil && indexOf(presented, crt) < 0 {
+					anchors = append(anchors[:len(anchors):len(anchors)], crt)
+				}
+			}
+		}
+		for _, crt := range anchors {
+			if !check(crt) {
+				continue
+			}
+			// the trust anchor replaces the system roots
+			roots, inter := x509.NewCertPool(), x509.NewCertPool()
+			roots.AddCert(crt)
+			for _, c := range presented[1:] {
+				inter.AddCert(c)
+			}
+			if _, err := leaf.Verify(x509.VerifyOptions{Roots: roots, Intermediates: inter, DNSName: name}); err != nil {
+				m.Error = "leaf does not chain to the matched trust anchor: " + err.Error()
+				return m
+			}
+			found(indexOf(presented, crt), crt)
+			return m
+		}
+	default:
+		m.Error = fmt.Sprintf("unsupported usage %d", t.Usage)
+	}
+	return m
+}
+
+func indexOf(certs []*x509.Certificate, crt *x509.Certificate) int {
+	for i, c := range certs {
+		if c.Equal(crt) {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,52 @@
+package pki_test
+
+import (
+	"crypto/x509"
+	"testing"
+
+	"engtools/backend/internal/pki"
+	"engtools/backend/internal/pki/pkitest"
+)
+
+func TestMatchTLSA(t *testing.T) {
+	issuer := pkitest.CA(t, "CA", nil)
+	ca, leaf := issuer.Cert, pkitest.Leaf(t, "mx.test", issuer).Cert
+
+	records := pki.GenerateTLSA(pki.TLSAOwner("mx.test", 25, ""), leaf, nil)
+	if len(records) != 24 || records[0].Record[:25] != "_25._tcp.mx.test. IN TLSA" {
+		t.Fatalf("%d records, first %q", len(records), records[0].Record)
+	}
+	if n := len(pki.GenerateTLSA("", nil, leaf.RawSubjectPublicKeyInfo)); n != 12 {
+		t.Errorf("%d records from a public key", n)
+	}
+
+	chain := []*x509.Certificate{leaf, ca}
+	spki, _ := pki.TLSAData(leaf, nil, pki.TLSASelectorSPKI, pki.TLSAMatchSHA256)
+	caCert, _ := pki.TLSAData(ca, nil, pki.TLSASelectorCert, pki.TLSAMatchSHA512)
+	full, _ := pki.TLSAData(ca, nil, pki.TLSASelectorCert, pki.TLSAMatchFull)
+	for _, tc := range []struct {
+		rec      pki.TLSA
+		chain    []*x509.Certificate
+		verified [][]*x509.Certificate
+		cert     int
+	}{
+		{pki.NewTLSA("", pki.TLSAUsageDANEEE, 1, 1, spki), chain, nil, 0},
+		{pki.NewTLSA("", pki.TLSAUsageDANETA, 0, 2, caCert), chain, nil, 1},
+		{pki.NewTLSA("", pki.TLSAUsageDANETA, 0, 0, full), chain[:1], nil, -1}, // anchor only in DNS
+		{pki.NewTLSA("", pki.TLSAUsagePKIXEE, 1, 1, spki), chain, [][]*x509.Certificate{chain}, 0},
+		{pki.NewTLSA("", pki.TLSAUsagePKIXEE, 1, 1, spki), chain, nil, -2}, // not PKIX valid
+		{pki.NewTLSA("", pki.TLSAUsageDANEEE, 1, 1, caCert[:32]), chain, nil, -2},
+		{pki.NewTLSA("", pki.TLSAUsageDANETA, 1, 1, spki), chain, nil, -2}, // TA usage never matches the leaf
+	} {
+		m := pki.MatchTLSA(tc.rec, tc.chain, tc.verified, "mx.test")
+		if tc.cert == -2 {
+			if m.Matched {
+				t.Errorf("%s matched", tc.rec.Record)
+			}
+			continue
+		}
+		if !m.Matched || *m.Cert != tc.cert {
+			t.Errorf("%s: %+v", tc.rec.Record, m)
+		}
+	}
+}
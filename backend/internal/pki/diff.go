@@ -0,0 +1,258 @@
+package pki
+
+import (
+	"bytes"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math"
+	"reflect"
+	"time"
+)
+
+// FieldChange is a single-valued field that differs between two certificates.
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   any    `json:"old"`
+	New   any    `json:"new"`
+}
+
+// SetDiff compares two lists as sets.
+type SetDiff struct {
+	Added     []string `json:"added"`
+	Removed   []string `json:"removed"`
+	Unchanged []string `json:"unchanged"`
+}
+
+func (d SetDiff) changed() bool { return len(d.Added)+len(d.Removed) > 0 }
+
+func diffSets(old, new []string) SetDiff {
+	d := SetDiff{Added: []string{}, Removed: []string{}, Unchanged: []string{}}
+	inOld := map[string]bool{}
+	for _, s := range old {
+		inOld[s] = true
+	}
+	inNew := map[string]bool{}
+	for _, s := range new {
+		if inNew[s] {
+			continue
+		}
+		inNew[s] = true
+		if inOld[s] {
+			d.Unchanged = append(d.Unchanged, s)
+		} else {
+			d.Added = append(d.Added, s)
+		}
+	}
+	for _, s := range old {
+		if !inNew[s] {
+			d.Removed = append(d.Removed, s)
+			inNew[s] = true // report duplicates once
+		}
+	}
+	return d
+}
+
+// KeyDiff says whether the new certificate reuses the old key pair.
+type KeyDiff struct {
+	Reused  bool   `json:"reused"`
+	Old     string `json:"old"` // e.g. "EC 256 P-256"
+	New     string `json:"new"`
+	OldSPKI string `json:"old_spki_sha256"`
+	NewSPKI string `json:"new_spki_sha256"`
+}
+
+// ValidityDiff compares the validity periods.
+type ValidityDiff struct {
+	OldNotBefore    time.Time `json:"old_not_before"`
+	OldNotAfter     time.Time `json:"old_not_after"`
+	NewNotBefore    time.Time `json:"new_not_before"`
+	NewNotAfter     time.Time `json:"new_not_after"`
+	OldLifetimeDays int       `json:"old_lifetime_days"`
+	NewLifetimeDays int       `json:"new_lifetime_days"`
+	ExtendedByDays  int       `json:"extended_by_days"` // how much later the new one expires
+	OverlapDays     int       `json:"overlap_days"`     // both valid; negative is a gap
+}
+
+// ExtensionChange is an extension present in both certificates with a different value
+// or criticality.
+type ExtensionChange struct {
+	OID          string `json:"oid"`
+	Name         string `json:"name,omitempty"`
+	OldCritical  bool   `json:"old_critical"`
+	NewCritical  bool   `json:"new_critical"`
+	ValueChanged bool   `json:"value_changed"`
+}
+
+type ExtensionDiff struct {
+	Added   []Extension       `json:"added"`
+	Removed []Extension       `json:"removed"`
+	Changed []ExtensionChange `json:"changed"`
+}
+
+// CertDiff is a field-by-field comparison of two certificates, typically the one being
+// replaced and its renewal.
+type CertDiff struct {
+	Identical         bool          `json:"identical"`
+	Changes           []FieldChange `json:"changes"`
+	SubjectAttributes SetDiff       `json:"subject_attributes"`
+	SANs              SetDiff       `json:"sans"` // prefixed dns:, ip:, email: and uri:
+	Key               KeyDiff       `json:"key"`
+	Validity          ValidityDiff  `json:"validity"`
+	KeyUsage          SetDiff       `json:"key_usage"`
+	ExtKeyUsage       SetDiff       `json:"ext_key_usage"`
+	Policies          SetDiff       `json:"policies"`
+	OCSP              SetDiff       `json:"ocsp"`
+	IssuerURLs        SetDiff       `json:"issuer_urls"`
+	CRL               SetDiff       `json:"crl"`
+	Extensions        ExtensionDiff `json:"extensions"`
+}
+
+// DiffCertificates compares old with new using the fields of CertInfo.
+func DiffCertificates(old, new *x509.Certificate) CertDiff {
+	now := time.Now()
+	a, b := NewCertInfo(old, now), NewCertInfo(new, now)
+	d := CertDiff{Identical: bytes.Equal(old.Raw, new.Raw), Changes: []FieldChange{}}
+	field := func(name string, o, n any) {
+		if !reflect.DeepEqual(o, n) {
+			d.Changes = append(d.Changes, FieldChange{Field: name, Old: o, New: n})
+		}
+	}
+	field("version", a.Version, b.Version)
+	field("subject", a.Subject, b.Subject)
+	field("issuer", a.Issuer, b.Issuer)
+	field("serial", a.SerialHex, b.SerialHex)
+	field("sig_alg", a.SigAlg, b.SigAlg)
+	field("is_ca", a.IsCA, b.IsCA)
+	field("max_path_len", derefInt(a.MaxPathLen), derefInt(b.MaxPathLen))
+	field("subject_key_id", a.SubjectKeyID, b.SubjectKeyID)
+	field("authority_key_id", a.AuthorityKeyID, b.AuthorityKeyID)
+	field("must_staple", a.MustStaple, b.MustStaple)
+	field("self_signed", a.SelfSigned, b.SelfSigned)
+	field("name_constraints", a.NameConstraints, b.NameConstraints)
+
+	d.SubjectAttributes = diffSets(nameAttributes(old.Subject), nameAttributes(new.Subject))
+	d.SANs = diffSets(sanList(a), sanList(b))
+	d.Key = KeyDiff{
+		Reused: bytes.Equal(old.RawSubjectPublicKeyInfo, new.RawSubjectPublicKeyInfo),
+		Old:    keyString(a), New: keyString(b), OldSPKI: a.Fingerprints.SPKISHA256, NewSPKI: b.Fingerprints.SPKISHA256,
+	}
+	days := func(d time.Duration) int { return int(math.Floor(d.Hours() / 24)) }
+	d.Validity = ValidityDiff{
+		OldNotBefore: old.NotBefore, OldNotAfter: old.NotAfter, NewNotBefore: new.NotBefore, NewNotAfter: new.NotAfter,
+		OldLifetimeDays: days(old.NotAfter.Sub(old.NotBefore)), NewLifetimeDays: days(new.NotAfter.Sub(new.NotBefore)),
+		ExtendedByDays: days(new.NotAfter.Sub(old.NotAfter)), OverlapDays: days(old.NotAfter.Sub(new.NotBefore)),
+	}
+	d.KeyUsage = diffSets(a.KeyUsage, b.KeyUsage)
+	d.ExtKeyUsage = diffSets(a.ExtKeyUsage, b.ExtKeyUsage)
+	d.Policies = diffSets(policyList(a.Policies), policyList(b.Policies))
+	d.OCSP = diffSets(a.OCSP, b.OCSP)
+	d.IssuerURLs = diffSets(a.IssuerURLs, b.IssuerURLs)
+	d.CRL = diffSets(a.CRL, b.CRL)
+	d.Extensions = diffExtensions(old.Extensions, new.Extensions)
+	return d
+}
+
+func derefInt(p *int) any {
+	if p == nil {
+		return nil
+	}
+	return *p
+}
+
+func nameAttributes(n pkix.Name) []string {
+	var out []string
+	for _, atv := range n.Names {
+		out = append(out, pkix.RDNSequence{{atv}}.String())
+	}
+	return out
+}
+
+func sanList(info CertInfo) []string {
+	var out []string
+	for _, group := range []struct {
+		prefix string
+		values []string
+	}{{"dns:", info.DNS}, {"ip:", info.IP}, {"email:", info.Email}, {"uri:", info.URI}} {
+		for _, v := range group.values {
+			out = append(out, group.prefix+v)
+		}
+	}
+	return out
+}
+
+func keyString(info CertInfo) string {
+	s := fmt.Sprintf("%s %d", info.KeyAlg, info.KeyBits)
+	if info.KeyCurve != "" {
+		s += " " + info.KeyCurve
+	}
+	return s
+}
+
+func policyList(policies []Policy) []string {
+	out := make([]string, 0, len(policies))
+	for _, p := range policies {
+		out = append(out, p.OID)
+	}
+	return out
+}
+
+func diffExtensions(old, new []pkix.Extension) ExtensionDiff {
+	d := ExtensionDiff{Added: []Extension{}, Removed: []Extension{}, Changed: []ExtensionChange{}}
+	byOID := map[string]pkix.Extension{}
+	for _, e := range old {
+		byOID[e.Id.String()] = e
+	}
+	seen := map[string]bool{}
+	for _, e := range new {
+		oid := e.Id.String()
+		seen[oid] = true
+		prev, ok := byOID[oid]
+		if !ok {
+			d.Added = append(d.Added, DescribeExtensions([]pkix.Extension{e})...)
+			continue
+		}
+		if prev.Critical != e.Critical || !bytes.Equal(prev.Value, e.Value) {
+			d.Changed = append(d.Changed, ExtensionChange{
+				OID: oid, Name: extensionNames[oid], OldCritical: prev.Critical, NewCritical: e.Critical,
+				ValueChanged: !bytes.Equal(prev.Value, e.Value),
+			})
+		}
+	}
+	for _, e := range old {
+		if !seen[e.Id.String()] {
+			d.Removed = append(d.Removed, DescribeExtensions([]pkix.Extension{e})...)
+		}
+	}
+	return d
+}
+
+// ChainDiff compares the issuing certificates of two chains, matched by fingerprint.
+type ChainDiff struct {
+	Changed bool          `json:"changed"`
+	Old     []CertSummary `json:"old"`
+	New     []CertSummary `json:"new"`
+	// Added and Removed list "subject (sha256)" of intermediates and roots
+	Added   []string `json:"added"`
+	Removed []string `json:"removed"`
+}
+
+// DiffChains compares the certificates that follow the leaves of two chains.
+func DiffChains(old, new []*x509.Certificate) ChainDiff {
+	label := func(certs []*x509.Certificate) ([]CertSummary, []string) {
+		sums, labels := []CertSummary{}, []string{}
+		for _, c := range certs {
+			s := Summarize(c, "supplied")
+			sums = append(sums, s)
+			labels = append(labels, fmt.Sprintf("%s (%s)", s.Subject, s.SHA256))
+		}
+		return sums, labels
+	}
+	d := ChainDiff{}
+	var oldLabels, newLabels []string
+	d.Old, oldLabels = label(old)
+	d.New, newLabels = label(new)
+	sets := diffSets(oldLabels, newLabels)
+	d.Added, d.Removed, d.Changed = sets.Added, sets.Removed, sets.changed() || len(old) != len(new)
+	return d
+}
@@ -0,0 +1,41 @@
+package pki_test
+
+import (
+	"testing"
+	"time"
+
+	"engtools/backend/internal/pki"
+	"engtools/backend/internal/pki/pkitest"
+)
+
+func TestDiffCertificates(t *testing.T) {
+	ca := pkitest.CA(t, "CA", nil)
+	key := pkitest.Key(t, "ec")
+	old := pkitest.Issue(t, pki.CertSpec{NameSpec: pki.NameSpec{CN: "www.test", O: "Old"}, SANSpec: pki.SANSpec{DNS: []string{"www.test", "old.test"}}}, key, ca).Cert
+	notAfter := old.NotAfter.Add(90 * 24 * time.Hour)
+	renewed := pkitest.Issue(t, pki.CertSpec{NameSpec: pki.NameSpec{CN: "www.test", O: "New"}, SANSpec: pki.SANSpec{DNS: []string{"www.test", "api.test"}}, NotAfter: &notAfter}, key, ca).Cert
+
+	d := pki.DiffCertificates(old, renewed)
+	if d.Identical || !d.Key.Reused {
+		t.Errorf("identical %v, key reused %v", d.Identical, d.Key.Reused)
+	}
+	if len(d.SANs.Added) != 1 || d.SANs.Added[0] != "dns:api.test" || len(d.SANs.Removed) != 1 || d.SANs.Removed[0] != "dns:old.test" {
+		t.Errorf("sans %+v", d.SANs)
+	}
+	if len(d.SubjectAttributes.Added) != 1 || d.SubjectAttributes.Added[0] != "O=New" {
+		t.Errorf("subject attributes %+v", d.SubjectAttributes)
+	}
+	fields := map[string]bool{}
+	for _, c := range d.Changes {
+		fields[c.Field] = true
+	}
+	if !fields["subject"] || !fields["serial"] || fields["issuer"] || fields["subject_key_id"] {
+		t.Errorf("changes %+v", d.Changes)
+	}
+	if d.Validity.ExtendedByDays < 89 || len(d.Extensions.Changed) != 1 || d.Extensions.Changed[0].Name != "subjectAltName" {
+		t.Errorf("validity %+v, extensions %+v", d.Validity, d.Extensions)
+	}
+	if !pki.DiffCertificates(old, old).Identical {
+		t.Error("certificate differs from itself")
+	}
+}
@@ -0,0 +1,11 @@
+package pki
+
+// SCTSignedData exposes sctSignedData to the external tests, which sign test SCTs with it.
+var SCTSignedData = sctSignedData
+
+// SetPKCS12IterationBudget lowers the per-file KDF budget and returns a func restoring it.
+func SetPKCS12IterationBudget(n int) func() {
+	old := pkcs12MaxTotalIterations
+	pkcs12MaxTotalIterations = n
+	return func() { pkcs12MaxTotalIterations = old }
+}
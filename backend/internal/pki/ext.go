@@ -0,0 +1,240 @@
+package pki
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/hex"
+)
+
+var (
+	OIDExtSubjectKeyID       = asn1.ObjectIdentifier{2, 5, 29, 14}
+	OIDExtKeyUsage           = asn1.ObjectIdentifier{2, 5, 29, 15}
+	OIDExtSubjectAltName     = asn1.ObjectIdentifier{2, 5, 29, 17}
+	OIDExtBasicConstraints   = asn1.ObjectIdentifier{2, 5, 29, 19}
+	OIDExtNameConstraints    = asn1.ObjectIdentifier{2, 5, 29, 30}
+	OIDExtCRLDistribution    = asn1.ObjectIdentifier{2, 5, 29, 31}
+	OIDExtCertPolicies       = asn1.ObjectIdentifier{2, 5, 29, 32}
+	OIDExtAuthorityKeyID     = asn1.ObjectIdentifier{2, 5, 29, 35}
+	OIDExtExtKeyUsage        = asn1.ObjectIdentifier{2, 5, 29, 37}
+	OIDExtAuthorityInfo      = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 1, 1}
+	OIDExtTLSFeature         = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 1, 24}
+	OIDExtSCTList            = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 2}
+	OIDExtCTPoison           = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 3}
+	OIDExtOCSPSCTList        = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 5}
+	OIDExtOCSPNoCheck        = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 5}
+	OIDExtCRLNumber          = asn1.ObjectIdentifier{2, 5, 29, 20}
+	OIDExtDeltaCRLIndicator  = asn1.ObjectIdentifier{2, 5, 29, 27}
+	OIDExtIssuingDistPoint   = asn1.ObjectIdentifier{2, 5, 29, 28}
+	OIDExtCRLReason          = asn1.ObjectIdentifier{2, 5, 29, 21}
+	OIDExtInvalidityDate     = asn1.ObjectIdentifier{2, 5, 29, 24}
+	OIDExtPolicyConstraints  = asn1.ObjectIdentifier{2, 5, 29, 36}
+	OIDExtInhibitAnyPolicy   = asn1.ObjectIdentifier{2, 5, 29, 54}
+	OIDExtSubjectInfoAccess  = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 1, 11}
+	OIDExtNetscapeCertType   = asn1.ObjectIdentifier{2, 16, 840, 1, 113730, 1, 1}
+	OIDExtNetscapeComment    = asn1.ObjectIdentifier{2, 16, 840, 1, 113730, 1, 13}
+	OIDExtMSCertTemplateName = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 20, 2}
+	OIDExtMSCertTemplate     = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 21, 7}
+	OIDExtMSAppPolicies      = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 21, 10}
+)
+
+var extensionNames = map[string]string{
+	OIDExtSubjectKeyID.String():       "subjectKeyIdentifier",
+	OIDExtKeyUsage.String():           "keyUsage",
+	OIDExtSubjectAltName.String():     "subjectAltName",
+	OIDExtBasicConstraints.String():   "basicConstraints",
+	OIDExtNameConstraints.String():    "nameConstraints",
+	OIDExtCRLDistribution.String():    "cRLDistributionPoints",
+	OIDExtCertPolicies.String():       "certificatePolicies",
+	OIDExtAuthorityKeyID.String():     "authorityKeyIdentifier",
+	OIDExtExtKeyUsage.String():        "extKeyUsage",
+	OIDExtAuthorityInfo.String():      "authorityInfoAccess",
+	OIDExtTLSFeature.String():         "tlsFeature",
+	OIDExtSCTList.String():            "signedCertificateTimestampList",
+	OIDExtOCSPSCTList.String():        "ocspSignedCertificateTimestampList",
+	OIDExtCTPoison.String():           "ctPrecertificatePoison",
+	OIDExtOCSPNoCheck.String():        "ocspNoCheck",
+	OIDExtCRLNumber.String():          "cRLNumber",
+	OIDExtDeltaCRLIndicator.String():  "deltaCRLIndicator",
+	OIDExtIssuingDistPoint.String():   "issuingDistributionPoint",
+	OIDExtCRLReason.String():          "cRLReason",
+	OIDExtInvalidityDate.String():     "invalidityDate",
+	OIDExtPolicyConstraints.String():  "policyConstraints",
+	OIDExtInhibitAnyPolicy.String():   "inhibitAnyPolicy",
+	OIDExtSubjectInfoAccess.String():  "subjectInfoAccess",
+	OIDExtNetscapeCertType.String():   "netscapeCertType",
+	OIDExtNetscapeComment.String():    "netscapeComment",
+	OIDExtMSCertTemplateName.String(): "msCertificateTemplateName",
+	OIDExtMSCertTemplate.String():     "msCertificateTemplate",
+	OIDExtMSAppPolicies.String():      "msApplicationPolicies",
+}
+
+// ExtensionName returns the conventional name of an extension OID, or "" if unknown.
+func ExtensionName(oid asn1.ObjectIdentifier) string {
+	return extensionNames[oid.String()]
+}
+
+// Extension is the JSON view of a single X.509 extension.
+type Extension struct {
+	OID      string `json:"oid"`
+	Name     string `json:"name,omitempty"`
+	Critical bool   `json:"critical"`
+	Value    string `json:"value_hex,omitempty"`
+}
+
+// DescribeExtensions lists exts; the raw value is included only for extensions without a
+// known name, since the named ones are decoded into dedicated fields by the callers.
+func DescribeExtensions(exts []pkix.Extension) []Extension {
+	out := make([]Extension, 0, len(exts))
+	for _, e := range exts {
+		x := Extension{OID: e.Id.String(), Name: ExtensionName(e.Id), Critical: e.Critical}
+		if x.Name == "" {
+			x.Value = hex.EncodeToString(e.Value)
+		}
+		out = append(out, x)
+	}
+	return out
+}
+
+// KeyUsageStrings names the bits set in ku.
+func KeyUsageStrings(ku x509.KeyUsage) []string {
+	names := []string{"DigitalSignature", "ContentCommitment", "KeyEncipherment", "DataEncipherment", "KeyAgreement", "CertSign", "CRLSign", "EncipherOnly", "DecipherOnly"}
+	var out []string
+	for i, n := range names {
+		if ku&(1<<i) != 0 {
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+var extKeyUsageStrings = map[x509.ExtKeyUsage]string{
+	x509.ExtKeyUsageAny:                            "Any",
+	x509.ExtKeyUsageServerAuth:                     "ServerAuth",
+	x509.ExtKeyUsageClientAuth:                     "ClientAuth",
+	x509.ExtKeyUsageCodeSigning:                    "CodeSigning",
+	x509.ExtKeyUsageEmailProtection:                "EmailProtection",
+	x509.ExtKeyUsageIPSECEndSystem:                 "IPSECEndSystem",
+	x509.ExtKeyUsageIPSECTunnel:                    "IPSECTunnel",
+	x509.ExtKeyUsageIPSECUser:                      "IPSECUser",
+	x509.ExtKeyUsageTimeStamping:                   "TimeStamping",
+	x509.ExtKeyUsageOCSPSigning:                    "OCSPSigning",
+	x509.ExtKeyUsageMicrosoftServerGatedCrypto:     "MicrosoftServerGatedCrypto",
+	x509.ExtKeyUsageNetscapeServerGatedCrypto:      "NetscapeServerGatedCrypto",
+	x509.ExtKeyUsageMicrosoftCommercialCodeSigning: "MicrosoftCommercialCodeSigning",
+	x509.ExtKeyUsageMicrosoftKernelCodeSigning:     "MicrosoftKernelCodeSigning",
+}
+
+// ExtKeyUsageStrings names known usages and renders unknown ones as dotted OIDs.
+func ExtKeyUsageStrings(ekus []x509.ExtKeyUsage, unknown []asn1.ObjectIdentifier) []string {
+	var out []string
+	for _, e := range ekus {
+		if n, ok := extKeyUsageStrings[e]; ok {
+			out = append(out, n)
+		} else {
+			out = append(out, "Unknown")
+		}
+	}
+	for _, oid := range unknown {
+		out = append(out, oid.String())
+	}
+	return out
+}
+
+var extKeyUsageOIDs = map[string]x509.ExtKeyUsage{
+	"2.5.29.37.0":            x509.ExtKeyUsageAny,
+	"1.3.6.1.5.5.7.3.1":      x509.ExtKeyUsageServerAuth,
+	"1.3.6.1.5.5.7.3.2":      x509.ExtKeyUsageClientAuth,
+	"1.3.6.1.5.5.7.3.3":      x509.ExtKeyUsageCodeSigning,
+	"1.3.6.1.5.5.7.3.4":      x509.ExtKeyUsageEmailProtection,
+	"1.3.6.1.5.5.7.3.5":      x509.ExtKeyUsageIPSECEndSystem,
+	"1.3.6.1.5.5.7.3.6":      x509.ExtKeyUsageIPSECTunnel,
+	"1.3.6.1.5.5.7.3.7":      x509.ExtKeyUsageIPSECUser,
+	"1.3.6.1.5.5.7.3.8":      x509.ExtKeyUsageTimeStamping,
+	"1.3.6.1.5.5.7.3.9":      x509.ExtKeyUsageOCSPSigning,
+	"1.3.6.1.4.1.311.10.3.3": x509.ExtKeyUsageMicrosoftServerGatedCrypto,
+	"2.16.840.1.113730.4.1":  x509.ExtKeyUsageNetscapeServerGatedCrypto,
+	"1.3.6.1.4.1.311.2.1.22": x509.ExtKeyUsageMicrosoftCommercialCodeSigning,
+	"1.3.6.1.4.1.311.61.1.1": x509.ExtKeyUsageMicrosoftKernelCodeSigning,
+}
+
+// RequestedExtensions decodes the usage-related extensions of a CSR, which the standard
+// library only exposes as raw values. A malformed extension leaves its field empty and
+// is reported in Errors under the extension name, so the rest can still be shown.
+type RequestedExtensions struct {
+	KeyUsage    []string          `json:"key_usage,omitempty"`
+	ExtKeyUsage []string          `json:"ext_key_usage,omitempty"`
+	IsCA        *bool             `json:"is_ca,omitempty"`
+	PathLen     *int              `json:"path_len,omitempty"`
+	MustStaple  bool              `json:"must_staple"`
+	Errors      map[string]string `json:"errors,omitempty"`
+}
+
+func DecodeRequestedExtensions(exts []pkix.Extension) *RequestedExtensions {
+	out := &RequestedExtensions{}
+	fail := func(e pkix.Extension, msg string) {
+		if out.Errors == nil {
+			out.Errors = map[string]string{}
+		}
+		out.Errors[ExtensionName(e.Id)] = msg
+	}
+	for _, e := range exts {
+		switch {
+		case e.Id.Equal(OIDExtKeyUsage):
+			var bits asn1.BitString
+			if rest, err := asn1.Unmarshal(e.Value, &bits); err != nil || len(rest) > 0 {
+				fail(e, "malformed key usage extension")
+				continue
+			}
+			var ku x509.KeyUsage
+			for i := 0; i < 9; i++ {
+				if bits.At(i) != 0 {
+					ku |= 1 << i
+				}
+			}
+			out.KeyUsage = KeyUsageStrings(ku)
+		case e.Id.Equal(OIDExtExtKeyUsage):
+			var oids []asn1.ObjectIdentifier
+			if rest, err := asn1.Unmarshal(e.Value, &oids); err != nil || len(rest) > 0 {
+				fail(e, "malformed extended key usage extension")
+				continue
+			}
+			var known []x509.ExtKeyUsage
+			var unknown []asn1.ObjectIdentifier
+			for _, oid := range oids {
+				if v, ok := extKeyUsageOIDs[oid.String()]; ok {
+					known = append(known, v)
+				} else {
+					unknown = append(unknown, oid)
+				}
+			}
+			out.ExtKeyUsage = ExtKeyUsageStrings(known, unknown)
+		case e.Id.Equal(OIDExtBasicConstraints):
+			var bc struct {
+				IsCA    bool `asn1:"optional"`
+				PathLen int  `asn1:"optional,default:-1"`
+			}
+			if rest, err := asn1.Unmarshal(e.Value, &bc); err != nil || len(rest) > 0 {
+				fail(e, "malformed basic constraints extension")
+				continue
+			}
+			out.IsCA = &bc.IsCA
+			if bc.PathLen >= 0 {
+				out.PathLen = &bc.PathLen
+			}
+		case e.Id.Equal(OIDExtTLSFeature):
+			var features []int
+			if rest, err := asn1.Unmarshal(e.Value, &features); err != nil || len(rest) > 0 {
+				fail(e, "malformed tls feature extension")
+				continue
+			}
+			for _, f := range features {
+				// status_request (5) is the OCSP must-staple feature
+				if f == 5 {
+					out.MustStaple = true
+				}
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,39 @@
+package pki
+
+import (
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"testing"
+)
+
+func TestDecodeRequestedExtensions(t *testing.T) {
+	eku, _ := asn1.Marshal([]asn1.ObjectIdentifier{{1, 3, 6, 1, 5, 5, 7, 3, 1}})
+	mustStaple, _ := asn1.Marshal([]int{5})
+	bc, _ := asn1.Marshal(struct {
+		IsCA    bool
+		PathLen int
+	}{true, 0})
+	garbage := []byte{0x30, 0x05, 0x01}
+
+	req := DecodeRequestedExtensions([]pkix.Extension{
+		{Id: OIDExtKeyUsage, Value: garbage},
+		{Id: OIDExtExtKeyUsage, Value: eku},
+		{Id: OIDExtBasicConstraints, Value: bc},
+		{Id: OIDExtTLSFeature, Value: mustStaple},
+	})
+	if req.KeyUsage != nil || req.Errors["keyUsage"] == "" || len(req.Errors) != 1 {
+		t.Errorf("malformed key usage: %+v", req)
+	}
+	if len(req.ExtKeyUsage) != 1 || req.ExtKeyUsage[0] != "ServerAuth" || req.IsCA == nil || !*req.IsCA || req.PathLen == nil || *req.PathLen != 0 || !req.MustStaple {
+		t.Errorf("well-formed extensions lost: %+v", req)
+	}
+
+	req = DecodeRequestedExtensions([]pkix.Extension{
+		{Id: OIDExtExtKeyUsage, Value: garbage},
+		{Id: OIDExtBasicConstraints, Value: append(bc, 0)},
+		{Id: OIDExtTLSFeature, Value: garbage},
+	})
+	if req.ExtKeyUsage != nil || req.IsCA != nil || req.MustStaple || len(req.Errors) != 3 || req.Errors["basicConstraints"] == "" {
+		t.Errorf("malformed extensions: %+v", req)
+	}
+}
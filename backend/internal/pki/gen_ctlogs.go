@@ -0,0 +1,45 @@
+//go:build ignore
+
+// gen_ctlogs refreshes data/ct_log_list.json from Chrome's published log list. Run it with
+// go generate before a release and commit the file, so the binary ships a current snapshot.
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"engtools/backend/internal/pki"
+)
+
+const logListURL = "https://www.gstatic.com/ct/log_list/v3/log_list.json"
+
+func main() {
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Get(logListURL)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		log.Fatalf("%s: %s", logListURL, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 8<<20))
+	if err != nil {
+		log.Fatal(err)
+	}
+	logs, version, err := pki.ParseCTLogList(data)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(logs) == 0 {
+		log.Fatal("log list has no logs")
+	}
+	if err := os.WriteFile("data/ct_log_list.json", data, 0o644); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("ct log list %s: %d logs\n", version, len(logs))
+}
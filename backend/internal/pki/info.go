@@ -0,0 +1,190 @@
+package pki
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
+	"math"
+	"strings"
+	"time"
+
+	"engtools/backend/internal/asn1dump"
+)
+
+// Fingerprints are upper-case hex with colons, the form browsers and openssl display.
+type Fingerprints struct {
+	SHA1       string `json:"sha1"`
+	SHA256     string `json:"sha256"`
+	SPKISHA256 string `json:"spki_sha256"`
+}
+
+type Policy struct {
+	OID  string `json:"oid"`
+	Name string `json:"name,omitempty"`
+}
+
+// CertInfo is the structured view of a certificate returned by the certificate and TLS
+// endpoints.
+type CertInfo struct {
+	Version           int              `json:"version"`
+	Subject           string           `json:"subject"`
+	Issuer            string           `json:"issuer"`
+	Serial            string           `json:"serial"`
+	SerialHex         string           `json:"serial_hex"`
+	NotBefore         time.Time        `json:"not_before"`
+	NotAfter          time.Time        `json:"not_after"`
+	Status            string           `json:"status"` // valid, expired or not_yet_valid
+	DaysRemaining     int              `json:"days_remaining"`
+	DNS               []string         `json:"dns"`
+	IP                []string         `json:"ip"`
+	Email             []string         `json:"email"`
+	URI               []string         `json:"uri"`
+	IsCA              bool             `json:"is_ca"`
+	SelfSigned        bool             `json:"self_signed"`
+	BasicConstraints  bool             `json:"basic_constraints"`
+	MaxPathLen        *int             `json:"max_path_len"` // nil when unlimited or not a CA
+	SigAlg            string           `json:"sig_alg"`
+	KeyAlg            string           `json:"key_alg"`
+	KeyBits           int              `json:"key_bits"`
+	KeyCurve          string           `json:"key_curve,omitempty"`
+	FingerprintSHA256 string           `json:"fingerprint_sha256"` // Deprecated: base64; use Fingerprints.SHA256
+	SPKI_SHA256       string           `json:"spki_sha256"`        // Deprecated: base64; use Fingerprints.SPKISHA256
+	Fingerprints      Fingerprints     `json:"fingerprints"`
+	SubjectKeyID      string           `json:"subject_key_id,omitempty"`
+	AuthorityKeyID    string           `json:"authority_key_id,omitempty"`
+	KeyUsage          []string         `json:"key_usage"`
+	ExtKeyUsage       []string         `json:"ext_key_usage"`
+	Policies          []Policy         `json:"policies,omitempty"`
+	NameConstraints   *NameConstraints `json:"name_constraints,omitempty"`
+	OCSP              []string         `json:"ocsp"`
+	IssuerURLs        []string         `json:"issuer_urls"`
+	CRL               []string         `json:"crl"`
+	MustStaple        bool             `json:"must_staple"`
+	SCTs              []SCT            `json:"scts,omitempty"`
+	Extensions        []Extension      `json:"extensions"`
+	Warnings          []string         `json:"warnings,omitempty"`
+	ASN1              *asn1dump.Node   `json:"asn1,omitempty"`
+}
+
+var policyNames = map[string]string{
+	"2.5.29.32.0":             "anyPolicy",
+	"2.23.140.1.1":            "CA/B Forum extended validation",
+	"2.23.140.1.2.1":          "CA/B Forum domain validated",
+	"2.23.140.1.2.2":          "CA/B Forum organization validated",
+	"2.23.140.1.2.3":          "CA/B Forum individual validated",
+	"2.23.140.1.3":            "CA/B Forum EV code signing",
+	"2.23.140.1.4.1":          "CA/B Forum code signing",
+	"2.23.140.1.5.1.1":        "CA/B Forum S/MIME mailbox legacy",
+	"1.3.6.1.4.1.44947.1.1.1": "ISRG domain validated",
+	"1.3.6.1.5.5.7.2.1":       "CPS qualifier",
+}
+
+// HexColon formats b as upper-case hex bytes separated by colons.
+func HexColon(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+	h := strings.ToUpper(hex.EncodeToString(b))
+	var sb strings.Builder
+	for i := 0; i < len(h); i += 2 {
+		if i > 0 {
+			sb.WriteByte(':')
+		}
+		sb.WriteString(h[i : i+2])
+	}
+	return sb.String()
+}
+
+// NewCertInfo describes crt; now determines the validity status.
+func NewCertInfo(crt *x509.Certificate, now time.Time) CertInfo {
+	fp := sha256.Sum256(crt.Raw)
+	fp1 := sha1.Sum(crt.Raw)
+	spki := sha256.Sum256(crt.RawSubjectPublicKeyInfo)
+	info := CertInfo{
+		Version: crt.Version, Subject: crt.Subject.String(), Issuer: crt.Issuer.String(),
+		Serial: crt.SerialNumber.String(), SerialHex: HexColon(crt.SerialNumber.Bytes()),
+		NotBefore: crt.NotBefore, NotAfter: crt.NotAfter,
+		DNS: crt.DNSNames, Email: crt.EmailAddresses,
+		IsCA: crt.IsCA, BasicConstraints: crt.BasicConstraintsValid, SigAlg: crt.SignatureAlgorithm.String(),
+		FingerprintSHA256: base64.StdEncoding.EncodeToString(fp[:]), SPKI_SHA256: base64.StdEncoding.EncodeToString(spki[:]),
+		Fingerprints:   Fingerprints{SHA1: HexColon(fp1[:]), SHA256: HexColon(fp[:]), SPKISHA256: HexColon(spki[:])},
+		SubjectKeyID:   HexColon(crt.SubjectKeyId),
+		AuthorityKeyID: HexColon(crt.AuthorityKeyId),
+		KeyUsage:       KeyUsageStrings(crt.KeyUsage),
+		ExtKeyUsage:    ExtKeyUsageStrings(crt.ExtKeyUsage, crt.UnknownExtKeyUsage),
+		OCSP:           crt.OCSPServer, IssuerURLs: crt.IssuingCertificateURL, CRL: crt.CRLDistributionPoints,
+		Extensions: DescribeExtensions(crt.Extensions),
+	}
+	switch {
+	case now.Before(crt.NotBefore):
+		info.Status = "not_yet_valid"
+	case now.After(crt.NotAfter):
+		info.Status = "expired"
+	default:
+		info.Status = "valid"
+	}
+	info.DaysRemaining = int(math.Floor(crt.NotAfter.Sub(now).Hours() / 24))
+	for _, ip := range crt.IPAddresses {
+		info.IP = append(info.IP, ip.String())
+	}
+	for _, u := range crt.URIs {
+		info.URI = append(info.URI, u.String())
+	}
+	info.KeyAlg, info.KeyBits, info.KeyCurve = KeyDescription(crt.PublicKey)
+	if crt.IsCA && crt.BasicConstraintsValid && (crt.MaxPathLen > 0 || crt.MaxPathLenZero) {
+		n := crt.MaxPathLen
+		info.MaxPathLen = &n
+	}
+	// CheckSignatureFrom refuses non-CA parents, which would miss self-signed leaves
+	if bytes.Equal(crt.RawSubject, crt.RawIssuer) && crt.CheckSignature(crt.SignatureAlgorithm, crt.RawTBSCertificate, crt.Signature) == nil {
+		info.SelfSigned = true
+	}
+	for _, oid := range crt.PolicyIdentifiers {
+		info.Policies = append(info.Policies, Policy{OID: oid.String(), Name: policyNames[oid.String()]})
+	}
+	if hasNameConstraints(crt) {
+		nc := &NameConstraints{
+			Critical:     crt.PermittedDNSDomainsCritical,
+			PermittedDNS: crt.PermittedDNSDomains, ExcludedDNS: crt.ExcludedDNSDomains,
+			PermittedEmail: crt.PermittedEmailAddresses, ExcludedEmail: crt.ExcludedEmailAddresses,
+			PermittedURI: crt.PermittedURIDomains, ExcludedURI: crt.ExcludedURIDomains,
+		}
+		for _, n := range crt.PermittedIPRanges {
+			nc.PermittedIP = append(nc.PermittedIP, n.String())
+		}
+		for _, n := range crt.ExcludedIPRanges {
+			nc.ExcludedIP = append(nc.ExcludedIP, n.String())
+		}
+		info.NameConstraints = nc
+	}
+	for _, e := range crt.Extensions {
+		switch {
+		case e.Id.Equal(OIDExtSCTList):
+			scts, err := ParseSCTListExtension(e.Value)
+			if err != nil {
+				info.Warnings = append(info.Warnings, err.Error())
+			}
+			info.SCTs = SCTsFrom(scts, "embedded")
+		case e.Id.Equal(OIDExtTLSFeature):
+			req := DecodeRequestedExtensions([]pkix.Extension{e})
+			info.MustStaple = req.MustStaple
+			for _, msg := range req.Errors {
+				info.Warnings = append(info.Warnings, msg)
+			}
+		}
+	}
+	return info
+}
+
+func hasNameConstraints(crt *x509.Certificate) bool {
+	for _, e := range crt.Extensions {
+		if e.Id.Equal(OIDExtNameConstraints) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,88 @@
+package pki_test
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"engtools/backend/internal/pki"
+	"engtools/backend/internal/pki/pkitest"
+)
+
+func TestCertInfoStatus(t *testing.T) {
+	nb := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	na := nb.AddDate(0, 0, 90)
+	crt := pkitest.Issue(t, pki.CertSpec{NameSpec: pki.NameSpec{CN: "a.test"}, NotBefore: &nb, NotAfter: &na}, pkitest.Key(t, "ec"), nil).Cert
+	for _, tc := range []struct {
+		now    time.Time
+		status string
+		days   int
+	}{
+		{nb.Add(-time.Hour), "not_yet_valid", 90},
+		{nb, "valid", 90},
+		{nb.Add(12 * time.Hour), "valid", 89},
+		{na.Add(-time.Hour), "valid", 0},
+		{na.Add(time.Hour), "expired", -1},
+		{na.AddDate(0, 0, 3), "expired", -3},
+	} {
+		info := pki.NewCertInfo(crt, tc.now)
+		if info.Status != tc.status || info.DaysRemaining != tc.days {
+			t.Errorf("at %s: status %s days %d, want %s %d", tc.now, info.Status, info.DaysRemaining, tc.status, tc.days)
+		}
+	}
+	info := pki.NewCertInfo(crt, nb)
+	fp := sha256.Sum256(crt.Raw)
+	if info.Fingerprints.SHA256 != pki.HexColon(fp[:]) || len(info.Fingerprints.SHA1) != 59 || !info.SelfSigned ||
+		info.FingerprintSHA256 != base64.StdEncoding.EncodeToString(fp[:]) {
+		t.Errorf("fingerprints %+v, self-signed %v", info.Fingerprints, info.SelfSigned)
+	}
+}
+
+func TestCertInfoExtensions(t *testing.T) {
+	_, excluded, _ := net.ParseCIDR("10.0.0.0/8")
+	issuer := pkitest.Issue(t, pki.CertSpec{NameSpec: pki.NameSpec{CN: "Constrained CA"}, IsCA: true}, pkitest.Key(t, "ec"), nil, func(tpl *x509.Certificate) {
+		tpl.PermittedDNSDomainsCritical = true
+		tpl.PermittedDNSDomains = []string{"example.com"}
+		tpl.ExcludedIPRanges = []*net.IPNet{excluded}
+		tpl.PolicyIdentifiers = []asn1.ObjectIdentifier{{2, 23, 140, 1, 2, 1}, {1, 2, 3, 4}}
+		tpl.MaxPathLen, tpl.MaxPathLenZero = 0, true
+	})
+	info := pki.NewCertInfo(issuer.Cert, time.Now())
+	nc := info.NameConstraints
+	if nc == nil || !nc.Critical || len(nc.PermittedDNS) != 1 || nc.PermittedDNS[0] != "example.com" || len(nc.ExcludedIP) != 1 || nc.ExcludedIP[0] != "10.0.0.0/8" {
+		t.Errorf("name constraints %+v", nc)
+	}
+	want := []pki.Policy{{OID: "2.23.140.1.2.1", Name: "CA/B Forum domain validated"}, {OID: "1.2.3.4"}}
+	if len(info.Policies) != 2 || info.Policies[0] != want[0] || info.Policies[1] != want[1] {
+		t.Errorf("policies %+v", info.Policies)
+	}
+	if info.MaxPathLen == nil || *info.MaxPathLen != 0 {
+		t.Errorf("max path len %v", info.MaxPathLen)
+	}
+
+	ts := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	logID := make([]byte, 32)
+	leaf := pkitest.Issue(t, pki.CertSpec{NameSpec: pki.NameSpec{CN: "a.example.com"}, SANSpec: pki.SANSpec{DNS: []string{"a.example.com"}}}, pkitest.Key(t, "ec"), issuer, func(tpl *x509.Certificate) {
+		tpl.ExtraExtensions = []pkix.Extension{sctExtension(logID, ts, []byte{1, 2, 3})}
+	}).Cert
+	info = pki.NewCertInfo(leaf, time.Now())
+	if len(info.SCTs) != 1 || info.SCTs[0].Source != "embedded" || !info.SCTs[0].Timestamp.Equal(ts) || info.SCTs[0].HashAlg != "sha256" || len(info.Warnings) != 0 {
+		t.Errorf("scts %+v, warnings %v", info.SCTs, info.Warnings)
+	}
+	if info.NameConstraints != nil || info.Policies != nil {
+		t.Errorf("leaf reports constraints %+v policies %+v", info.NameConstraints, info.Policies)
+	}
+
+	broken := pkitest.Issue(t, pki.CertSpec{NameSpec: pki.NameSpec{CN: "b.test"}}, pkitest.Key(t, "ec"), nil, func(tpl *x509.Certificate) {
+		tpl.ExtraExtensions = []pkix.Extension{{Id: pki.OIDExtSCTList, Value: []byte{0x04, 0x02, 0x00, 0x09}}}
+	}).Cert
+	if info := pki.NewCertInfo(broken, time.Now()); len(info.SCTs) != 0 || len(info.Warnings) != 1 || !strings.Contains(info.Warnings[0], "sct") {
+		t.Errorf("malformed sct list: scts %+v warnings %v", info.SCTs, info.Warnings)
+	}
+}
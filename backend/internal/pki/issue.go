@@ -0,0 +1,380 @@
+package pki
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// NameSpec is the flat subject representation used by the certificate endpoints.
+type NameSpec struct {
+	CN string `json:"cn"`
+	O  string `json:"o"`
+	OU string `json:"ou"`
+	L  string `json:"l"`
+	ST string `json:"st"`
+	C  string `json:"c"`
+}
+
+func (n NameSpec) Name() pkix.Name {
+	return pkix.Name{CommonName: n.CN, Organization: nonEmpty(n.O), OrganizationalUnit: nonEmpty(n.OU), Locality: nonEmpty(n.L), Province: nonEmpty(n.ST), Country: nonEmpty(n.C)}
+}
+
+func nonEmpty(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return []string{s}
+}
+
+// SANSpec lists subject alternative names of every supported type.
+type SANSpec struct {
+	DNS   []string `json:"san_dns"`
+	IP    []string `json:"san_ip"`
+	Email []string `json:"san_email"`
+	URI   []string `json:"san_uri"`
+}
+
+func (s SANSpec) apply(dns *[]string, ips *[]net.IP, emails *[]string, uris *[]*url.URL) error {
+	*dns = append(*dns, s.DNS...)
+	*emails = append(*emails, s.Email...)
+	for _, v := range s.IP {
+		ip := net.ParseIP(strings.TrimSpace(v))
+		if ip == nil {
+			return fmt.Errorf("invalid san ip %q", v)
+		}
+		*ips = append(*ips, ip)
+	}
+	for _, v := range s.URI {
+		u, err := url.Parse(strings.TrimSpace(v))
+		if err != nil || u.Scheme == "" {
+			return fmt.Errorf("invalid san uri %q", v)
+		}
+		*uris = append(*uris, u)
+	}
+	return nil
+}
+
+type NameConstraints struct {
+	Critical       bool     `json:"critical"`
+	PermittedDNS   []string `json:"permitted_dns"`
+	ExcludedDNS    []string `json:"excluded_dns"`
+	PermittedIP    []string `json:"permitted_ip"`
+	ExcludedIP     []string `json:"excluded_ip"`
+	PermittedEmail []string `json:"permitted_email"`
+	ExcludedEmail  []string `json:"excluded_email"`
+	PermittedURI   []string `json:"permitted_uri"`
+	ExcludedURI    []string `json:"excluded_uri"`
+}
+
+// CertSpec describes a certificate to issue. Zero values pick sensible defaults for a
+// leaf or CA certificate respectively.
+type CertSpec struct {
+	NameSpec
+	SANSpec
+	Serial          string           `json:"serial"` // hex; random 128-bit when empty
+	NotBefore       *time.Time       `json:"not_before"`
+	NotAfter        *time.Time       `json:"not_after"`
+	ValidityDays    int              `json:"validity_days"`
+	KeyUsage        []string         `json:"key_usage"`
+	ExtKeyUsage     []string         `json:"ext_key_usage"`
+	IsCA            bool             `json:"is_ca"`
+	PathLen         *int             `json:"path_len"`
+	NameConstraints *NameConstraints `json:"name_constraints"`
+	OCSP            []string         `json:"ocsp"`
+	IssuerURLs      []string         `json:"issuer_urls"`
+	CRL             []string         `json:"crl"`
+}
+
+var keyUsageNames = map[string]x509.KeyUsage{
+	"digitalsignature":  x509.KeyUsageDigitalSignature,
+	"contentcommitment": x509.KeyUsageContentCommitment,
+	"nonrepudiation":    x509.KeyUsageContentCommitment,
+	"keyencipherment":   x509.KeyUsageKeyEncipherment,
+	"dataencipherment":  x509.KeyUsageDataEncipherment,
+	"keyagreement":      x509.KeyUsageKeyAgreement,
+	"certsign":          x509.KeyUsageCertSign,
+	"keycertsign":       x509.KeyUsageCertSign,
+	"crlsign":           x509.KeyUsageCRLSign,
+	"encipheronly":      x509.KeyUsageEncipherOnly,
+	"decipheronly":      x509.KeyUsageDecipherOnly,
+}
+
+var extKeyUsageNames = map[string]x509.ExtKeyUsage{
+	"any":                            x509.ExtKeyUsageAny,
+	"serverauth":                     x509.ExtKeyUsageServerAuth,
+	"clientauth":                     x509.ExtKeyUsageClientAuth,
+	"codesigning":                    x509.ExtKeyUsageCodeSigning,
+	"emailprotection":                x509.ExtKeyUsageEmailProtection,
+	"ipsecendsystem":                 x509.ExtKeyUsageIPSECEndSystem,
+	"ipsectunnel":                    x509.ExtKeyUsageIPSECTunnel,
+	"ipsecuser":                      x509.ExtKeyUsageIPSECUser,
+	"timestamping":                   x509.ExtKeyUsageTimeStamping,
+	"ocspsigning":                    x509.ExtKeyUsageOCSPSigning,
+	"microsoftservergatedcrypto":     x509.ExtKeyUsageMicrosoftServerGatedCrypto,
+	"netscapeservergatedcrypto":      x509.ExtKeyUsageNetscapeServerGatedCrypto,
+	"microsoftcommercialcodesigning": x509.ExtKeyUsageMicrosoftCommercialCodeSigning,
+	"microsoftkernelcodesigning":     x509.ExtKeyUsageMicrosoftKernelCodeSigning,
+}
+
+func normalizeUsage(s string) string {
+	return strings.ToLower(strings.NewReplacer("_", "", "-", "", " ", "").Replace(s))
+}
+
+// ParseKeyUsage maps names such as "DigitalSignature", "digital_signature" or the OpenSSL
+// spelling "keyCertSign" onto the x509 bit mask.
+func ParseKeyUsage(names []string) (x509.KeyUsage, error) {
+	var ku x509.KeyUsage
+	for _, n := range names {
+		v, ok := keyUsageNames[normalizeUsage(n)]
+		if !ok {
+			return 0, fmt.Errorf("unknown key usage %q", n)
+		}
+		ku |= v
+	}
+	return ku, nil
+}
+
+// ParseExtKeyUsage maps EKU names onto x509 constants; dotted OIDs are passed through as
+// unknown usages.
+func ParseExtKeyUsage(names []string) ([]x509.ExtKeyUsage, []asn1.ObjectIdentifier, error) {
+	var ekus []x509.ExtKeyUsage
+	var oids []asn1.ObjectIdentifier
+	for _, n := range names {
+		if v, ok := extKeyUsageNames[normalizeUsage(n)]; ok {
+			ekus = append(ekus, v)
+			continue
+		}
+		oid, err := ParseOID(n)
+		if err != nil {
+			return nil, nil, fmt.Errorf("unknown extended key usage %q", n)
+		}
+		oids = append(oids, oid)
+	}
+	return ekus, oids, nil
+}
+
+func ParseOID(s string) (asn1.ObjectIdentifier, error) {
+	parts := strings.Split(strings.TrimSpace(s), ".")
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("invalid oid %q", s)
+	}
+	oid := make(asn1.ObjectIdentifier, len(parts))
+	for i, p := range parts {
+		var n int
+		if _, err := fmt.Sscanf(p, "%d", &n); err != nil || n < 0 || fmt.Sprint(n) != p {
+			return nil, fmt.Errorf("invalid oid %q", s)
+		}
+		oid[i] = n
+	}
+	return oid, nil
+}
+
+// RandomSerial returns a positive 128-bit serial number, well above the 64 bits of entropy
+// the CA/Browser Forum requires.
+func RandomSerial() (*big.Int, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	b[0] &= 0x7f
+	return new(big.Int).SetBytes(b), nil
+}
+
+// Template converts the spec into an x509 template ready for CreateCertificate.
+func (s CertSpec) Template() (*x509.Certificate, error) {
+	tpl := &x509.Certificate{Subject: s.NameSpec.Name(), OCSPServer: s.OCSP, IssuingCertificateURL: s.IssuerURLs, CRLDistributionPoints: s.CRL}
+	if s.Serial != "" {
+		b, err := hex.DecodeString(strings.NewReplacer(":", "", " ", "").Replace(s.Serial))
+		if err != nil || len(b) == 0 || len(b) > 20 {
+			return nil, errors.New("serial must be 1-20 bytes of hex")
+		}
+		tpl.SerialNumber = new(big.Int).SetBytes(b)
+	} else {
+		sn, err := RandomSerial()
+		if err != nil {
+			return nil, err
+		}
+		tpl.SerialNumber = sn
+	}
+	if err := s.SANSpec.apply(&tpl.DNSNames, &tpl.IPAddresses, &tpl.EmailAddresses, &tpl.URIs); err != nil {
+		return nil, err
+	}
+
+	tpl.NotBefore = time.Now().Add(-5 * time.Minute).UTC().Truncate(time.Second)
+	if s.NotBefore != nil {
+		tpl.NotBefore = s.NotBefore.UTC()
+	}
+	days := s.ValidityDays
+	if days == 0 {
+		days = 365
+		if s.IsCA {
+			days = 3650
+		}
+	}
+	tpl.NotAfter = tpl.NotBefore.AddDate(0, 0, days)
+	if s.NotAfter != nil {
+		tpl.NotAfter = s.NotAfter.UTC()
+	}
+	if !tpl.NotAfter.After(tpl.NotBefore) {
+		return nil, errors.New("not_after must be after not_before")
+	}
+
+	ku, err := ParseKeyUsage(s.KeyUsage)
+	if err != nil {
+		return nil, err
+	}
+	ekus, ekuOIDs, err := ParseExtKeyUsage(s.ExtKeyUsage)
+	if err != nil {
+		return nil, err
+	}
+	tpl.KeyUsage, tpl.ExtKeyUsage, tpl.UnknownExtKeyUsage = ku, ekus, ekuOIDs
+
+	if s.IsCA {
+		tpl.IsCA = true
+		tpl.BasicConstraintsValid = true
+		if tpl.KeyUsage == 0 {
+			tpl.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature
+		}
+		if s.PathLen != nil {
+			if *s.PathLen < 0 {
+				return nil, errors.New("path_len must not be negative")
+			}
+			tpl.MaxPathLen = *s.PathLen
+			tpl.MaxPathLenZero = *s.PathLen == 0
+		} else {
+			tpl.MaxPathLen = -1
+		}
+	} else {
+		if s.PathLen != nil {
+			return nil, errors.New("path_len is only valid for CA certificates")
+		}
+		tpl.BasicConstraintsValid = true
+		if tpl.KeyUsage == 0 {
+			tpl.KeyUsage = x509.KeyUsageDigitalSignature
+		}
+		if len(s.ExtKeyUsage) == 0 {
+			tpl.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth}
+		}
+	}
+	if nc := s.NameConstraints; nc != nil {
+		if err := nc.apply(tpl); err != nil {
+			return nil, err
+		}
+	}
+	return tpl, nil
+}
+
+func (nc *NameConstraints) apply(tpl *x509.Certificate) error {
+	parseNets := func(in []string) ([]*net.IPNet, error) {
+		var out []*net.IPNet
+		for _, s := range in {
+			_, n, err := net.ParseCIDR(strings.TrimSpace(s))
+			if err != nil {
+				return nil, fmt.Errorf("invalid name constraint range %q", s)
+			}
+			out = append(out, n)
+		}
+		return out, nil
+	}
+	var err error
+	tpl.PermittedDNSDomainsCritical = nc.Critical
+	tpl.PermittedDNSDomains, tpl.ExcludedDNSDomains = nc.PermittedDNS, nc.ExcludedDNS
+	tpl.PermittedEmailAddresses, tpl.ExcludedEmailAddresses = nc.PermittedEmail, nc.ExcludedEmail
+	tpl.PermittedURIDomains, tpl.ExcludedURIDomains = nc.PermittedURI, nc.ExcludedURI
+	if tpl.PermittedIPRanges, err = parseNets(nc.PermittedIP); err != nil {
+		return err
+	}
+	if tpl.ExcludedIPRanges, err = parseNets(nc.ExcludedIP); err != nil {
+		return err
+	}
+	return nil
+}
+
+// SubjectKeyID derives the RFC 5280 method 1 key identifier (SHA-1 of the public key bits).
+func SubjectKeyID(pub crypto.PublicKey) ([]byte, error) {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return nil, err
+	}
+	var spki struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}
+	if _, err := asn1.Unmarshal(der, &spki); err != nil {
+		return nil, err
+	}
+	sum := sha1.Sum(spki.PublicKey.Bytes)
+	return sum[:], nil
+}
+
+// Issue signs tpl for pub. A nil parent produces a self-signed certificate, in which case
+// signer must be the private half of pub.
+func Issue(tpl *x509.Certificate, pub crypto.PublicKey, parent *x509.Certificate, signer crypto.Signer) (*x509.Certificate, error) {
+	if len(tpl.SubjectKeyId) == 0 {
+		ski, err := SubjectKeyID(pub)
+		if err != nil {
+			return nil, err
+		}
+		tpl.SubjectKeyId = ski
+	}
+	if parent == nil {
+		parent = tpl
+	} else {
+		if !parent.IsCA || (parent.KeyUsage != 0 && parent.KeyUsage&x509.KeyUsageCertSign == 0) {
+			return nil, errors.New("issuer certificate is not a CA")
+		}
+		if tpl.NotAfter.After(parent.NotAfter) {
+			if !parent.NotAfter.After(tpl.NotBefore) {
+				return nil, fmt.Errorf("issuer certificate expires %s, before the requested not_before", parent.NotAfter.UTC().Format(time.RFC3339))
+			}
+			tpl.NotAfter = parent.NotAfter
+		}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tpl, parent, pub, signer)
+	if err != nil {
+		return nil, err
+	}
+	return x509.ParseCertificate(der)
+}
+
+func EncodeCertPEM(crt *x509.Certificate) string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: crt.Raw}))
+}
+
+// ParseCertificatesPEM returns every CERTIFICATE block in s.
+func ParseCertificatesPEM(s string) ([]*x509.Certificate, error) {
+	var out []*x509.Certificate
+	data := []byte(s)
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		crt, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, crt)
+	}
+	if len(out) == 0 {
+		return nil, errors.New("no certificates found")
+	}
+	return out, nil
+}
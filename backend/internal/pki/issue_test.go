@@ -0,0 +1,69 @@
+package pki
+
+import (
+	"crypto/x509"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIssueChain(t *testing.T) {
+	caKey, err := GenerateKey("ec", 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	caTpl, err := CertSpec{NameSpec: NameSpec{CN: "Test Root"}, IsCA: true}.Template()
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := Issue(caTpl, caKey.Public(), nil, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leafKey, err := GenerateKey("ed25519", 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	leafTpl, err := CertSpec{NameSpec: NameSpec{CN: "a.test"}, SANSpec: SANSpec{DNS: []string{"a.test"}}, ValidityDays: 7300}.Template()
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := Issue(leafTpl, leafKey.Public(), root, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if leaf.NotAfter.After(root.NotAfter) {
+		t.Fatal("leaf outlives its issuer")
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(root)
+	if _, err := leaf.Verify(x509.VerifyOptions{Roots: roots, DNSName: "a.test"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Issue(leafTpl, leafKey.Public(), leaf, leafKey); err == nil {
+		t.Fatal("leaf certificate accepted as issuer")
+	}
+}
+
+func TestIssueAfterIssuerExpiry(t *testing.T) {
+	key, err := GenerateKey("ec", 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	caTpl, err := CertSpec{NameSpec: NameSpec{CN: "Short Root"}, IsCA: true, ValidityDays: 30}.Template()
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := Issue(caTpl, key.Public(), nil, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nb := root.NotAfter.Add(time.Hour)
+	tpl, err := CertSpec{NameSpec: NameSpec{CN: "late.test"}, NotBefore: &nb}.Template()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Issue(tpl, key.Public(), root, key); err == nil || !strings.Contains(err.Error(), "not_before") {
+		t.Fatalf("leaf starting after its issuer expires: %v", err)
+	}
+}
@@ -0,0 +1,120 @@
+// Package pki holds the X.509 helpers shared by the certificate endpoints: key handling,
+// certificate issuance and the structured views returned to clients.
+package pki
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// GenerateKey creates a private key. keyType is rsa (default), ec or ed25519; bits applies
+// to RSA and curve (P256/P384/P521) to EC.
+func GenerateKey(keyType string, bits int, curve string) (crypto.Signer, error) {
+	switch strings.ToLower(keyType) {
+	case "ec", "ecdsa":
+		c, err := ParseCurve(curve)
+		if err != nil {
+			return nil, err
+		}
+		return ecdsa.GenerateKey(c, rand.Reader)
+	case "ed25519":
+		_, priv, err := ed25519.GenerateKey(rand.Reader)
+		return priv, err
+	case "", "rsa":
+		if bits == 0 {
+			bits = 2048
+		}
+		if bits < 1024 || bits > 8192 {
+			return nil, errors.New("rsa bits must be between 1024 and 8192")
+		}
+		return rsa.GenerateKey(rand.Reader, bits)
+	}
+	return nil, fmt.Errorf("unsupported key type %q", keyType)
+}
+
+func ParseCurve(name string) (elliptic.Curve, error) {
+	switch strings.ToUpper(strings.ReplaceAll(name, "-", "")) {
+	case "", "P256", "PRIME256V1", "SECP256R1":
+		return elliptic.P256(), nil
+	case "P384", "SECP384R1":
+		return elliptic.P384(), nil
+	case "P521", "SECP521R1":
+		return elliptic.P521(), nil
+	}
+	return nil, fmt.Errorf("unsupported curve %q", name)
+}
+
+// ParsePrivateKeyPEM accepts PKCS#8, PKCS#1 RSA and SEC1 EC private keys in PEM form.
+func ParsePrivateKeyPEM(s string) (crypto.Signer, error) {
+	data := []byte(s)
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return nil, errors.New("no private key found")
+		}
+		if !strings.Contains(block.Type, "PRIVATE KEY") {
+			continue
+		}
+		if strings.Contains(block.Type, "ENCRYPTED") || block.Headers["Proc-Type"] != "" {
+			return nil, errors.New("encrypted private keys are not supported")
+		}
+		return ParsePrivateKeyDER(block.Bytes)
+	}
+}
+
+func ParsePrivateKeyDER(der []byte) (crypto.Signer, error) {
+	if k, err := x509.ParsePKCS8PrivateKey(der); err == nil {
+		s, ok := k.(crypto.Signer)
+		if !ok {
+			return nil, errors.New("unsupported private key type")
+		}
+		return s, nil
+	}
+	if k, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return k, nil
+	}
+	if k, err := x509.ParseECPrivateKey(der); err == nil {
+		return k, nil
+	}
+	return nil, errors.New("unrecognized private key encoding")
+}
+
+// MarshalPrivateKeyPEM encodes a key as an unencrypted PKCS#8 "PRIVATE KEY" block.
+func MarshalPrivateKeyPEM(key crypto.Signer) (string, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return "", err
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})), nil
+}
+
+// PublicKeysEqual reports whether a and b are the same public key, as when checking that a
+// private key belongs to a certificate.
+func PublicKeysEqual(a, b crypto.PublicKey) bool {
+	k, ok := a.(interface{ Equal(crypto.PublicKey) bool })
+	return ok && k.Equal(b)
+}
+
+// KeyDescription returns the algorithm name and size in bits of a public key, plus the
+// curve name for EC keys.
+func KeyDescription(pub crypto.PublicKey) (alg string, bits int, curve string) {
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		return "RSA", k.N.BitLen(), ""
+	case *ecdsa.PublicKey:
+		return "EC", k.Curve.Params().BitSize, k.Curve.Params().Name
+	case ed25519.PublicKey:
+		return "Ed25519", 256, ""
+	}
+	return fmt.Sprintf("%T", pub), 0, ""
+}
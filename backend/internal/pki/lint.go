@@ -0,0 +1,421 @@
+package pki
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/cryptobyte"
+	casn1 "golang.org/x/crypto/cryptobyte/asn1"
+)
+
+// Lint severities, most serious first.
+const (
+	LintError   = "error"   // a CA/B Forum or RFC MUST is violated; browsers or partners will reject
+	LintWarning = "warning" // a SHOULD is violated or the construction is likely a mistake
+	LintNotice  = "notice"  // informational
+)
+
+// Finding is one failed check.
+type Finding struct {
+	ID        string `json:"id"`
+	Severity  string `json:"severity"`
+	Message   string `json:"message"`
+	Reference string `json:"reference"`
+}
+
+// Cert kinds as seen by the linter.
+const (
+	KindSubscriber   = "subscriber"
+	KindIntermediate = "intermediate"
+	KindRoot         = "root"
+)
+
+type lintCert struct {
+	*x509.Certificate
+	kind      string
+	serverTLS bool // subscriber certificate usable for TLS servers, so the BRs apply
+	now       time.Time
+}
+
+type lint struct {
+	id, severity, ref string
+	// applies restricts the check; nil means every certificate.
+	applies func(c lintCert) bool
+	// check returns one message per problem found.
+	check func(c lintCert) []string
+}
+
+func subscriberTLS(c lintCert) bool { return c.kind == KindSubscriber && c.serverTLS }
+func isCA(c lintCert) bool          { return c.kind != KindSubscriber }
+func isSubscriber(c lintCert) bool  { return c.kind == KindSubscriber }
+
+func one(cond bool, format string, args ...any) []string {
+	if cond {
+		return []string{fmt.Sprintf(format, args...)}
+	}
+	return nil
+}
+
+// serialLength is the length of the serial number's DER contents as it appears in the
+// certificate, counting a leading 0x00 pad the way zlint does.
+func serialLength(crt *x509.Certificate) int {
+	in := cryptobyte.String(crt.RawTBSCertificate)
+	var tbs, serial cryptobyte.String
+	if !in.ReadASN1(&tbs, casn1.SEQUENCE) ||
+		!tbs.SkipOptionalASN1(casn1.Tag(0).Constructed().ContextSpecific()) ||
+		!tbs.ReadASN1(&serial, casn1.INTEGER) {
+		return 0
+	}
+	return len(serial)
+}
+
+func hasExt(crt *x509.Certificate, id []int) (found, critical bool) {
+	for _, e := range crt.Extensions {
+		if e.Id.Equal(id) {
+			return true, e.Critical
+		}
+	}
+	return false, false
+}
+
+var (
+	oidExtAKI  = []int{2, 5, 29, 35}
+	oidExtSKI  = []int{2, 5, 29, 14}
+	oidExtSAN  = []int{2, 5, 29, 17}
+	oidExtBC   = []int{2, 5, 29, 19}
+	oidExtKU   = []int{2, 5, 29, 15}
+	oidExtEKU  = []int{2, 5, 29, 37}
+	oidExtCP   = []int{2, 5, 29, 32}
+	oidExtAIA  = []int{1, 3, 6, 1, 5, 5, 7, 1, 1}
+	oidPoison  = []int{1, 3, 6, 1, 4, 1, 11129, 2, 4, 3}
+	knownExtID = map[string]bool{}
+)
+
+func init() {
+	for id := range extensionNames {
+		knownExtID[id] = true
+	}
+}
+
+// internalSuffixes are names that can never be publicly validated.
+var internalSuffixes = []string{".local", ".localhost", ".internal", ".lan", ".home", ".corp", ".intranet", ".test", ".example", ".invalid", ".home.arpa"}
+
+func dnsNameProblems(name string) []string {
+	var out []string
+	if name == "" {
+		return []string{"empty dNSName"}
+	}
+	if len(name) > 253 {
+		out = append(out, fmt.Sprintf("%q is longer than 253 characters", name))
+	}
+	if strings.HasSuffix(name, ".") {
+		out = append(out, fmt.Sprintf("%q has a trailing dot", name))
+	}
+	labels := strings.Split(strings.TrimSuffix(name, "."), ".")
+	for i, l := range labels {
+		switch {
+		case l == "":
+			out = append(out, fmt.Sprintf("%q has an empty label", name))
+		case len(l) > 63:
+			out = append(out, fmt.Sprintf("%q has a label longer than 63 characters", name))
+		case l == "*" && i == 0:
+		case strings.Contains(l, "*"):
+			out = append(out, fmt.Sprintf("%q uses a wildcard other than a whole leftmost label", name))
+		case strings.HasPrefix(l, "-") || strings.HasSuffix(l, "-"):
+			out = append(out, fmt.Sprintf("%q has a label starting or ending with a hyphen", name))
+		case strings.Contains(l, "_"):
+			out = append(out, fmt.Sprintf("%q contains an underscore", name))
+		default:
+			for _, r := range l {
+				if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-') {
+					out = append(out, fmt.Sprintf("%q contains invalid character %q", name, r))
+					break
+				}
+			}
+		}
+	}
+	return out
+}
+
+func isInternalName(name string) bool {
+	n := strings.ToLower(strings.TrimSuffix(name, "."))
+	if !strings.Contains(n, ".") {
+		return true
+	}
+	for _, s := range internalSuffixes {
+		if strings.HasSuffix(n, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func isReservedIP(ip net.IP) bool {
+	return ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsUnspecified() ||
+		ip.IsMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsLinkLocalMulticast() ||
+		(ip.To4() != nil && ip.To4()[0] == 100 && ip.To4()[1]&0xc0 == 64) // 100.64.0.0/10 CGNAT
+}
+
+func validityDays(crt *x509.Certificate) float64 {
+	// RFC 5280 validity is inclusive of both ends, hence the extra second
+	return (crt.NotAfter.Sub(crt.NotBefore) + time.Second).Hours() / 24
+}
+
+var lints = []lint{
+	// RFC 5280 structure
+	{"rfc_version", LintError, "RFC 5280 4.1.2.1", nil, func(c lintCert) []string {
+		return one(len(c.Extensions) > 0 && c.Version != 3, "certificate has extensions but is version %d", c.Version)
+	}},
+	{"rfc_serial_positive", LintError, "RFC 5280 4.1.2.2", nil, func(c lintCert) []string {
+		return one(c.SerialNumber.Sign() <= 0, "serial number is not positive")
+	}},
+	{"rfc_serial_length", LintError, "RFC 5280 4.1.2.2", nil, func(c lintCert) []string {
+		return one(len(c.SerialNumber.Bytes()) > 20, "serial number is %d octets; at most 20 are allowed", len(c.SerialNumber.Bytes()))
+	}},
+	{"rfc_validity_order", LintError, "RFC 5280 4.1.2.5", nil, func(c lintCert) []string {
+		return one(c.NotAfter.Before(c.NotBefore), "notAfter precedes notBefore")
+	}},
+	{"rfc_empty_subject_san_critical", LintError, "RFC 5280 4.2.1.6", nil, func(c lintCert) []string {
+		found, critical := hasExt(c.Certificate, oidExtSAN)
+		return one(len(c.RawSubject) <= 2 && (!found || !critical), "subject is empty but subjectAltName is missing or not critical")
+	}},
+	{"rfc_unknown_critical_ext", LintWarning, "RFC 5280 4.2", nil, func(c lintCert) []string {
+		var out []string
+		for _, e := range c.Extensions {
+			if e.Critical && !knownExtID[e.Id.String()] {
+				out = append(out, fmt.Sprintf("critical extension %s is not understood by common clients", e.Id))
+			}
+		}
+		return out
+	}},
+	{"rfc_aki_missing", LintError, "RFC 5280 4.2.1.1", func(c lintCert) bool { return c.kind != KindRoot }, func(c lintCert) []string {
+		found, _ := hasExt(c.Certificate, oidExtAKI)
+		return one(!found, "authorityKeyIdentifier is missing")
+	}},
+	{"rfc_ski_missing_ca", LintError, "RFC 5280 4.2.1.2", isCA, func(c lintCert) []string {
+		found, _ := hasExt(c.Certificate, oidExtSKI)
+		return one(!found, "CA certificate lacks subjectKeyIdentifier")
+	}},
+	{"rfc_ca_basic_constraints_critical", LintError, "RFC 5280 4.2.1.9", isCA, func(c lintCert) []string {
+		found, critical := hasExt(c.Certificate, oidExtBC)
+		return one(!found || !critical, "CA certificate basicConstraints is missing or not critical")
+	}},
+	{"rfc_ca_key_cert_sign", LintError, "RFC 5280 4.2.1.3", isCA, func(c lintCert) []string {
+		found, _ := hasExt(c.Certificate, oidExtKU)
+		return one(!found || c.KeyUsage&x509.KeyUsageCertSign == 0, "CA certificate keyUsage lacks keyCertSign")
+	}},
+	{"rfc_subscriber_cert_sign", LintError, "RFC 5280 4.2.1.3", isSubscriber, func(c lintCert) []string {
+		return one(c.KeyUsage&(x509.KeyUsageCertSign|x509.KeyUsageCRLSign) != 0, "keyCertSign or cRLSign set on a certificate that is not a CA")
+	}},
+	{"rfc_path_len_non_ca", LintError, "RFC 5280 4.2.1.9", isSubscriber, func(c lintCert) []string {
+		return one(c.BasicConstraintsValid && (c.MaxPathLen > 0 || c.MaxPathLenZero), "pathLenConstraint set on a certificate that is not a CA")
+	}},
+	{"rfc_name_constraints_non_ca", LintError, "RFC 5280 4.2.1.10", isSubscriber, func(c lintCert) []string {
+		found, _ := hasExt(c.Certificate, OIDExtNameConstraints)
+		return one(found, "nameConstraints on a certificate that is not a CA")
+	}},
+	{"rfc_precert_poison", LintError, "RFC 6962 3.1", nil, func(c lintCert) []string {
+		found, _ := hasExt(c.Certificate, oidPoison)
+		return one(found, "certificate carries the CT precertificate poison extension and must not be used")
+	}},
+
+	// algorithms and keys
+	{"br_weak_signature", LintError, "CA/B Forum BR 7.1.3.2", nil, func(c lintCert) []string {
+		switch c.SignatureAlgorithm {
+		case x509.MD2WithRSA, x509.MD5WithRSA, x509.SHA1WithRSA, x509.DSAWithSHA1, x509.DSAWithSHA256, x509.ECDSAWithSHA1:
+			return []string{"weak signature algorithm " + c.SignatureAlgorithm.String()}
+		}
+		return nil
+	}},
+	{"br_key_algorithm", LintError, "CA/B Forum BR 6.1.5", func(c lintCert) bool { return isCA(c) || c.serverTLS }, func(c lintCert) []string {
+		var out []string
+		switch k := c.PublicKey.(type) {
+		case *rsa.PublicKey:
+			bits := k.N.BitLen()
+			if bits < 2048 {
+				out = append(out, fmt.Sprintf("RSA key is %d bits; at least 2048 are required", bits))
+			}
+			if bits%8 != 0 {
+				out = append(out, fmt.Sprintf("RSA modulus size %d is not a multiple of 8", bits))
+			}
+			if k.E < 3 || k.E%2 == 0 {
+				out = append(out, fmt.Sprintf("RSA public exponent %d must be odd and at least 3", k.E))
+			}
+		case *ecdsa.PublicKey:
+			switch k.Curve {
+			case elliptic.P256(), elliptic.P384(), elliptic.P521():
+			default:
+				out = append(out, "ECDSA curve "+k.Curve.Params().Name+" is not allowed; use P-256, P-384 or P-521")
+			}
+		case ed25519.PublicKey:
+			out = append(out, "Ed25519 keys are not permitted in publicly trusted TLS certificates")
+		default:
+			out = append(out, "unsupported public key algorithm "+c.PublicKeyAlgorithm.String())
+		}
+		return out
+	}},
+	{"br_p521_signature", LintWarning, "Mozilla Root Store Policy 5.1", nil, func(c lintCert) []string {
+		return one(c.SignatureAlgorithm == x509.ECDSAWithSHA512, "ecdsa-with-SHA512 signatures are not accepted by all browsers")
+	}},
+
+	// subscriber TLS profile
+	{"br_serial_entropy", LintError, "CA/B Forum BR 7.1", func(c lintCert) bool { return isCA(c) || c.serverTLS }, func(c lintCert) []string {
+		n := serialLength(c.Certificate)
+		return one(n < 8, "serial number is %d bytes when encoded; at least 8 are needed to hold 64 bits of CSPRNG output", n)
+	}},
+	{"br_validity_398", LintError, "CA/B Forum BR 6.3.2", subscriberTLS, func(c lintCert) []string {
+		d := validityDays(c.Certificate)
+		return one(d > 398, "validity is %.0f days; subscriber certificates are limited to 398", d)
+	}},
+	{"br_san_missing", LintError, "CA/B Forum BR 7.1.2.7.12", subscriberTLS, func(c lintCert) []string {
+		found, _ := hasExt(c.Certificate, oidExtSAN)
+		return one(!found || len(c.DNSNames)+len(c.IPAddresses) == 0, "no dNSName or iPAddress subjectAltName entries")
+	}},
+	{"br_cn_not_in_san", LintError, "CA/B Forum BR 7.1.4.3", subscriberTLS, func(c lintCert) []string {
+		cn := c.Subject.CommonName
+		if cn == "" {
+			return nil
+		}
+		for _, d := range c.DNSNames {
+			if strings.EqualFold(d, cn) {
+				return nil
+			}
+		}
+		for _, ip := range c.IPAddresses {
+			if ip.String() == cn {
+				return nil
+			}
+		}
+		return []string{fmt.Sprintf("common name %q is not one of the subjectAltName entries", cn)}
+	}},
+	{"br_dns_name_syntax", LintError, "RFC 5280 4.2.1.6, CA/B Forum BR 7.1.2.7.12", subscriberTLS, func(c lintCert) []string {
+		var out []string
+		for _, d := range c.DNSNames {
+			out = append(out, dnsNameProblems(d)...)
+		}
+		return out
+	}},
+	{"br_wildcard_tld", LintError, "RFC 6125 6.4.3", subscriberTLS, func(c lintCert) []string {
+		var out []string
+		for _, d := range c.DNSNames {
+			if strings.HasPrefix(d, "*.") && strings.Count(strings.TrimSuffix(d, "."), ".") < 2 {
+				out = append(out, fmt.Sprintf("wildcard %q covers a whole top-level domain", d))
+			}
+		}
+		return out
+	}},
+	{"br_internal_name", LintError, "CA/B Forum BR 7.1.2.7.12", subscriberTLS, func(c lintCert) []string {
+		var out []string
+		for _, d := range c.DNSNames {
+			base := strings.TrimPrefix(d, "*.")
+			if base != d && !strings.Contains(base, ".") {
+				continue // reported by br_wildcard_tld
+			}
+			if isInternalName(base) {
+				out = append(out, fmt.Sprintf("%q is an internal name that cannot be publicly validated", d))
+			}
+		}
+		return out
+	}},
+	{"br_reserved_ip", LintError, "CA/B Forum BR 7.1.2.7.12", subscriberTLS, func(c lintCert) []string {
+		var out []string
+		for _, ip := range c.IPAddresses {
+			if isReservedIP(ip) {
+				out = append(out, fmt.Sprintf("%s is a reserved IP address", ip))
+			}
+		}
+		return out
+	}},
+	{"br_eku_any", LintError, "CA/B Forum BR 7.1.2.7.10", subscriberTLS, func(c lintCert) []string {
+		for _, e := range c.ExtKeyUsage {
+			if e == x509.ExtKeyUsageAny {
+				return []string{"anyExtendedKeyUsage is not allowed"}
+			}
+		}
+		return nil
+	}},
+	{"br_eku_missing", LintError, "CA/B Forum BR 7.1.2.7.10", subscriberTLS, func(c lintCert) []string {
+		found, _ := hasExt(c.Certificate, oidExtEKU)
+		return one(!found, "extKeyUsage is missing")
+	}},
+	{"br_eku_mixed", LintWarning, "CA/B Forum BR 7.1.2.7.10", subscriberTLS, func(c lintCert) []string {
+		for _, e := range c.ExtKeyUsage {
+			switch e {
+			case x509.ExtKeyUsageCodeSigning, x509.ExtKeyUsageEmailProtection, x509.ExtKeyUsageTimeStamping, x509.ExtKeyUsageOCSPSigning:
+				return []string{"TLS server certificate also asserts " + ExtKeyUsageStrings([]x509.ExtKeyUsage{e}, nil)[0]}
+			}
+		}
+		return nil
+	}},
+	{"br_policies_missing", LintWarning, "CA/B Forum BR 7.1.6.4", subscriberTLS, func(c lintCert) []string {
+		found, _ := hasExt(c.Certificate, oidExtCP)
+		return one(!found, "certificatePolicies is missing; a CA/B Forum reserved policy OID is expected")
+	}},
+	{"br_aia_missing", LintWarning, "CA/B Forum BR 7.1.2.7.7", subscriberTLS, func(c lintCert) []string {
+		found, _ := hasExt(c.Certificate, oidExtAIA)
+		return one(!found || len(c.IssuingCertificateURL) == 0, "authorityInformationAccess with a caIssuers URL is missing")
+	}},
+	{"br_ca_key_usage_critical", LintError, "CA/B Forum BR 7.1.2.10.7", isCA, func(c lintCert) []string {
+		found, critical := hasExt(c.Certificate, oidExtKU)
+		return one(found && !critical, "CA certificate keyUsage is not critical")
+	}},
+	{"br_root_validity", LintWarning, "CA/B Forum BR 6.3.2", func(c lintCert) bool { return c.kind == KindRoot }, func(c lintCert) []string {
+		d := validityDays(c.Certificate)
+		return one(d > 25*366, "root validity is %.0f days; more than 25 years", d)
+	}},
+	{"br_email_san_tls", LintNotice, "CA/B Forum BR 7.1.2.7.12", subscriberTLS, func(c lintCert) []string {
+		return one(len(c.EmailAddresses)+len(c.URIs) > 0, "TLS server certificate carries email or URI subjectAltNames")
+	}},
+
+	// current state
+	{"validity_expired", LintNotice, "RFC 5280 4.1.2.5", nil, func(c lintCert) []string {
+		return one(c.now.After(c.NotAfter), "certificate expired on %s", c.NotAfter.Format(time.RFC3339))
+	}},
+	{"validity_not_yet", LintNotice, "RFC 5280 4.1.2.5", nil, func(c lintCert) []string {
+		return one(c.now.Before(c.NotBefore), "certificate is not valid until %s", c.NotBefore.Format(time.RFC3339))
+	}},
+}
+
+// LintKind classifies crt as a subscriber, intermediate or root certificate.
+func LintKind(crt *x509.Certificate) string {
+	switch {
+	case !crt.IsCA:
+		return KindSubscriber
+	case bytes.Equal(crt.RawSubject, crt.RawIssuer) && (len(crt.AuthorityKeyId) == 0 || bytes.Equal(crt.AuthorityKeyId, crt.SubjectKeyId)):
+		// not a signature check: roots signed with SHA-1 would fail it
+		return KindRoot
+	}
+	return KindIntermediate
+}
+
+// Lint runs the check catalog against crt. The CA/B Forum TLS rules only apply to
+// subscriber certificates that can authenticate TLS servers (serverAuth or no EKU).
+func Lint(crt *x509.Certificate, now time.Time) []Finding {
+	c := lintCert{Certificate: crt, kind: LintKind(crt), now: now}
+	if c.kind == KindSubscriber {
+		c.serverTLS = len(crt.ExtKeyUsage) == 0 && len(crt.UnknownExtKeyUsage) == 0
+		for _, e := range crt.ExtKeyUsage {
+			if e == x509.ExtKeyUsageServerAuth || e == x509.ExtKeyUsageAny {
+				c.serverTLS = true
+			}
+		}
+	}
+	findings := []Finding{}
+	for _, l := range lints {
+		if l.applies != nil && !l.applies(c) {
+			continue
+		}
+		for _, msg := range l.check(c) {
+			findings = append(findings, Finding{ID: l.id, Severity: l.severity, Message: msg, Reference: l.ref})
+		}
+	}
+	return findings
+}
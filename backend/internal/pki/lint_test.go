@@ -0,0 +1,60 @@
+package pki_test
+
+import (
+	"crypto/x509"
+	"math/big"
+	"testing"
+	"time"
+
+	"engtools/backend/internal/pki"
+	"engtools/backend/internal/pki/pkitest"
+)
+
+func TestLint(t *testing.T) {
+	crt := pkitest.Issue(t, pki.CertSpec{
+		NameSpec:     pki.NameSpec{CN: "www.example.org"},
+		SANSpec:      pki.SANSpec{DNS: []string{"a.example.org", "*.org", "b_c.example.org"}},
+		ValidityDays: 730,
+	}, pkitest.Key(t, "ec"), nil).Cert
+	got := map[string]bool{}
+	for _, f := range pki.Lint(crt, time.Now()) {
+		got[f.ID] = true
+	}
+	for _, id := range []string{"br_validity_398", "br_cn_not_in_san", "br_wildcard_tld", "br_dns_name_syntax"} {
+		if !got[id] {
+			t.Errorf("expected finding %s, got %v", id, got)
+		}
+	}
+	for _, id := range []string{"br_key_algorithm", "br_serial_entropy", "br_weak_signature", "br_internal_name"} {
+		if got[id] {
+			t.Errorf("unexpected finding %s", id)
+		}
+	}
+}
+
+func TestLintSerialEntropy(t *testing.T) {
+	key := pkitest.Key(t, "ec")
+	for _, tc := range []struct {
+		bits uint
+		want bool
+	}{
+		{63, false}, // 8 bytes
+		{57, false}, // 8 bytes although fewer than 64 bits
+		{56, false}, // 7 bytes plus the 0x00 pad
+		{55, true},  // 7 bytes
+	} {
+		serial := new(big.Int).Lsh(big.NewInt(1), tc.bits-1)
+		crt := pkitest.Issue(t, pki.CertSpec{
+			NameSpec:     pki.NameSpec{CN: "www.example.org"},
+			SANSpec:      pki.SANSpec{DNS: []string{"www.example.org"}},
+			ValidityDays: 90,
+		}, key, nil, func(c *x509.Certificate) { c.SerialNumber = serial }).Cert
+		got := false
+		for _, f := range pki.Lint(crt, time.Now()) {
+			got = got || f.ID == "br_serial_entropy"
+		}
+		if got != tc.want {
+			t.Errorf("%d-bit serial: finding %v, want %v", tc.bits, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package pki
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+// maxOCSPResponse bounds what is read from a responder.
+const maxOCSPResponse = 1 << 20
+
+var ocspStatusNames = map[int]string{ocsp.Good: "good", ocsp.Revoked: "revoked", ocsp.Unknown: "unknown"}
+
+// ParseOCSPHash maps sha1 (the default, and what most responders expect), sha256, sha384
+// and sha512 to the CertID hash.
+func ParseOCSPHash(s string) (crypto.Hash, error) {
+	switch strings.ToLower(strings.ReplaceAll(s, "-", "")) {
+	case "", "sha1":
+		return crypto.SHA1, nil
+	case "sha256":
+		return crypto.SHA256, nil
+	case "sha384":
+		return crypto.SHA384, nil
+	case "sha512":
+		return crypto.SHA512, nil
+	}
+	return 0, errors.New("unsupported hash: " + s)
+}
+
+// OCSPRequestInfo describes a request we built or were given.
+type OCSPRequestInfo struct {
+	HashAlg        string `json:"hash_alg"`
+	IssuerNameHash string `json:"issuer_name_hash"`
+	IssuerKeyHash  string `json:"issuer_key_hash"`
+	Serial         string `json:"serial"`
+}
+
+func NewOCSPRequestInfo(req *ocsp.Request) OCSPRequestInfo {
+	return OCSPRequestInfo{
+		HashAlg:        strings.ToLower(strings.ReplaceAll(req.HashAlgorithm.String(), "-", "")),
+		IssuerNameHash: HexColon(req.IssuerNameHash),
+		IssuerKeyHash:  HexColon(req.IssuerKeyHash),
+		Serial:         req.SerialNumber.Text(16),
+	}
+}
+
+// CreateOCSPRequest builds a DER OCSP request for crt as issued by issuer.
+func CreateOCSPRequest(crt, issuer *x509.Certificate, hash crypto.Hash) ([]byte, *ocsp.Request, error) {
+	der, err := ocsp.CreateRequest(crt, issuer, &ocsp.RequestOptions{Hash: hash})
+	if err != nil {
+		return nil, nil, err
+	}
+	req, err := ocsp.ParseRequest(der)
+	if err != nil {
+		return nil, nil, err
+	}
+	return der, req, nil
+}
+
+// SendOCSP posts req to the responder at rawURL, or uses the RFC 6960 appendix A GET form
+// when get is set.
+func SendOCSP(ctx context.Context, client *http.Client, rawURL string, req []byte, get bool) ([]byte, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, errors.New("invalid ocsp url: " + rawURL)
+	}
+	var hreq *http.Request
+	if get {
+		// the base64 may contain '/', which must be escaped in the path
+		target := strings.TrimSuffix(rawURL, "/") + "/" + url.PathEscape(base64.StdEncoding.EncodeToString(req))
+		hreq, err = http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	} else {
+		hreq, err = http.NewRequestWithContext(ctx, http.MethodPost, rawURL, bytes.NewReader(req))
+		if err == nil {
+			hreq.Header.Set("Content-Type", "application/ocsp-request")
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	hreq.Header.Set("Accept", "application/ocsp-response")
+	resp, err := client.Do(hreq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("responder returned http %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxOCSPResponse+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > maxOCSPResponse {
+		return nil, errors.New("ocsp response too large")
+	}
+	return body, nil
+}
+
+// OCSPInfo is the parsed view of an OCSP response. ResponseStatus other than "successful"
+// means the responder refused and the remaining fields are empty.
+type OCSPInfo struct {
+	ResponseStatus   string      `json:"response_status"`
+	CertStatus       string      `json:"cert_status,omitempty"` // good, revoked or unknown
+	Serial           string      `json:"serial,omitempty"`
+	ProducedAt       *time.Time  `json:"produced_at,omitempty"`
+	ThisUpdate       *time.Time  `json:"this_update,omitempty"`
+	NextUpdate       *time.Time  `json:"next_update,omitempty"`
+	RevokedAt        *time.Time  `json:"revoked_at,omitempty"`
+	RevocationReason string      `json:"revocation_reason,omitempty"`
+	ResponderName    string      `json:"responder_name,omitempty"`
+	ResponderKeyHash string      `json:"responder_key_hash,omitempty"`
+	ResponderCert    string      `json:"responder_cert,omitempty"` // subject of the embedded delegated responder
+	SigAlg           string      `json:"sig_alg,omitempty"`
+	SignatureValid   *bool       `json:"signature_valid,omitempty"` // nil when no issuer was given
+	SignatureError   string      `json:"signature_error,omitempty"`
+	Extensions       []Extension `json:"extensions,omitempty"`
+	SCTs             []SCT       `json:"scts,omitempty"`
+	Warnings         []string    `json:"warnings,omitempty"`
+}
+
+func timePtr(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// ParseOCSPResponse decodes der. crt picks the matching entry when a response carries
+// several; issuer, when given, is used to check the signature. A bad signature is
+// reported in the result rather than as an error so the contents can still be inspected.
+func ParseOCSPResponse(der []byte, crt, issuer *x509.Certificate) (*OCSPInfo, error) {
+	resp, err := ocsp.ParseResponseForCert(der, crt, nil)
+	var re ocsp.ResponseError
+	if errors.As(err, &re) {
+		return &OCSPInfo{ResponseStatus: responseStatusName(re.Status)}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	info := &OCSPInfo{
+		ResponseStatus: "successful",
+		CertStatus:     ocspStatusNames[resp.Status],
+		ProducedAt:     timePtr(resp.ProducedAt), ThisUpdate: timePtr(resp.ThisUpdate), NextUpdate: timePtr(resp.NextUpdate),
+		SigAlg:           resp.SignatureAlgorithm.String(),
+		ResponderKeyHash: HexColon(resp.ResponderKeyHash),
+		Extensions:       DescribeExtensions(resp.Extensions),
+	}
+	if resp.SerialNumber != nil {
+		info.Serial = resp.SerialNumber.Text(16)
+	}
+	if resp.Status == ocsp.Revoked {
+		info.RevokedAt = timePtr(resp.RevokedAt)
+		info.RevocationReason = ReasonName(resp.RevocationReason)
+	}
+	if len(resp.RawResponderName) > 0 {
+		var rdn pkix.RDNSequence
+		if _, err := asn1.Unmarshal(resp.RawResponderName, &rdn); err == nil {
+			var name pkix.Name
+			name.FillFromRDNSequence(&rdn)
+			info.ResponderName = name.String()
+		}
+	}
+	if resp.Certificate != nil {
+		info.ResponderCert = resp.Certificate.Subject.String()
+	}
+	for _, e := range resp.Extensions {
+		if e.Id.Equal(OIDExtOCSPSCTList) {
+			scts, err := ParseSCTListExtension(e.Value)
+			if err != nil {
+				info.Warnings = append(info.Warnings, err.Error())
+			}
+			info.SCTs = SCTsFrom(scts, "ocsp")
+		}
+	}
+	if issuer != nil {
+		err := verifyOCSPSignature(resp, issuer)
+		valid := err == nil
+		info.SignatureValid = &valid
+		if err != nil {
+			info.SignatureError = err.Error()
+		}
+	}
+	now := time.Now()
+	if info.NextUpdate != nil && now.After(*info.NextUpdate) {
+		info.Warnings = append(info.Warnings, "response is past its next update")
+	}
+	if info.ThisUpdate != nil && now.Before(*info.ThisUpdate) {
+		info.Warnings = append(info.Warnings, "response this update is in the future")
+	}
+	return info, nil
+}
+
+// verifyOCSPSignature accepts a response signed by the issuer itself or by a delegated
+// responder that the issuer certified for OCSP signing (RFC 6960 section 4.2.2.2).
+func verifyOCSPSignature(resp *ocsp.Response, issuer *x509.Certificate) error {
+	if resp.Certificate == nil || bytes.Equal(resp.Certificate.RawSubjectPublicKeyInfo, issuer.RawSubjectPublicKeyInfo) {
+		return resp.CheckSignatureFrom(issuer)
+	}
+	if err := resp.Certificate.CheckSignatureFrom(issuer); err != nil {
+		return fmt.Errorf("responder certificate not issued by the issuer: %w", err)
+	}
+	for _, eku := range resp.Certificate.ExtKeyUsage {
+		if eku == x509.ExtKeyUsageOCSPSigning {
+			return nil
+		}
+	}
+	return errors.New("responder certificate lacks the OCSPSigning extended key usage")
+}
+
+func responseStatusName(s ocsp.ResponseStatus) string {
+	switch s {
+	case ocsp.Malformed:
+		return "malformedRequest"
+	case ocsp.InternalError:
+		return "internalError"
+	case ocsp.TryLater:
+		return "tryLater"
+	case ocsp.SignatureRequired:
+		return "sigRequired"
+	case ocsp.Unauthorized:
+		return "unauthorized"
+	}
+	return fmt.Sprintf("unknown(%d)", int(s))
+}
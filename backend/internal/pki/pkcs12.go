@@ -0,0 +1,712 @@
+package pki
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"hash"
+	"unicode/utf16"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// PKCS#12 support, written here rather than taken from golang.org/x/crypto/pkcs12. That
+// package is frozen and decode-only, reads only SHA-1 MACs with 3DES/RC2 PBE (so it fails
+// on the PBES2/AES and SHA-256 MAC defaults of OpenSSL 3, Windows Server 2019+ and Java
+// 8u301+), and puts no bound on iteration counts, so one uploaded file could keep it busy
+// in the KDF for minutes. Here every key derivation, the MAC included, is charged to a
+// per-file iteration budget before it runs.
+
+var (
+	oidData                 = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidEncryptedData        = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 6}
+	oidKeyBag               = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 1}
+	oidShroudedKeyBag       = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 2}
+	oidCertBag              = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 3}
+	oidCertTypeX509         = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 22, 1}
+	oidFriendlyName         = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 20}
+	oidLocalKeyID           = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 21}
+	oidPBEWithSHA3DES       = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 1, 3}
+	oidPBEWithSHARC240      = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 1, 6}
+	oidPBES2                = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2               = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+	oidHMACWithSHA1         = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 7}
+	oidHMACWithSHA256       = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	oidHMACWithSHA384       = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 10}
+	oidHMACWithSHA512       = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 11}
+	oidAES128CBC            = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 2}
+	oidAES192CBC            = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 22}
+	oidAES256CBC            = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+	oidDESEDE3CBC           = asn1.ObjectIdentifier{1, 2, 840, 113549, 3, 7}
+	oidSHA1                 = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+	oidSHA256               = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidSHA384               = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 2}
+	oidSHA512               = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3}
+	errPKCS12Unsupported    = errors.New("pkcs12: unsupported encoding")
+	ErrPKCS12BadPassword    = errors.New("pkcs12: incorrect password or corrupted file")
+	ErrPKCS12Iterations     = errors.New("pkcs12: iteration count out of range")
+	pkcs12DefaultIterations = 2048
+	// pkcs12MaxIterations bounds a single key derivation and pkcs12MaxTotalIterations all
+	// of them in one file. Real files use at most 600,000 (PBKDF2 as recommended by OWASP),
+	// usually 2,048.
+	pkcs12MaxIterations      = 1_000_000
+	pkcs12MaxTotalIterations = 4_000_000
+)
+
+type p12ContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"tag:0,explicit,optional"`
+}
+
+type p12EncryptedData struct {
+	Version              int
+	EncryptedContentInfo p12EncryptedContentInfo
+}
+
+type p12EncryptedContentInfo struct {
+	ContentType                asn1.ObjectIdentifier
+	ContentEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedContent           []byte `asn1:"tag:0,optional"`
+}
+
+type p12SafeBag struct {
+	ID         asn1.ObjectIdentifier
+	Value      asn1.RawValue  `asn1:"tag:0,explicit"`
+	Attributes []p12Attribute `asn1:"set,optional"`
+}
+
+type p12Attribute struct {
+	ID    asn1.ObjectIdentifier
+	Value asn1.RawValue `asn1:"set"`
+}
+
+type p12CertBag struct {
+	ID   asn1.ObjectIdentifier
+	Data []byte `asn1:"tag:0,explicit"`
+}
+
+type p12MacData struct {
+	Mac        p12DigestInfo
+	MacSalt    []byte
+	Iterations int `asn1:"optional,default:1"`
+}
+
+type p12DigestInfo struct {
+	Algorithm pkix.AlgorithmIdentifier
+	Digest    []byte
+}
+
+type p12PFX struct {
+	Version  int
+	AuthSafe p12ContentInfo
+	MacData  p12MacData `asn1:"optional"`
+}
+
+type encryptedPrivateKeyInfo struct {
+	Algorithm     pkix.AlgorithmIdentifier
+	EncryptedData []byte
+}
+
+type pbes2Params struct {
+	KeyDerivationFunc pkix.AlgorithmIdentifier
+	EncryptionScheme  pkix.AlgorithmIdentifier
+}
+
+type pbkdf2Params struct {
+	Salt       []byte
+	Iterations int
+	KeyLength  int                      `asn1:"optional"`
+	PRF        pkix.AlgorithmIdentifier `asn1:"optional"`
+}
+
+type pbeParams struct {
+	Salt       []byte
+	Iterations int
+}
+
+// PKCS12Options controls Encode. Legacy selects SHA-1 MAC and 3DES encryption for old
+// consumers (Windows Server 2016 and earlier, Java 8 before 8u301).
+type PKCS12Options struct {
+	Password     string
+	FriendlyName string
+	Legacy       bool
+}
+
+// PKCS12Contents is the decoded content of a PKCS#12 file.
+type PKCS12Contents struct {
+	Key          crypto.Signer
+	Leaf         *x509.Certificate
+	Chain        []*x509.Certificate
+	FriendlyName string
+	// Legacy reports whether the file used the PKCS#12 PBE algorithms (3DES, RC2)
+	// rather than PBES2.
+	Legacy bool
+}
+
+// EncodePKCS12 bundles key, leaf and chain. The key may be nil for a certificate-only
+// truststore.
+func EncodePKCS12(key crypto.Signer, leaf *x509.Certificate, chain []*x509.Certificate, opts PKCS12Options) ([]byte, error) {
+	if leaf == nil {
+		return nil, errors.New("certificate required")
+	}
+	localKeyID := sha1.Sum(leaf.Raw)
+	leafAttrs, err := p12Attributes(opts.FriendlyName, localKeyID[:])
+	if err != nil {
+		return nil, err
+	}
+	var certBags []p12SafeBag
+	for i, crt := range append([]*x509.Certificate{leaf}, chain...) {
+		bag, err := p12MakeCertBag(crt)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			bag.Attributes = leafAttrs
+		}
+		certBags = append(certBags, *bag)
+	}
+	certSafe, err := asn1.Marshal(certBags)
+	if err != nil {
+		return nil, err
+	}
+	certEnc, err := p12Encrypt(certSafe, opts)
+	if err != nil {
+		return nil, err
+	}
+	encData, err := asn1.Marshal(p12EncryptedData{EncryptedContentInfo: p12EncryptedContentInfo{ContentType: oidData, ContentEncryptionAlgorithm: certEnc.Algorithm, EncryptedContent: certEnc.EncryptedData}})
+	if err != nil {
+		return nil, err
+	}
+	authSafe := []p12ContentInfo{{ContentType: oidEncryptedData, Content: explicit0(encData)}}
+
+	if key != nil {
+		if !PublicKeysEqual(leaf.PublicKey, key.Public()) {
+			return nil, errors.New("private key does not match the certificate")
+		}
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			return nil, err
+		}
+		epki, err := p12Encrypt(der, opts)
+		if err != nil {
+			return nil, err
+		}
+		epkiDER, err := asn1.Marshal(*epki)
+		if err != nil {
+			return nil, err
+		}
+		keySafe, err := asn1.Marshal([]p12SafeBag{{ID: oidShroudedKeyBag, Value: explicit0(epkiDER), Attributes: leafAttrs}})
+		if err != nil {
+			return nil, err
+		}
+		keyOctets, err := asn1.Marshal(keySafe)
+		if err != nil {
+			return nil, err
+		}
+		authSafe = append(authSafe, p12ContentInfo{ContentType: oidData, Content: explicit0(keyOctets)})
+	}
+
+	authSafeDER, err := asn1.Marshal(authSafe)
+	if err != nil {
+		return nil, err
+	}
+	authOctets, err := asn1.Marshal(authSafeDER)
+	if err != nil {
+		return nil, err
+	}
+	pfx := p12PFX{Version: 3, AuthSafe: p12ContentInfo{ContentType: oidData, Content: explicit0(authOctets)}}
+	macAlg, newHash := oidSHA256, sha256.New
+	if opts.Legacy {
+		macAlg, newHash = oidSHA1, sha1.New
+	}
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	pfx.MacData = p12MacData{
+		Mac:        p12DigestInfo{Algorithm: pkix.AlgorithmIdentifier{Algorithm: macAlg, Parameters: asn1.NullRawValue}, Digest: p12MAC(newHash, authSafeDER, salt, opts.Password, pkcs12DefaultIterations)},
+		MacSalt:    salt,
+		Iterations: pkcs12DefaultIterations,
+	}
+	return asn1.Marshal(pfx)
+}
+
+// explicit0 wraps der in the [0] EXPLICIT tag. encoding/asn1 ignores the tag parameters of
+// RawValue fields, so the wrapper is built (and on decode, unwrapped via Bytes) by hand.
+func explicit0(der []byte) asn1.RawValue {
+	return asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: der}
+}
+
+func p12Attributes(friendlyName string, localKeyID []byte) ([]p12Attribute, error) {
+	var attrs []p12Attribute
+	id, err := asn1.Marshal(localKeyID)
+	if err != nil {
+		return nil, err
+	}
+	attrs = append(attrs, p12Attribute{ID: oidLocalKeyID, Value: asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true, Bytes: id}})
+	if friendlyName != "" {
+		name, err := asn1.Marshal(asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagBMPString, Bytes: bmpString(friendlyName, false)})
+		if err != nil {
+			return nil, err
+		}
+		attrs = append(attrs, p12Attribute{ID: oidFriendlyName, Value: asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true, Bytes: name}})
+	}
+	return attrs, nil
+}
+
+func p12MakeCertBag(crt *x509.Certificate) (*p12SafeBag, error) {
+	der, err := asn1.Marshal(p12CertBag{ID: oidCertTypeX509, Data: crt.Raw})
+	if err != nil {
+		return nil, err
+	}
+	return &p12SafeBag{ID: oidCertBag, Value: explicit0(der)}, nil
+}
+
+// bmpString encodes s as UTF-16BE, optionally with the two-byte terminator the PKCS#12
+// key derivation expects.
+func bmpString(s string, terminate bool) []byte {
+	var out []byte
+	for _, r := range utf16.Encode([]rune(s)) {
+		out = append(out, byte(r>>8), byte(r))
+	}
+	if terminate {
+		out = append(out, 0, 0)
+	}
+	return out
+}
+
+func decodeBMPString(b []byte) string {
+	if len(b)%2 != 0 {
+		return ""
+	}
+	u := make([]uint16, 0, len(b)/2)
+	for i := 0; i < len(b); i += 2 {
+		u = append(u, uint16(b[i])<<8|uint16(b[i+1]))
+	}
+	return string(utf16.Decode(u))
+}
+
+// p12KDF is the PKCS#12 key derivation function of RFC 7292 appendix B.2.
+func p12KDF(newHash func() hash.Hash, id byte, password, salt []byte, iterations, n int) []byte {
+	h := newHash()
+	u, v := h.Size(), h.BlockSize()
+	fill := func(in []byte) []byte {
+		if len(in) == 0 {
+			return nil
+		}
+		out := make([]byte, v*((len(in)+v-1)/v))
+		for i := range out {
+			out[i] = in[i%len(in)]
+		}
+		return out
+	}
+	D := bytes.Repeat([]byte{id}, v)
+	I := append(fill(salt), fill(password)...)
+	var out []byte
+	for len(out) < n {
+		h.Reset()
+		h.Write(D)
+		h.Write(I)
+		A := h.Sum(nil)
+		for i := 1; i < iterations; i++ {
+			h.Reset()
+			h.Write(A)
+			A = h.Sum(nil)
+		}
+		out = append(out, A...)
+		if len(out) >= n {
+			break
+		}
+		B := make([]byte, v)
+		for i := range B {
+			B[i] = A[i%u]
+		}
+		// I_j = (I_j + B + 1) mod 2^(8v) for each v-byte block
+		for j := 0; j < len(I); j += v {
+			carry := 1
+			for k := v - 1; k >= 0; k-- {
+				sum := int(I[j+k]) + int(B[k]) + carry
+				I[j+k] = byte(sum)
+				carry = sum >> 8
+			}
+		}
+	}
+	return out[:n]
+}
+
+func p12MAC(newHash func() hash.Hash, data, salt []byte, password string, iterations int) []byte {
+	key := p12KDF(newHash, 3, bmpString(password, true), salt, iterations, newHash().Size())
+	m := hmac.New(newHash, key)
+	m.Write(data)
+	return m.Sum(nil)
+}
+
+func macHash(oid asn1.ObjectIdentifier) func() hash.Hash {
+	switch {
+	case oid.Equal(oidSHA1):
+		return sha1.New
+	case oid.Equal(oidSHA256):
+		return sha256.New
+	case oid.Equal(oidSHA384):
+		return sha512.New384
+	case oid.Equal(oidSHA512):
+		return sha512.New
+	}
+	return nil
+}
+
+// p12Encrypt encrypts data with PBES2 (PBKDF2-HMAC-SHA256, AES-256-CBC), or with
+// pbeWithSHAAnd3-KeyTripleDES-CBC in legacy mode.
+func p12Encrypt(data []byte, opts PKCS12Options) (*encryptedPrivateKeyInfo, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	if opts.Legacy {
+		salt = salt[:8]
+		params, err := asn1.Marshal(pbeParams{Salt: salt, Iterations: pkcs12DefaultIterations})
+		if err != nil {
+			return nil, err
+		}
+		pw := bmpString(opts.Password, true)
+		key := p12KDF(sha1.New, 1, pw, salt, pkcs12DefaultIterations, 24)
+		iv := p12KDF(sha1.New, 2, pw, salt, pkcs12DefaultIterations, 8)
+		block, err := des.NewTripleDESCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		return &encryptedPrivateKeyInfo{
+			Algorithm:     pkix.AlgorithmIdentifier{Algorithm: oidPBEWithSHA3DES, Parameters: asn1.RawValue{FullBytes: params}},
+			EncryptedData: cbcEncrypt(block, iv, data),
+		}, nil
+	}
+	iv := make([]byte, aes.BlockSize)
+	if _, err := rand.Read(iv); err != nil {
+		return nil, err
+	}
+	key := pbkdf2.Key([]byte(opts.Password), salt, pkcs12DefaultIterations, 32, sha256.New)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	kdf, err := asn1.Marshal(pbkdf2Params{Salt: salt, Iterations: pkcs12DefaultIterations, PRF: pkix.AlgorithmIdentifier{Algorithm: oidHMACWithSHA256, Parameters: asn1.NullRawValue}})
+	if err != nil {
+		return nil, err
+	}
+	ivDER, err := asn1.Marshal(iv)
+	if err != nil {
+		return nil, err
+	}
+	params, err := asn1.Marshal(pbes2Params{
+		KeyDerivationFunc: pkix.AlgorithmIdentifier{Algorithm: oidPBKDF2, Parameters: asn1.RawValue{FullBytes: kdf}},
+		EncryptionScheme:  pkix.AlgorithmIdentifier{Algorithm: oidAES256CBC, Parameters: asn1.RawValue{FullBytes: ivDER}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &encryptedPrivateKeyInfo{
+		Algorithm:     pkix.AlgorithmIdentifier{Algorithm: oidPBES2, Parameters: asn1.RawValue{FullBytes: params}},
+		EncryptedData: cbcEncrypt(block, iv, data),
+	}, nil
+}
+
+func cbcEncrypt(block cipher.Block, iv, data []byte) []byte {
+	pad := block.BlockSize() - len(data)%block.BlockSize()
+	buf := append(append([]byte{}, data...), bytes.Repeat([]byte{byte(pad)}, pad)...)
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(buf, buf)
+	return buf
+}
+
+// p12Budget is the number of KDF iterations a file may still demand.
+type p12Budget struct {
+	left int
+}
+
+func (b *p12Budget) spend(n int) error {
+	if n < 1 || n > pkcs12MaxIterations {
+		return fmt.Errorf("%w: %d (maximum %d)", ErrPKCS12Iterations, n, pkcs12MaxIterations)
+	}
+	if n > b.left {
+		return fmt.Errorf("%w: more than %d in total", ErrPKCS12Iterations, pkcs12MaxTotalIterations)
+	}
+	b.left -= n
+	return nil
+}
+
+func p12Decrypt(alg pkix.AlgorithmIdentifier, password string, data []byte, budget *p12Budget) ([]byte, error) {
+	var block cipher.Block
+	var iv []byte
+	switch {
+	case alg.Algorithm.Equal(oidPBEWithSHA3DES), alg.Algorithm.Equal(oidPBEWithSHARC240):
+		var p pbeParams
+		if _, err := asn1.Unmarshal(alg.Parameters.FullBytes, &p); err != nil {
+			return nil, err
+		}
+		// the key and the IV are derived separately
+		if err := budget.spend(p.Iterations); err != nil {
+			return nil, err
+		}
+		if err := budget.spend(p.Iterations); err != nil {
+			return nil, err
+		}
+		pw := bmpString(password, true)
+		if alg.Algorithm.Equal(oidPBEWithSHARC240) {
+			block = newRC2(p12KDF(sha1.New, 1, pw, p.Salt, p.Iterations, 5), 40)
+		} else {
+			var err error
+			if block, err = des.NewTripleDESCipher(p12KDF(sha1.New, 1, pw, p.Salt, p.Iterations, 24)); err != nil {
+				return nil, err
+			}
+		}
+		iv = p12KDF(sha1.New, 2, pw, p.Salt, p.Iterations, 8)
+	case alg.Algorithm.Equal(oidPBES2):
+		var p pbes2Params
+		if _, err := asn1.Unmarshal(alg.Parameters.FullBytes, &p); err != nil {
+			return nil, err
+		}
+		if !p.KeyDerivationFunc.Algorithm.Equal(oidPBKDF2) {
+			return nil, errPKCS12Unsupported
+		}
+		var kp pbkdf2Params
+		if _, err := asn1.Unmarshal(p.KeyDerivationFunc.Parameters.FullBytes, &kp); err != nil {
+			return nil, err
+		}
+		prf := sha1.New
+		switch {
+		case len(kp.PRF.Algorithm) == 0, kp.PRF.Algorithm.Equal(oidHMACWithSHA1):
+		case kp.PRF.Algorithm.Equal(oidHMACWithSHA256):
+			prf = sha256.New
+		case kp.PRF.Algorithm.Equal(oidHMACWithSHA384):
+			prf = sha512.New384
+		case kp.PRF.Algorithm.Equal(oidHMACWithSHA512):
+			prf = sha512.New
+		default:
+			return nil, errPKCS12Unsupported
+		}
+		keyLen := 0
+		switch {
+		case p.EncryptionScheme.Algorithm.Equal(oidAES128CBC):
+			keyLen = 16
+		case p.EncryptionScheme.Algorithm.Equal(oidAES192CBC):
+			keyLen = 24
+		case p.EncryptionScheme.Algorithm.Equal(oidAES256CBC):
+			keyLen = 32
+		case p.EncryptionScheme.Algorithm.Equal(oidDESEDE3CBC):
+			keyLen = 24
+		default:
+			return nil, errPKCS12Unsupported
+		}
+		if _, err := asn1.Unmarshal(p.EncryptionScheme.Parameters.FullBytes, &iv); err != nil {
+			return nil, err
+		}
+		if err := budget.spend(kp.Iterations); err != nil {
+			return nil, err
+		}
+		key := pbkdf2.Key([]byte(password), kp.Salt, kp.Iterations, keyLen, prf)
+		var err error
+		if p.EncryptionScheme.Algorithm.Equal(oidDESEDE3CBC) {
+			block, err = des.NewTripleDESCipher(key)
+		} else {
+			block, err = aes.NewCipher(key)
+		}
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errPKCS12Unsupported
+	}
+	bs := block.BlockSize()
+	if len(iv) != bs || len(data) == 0 || len(data)%bs != 0 {
+		return nil, ErrPKCS12BadPassword
+	}
+	out := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(out, data)
+	pad := int(out[len(out)-1])
+	if pad == 0 || pad > bs || !bytes.Equal(out[len(out)-pad:], bytes.Repeat([]byte{byte(pad)}, pad)) {
+		return nil, ErrPKCS12BadPassword
+	}
+	return out[:len(out)-pad], nil
+}
+
+// DecodePKCS12 extracts the key, leaf certificate, chain and friendly name. The leaf is the
+// certificate matching the key, or the first certificate when there is no key.
+func DecodePKCS12(data []byte, password string) (*PKCS12Contents, error) {
+	var pfx p12PFX
+	if rest, err := asn1.Unmarshal(data, &pfx); err != nil || len(rest) != 0 {
+		return nil, errPKCS12Unsupported
+	}
+	if pfx.Version != 3 || !pfx.AuthSafe.ContentType.Equal(oidData) {
+		return nil, errPKCS12Unsupported
+	}
+	var authSafeDER []byte
+	if _, err := asn1.Unmarshal(pfx.AuthSafe.Content.Bytes, &authSafeDER); err != nil {
+		return nil, errPKCS12Unsupported
+	}
+	col := &p12Collected{budget: p12Budget{left: pkcs12MaxTotalIterations}}
+	if len(pfx.MacData.Mac.Digest) > 0 {
+		newHash := macHash(pfx.MacData.Mac.Algorithm.Algorithm)
+		if newHash == nil {
+			return nil, errPKCS12Unsupported
+		}
+		iter := pfx.MacData.Iterations
+		if err := col.budget.spend(iter); err != nil {
+			return nil, err
+		}
+		want := pfx.MacData.Mac.Digest
+		if !hmac.Equal(p12MAC(newHash, authSafeDER, pfx.MacData.MacSalt, password, iter), want) {
+			if password != "" {
+				return nil, ErrPKCS12BadPassword
+			}
+			// some tools write the MAC of an empty password without the BMP terminator
+			if err := col.budget.spend(iter); err != nil {
+				return nil, err
+			}
+			if !hmac.Equal(p12MACNoTerminator(newHash, authSafeDER, pfx.MacData.MacSalt, iter), want) {
+				return nil, ErrPKCS12BadPassword
+			}
+		}
+	}
+	var contents []p12ContentInfo
+	if _, err := asn1.Unmarshal(authSafeDER, &contents); err != nil {
+		return nil, errPKCS12Unsupported
+	}
+	for _, ci := range contents {
+		var safe []byte
+		switch {
+		case ci.ContentType.Equal(oidData):
+			if _, err := asn1.Unmarshal(ci.Content.Bytes, &safe); err != nil {
+				return nil, errPKCS12Unsupported
+			}
+		case ci.ContentType.Equal(oidEncryptedData):
+			var ed p12EncryptedData
+			if _, err := asn1.Unmarshal(ci.Content.Bytes, &ed); err != nil {
+				return nil, errPKCS12Unsupported
+			}
+			var err error
+			if safe, err = col.decrypt(ed.EncryptedContentInfo.ContentEncryptionAlgorithm, password, ed.EncryptedContentInfo.EncryptedContent); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, errPKCS12Unsupported
+		}
+		var bags []p12SafeBag
+		if _, err := asn1.Unmarshal(safe, &bags); err != nil {
+			return nil, errPKCS12Unsupported
+		}
+		for _, bag := range bags {
+			if err := col.add(bag, password); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return col.contents()
+}
+
+type p12Collected struct {
+	keys         []crypto.Signer
+	certs        []*x509.Certificate
+	friendlyName string
+	legacy       bool
+	budget       p12Budget
+}
+
+func (c *p12Collected) decrypt(alg pkix.AlgorithmIdentifier, password string, data []byte) ([]byte, error) {
+	if !alg.Algorithm.Equal(oidPBES2) {
+		c.legacy = true
+	}
+	return p12Decrypt(alg, password, data, &c.budget)
+}
+
+func p12MACNoTerminator(newHash func() hash.Hash, data, salt []byte, iterations int) []byte {
+	key := p12KDF(newHash, 3, nil, salt, iterations, newHash().Size())
+	m := hmac.New(newHash, key)
+	m.Write(data)
+	return m.Sum(nil)
+}
+
+func (c *p12Collected) add(bag p12SafeBag, password string) error {
+	switch {
+	case bag.ID.Equal(oidCertBag):
+		var cb p12CertBag
+		if _, err := asn1.Unmarshal(bag.Value.Bytes, &cb); err != nil {
+			return errPKCS12Unsupported
+		}
+		if !cb.ID.Equal(oidCertTypeX509) {
+			return nil
+		}
+		crt, err := x509.ParseCertificate(cb.Data)
+		if err != nil {
+			return err
+		}
+		c.certs = append(c.certs, crt)
+	case bag.ID.Equal(oidShroudedKeyBag), bag.ID.Equal(oidKeyBag):
+		der := bag.Value.Bytes
+		if bag.ID.Equal(oidShroudedKeyBag) {
+			var epki encryptedPrivateKeyInfo
+			if _, err := asn1.Unmarshal(bag.Value.Bytes, &epki); err != nil {
+				return errPKCS12Unsupported
+			}
+			var err error
+			if der, err = c.decrypt(epki.Algorithm, password, epki.EncryptedData); err != nil {
+				return err
+			}
+		}
+		key, err := ParsePrivateKeyDER(der)
+		if err != nil {
+			return err
+		}
+		c.keys = append(c.keys, key)
+	default:
+		return nil
+	}
+	for _, a := range bag.Attributes {
+		if a.ID.Equal(oidFriendlyName) && c.friendlyName == "" {
+			var raw asn1.RawValue
+			if _, err := asn1.Unmarshal(a.Value.Bytes, &raw); err == nil && raw.Tag == asn1.TagBMPString {
+				c.friendlyName = decodeBMPString(raw.Bytes)
+			}
+		}
+	}
+	return nil
+}
+
+func (c *p12Collected) contents() (*PKCS12Contents, error) {
+	if len(c.certs) == 0 && len(c.keys) == 0 {
+		return nil, errors.New("pkcs12: no certificates or keys found")
+	}
+	if len(c.keys) > 1 {
+		return nil, fmt.Errorf("pkcs12: %d private keys found, only one is supported", len(c.keys))
+	}
+	out := &PKCS12Contents{FriendlyName: c.friendlyName, Legacy: c.legacy}
+	leaf := 0
+	if len(c.keys) == 1 {
+		out.Key = c.keys[0]
+		leaf = -1
+		for i, crt := range c.certs {
+			if PublicKeysEqual(crt.PublicKey, out.Key.Public()) {
+				leaf = i
+				break
+			}
+		}
+	}
+	for i, crt := range c.certs {
+		if i == leaf {
+			out.Leaf = crt
+		} else {
+			out.Chain = append(out.Chain, crt)
+		}
+	}
+	return out, nil
+}
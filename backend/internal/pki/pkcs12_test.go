@@ -0,0 +1,164 @@
+package pki_test
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"errors"
+	"testing"
+
+	"engtools/backend/internal/pki"
+	"engtools/backend/internal/pki/pkitest"
+)
+
+func TestPKCS12RoundTrip(t *testing.T) {
+	ca := pkitest.CA(t, "Test Root", nil)
+	leaf := pkitest.Issue(t, pki.CertSpec{NameSpec: pki.NameSpec{CN: "leaf"}}, pkitest.Key(t, "rsa"), ca)
+	for _, legacy := range []bool{false, true} {
+		der, err := pki.EncodePKCS12(leaf.Key, leaf.Cert, []*x509.Certificate{ca.Cert}, pki.PKCS12Options{Password: "pässword", FriendlyName: "my key", Legacy: legacy})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := pki.DecodePKCS12(der, "pässword")
+		if err != nil {
+			t.Fatalf("legacy=%v: %v", legacy, err)
+		}
+		if !got.Leaf.Equal(leaf.Cert) || len(got.Chain) != 1 || !got.Chain[0].Equal(ca.Cert) || got.FriendlyName != "my key" || got.Legacy != legacy {
+			t.Fatalf("legacy=%v: unexpected contents %+v", legacy, got)
+		}
+		if !pki.PublicKeysEqual(got.Key.Public(), leaf.Key.Public()) {
+			t.Fatal("key mismatch")
+		}
+		if _, err := pki.DecodePKCS12(der, "wrong"); err != pki.ErrPKCS12BadPassword {
+			t.Fatalf("wrong password: %v", err)
+		}
+	}
+}
+
+func TestPKCS12IterationLimit(t *testing.T) {
+	leaf := pkitest.Leaf(t, "leaf.test", nil)
+	der, err := pki.EncodePKCS12(leaf.Key, leaf.Cert, nil, pki.PKCS12Options{Password: "pw"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// PFX with the MAC iteration count exposed
+	var pfx struct {
+		Version  int
+		AuthSafe asn1.RawValue
+		MacData  struct {
+			Mac        asn1.RawValue
+			MacSalt    []byte
+			Iterations int
+		}
+	}
+	if _, err := asn1.Unmarshal(der, &pfx); err != nil {
+		t.Fatal(err)
+	}
+	pfx.MacData.Iterations = 1 << 30
+	if der, err = asn1.Marshal(pfx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pki.DecodePKCS12(der, "pw"); !errors.Is(err, pki.ErrPKCS12Iterations) {
+		t.Fatalf("huge MAC iteration count: %v", err)
+	}
+
+	// no MAC and one RC2-encrypted bag, the derivation the x/crypto decoder never bounded
+	type contentInfo struct {
+		ContentType asn1.ObjectIdentifier
+		Content     asn1.RawValue
+	}
+	type encryptedContentInfo struct {
+		ContentType asn1.ObjectIdentifier
+		Algorithm   pkix.AlgorithmIdentifier
+		Content     []byte `asn1:"tag:0,optional"`
+	}
+	params, err := asn1.Marshal(struct {
+		Salt       []byte
+		Iterations int
+	}{[]byte("saltsalt"), 1<<31 - 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rc2 := pkix.AlgorithmIdentifier{Algorithm: asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 1, 6}, Parameters: asn1.RawValue{FullBytes: params}}
+	ed, err := asn1.Marshal(struct {
+		Version int
+		Info    encryptedContentInfo
+	}{Info: encryptedContentInfo{ContentType: asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}, Algorithm: rc2, Content: make([]byte, 16)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	authSafe, err := asn1.Marshal([]contentInfo{{asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 6}, explicit0(ed)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	octets, err := asn1.Marshal(authSafe)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err = asn1.Marshal(struct {
+		Version  int
+		AuthSafe contentInfo
+	}{3, contentInfo{asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}, explicit0(octets)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pki.DecodePKCS12(der, "pw"); !errors.Is(err, pki.ErrPKCS12Iterations) {
+		t.Fatalf("huge RC2 iteration count: %v", err)
+	}
+}
+
+func explicit0(der []byte) asn1.RawValue {
+	return asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: der}
+}
+
+func TestPKCS12IterationBudget(t *testing.T) {
+	leaf := pkitest.Leaf(t, "leaf.test", nil)
+	der, err := pki.EncodePKCS12(leaf.Key, leaf.Cert, nil, pki.PKCS12Options{Password: "pw"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the MAC and the key each take 2048 iterations
+	defer pki.SetPKCS12IterationBudget(3000)()
+	if _, err := pki.DecodePKCS12(der, "pw"); !errors.Is(err, pki.ErrPKCS12Iterations) {
+		t.Fatalf("over budget: %v", err)
+	}
+}
+
+// legacyRC2P12 is `openssl pkcs12 -export -legacy -name rc2 -passout pass:pw` of a P-256
+// key and self-signed certificate for rc2.test: RC2-40 certificate bag, 3DES key bag.
+const legacyRC2P12 = `
+MIIDrQIBAzCCA3MGCSqGSIb3DQEHAaCCA2QEggNgMIIDXDCCAjcGCSqGSIb3DQEHBqCCAigwggIk
+AgEAMIICHQYJKoZIhvcNAQcBMBwGCiqGSIb3DQEMAQYwDgQIgOBlGrG7yfkCAggAgIIB8LY2GYzZ
+vTBMzGQ3URuyB28eMzqOZGr8VANtisnYNec423+1cc0A8s32rP8HpiBuqo+Wi5qIeiUBPlA9tf1Q
+meydRZVq4Lrs7FcvkPNsu3m92tP/fqDPWSvuJJrEFYnkpKEl8TeD1QFXKJph6umFjKcADDxyyUso
+LvGkoQp/q2wq6vseInzpQuu1OZI/xro8jYCrEYnfu4M19V7zithw605VCR+2IMGpFFbkaiLfdSjU
+nFmMGBS2h7uSHAO9xPyWDKoiWD0xRd5G52fPljfQX6VYWlG2fuzbJZi2PBJcYS7bR6waAeSKY3VA
+G+1qj25HNVtcWa7FT0Pj2qO8jcYh73Ol7zR2UVWBOIORXpmhLrjEsM2B1W8w73ORwsOzRt969Wex
+NNDZKDpsNc3Q2yWDYOtwJT2j2e0nWx5bWtslh3tJzFH+Mh+dorOrDCb7m2z7j9ZtLYlJc1fUdSuP
+/xDFN49nG8J785XzIzFEwn6Emu63vi9Qayr2DTYXL1P7QP2N1xDceriDi/4RFW2kfedibiesbHZ3
+6WJmPXteg8uUInBWYWnzvOFP8bkU157HocmZ6tUtg52f12rTYMj2HWwAP/QhST7igMJ+YGKNRu7w
+j3j8UaKA1mLoVzsvdfqBXrsb8kbKsL2+6wMJPCmRv7ulYDswggEdBgkqhkiG9w0BBwGgggEOBIIB
+CjCCAQYwggECBgsqhkiG9w0BDAoBAqCBtDCBsTAcBgoqhkiG9w0BDAEDMA4ECCMESQyhlGaZAgII
+AASBkLjmacARx4zeKWgHariYw4Kg1K2aM75wjpmq4F4LJ7VhliZL5/6lscJFl6SYxQFEgDhqnYj5
+RfxIFKbnQEInnnCVr2HoRLc8ZEJfs7KiuK23G/fj+D/aJO6VzXh6EuUCppU0F1KKtXFHw9tf+flf
+sIYsMleNlmjjn+OG2j90Us6WtMESoivtbL/qtlWgJZFYyDE8MBUGCSqGSIb3DQEJFDEIHgYAcgBj
+ADIwIwYJKoZIhvcNAQkVMRYEFFLO2DPk05QMH4Ve0xwlZVn5KiLlMDEwITAJBgUrDgMCGgUABBSL
+HVWnJewPqexlgqknQiCCGXAgggQICWBstEnRwfoCAggA`
+
+func TestPKCS12LegacyRC2(t *testing.T) {
+	der, err := base64.StdEncoding.DecodeString(legacyRC2P12)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := pki.DecodePKCS12(der, "pw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Leaf == nil || got.Leaf.Subject.CommonName != "rc2.test" || got.Key == nil || got.FriendlyName != "rc2" || !got.Legacy {
+		t.Fatalf("unexpected contents %+v", got)
+	}
+	if _, err := pki.DecodePKCS12(der, "wrong"); err != pki.ErrPKCS12BadPassword {
+		t.Fatalf("wrong password: %v", err)
+	}
+}
@@ -0,0 +1,124 @@
+package pki
+
+import (
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+
+	"golang.org/x/crypto/cryptobyte"
+	cbasn1 "golang.org/x/crypto/cryptobyte/asn1"
+)
+
+var oidSignedData = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+
+var (
+	tagContext0 = cbasn1.Tag(0).Constructed().ContextSpecific()
+	tagContext1 = cbasn1.Tag(1).Constructed().ContextSpecific()
+)
+
+// EncodePKCS7 builds a degenerate (certificates-only) PKCS#7 SignedData, the .p7b format
+// used to ship chains to Windows and Java.
+func EncodePKCS7(certs []*x509.Certificate, crls [][]byte) ([]byte, error) {
+	if len(certs) == 0 && len(crls) == 0 {
+		return nil, errors.New("no certificates")
+	}
+	b := cryptobyte.NewBuilder(nil)
+	b.AddASN1(cbasn1.SEQUENCE, func(b *cryptobyte.Builder) {
+		b.AddASN1ObjectIdentifier(oidSignedData)
+		b.AddASN1(tagContext0, func(b *cryptobyte.Builder) {
+			b.AddASN1(cbasn1.SEQUENCE, func(b *cryptobyte.Builder) {
+				b.AddASN1Int64(1)
+				b.AddASN1(cbasn1.SET, func(b *cryptobyte.Builder) {})
+				b.AddASN1(cbasn1.SEQUENCE, func(b *cryptobyte.Builder) {
+					b.AddASN1ObjectIdentifier(oidData)
+				})
+				if len(certs) > 0 {
+					b.AddASN1(tagContext0, func(b *cryptobyte.Builder) {
+						for _, c := range certs {
+							b.AddBytes(c.Raw)
+						}
+					})
+				}
+				if len(crls) > 0 {
+					b.AddASN1(tagContext1, func(b *cryptobyte.Builder) {
+						for _, c := range crls {
+							b.AddBytes(c)
+						}
+					})
+				}
+				b.AddASN1(cbasn1.SET, func(b *cryptobyte.Builder) {})
+			})
+		})
+	})
+	return b.Bytes()
+}
+
+// DecodePKCS7 returns the certificates and raw CRLs carried by a PKCS#7 SignedData.
+// Signer information is ignored.
+func DecodePKCS7(der []byte) ([]*x509.Certificate, [][]byte, error) {
+	bad := errors.New("malformed pkcs7 (only DER SignedData is supported)")
+	in := cryptobyte.String(der)
+	var ci, wrapped, sd cryptobyte.String
+	var oid asn1.ObjectIdentifier
+	if !in.ReadASN1(&ci, cbasn1.SEQUENCE) || !ci.ReadASN1ObjectIdentifier(&oid) {
+		return nil, nil, bad
+	}
+	if !oid.Equal(oidSignedData) {
+		return nil, nil, errors.New("pkcs7 content is not SignedData")
+	}
+	if !ci.ReadASN1(&wrapped, tagContext0) || !wrapped.ReadASN1(&sd, cbasn1.SEQUENCE) {
+		return nil, nil, bad
+	}
+	if !sd.SkipASN1(cbasn1.INTEGER) || !sd.SkipASN1(cbasn1.SET) || !sd.SkipASN1(cbasn1.SEQUENCE) {
+		return nil, nil, bad
+	}
+	var certs []*x509.Certificate
+	var crls [][]byte
+	if sd.PeekASN1Tag(tagContext0) {
+		var set cryptobyte.String
+		if !sd.ReadASN1(&set, tagContext0) {
+			return nil, nil, bad
+		}
+		for !set.Empty() {
+			var raw cryptobyte.String
+			if !set.ReadASN1Element(&raw, cbasn1.SEQUENCE) {
+				return nil, nil, bad
+			}
+			crt, err := x509.ParseCertificate(raw)
+			if err != nil {
+				return nil, nil, err
+			}
+			certs = append(certs, crt)
+		}
+	}
+	if sd.PeekASN1Tag(tagContext1) {
+		var set cryptobyte.String
+		if !sd.ReadASN1(&set, tagContext1) {
+			return nil, nil, bad
+		}
+		for !set.Empty() {
+			var raw cryptobyte.String
+			if !set.ReadASN1Element(&raw, cbasn1.SEQUENCE) {
+				return nil, nil, bad
+			}
+			crls = append(crls, append([]byte(nil), raw...))
+		}
+	}
+	return certs, crls, nil
+}
+
+// PKCS7FromPEM finds the first PKCS7 block in s.
+func PKCS7FromPEM(s string) ([]byte, error) {
+	data := []byte(s)
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return nil, errors.New("no PKCS7 block found")
+		}
+		if block.Type == "PKCS7" || block.Type == "CMS" {
+			return block.Bytes, nil
+		}
+	}
+}
@@ -0,0 +1,69 @@
+// Package pkitest builds throwaway keys and certificates for tests through the same
+// GenerateKey, CertSpec.Template and Issue path the certificate endpoints use.
+package pkitest
+
+import (
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"testing"
+
+	"engtools/backend/internal/pki"
+)
+
+// Cert is an issued certificate and its private key.
+type Cert struct {
+	Cert *x509.Certificate
+	Key  crypto.Signer
+}
+
+// TLS returns c as a tls.Certificate without intermediates.
+func (c *Cert) TLS() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{c.Cert.Raw}, PrivateKey: c.Key, Leaf: c.Cert}
+}
+
+// Key generates a key of keyType (rsa, ec or ed25519) with the default size.
+func Key(t testing.TB, keyType string) crypto.Signer {
+	t.Helper()
+	key, err := pki.GenerateKey(keyType, 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// Issue signs spec for key with parent, or self-signs it when parent is nil. edit, when
+// given, adjusts the template before signing.
+func Issue(t testing.TB, spec pki.CertSpec, key crypto.Signer, parent *Cert, edit ...func(*x509.Certificate)) *Cert {
+	t.Helper()
+	tpl, err := spec.Template()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range edit {
+		f(tpl)
+	}
+	var crt *x509.Certificate
+	if parent == nil {
+		crt, err = pki.Issue(tpl, key.Public(), nil, key)
+	} else {
+		crt, err = pki.Issue(tpl, key.Public(), parent.Cert, parent.Key)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Cert{Cert: crt, Key: key}
+}
+
+// CA issues an EC CA certificate named cn, self-signed when parent is nil.
+func CA(t testing.TB, cn string, parent *Cert) *Cert {
+	t.Helper()
+	return Issue(t, pki.CertSpec{NameSpec: pki.NameSpec{CN: cn}, IsCA: true}, Key(t, "ec"), parent)
+}
+
+// Leaf issues an EC server certificate for dnsName, self-signed when parent is nil.
+func Leaf(t testing.TB, dnsName string, parent *Cert) *Cert {
+	t.Helper()
+	spec := pki.CertSpec{NameSpec: pki.NameSpec{CN: dnsName}, SANSpec: pki.SANSpec{DNS: []string{dnsName}}}
+	return Issue(t, spec, Key(t, "ec"), parent)
+}
@@ -0,0 +1,157 @@
+package pki
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// maxCRLSize bounds one CRL download. Public CAs shard their CRLs, so a single
+	// distribution point rarely serves more than a megabyte.
+	maxCRLSize = 4 << 20
+	// maxCRLTotal bounds what one RevocationChecker downloads across a whole chain.
+	maxCRLTotal = 8 << 20
+)
+
+// RevocationStatus is what one source (OCSP or CRL) says about one certificate.
+type RevocationStatus struct {
+	Source    string     `json:"source"` // ocsp or crl
+	URL       string     `json:"url,omitempty"`
+	Status    string     `json:"status"` // good, revoked, unknown or error
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	Reason    string     `json:"reason,omitempty"`
+	Error     string     `json:"error,omitempty"`
+}
+
+// RevocationChecker queries OCSP responders and CRL distribution points over HTTP. Use one
+// checker per request: CRL downloads are counted against a shared budget, and the checker
+// is not safe for concurrent use.
+type RevocationChecker struct {
+	Client *http.Client
+	// OCSPURL replaces the responder named in the certificate, e.g. a local stand-in.
+	OCSPURL string
+
+	crlBytes int64
+}
+
+func (rc *RevocationChecker) client() *http.Client {
+	if rc.Client != nil {
+		return rc.Client
+	}
+	return &http.Client{Timeout: 10 * time.Second}
+}
+
+// CheckOCSP asks the certificate's OCSP responder about crt.
+func (rc *RevocationChecker) CheckOCSP(ctx context.Context, crt, issuer *x509.Certificate) RevocationStatus {
+	st := RevocationStatus{Source: "ocsp", URL: rc.OCSPURL}
+	if st.URL == "" {
+		if len(crt.OCSPServer) == 0 {
+			st.Status, st.Error = "unknown", "certificate has no ocsp url"
+			return st
+		}
+		st.URL = crt.OCSPServer[0]
+	}
+	req, _, err := CreateOCSPRequest(crt, issuer, 0)
+	if err != nil {
+		st.Status, st.Error = "error", err.Error()
+		return st
+	}
+	der, err := SendOCSP(ctx, rc.client(), st.URL, req, false)
+	if err != nil {
+		st.Status, st.Error = "error", err.Error()
+		return st
+	}
+	info, err := ParseOCSPResponse(der, crt, issuer)
+	switch {
+	case err != nil:
+		st.Status, st.Error = "error", err.Error()
+	case info.ResponseStatus != "successful":
+		st.Status, st.Error = "error", "responder answered "+info.ResponseStatus
+	case info.SignatureValid != nil && !*info.SignatureValid:
+		st.Status, st.Error = "error", "bad response signature: "+info.SignatureError
+	default:
+		st.Status, st.RevokedAt, st.Reason = info.CertStatus, info.RevokedAt, info.RevocationReason
+	}
+	return st
+}
+
+// CheckCRL downloads the first HTTP CRL distribution point of crt and looks it up there.
+func (rc *RevocationChecker) CheckCRL(ctx context.Context, crt, issuer *x509.Certificate) RevocationStatus {
+	st := RevocationStatus{Source: "crl"}
+	for _, u := range crt.CRLDistributionPoints {
+		if strings.HasPrefix(u, "http://") || strings.HasPrefix(u, "https://") {
+			st.URL = u
+			break
+		}
+	}
+	if st.URL == "" {
+		st.Status, st.Error = "unknown", "certificate has no http crl distribution point"
+		return st
+	}
+	limit := min(maxCRLSize, maxCRLTotal-rc.crlBytes)
+	if limit <= 0 {
+		st.Status, st.Error = "error", "crl download budget for this request exhausted"
+		return st
+	}
+	body, err := fetchCRL(ctx, rc.client(), st.URL, limit)
+	rc.crlBytes += int64(len(body))
+	if err != nil {
+		st.Status, st.Error = "error", err.Error()
+		return st
+	}
+	crl, err := ParseCRL(body)
+	if err != nil {
+		st.Status, st.Error = "error", err.Error()
+		return st
+	}
+	if err := crl.CheckSignatureFrom(issuer); err != nil {
+		st.Status, st.Error = "error", "crl signature: "+err.Error()
+		return st
+	}
+	if !crl.NextUpdate.IsZero() && time.Now().After(crl.NextUpdate) {
+		st.Error = "crl is past its next update"
+	}
+	st.Status = "good"
+	if e := FindRevoked(crl, crt.SerialNumber); e != nil {
+		st.Status, st.Reason = "revoked", e.Reason
+		st.RevokedAt = &e.RevokedAt
+	}
+	return st
+}
+
+// FetchCRL downloads and parses a CRL; both DER and PEM are accepted.
+func FetchCRL(ctx context.Context, client *http.Client, url string) (*x509.RevocationList, error) {
+	body, err := fetchCRL(ctx, client, url, maxCRLSize)
+	if err != nil {
+		return nil, err
+	}
+	return ParseCRL(body)
+}
+
+func fetchCRL(ctx context.Context, client *http.Client, url string, limit int64) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("crl download returned http %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return body, err
+	}
+	if int64(len(body)) > limit {
+		return body, fmt.Errorf("crl larger than %d bytes", limit)
+	}
+	return body, nil
+}
@@ -0,0 +1,166 @@
+package pki_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"engtools/backend/internal/pki"
+	"engtools/backend/internal/pki/pkitest"
+	"golang.org/x/crypto/ocsp"
+)
+
+// revocationServer answers OCSP requests and serves a CRL for ca. Serial 0b is revoked,
+// 0c is unknown to the OCSP responder, anything else is good.
+func revocationServer(t *testing.T, ca *pkitest.Cert) *httptest.Server {
+	t.Helper()
+	revokedAt := time.Now().Add(-time.Hour).Truncate(time.Second)
+	mux := http.NewServeMux()
+	ocspHandler := func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Method == http.MethodGet {
+			body, _ = base64.StdEncoding.DecodeString(strings.TrimPrefix(r.URL.Path, "/ocsp/"))
+		}
+		req, err := ocsp.ParseRequest(body)
+		if err != nil {
+			w.Write(ocsp.MalformedRequestErrorResponse)
+			return
+		}
+		tpl := ocsp.Response{Status: ocsp.Good, SerialNumber: req.SerialNumber, ThisUpdate: time.Now().Add(-time.Minute), NextUpdate: time.Now().Add(time.Hour)}
+		switch req.SerialNumber.Int64() {
+		case 0x0b:
+			tpl.Status, tpl.RevokedAt, tpl.RevocationReason = ocsp.Revoked, revokedAt, ocsp.KeyCompromise
+		case 0x0c:
+			tpl.Status = ocsp.Unknown
+		}
+		der, err := ocsp.CreateResponse(ca.Cert, ca.Cert, tpl, ca.Key)
+		if err != nil {
+			t.Error(err)
+		}
+		w.Header().Set("Content-Type", "application/ocsp-response")
+		w.Write(der)
+	}
+	mux.HandleFunc("/ocsp", ocspHandler)
+	mux.HandleFunc("/ocsp/", ocspHandler)
+	mux.HandleFunc("/crl", func(w http.ResponseWriter, r *http.Request) {
+		der, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+			Number:     big.NewInt(1),
+			ThisUpdate: time.Now().Add(-time.Minute),
+			NextUpdate: time.Now().Add(time.Hour),
+			RevokedCertificateEntries: []x509.RevocationListEntry{
+				{SerialNumber: big.NewInt(0x0b), RevocationTime: revokedAt, ReasonCode: ocsp.KeyCompromise},
+			},
+		}, ca.Cert, ca.Key)
+		if err != nil {
+			t.Error(err)
+		}
+		w.Write(der)
+	})
+	mux.HandleFunc("/huge.crl", func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(w, io.LimitReader(zeros{}, 64<<20))
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+type zeros struct{}
+
+func (zeros) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+func TestRevocationChecker(t *testing.T) {
+	ca := pkitest.CA(t, "CA", nil)
+	srv := revocationServer(t, ca)
+	leaf := func(serial string) *x509.Certificate {
+		spec := pki.CertSpec{NameSpec: pki.NameSpec{CN: serial + ".test"}, Serial: serial, OCSP: []string{srv.URL + "/ocsp"}, CRL: []string{srv.URL + "/crl"}}
+		return pkitest.Issue(t, spec, pkitest.Key(t, "ec"), ca).Cert
+	}
+	rc := &pki.RevocationChecker{Client: srv.Client()}
+	for _, tc := range []struct {
+		serial, ocsp, crl string
+	}{
+		{"0a", "good", "good"},
+		{"0b", "revoked", "revoked"},
+		{"0c", "unknown", "good"},
+	} {
+		crt := leaf(tc.serial)
+		o := rc.CheckOCSP(context.Background(), crt, ca.Cert)
+		if o.Status != tc.ocsp || o.Error != "" {
+			t.Errorf("%s: ocsp %+v", tc.serial, o)
+		}
+		l := rc.CheckCRL(context.Background(), crt, ca.Cert)
+		if l.Status != tc.crl || l.Error != "" {
+			t.Errorf("%s: crl %+v", tc.serial, l)
+		}
+		if tc.ocsp == "revoked" && (o.Reason != "keyCompromise" || o.RevokedAt == nil || l.Reason != "keyCompromise" || !l.RevokedAt.Equal(*o.RevokedAt)) {
+			t.Errorf("%s: revocation details ocsp %+v crl %+v", tc.serial, o, l)
+		}
+	}
+
+	// a responder signing with another key is reported, not trusted
+	other := pkitest.CA(t, "Other CA", nil)
+	if st := rc.CheckOCSP(context.Background(), leaf("0b"), other.Cert); st.Status != "error" || !strings.Contains(st.Error, "signature") {
+		t.Errorf("ocsp from the wrong issuer: %+v", st)
+	}
+	if st := rc.CheckCRL(context.Background(), leaf("0b"), other.Cert); st.Status != "error" || !strings.Contains(st.Error, "signature") {
+		t.Errorf("crl from the wrong issuer: %+v", st)
+	}
+}
+
+func TestRevocationCheckerCRLBudget(t *testing.T) {
+	ca := pkitest.CA(t, "CA", nil)
+	srv := revocationServer(t, ca)
+	crt := pkitest.Issue(t, pki.CertSpec{NameSpec: pki.NameSpec{CN: "a.test"}, CRL: []string{srv.URL + "/huge.crl"}}, pkitest.Key(t, "ec"), ca).Cert
+	rc := &pki.RevocationChecker{Client: srv.Client()}
+	var errs []string
+	for range 3 {
+		errs = append(errs, rc.CheckCRL(context.Background(), crt, ca.Cert).Error)
+	}
+	if !strings.Contains(errs[0], "larger than") || !strings.Contains(errs[1], "larger than") || !strings.Contains(errs[2], "budget") {
+		t.Errorf("errors %q", errs)
+	}
+	if _, err := pki.FetchCRL(context.Background(), srv.Client(), srv.URL+"/huge.crl"); err == nil || !strings.Contains(err.Error(), "larger than") {
+		t.Errorf("FetchCRL of an oversized crl: %v", err)
+	}
+}
+
+func TestOCSPResponse(t *testing.T) {
+	ca := pkitest.CA(t, "CA", nil)
+	srv := revocationServer(t, ca)
+	crt := pkitest.Issue(t, pki.CertSpec{NameSpec: pki.NameSpec{CN: "b.test"}, Serial: "0b"}, pkitest.Key(t, "ec"), ca).Cert
+	req, parsed, err := pki.CreateOCSPRequest(crt, ca.Cert, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info := pki.NewOCSPRequestInfo(parsed); info.HashAlg != "sha1" || info.Serial != "b" {
+		t.Errorf("request %+v", info)
+	}
+	der, err := pki.SendOCSP(context.Background(), srv.Client(), srv.URL+"/ocsp", req, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := pki.ParseOCSPResponse(der, crt, ca.Cert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.ResponseStatus != "successful" || info.CertStatus != "revoked" || info.Serial != "b" || info.RevocationReason != "keyCompromise" {
+		t.Errorf("response %+v", info)
+	}
+	if info.SignatureValid == nil || !*info.SignatureValid || info.ResponderName != "CN=CA" || len(info.Warnings) != 0 {
+		t.Errorf("signature %v, responder %q, warnings %v", info.SignatureValid, info.ResponderName, info.Warnings)
+	}
+	if info, err := pki.ParseOCSPResponse(ocsp.UnauthorizedErrorResponse, crt, ca.Cert); err != nil || info.ResponseStatus != "unauthorized" {
+		t.Errorf("unauthorized: %+v, %v", info, err)
+	}
+}
@@ -0,0 +1,103 @@
+package pki
+
+import (
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"golang.org/x/crypto/cryptobyte"
+)
+
+// SCT is a signed certificate timestamp (RFC 6962 section 3.2) as embedded in a
+// certificate, stapled in OCSP or sent in the TLS extension.
+type SCT struct {
+	Source     string    `json:"source,omitempty"` // embedded, tls or ocsp
+	Version    int       `json:"version"`
+	LogID      string    `json:"log_id"` // base64, as used by the CT log lists
+	Timestamp  time.Time `json:"timestamp"`
+	Extensions string    `json:"extensions,omitempty"`
+	HashAlg    string    `json:"hash_alg"`
+	SigAlg     string    `json:"sig_alg"`
+	Signature  string    `json:"signature"`
+	// the log and verification fields are filled in by CTLogList.Annotate
+	LogName     string `json:"log_name,omitempty"`
+	LogOperator string `json:"log_operator,omitempty"`
+	Verified    *bool  `json:"verified,omitempty"` // nil when the log or issuer is unknown
+	VerifyError string `json:"verify_error,omitempty"`
+	// Raw is the serialized SCT, kept for signature verification.
+	Raw []byte `json:"-"`
+}
+
+var sctHashNames = map[uint8]string{0: "none", 1: "md5", 2: "sha1", 3: "sha224", 4: "sha256", 5: "sha384", 6: "sha512"}
+var sctSigNames = map[uint8]string{0: "anonymous", 1: "rsa", 2: "dsa", 3: "ecdsa"}
+
+// SCTsFrom labels scts with where they were found.
+func SCTsFrom(scts []SCT, source string) []SCT {
+	for i := range scts {
+		scts[i].Source = source
+	}
+	return scts
+}
+
+// ParseSCTListExtension decodes the value of the certificate SCT list extension, which is
+// an OCTET STRING wrapping the TLS-encoded list.
+func ParseSCTListExtension(value []byte) ([]SCT, error) {
+	var inner []byte
+	if _, err := asn1.Unmarshal(value, &inner); err != nil {
+		return nil, errors.New("malformed sct list extension")
+	}
+	return ParseSCTList(inner)
+}
+
+// ParseSCTList decodes a TLS SignedCertificateTimestampList.
+func ParseSCTList(data []byte) ([]SCT, error) {
+	s := cryptobyte.String(data)
+	var list cryptobyte.String
+	if !s.ReadUint16LengthPrefixed(&list) || !s.Empty() {
+		return nil, errors.New("malformed sct list")
+	}
+	var out []SCT
+	for !list.Empty() {
+		var raw cryptobyte.String
+		if !list.ReadUint16LengthPrefixed(&raw) {
+			return nil, errors.New("malformed sct list")
+		}
+		sct, err := ParseSCT(raw)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *sct)
+	}
+	return out, nil
+}
+
+// ParseSCT decodes a single serialized v1 SCT.
+func ParseSCT(raw []byte) (*SCT, error) {
+	s := cryptobyte.String(raw)
+	var version, hashAlg, sigAlg uint8
+	var logID []byte
+	var ts uint64
+	var exts, sig cryptobyte.String
+	if !s.ReadUint8(&version) {
+		return nil, errors.New("malformed sct")
+	}
+	if version != 0 {
+		return &SCT{Version: int(version) + 1, Raw: raw}, nil
+	}
+	if !s.ReadBytes(&logID, 32) || !s.ReadUint64(&ts) || !s.ReadUint16LengthPrefixed(&exts) ||
+		!s.ReadUint8(&hashAlg) || !s.ReadUint8(&sigAlg) || !s.ReadUint16LengthPrefixed(&sig) || !s.Empty() {
+		return nil, errors.New("malformed sct")
+	}
+	return &SCT{
+		Version:    1,
+		LogID:      base64.StdEncoding.EncodeToString(logID),
+		Timestamp:  time.UnixMilli(int64(ts)).UTC(),
+		Extensions: hex.EncodeToString(exts),
+		HashAlg:    sctHashNames[hashAlg],
+		SigAlg:     sctSigNames[sigAlg],
+		Signature:  base64.StdEncoding.EncodeToString(sig),
+		Raw:        append([]byte(nil), raw...),
+	}, nil
+}
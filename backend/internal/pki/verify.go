@@ -0,0 +1,289 @@
+package pki
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// IssuerFetcher retrieves issuer certificates from an AIA caIssuers URL. It is an
+// interface so callers can substitute a cache or a stand-in in tests.
+type IssuerFetcher interface {
+	FetchIssuers(ctx context.Context, url string) ([]*x509.Certificate, error)
+}
+
+// HTTPIssuerFetcher fetches over HTTP. CAs publish a single DER certificate, PEM, or a
+// PKCS#7 certs-only bundle (.p7c); all three are accepted.
+type HTTPIssuerFetcher struct {
+	Client *http.Client
+}
+
+// maxIssuerSize bounds AIA downloads.
+const maxIssuerSize = 1 << 20
+
+func (f HTTPIssuerFetcher) FetchIssuers(ctx context.Context, url string) ([]*x509.Certificate, error) {
+	client := f.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned http %d", url, resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxIssuerSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > maxIssuerSize {
+		return nil, errors.New("issuer certificate too large")
+	}
+	if bytes.Contains(body, []byte("-----BEGIN")) {
+		return ParseCertificatesPEM(string(body))
+	}
+	if crt, err := x509.ParseCertificate(body); err == nil {
+		return []*x509.Certificate{crt}, nil
+	}
+	certs, _, err := DecodePKCS7(body)
+	if err != nil {
+		return nil, errors.New("unrecognized issuer certificate format")
+	}
+	return certs, nil
+}
+
+// VerifyOptions extends x509.VerifyOptions with AIA fetching.
+type VerifyOptions struct {
+	Intermediates []*x509.Certificate
+	Roots         []*x509.Certificate // nil uses the system pool
+	DNSName       string
+	CurrentTime   time.Time // zero means now
+	KeyUsages     []x509.ExtKeyUsage
+	// Fetcher, when set, is used to download intermediates missing from the chain.
+	Fetcher    IssuerFetcher
+	MaxFetches int // default 4
+}
+
+// CertSummary is the short per-certificate view used in chains.
+type CertSummary struct {
+	Subject   string    `json:"subject"`
+	Issuer    string    `json:"issuer"`
+	Serial    string    `json:"serial"`
+	NotBefore time.Time `json:"not_before"`
+	NotAfter  time.Time `json:"not_after"`
+	IsCA      bool      `json:"is_ca"`
+	SHA256    string    `json:"fingerprint_sha256"`
+	// Source says where the certificate came from: supplied, root or aia.
+	Source string `json:"source"`
+}
+
+// VerifyResult explains the outcome of Verify. Reason classifies failures as expired,
+// not_yet_valid, unknown_authority, hostname_mismatch, incompatible_usage,
+// not_authorized_to_sign, name_constraints, too_many_intermediates or other.
+type VerifyResult struct {
+	OK          bool            `json:"ok"`
+	Chains      [][]CertSummary `json:"chains"`
+	Error       string          `json:"error,omitempty"`
+	Reason      string          `json:"reason,omitempty"`
+	FailedCert  *CertSummary    `json:"failed_cert,omitempty"`
+	Fetched     []CertSummary   `json:"fetched,omitempty"`
+	Diagnostics []string        `json:"diagnostics,omitempty"`
+	VerifiedAt  time.Time       `json:"verified_at"`
+	// Raw holds the verified chains for follow-up checks such as revocation.
+	Raw [][]*x509.Certificate `json:"-"`
+}
+
+// Verify builds and validates chains for leaf, fetching missing intermediates through
+// opts.Fetcher when the issuer is unknown.
+func Verify(ctx context.Context, leaf *x509.Certificate, opts VerifyOptions) VerifyResult {
+	now := opts.CurrentTime
+	if now.IsZero() {
+		now = time.Now()
+	}
+	res := VerifyResult{VerifiedAt: now, Chains: [][]CertSummary{}}
+	source := map[string]string{}
+	source[string(leaf.Raw)] = "supplied"
+	inter := x509.NewCertPool()
+	known := append([]*x509.Certificate(nil), opts.Intermediates...)
+	for _, crt := range opts.Intermediates {
+		inter.AddCert(crt)
+		source[string(crt.Raw)] = "supplied"
+	}
+	xopts := x509.VerifyOptions{Intermediates: inter, DNSName: opts.DNSName, CurrentTime: now, KeyUsages: opts.KeyUsages}
+	if opts.Roots != nil {
+		xopts.Roots = x509.NewCertPool()
+		for _, crt := range opts.Roots {
+			xopts.Roots.AddCert(crt)
+			source[string(crt.Raw)] = "root"
+		}
+	}
+	maxFetches := opts.MaxFetches
+	if maxFetches <= 0 {
+		maxFetches = 4
+	}
+
+	var chains [][]*x509.Certificate
+	var err error
+	for fetches := 0; ; fetches++ {
+		chains, err = leaf.Verify(xopts)
+		var uae x509.UnknownAuthorityError
+		if err == nil || !errors.As(err, &uae) || fetches >= maxFetches {
+			break
+		}
+		top := topOfChain(leaf, known)
+		if opts.Fetcher == nil {
+			if len(top.IssuingCertificateURL) > 0 {
+				res.Diagnostics = append(res.Diagnostics, fmt.Sprintf("%s: issuer missing; it is published at %s", top.Subject, top.IssuingCertificateURL[0]))
+			}
+			break
+		}
+		if len(top.IssuingCertificateURL) == 0 {
+			res.Diagnostics = append(res.Diagnostics, fmt.Sprintf("%s: issuer missing and no AIA caIssuers URL to fetch it from", top.Subject))
+			break
+		}
+		url := top.IssuingCertificateURL[0]
+		fetched, ferr := opts.Fetcher.FetchIssuers(ctx, url)
+		if ferr != nil {
+			res.Diagnostics = append(res.Diagnostics, fmt.Sprintf("fetching issuer of %s from %s: %v", top.Subject, url, ferr))
+			break
+		}
+		added := false
+		for _, crt := range fetched {
+			if _, dup := source[string(crt.Raw)]; dup {
+				continue
+			}
+			inter.AddCert(crt)
+			known = append(known, crt)
+			source[string(crt.Raw)] = "aia"
+			res.Fetched = append(res.Fetched, Summarize(crt, "aia"))
+			added = true
+		}
+		if !added {
+			res.Diagnostics = append(res.Diagnostics, fmt.Sprintf("%s served no new certificates", url))
+			break
+		}
+	}
+
+	used := map[string]bool{}
+	for _, chain := range chains {
+		sums := make([]CertSummary, 0, len(chain))
+		for _, crt := range chain {
+			src := source[string(crt.Raw)]
+			if src == "" {
+				src = "system"
+			}
+			used[string(crt.Raw)] = true
+			sums = append(sums, Summarize(crt, src))
+		}
+		res.Chains = append(res.Chains, sums)
+	}
+	res.Raw = chains
+	if err == nil {
+		res.OK = true
+	} else {
+		res.Error = err.Error()
+		res.Reason, res.FailedCert = classifyVerifyError(err, now, source)
+	}
+
+	for _, crt := range append([]*x509.Certificate{leaf}, opts.Intermediates...) {
+		switch {
+		case now.After(crt.NotAfter):
+			res.Diagnostics = append(res.Diagnostics, fmt.Sprintf("%s: expired at %s", crt.Subject, crt.NotAfter.Format(time.RFC3339)))
+		case now.Before(crt.NotBefore):
+			res.Diagnostics = append(res.Diagnostics, fmt.Sprintf("%s: not valid until %s", crt.Subject, crt.NotBefore.Format(time.RFC3339)))
+		}
+	}
+	if res.OK {
+		for _, crt := range opts.Intermediates {
+			if !used[string(crt.Raw)] {
+				res.Diagnostics = append(res.Diagnostics, fmt.Sprintf("%s: supplied but not part of any chain", crt.Subject))
+			}
+		}
+	}
+	return res
+}
+
+// topOfChain follows issuers from leaf through pool and returns the last certificate
+// whose issuer is available, i.e. the one whose issuer is missing.
+func topOfChain(leaf *x509.Certificate, pool []*x509.Certificate) *x509.Certificate {
+	cur := leaf
+	seen := map[*x509.Certificate]bool{leaf: true}
+	for {
+		var next *x509.Certificate
+		for _, cand := range pool {
+			if !seen[cand] && bytes.Equal(cand.RawSubject, cur.RawIssuer) && cur.CheckSignatureFrom(cand) == nil {
+				next = cand
+				break
+			}
+		}
+		if next == nil {
+			return cur
+		}
+		seen[next] = true
+		cur = next
+	}
+}
+
+// Summarize describes crt in a line or two; source says where it came from.
+func Summarize(crt *x509.Certificate, source string) CertSummary {
+	fp := sha256.Sum256(crt.Raw)
+	return CertSummary{
+		Subject: crt.Subject.String(), Issuer: crt.Issuer.String(), Serial: crt.SerialNumber.Text(16),
+		NotBefore: crt.NotBefore, NotAfter: crt.NotAfter, IsCA: crt.IsCA, SHA256: HexColon(fp[:]), Source: source,
+	}
+}
+
+func classifyVerifyError(err error, now time.Time, source map[string]string) (string, *CertSummary) {
+	failed := func(crt *x509.Certificate) *CertSummary {
+		if crt == nil {
+			return nil
+		}
+		src := source[string(crt.Raw)]
+		if src == "" {
+			src = "system"
+		}
+		s := Summarize(crt, src)
+		return &s
+	}
+	var cie x509.CertificateInvalidError
+	var he x509.HostnameError
+	var uae x509.UnknownAuthorityError
+	switch {
+	case errors.As(err, &cie):
+		switch cie.Reason {
+		case x509.Expired:
+			if cie.Cert != nil && now.Before(cie.Cert.NotBefore) {
+				return "not_yet_valid", failed(cie.Cert)
+			}
+			return "expired", failed(cie.Cert)
+		case x509.IncompatibleUsage:
+			return "incompatible_usage", failed(cie.Cert)
+		case x509.NotAuthorizedToSign:
+			return "not_authorized_to_sign", failed(cie.Cert)
+		case x509.TooManyIntermediates:
+			return "too_many_intermediates", failed(cie.Cert)
+		case x509.CANotAuthorizedForThisName, x509.NameConstraintsWithoutSANs, x509.UnconstrainedName, x509.TooManyConstraints:
+			return "name_constraints", failed(cie.Cert)
+		case x509.CANotAuthorizedForExtKeyUsage:
+			return "incompatible_usage", failed(cie.Cert)
+		}
+		return "other", failed(cie.Cert)
+	case errors.As(err, &he):
+		return "hostname_mismatch", failed(he.Certificate)
+	case errors.As(err, &uae):
+		return "unknown_authority", failed(uae.Cert)
+	}
+	return "other", nil
+}
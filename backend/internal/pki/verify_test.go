@@ -0,0 +1,36 @@
+package pki_test
+
+import (
+	"context"
+	"crypto/x509"
+	"testing"
+
+	"engtools/backend/internal/pki"
+	"engtools/backend/internal/pki/pkitest"
+)
+
+type staticFetcher map[string]*x509.Certificate
+
+func (f staticFetcher) FetchIssuers(_ context.Context, url string) ([]*x509.Certificate, error) {
+	return []*x509.Certificate{f[url]}, nil
+}
+
+func TestVerifyFetchesIntermediate(t *testing.T) {
+	root := pkitest.CA(t, "Root", nil)
+	inter := pkitest.CA(t, "Intermediate", root)
+	leaf := pkitest.Issue(t, pki.CertSpec{NameSpec: pki.NameSpec{CN: "a.test"}, SANSpec: pki.SANSpec{DNS: []string{"a.test"}}, IssuerURLs: []string{"http://aia.test/int.cer"}}, pkitest.Key(t, "ec"), inter).Cert
+
+	opts := pki.VerifyOptions{Roots: []*x509.Certificate{root.Cert}, DNSName: "a.test"}
+	if res := pki.Verify(context.Background(), leaf, opts); res.OK || res.Reason != "unknown_authority" {
+		t.Fatalf("want unknown_authority without the intermediate, got %+v", res)
+	}
+	opts.Fetcher = staticFetcher{"http://aia.test/int.cer": inter.Cert}
+	res := pki.Verify(context.Background(), leaf, opts)
+	if !res.OK || len(res.Chains) != 1 || len(res.Chains[0]) != 3 || res.Chains[0][1].Source != "aia" {
+		t.Fatalf("intermediate not fetched: %+v", res)
+	}
+	opts.DNSName = "b.test"
+	if res := pki.Verify(context.Background(), leaf, opts); res.Reason != "hostname_mismatch" {
+		t.Fatalf("want hostname_mismatch, got %q", res.Reason)
+	}
+}
@@ -16,7 +16,8 @@ func InitDB(cfg *config.Config, log *zap.Logger) *gorm.DB {
     if err != nil {
         log.Fatal("db open", zap.Error(err))
     }
-    if err := db.AutoMigrate(&model.User{}); err != nil {
+    if err := db.AutoMigrate(&model.User{}, &model.CertAuthority{}, &model.RevokedCert{},
+        &model.MonitoredEndpoint{}, &model.CertCheck{}, &model.NotificationChannel{}); err != nil {
         log.Fatal("db migrate", zap.Error(err))
     }
     model.EnsureDefaultAdmin(db)
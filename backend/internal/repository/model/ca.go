@@ -0,0 +1,30 @@
+package model
+
+import "time"
+
+// CertAuthority is a CA whose key is kept server-side so a team can share it. KeyCipher
+// holds the PKCS#8 key sealed with AES-GCM under the CA key secret.
+type CertAuthority struct {
+	ID          uint   `gorm:"primaryKey"`
+	Name        string `gorm:"uniqueIndex;size:128"`
+	Description string `gorm:"size:255"`
+	Subject     string `gorm:"size:512"`
+	Serial      string `gorm:"size:64"`
+	ParentID    *uint
+	NotAfter    time.Time
+	CertPEM     string `gorm:"type:text"`
+	KeyNonce    []byte
+	KeyCipher   []byte
+	CRLNumber   int64 // last CRL number issued
+	CreatedAt   time.Time
+}
+
+// RevokedCert records a certificate revoked by a stored CA for inclusion in its CRLs.
+type RevokedCert struct {
+	ID         uint   `gorm:"primaryKey"`
+	CAID       uint   `gorm:"uniqueIndex:idx_revoked_ca_serial"`
+	Serial     string `gorm:"uniqueIndex:idx_revoked_ca_serial;size:64"` // hex
+	ReasonCode int
+	RevokedAt  time.Time
+	CreatedAt  time.Time
+}
@@ -0,0 +1,66 @@
+package model
+
+import "time"
+
+// MonitoredEndpoint is a TLS endpoint whose certificate is checked on a schedule.
+// Thresholds is a comma separated list of days-to-expiry at which to notify.
+type MonitoredEndpoint struct {
+	ID              uint   `gorm:"primaryKey"`
+	Name            string `gorm:"uniqueIndex;size:128"`
+	Host            string `gorm:"size:255"`
+	Port            int
+	Address         string `gorm:"size:255"`
+	ServerName      string `gorm:"size:255"`
+	StartTLS        string `gorm:"size:16"`
+	IntervalMinutes int
+	Thresholds      string `gorm:"size:64"`
+	Enabled         bool
+
+	// state of the last check
+	LastCheckedAt *time.Time
+	Status        string `gorm:"size:16"` // pending, ok, warning, expired or error
+	LastError     string `gorm:"size:512"`
+	Fingerprint   string `gorm:"size:128"`
+	Subject       string `gorm:"size:512"`
+	NotAfter      *time.Time
+	DaysRemaining *int
+	VerifyOK      bool
+	// NotifiedThreshold is the lowest threshold already notified for the current
+	// certificate; it resets when the certificate changes.
+	NotifiedThreshold *int
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// CertCheck is one entry of an endpoint's check history.
+type CertCheck struct {
+	ID            uint      `gorm:"primaryKey"`
+	EndpointID    uint      `gorm:"index"`
+	CheckedAt     time.Time `gorm:"index"`
+	Status        string    `gorm:"size:16"`
+	Error         string    `gorm:"size:512"`
+	IP            string    `gorm:"size:64"`
+	Fingerprint   string    `gorm:"size:128"`
+	Subject       string    `gorm:"size:512"`
+	Issuer        string    `gorm:"size:512"`
+	Serial        string    `gorm:"size:64"`
+	NotAfter      *time.Time
+	DaysRemaining *int
+	VerifyOK      bool
+	VerifyError   string `gorm:"size:512"`
+	Changed       bool   // a different certificate than the previous check
+}
+
+// NotificationChannel is where monitor alerts go. Type is webhook, slack, feishu,
+// dingtalk or email; Target is the URL, or comma separated addresses for email. Secret
+// signs Feishu and DingTalk requests when their bots require it.
+type NotificationChannel struct {
+	ID        uint   `gorm:"primaryKey"`
+	Name      string `gorm:"uniqueIndex;size:128"`
+	Type      string `gorm:"size:16"`
+	Target    string `gorm:"size:1024"`
+	Secret    string `gorm:"size:255"`
+	Enabled   bool
+	CreatedAt time.Time
+}
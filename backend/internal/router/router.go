@@ -1,8 +1,10 @@
 package router
 
 import (
+    "context"
     "engtools/backend/internal/config"
     "engtools/backend/internal/controller"
+    "engtools/backend/internal/pki"
     "engtools/backend/internal/service"
     "github.com/gin-contrib/cors"
     "github.com/gin-gonic/gin"
@@ -11,10 +13,25 @@ import (
     "go.uber.org/zap"
     "gorm.io/gorm"
     "net/http"
+    "os"
+    "strings"
+    "time"
 )
 
 func New(cfg *config.Config, log *zap.Logger, db *gorm.DB) *gin.Engine {
     r := gin.Default()
+    if err := r.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+        log.Fatal("trusted proxies", zap.Error(err))
+    }
+    switch strings.ToLower(cfg.TrustedPlatform) {
+    case "":
+    case "cloudflare":
+        r.TrustedPlatform = gin.PlatformCloudflare
+    case "google", "appengine":
+        r.TrustedPlatform = gin.PlatformGoogleAppEngine
+    default:
+        r.TrustedPlatform = cfg.TrustedPlatform
+    }
     r.Use(cors.New(cors.Config{
         AllowOrigins:     []string{cfg.AllowOrigin},
         AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
@@ -30,6 +47,27 @@ func New(cfg *config.Config, log *zap.Logger, db *gorm.DB) *gin.Engine {
 
     authSvc := service.NewAuthService(cfg, db)
     geoSvc := service.NewGeoService(cfg)
+    caSvc := service.NewCAService(cfg, db)
+    monSvc := service.NewMonitorService(cfg, db)
+    if cfg.MonitorTick > 0 {
+        go monSvc.Run(context.Background(), cfg.MonitorTick, log)
+    }
+    aia := pki.HTTPIssuerFetcher{Client: &http.Client{Timeout: 10 * time.Second}}
+    ctLogs := pki.NewCTLogList()
+    if cfg.CTLogListFile != "" {
+        if data, err := os.ReadFile(cfg.CTLogListFile); err == nil {
+            if err := ctLogs.Load(data, cfg.CTLogListFile); err != nil {
+                log.Warn("ct log list", zap.String("file", cfg.CTLogListFile), zap.Error(err))
+            }
+        } else if !os.IsNotExist(err) {
+            log.Warn("ct log list", zap.Error(err))
+        }
+    }
+    if ctLogs.Info().Count == 0 {
+        log.Warn("ct log list is empty, SCTs cannot be verified; run go generate ./internal/pki or set CT_LOG_LIST")
+    }
+    ctSearch := service.NewCTSearchService(cfg)
+    dane := service.NewDANEService(cfg)
     v1 := r.Group("/api/v1")
     v1.POST("/auth/login", controller.LoginHandler(authSvc))
 
@@ -38,6 +76,18 @@ func New(cfg *config.Config, log *zap.Logger, db *gorm.DB) *gin.Engine {
     v1.POST("/tools/base64/encode", controller.Base64Encode())
     v1.POST("/tools/base64/decode", controller.Base64Decode())
     v1.GET("/tools/ip/geo", controller.IPGeo(geoSvc))
+    v1.POST("/tools/ip/geo/bulk", controller.IPGeoBulk(geoSvc))
+    v1.GET("/tools/country", controller.CountryLookup())
+    v1.GET("/tools/ip/classify", controller.IPClassify())
+    v1.Any("/tools/ip/echo", controller.IPEcho(cfg))
+    v1.GET("/tools/ip/registry", controller.IPSpecialRegistry())
+    v1.GET("/tools/ip/convert", controller.IPConvert())
+    v1.POST("/tools/ip/cidr/info", controller.CIDRInfo())
+    v1.POST("/tools/ip/cidr/subnet", controller.CIDRSubnet())
+    v1.POST("/tools/ip/cidr/aggregate", controller.CIDRAggregate())
+    v1.POST("/tools/ip/cidr/range", controller.CIDRFromRange())
+    v1.POST("/tools/ip/cidr/overlap", controller.CIDROverlap())
+    v1.POST("/tools/ip/cidr/contains", controller.CIDRContains())
     v1.GET("/tools/dns/resolve", controller.DNSDig())
     v1.GET("/tools/domain/whois", controller.DomainWhois())
 
@@ -55,12 +105,72 @@ func New(cfg *config.Config, log *zap.Logger, db *gorm.DB) *gin.Engine {
     v1.POST("/crypto/pbkdf2/derive", controller.PBKDF2Derive())
     v1.POST("/crypto/bcrypt/hash", controller.BcryptHash())
     v1.POST("/crypto/bcrypt/verify", controller.BcryptVerify())
-    v1.POST("/cert/parse", controller.CertParse())
-    v1.POST("/cert/verify", controller.CertVerify())
-    v1.POST("/tls/inspect", controller.TLSInspect())
+    v1.POST("/crypto/ssh/keygen", controller.SSHKeygen())
+    v1.POST("/crypto/ssh/key/parse", controller.SSHKeyParse())
+    v1.POST("/crypto/ssh/authorized_keys/parse", controller.SSHAuthorizedKeys())
+    v1.POST("/crypto/ssh/known_hosts/parse", controller.SSHKnownHosts())
+    v1.POST("/crypto/ssh/cert/parse", controller.SSHCertParse())
+    v1.POST("/crypto/ssh/cert/sign", controller.SSHCertSign())
+    v1.POST("/crypto/pgp/keygen", controller.PGPKeygen())
+    v1.POST("/crypto/pgp/key/parse", controller.PGPKeyParse())
+    v1.POST("/crypto/pgp/encrypt", controller.PGPEncrypt())
+    v1.POST("/crypto/pgp/decrypt", controller.PGPDecrypt())
+    v1.POST("/crypto/pgp/sign", controller.PGPSign())
+    v1.POST("/crypto/pgp/verify", controller.PGPVerify())
+    v1.POST("/cert/parse", controller.CertParse(ctLogs))
+    v1.POST("/cert/verify", controller.CertVerify(aia))
+    v1.POST("/cert/lint", controller.CertLint())
+    v1.POST("/cert/diff", controller.CertDiff())
+    v1.POST("/tls/inspect", controller.TLSInspect(ctLogs))
+    v1.POST("/tls/scan", controller.TLSScan(ctLogs))
+    v1.POST("/ssh/scan", controller.SSHScan())
     v1.POST("/cert/csr/generate", controller.CSRGenerate())
+    v1.POST("/cert/csr/parse", controller.CSRParse())
     v1.POST("/cert/convert/to_der", controller.CertToDER())
     v1.POST("/cert/convert/to_pem", controller.CertToPEM())
+    v1.POST("/cert/selfsigned", controller.SelfSigned())
+    v1.POST("/cert/match", controller.KeyMatch())
+    v1.POST("/cert/pkcs12/export", controller.PKCS12Export())
+    v1.POST("/cert/pkcs12/import", controller.PKCS12Import())
+    v1.POST("/cert/pkcs7/export", controller.PKCS7Export())
+    v1.POST("/cert/pkcs7/import", controller.PKCS7Import())
+    v1.POST("/cert/crl/parse", controller.CRLParse())
+    v1.POST("/cert/crl/check", controller.CRLCheck())
+    v1.POST("/cert/ocsp/request", controller.OCSPRequest())
+    v1.POST("/cert/ocsp/check", controller.OCSPCheck(ctLogs))
+    v1.POST("/cert/ocsp/parse", controller.OCSPParse(ctLogs))
+    v1.GET("/cert/ct/logs", controller.CTLogs(ctLogs))
+    v1.PUT("/cert/ct/logs", jwtMiddleware(cfg), controller.CTLogsUpdate(cfg, ctLogs))
+    v1.GET("/cert/ct/search", controller.CTSearch(ctSearch))
+    v1.POST("/cert/tlsa/generate", controller.TLSAGenerate())
+    v1.POST("/cert/tlsa/verify", controller.TLSAVerify(dane))
+    v1.POST("/asn1/decode", controller.ASN1Decode())
+
+    // CA keys live server-side, so everything touching them requires a login
+    ca := v1.Group("/cert/ca", jwtMiddleware(cfg))
+    ca.GET("", controller.CAList(caSvc))
+    ca.POST("/create", controller.CACreate(caSvc))
+    ca.POST("/import", controller.CAImport(caSvc))
+    ca.POST("/sign", controller.CASign(caSvc))
+    ca.GET("/:name", controller.CAGet(caSvc))
+    ca.DELETE("/:name", controller.CADelete(caSvc))
+    ca.POST("/:name/revoke", controller.CARevoke(caSvc))
+    ca.DELETE("/:name/revoke/:serial", controller.CAUnrevoke(caSvc))
+    ca.GET("/:name/revoked", controller.CARevocations(caSvc))
+    ca.POST("/:name/crl", controller.CACRL(caSvc))
+
+    mon := v1.Group("/monitor", jwtMiddleware(cfg))
+    mon.GET("/endpoints", controller.MonitorEndpointList(monSvc))
+    mon.POST("/endpoints", controller.MonitorEndpointCreate(monSvc))
+    mon.GET("/endpoints/:name", controller.MonitorEndpointGet(monSvc))
+    mon.PUT("/endpoints/:name", controller.MonitorEndpointUpdate(monSvc))
+    mon.DELETE("/endpoints/:name", controller.MonitorEndpointDelete(monSvc))
+    mon.POST("/endpoints/:name/check", controller.MonitorEndpointCheck(monSvc))
+    mon.GET("/endpoints/:name/history", controller.MonitorEndpointHistory(monSvc))
+    mon.GET("/channels", controller.MonitorChannelList(monSvc))
+    mon.POST("/channels", controller.MonitorChannelCreate(monSvc))
+    mon.DELETE("/channels/:name", controller.MonitorChannelDelete(monSvc))
+    mon.POST("/channels/:name/test", controller.MonitorChannelTest(monSvc))
     return r
 }
 
@@ -0,0 +1,236 @@
+package service
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"crypto/x509"
+	"errors"
+	"math/big"
+	"strings"
+	"time"
+
+	"engtools/backend/internal/config"
+	cryptox "engtools/backend/internal/crypto"
+	"engtools/backend/internal/pki"
+	"engtools/backend/internal/repository/model"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrCANotFound = errors.New("ca not found")
+	ErrCAExists   = errors.New("ca name already in use")
+	ErrRevoked    = errors.New("serial already revoked")
+	ErrNotRevoked = errors.New("serial not revoked")
+)
+
+// CAService stores certificate authorities so a team can share a development CA. Private
+// keys never leave the server in clear text once stored.
+type CAService struct {
+	db  *gorm.DB
+	key []byte
+}
+
+func NewCAService(cfg *config.Config, db *gorm.DB) *CAService {
+	k := sha256.Sum256([]byte("engtools-ca:" + cfg.CAKeySecret))
+	return &CAService{db: db, key: k[:]}
+}
+
+// CA is a stored authority with its key unsealed.
+type CA struct {
+	Record *model.CertAuthority
+	Cert   *x509.Certificate
+	Key    crypto.Signer
+}
+
+// Create stores a CA certificate and key under name. parent is the stored issuer of an
+// intermediate, or nil for roots and externally issued CAs.
+func (s *CAService) Create(name, desc string, crt *x509.Certificate, key crypto.Signer, parent *model.CertAuthority) (*model.CertAuthority, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, errors.New("name required")
+	}
+	if !crt.IsCA {
+		return nil, errors.New("certificate is not a CA")
+	}
+	var count int64
+	s.db.Model(&model.CertAuthority{}).Where("name = ?", name).Count(&count)
+	if count > 0 {
+		return nil, ErrCAExists
+	}
+	keyPEM, err := pki.MarshalPrivateKeyPEM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce, sealed, err := cryptox.AesEncrypt(s.key, []byte(keyPEM))
+	if err != nil {
+		return nil, err
+	}
+	rec := &model.CertAuthority{
+		Name: name, Description: desc, Subject: crt.Subject.String(), Serial: crt.SerialNumber.Text(16),
+		NotAfter: crt.NotAfter, CertPEM: pki.EncodeCertPEM(crt), KeyNonce: nonce, KeyCipher: sealed,
+	}
+	if parent != nil {
+		rec.ParentID = &parent.ID
+	}
+	if err := s.db.Create(rec).Error; err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+func (s *CAService) List() ([]model.CertAuthority, error) {
+	var out []model.CertAuthority
+	err := s.db.Order("name").Find(&out).Error
+	return out, err
+}
+
+// Get loads a CA and unseals its key.
+func (s *CAService) Get(name string) (*CA, error) {
+	rec, err := s.find(name)
+	if err != nil {
+		return nil, err
+	}
+	return s.unseal(rec)
+}
+
+// find loads the named record. Only a missing row is ErrCANotFound; database failures are
+// returned as they are.
+func (s *CAService) find(name string) (*model.CertAuthority, error) {
+	var rec model.CertAuthority
+	if err := s.db.Where("name = ?", name).First(&rec).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCANotFound
+		}
+		return nil, err
+	}
+	return &rec, nil
+}
+
+func (s *CAService) unseal(rec *model.CertAuthority) (*CA, error) {
+	certs, err := pki.ParseCertificatesPEM(rec.CertPEM)
+	if err != nil {
+		return nil, err
+	}
+	keyPEM, err := cryptox.AesDecrypt(s.key, rec.KeyNonce, rec.KeyCipher)
+	if err != nil {
+		return nil, errors.New("ca key cannot be unsealed; was CA_KEY_SECRET changed?")
+	}
+	key, err := pki.ParsePrivateKeyPEM(string(keyPEM))
+	if err != nil {
+		return nil, err
+	}
+	return &CA{Record: rec, Cert: certs[0], Key: key}, nil
+}
+
+// Chain returns the CA certificate followed by its stored issuers up to the root.
+func (s *CAService) Chain(rec *model.CertAuthority) ([]*x509.Certificate, error) {
+	var chain []*x509.Certificate
+	seen := map[uint]bool{}
+	for rec != nil && !seen[rec.ID] {
+		seen[rec.ID] = true
+		certs, err := pki.ParseCertificatesPEM(rec.CertPEM)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, certs[0])
+		if rec.ParentID == nil {
+			break
+		}
+		var parent model.CertAuthority
+		if err := s.db.First(&parent, *rec.ParentID).Error; err != nil {
+			break
+		}
+		rec = &parent
+	}
+	return chain, nil
+}
+
+func (s *CAService) Delete(name string) error {
+	rec, err := s.find(name)
+	if err != nil {
+		return err
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("ca_id = ?", rec.ID).Delete(&model.RevokedCert{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(rec).Error
+	})
+}
+
+// Revoke records serial as revoked by the named CA; it appears in the next CRL.
+func (s *CAService) Revoke(name string, serial *big.Int, reason int, at time.Time) (*model.RevokedCert, error) {
+	rec, err := s.find(name)
+	if err != nil {
+		return nil, err
+	}
+	var count int64
+	s.db.Model(&model.RevokedCert{}).Where("ca_id = ? AND serial = ?", rec.ID, serial.Text(16)).Count(&count)
+	if count > 0 {
+		return nil, ErrRevoked
+	}
+	rc := &model.RevokedCert{CAID: rec.ID, Serial: serial.Text(16), ReasonCode: reason, RevokedAt: at.UTC()}
+	if err := s.db.Create(rc).Error; err != nil {
+		return nil, err
+	}
+	return rc, nil
+}
+
+// Unrevoke lifts a revocation, typically a certificateHold.
+func (s *CAService) Unrevoke(name string, serial *big.Int) error {
+	rec, err := s.find(name)
+	if err != nil {
+		return err
+	}
+	res := s.db.Where("ca_id = ? AND serial = ?", rec.ID, serial.Text(16)).Delete(&model.RevokedCert{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotRevoked
+	}
+	return nil
+}
+
+func (s *CAService) Revocations(rec *model.CertAuthority) ([]model.RevokedCert, error) {
+	var out []model.RevokedCert
+	err := s.db.Where("ca_id = ?", rec.ID).Order("revoked_at").Find(&out).Error
+	return out, err
+}
+
+// GenerateCRL signs a CRL of everything the CA has revoked, valid for validity, under the
+// next CRL number.
+func (s *CAService) GenerateCRL(name string, validity time.Duration) ([]byte, error) {
+	ca, err := s.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	revoked, err := s.Revocations(ca.Record)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]x509.RevocationListEntry, 0, len(revoked))
+	for _, r := range revoked {
+		serial, ok := new(big.Int).SetString(r.Serial, 16)
+		if !ok {
+			continue
+		}
+		entries = append(entries, x509.RevocationListEntry{SerialNumber: serial, RevocationTime: r.RevokedAt, ReasonCode: r.ReasonCode})
+	}
+	var der []byte
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		// bump first so concurrent requests never sign two CRLs with the same number
+		res := tx.Model(&model.CertAuthority{}).Where("id = ?", ca.Record.ID).Update("crl_number", gorm.Expr("crl_number + 1"))
+		if res.Error != nil {
+			return res.Error
+		}
+		var rec model.CertAuthority
+		if err := tx.First(&rec, ca.Record.ID).Error; err != nil {
+			return err
+		}
+		now := time.Now().UTC()
+		der, err = pki.CreateCRL(ca.Cert, ca.Key, entries, big.NewInt(rec.CRLNumber), now, now.Add(validity))
+		return err
+	})
+	return der, err
+}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"engtools/backend/internal/config"
+	"engtools/backend/internal/pki"
+	"engtools/backend/internal/pki/pkitest"
+	"engtools/backend/internal/repository/model"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func newTestCAService(t *testing.T) *CAService {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// every connection to :memory: is a separate database
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	if err := db.AutoMigrate(&model.CertAuthority{}, &model.RevokedCert{}); err != nil {
+		t.Fatal(err)
+	}
+	return NewCAService(&config.Config{CAKeySecret: "test"}, db)
+}
+
+func TestCARevocationCRL(t *testing.T) {
+	svc := newTestCAService(t)
+	ca := pkitest.CA(t, "Dev CA", nil)
+	if _, err := svc.Create("dev", "", ca.Cert, ca.Key, nil); err != nil {
+		t.Fatal(err)
+	}
+	serial := big.NewInt(0x1234)
+	at := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if _, err := svc.Revoke("dev", serial, 1, at); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Revoke("dev", serial, 4, at); !errors.Is(err, ErrRevoked) {
+		t.Fatalf("duplicate revocation: %v", err)
+	}
+	if _, err := svc.Revoke("nope", serial, 1, at); !errors.Is(err, ErrCANotFound) {
+		t.Fatalf("unknown ca: %v", err)
+	}
+
+	for want := int64(1); want <= 2; want++ {
+		der, err := svc.GenerateCRL("dev", 24*time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		crl, err := pki.ParseCRL(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := crl.CheckSignatureFrom(ca.Cert); err != nil {
+			t.Fatal(err)
+		}
+		if crl.Number.Int64() != want {
+			t.Errorf("crl number %v, want %d", crl.Number, want)
+		}
+		e := pki.FindRevoked(crl, serial)
+		if e == nil || e.Reason != "keyCompromise" || !e.RevokedAt.Equal(at) {
+			t.Fatalf("revoked entry %+v", e)
+		}
+	}
+
+	if err := svc.Unrevoke("dev", serial); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.Unrevoke("dev", serial); !errors.Is(err, ErrNotRevoked) {
+		t.Fatalf("second unrevoke: %v", err)
+	}
+	der, err := svc.GenerateCRL("dev", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if crl, _ := pki.ParseCRL(der); crl == nil || pki.FindRevoked(crl, serial) != nil || crl.Number.Int64() != 3 {
+		t.Errorf("crl after unrevoke: %+v", crl)
+	}
+
+	if err := svc.Delete("dev"); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.Delete("dev"); !errors.Is(err, ErrCANotFound) {
+		t.Fatalf("deleting a missing ca: %v", err)
+	}
+}
+
+func TestCAServiceDatabaseErrors(t *testing.T) {
+	svc := newTestCAService(t)
+	sqlDB, _ := svc.db.DB()
+	sqlDB.Close()
+	if err := svc.Delete("dev"); err == nil || errors.Is(err, ErrCANotFound) {
+		t.Fatalf("database failure reported as %v", err)
+	}
+}
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+//go:embed data/countries.csv
+var countriesCSV []byte
+
+var continentNames = map[string]string{
+	"AF": "Africa",
+	"AN": "Antarctica",
+	"AS": "Asia",
+	"EU": "Europe",
+	"NA": "North America",
+	"OC": "Oceania",
+	"SA": "South America",
+}
+
+// Country is the ISO 3166-1 entry for a country or territory plus commonly needed metadata.
+type Country struct {
+	Alpha2        string `json:"alpha2"`
+	Alpha3        string `json:"alpha3"`
+	Numeric       string `json:"numeric"`
+	Name          string `json:"name"`
+	NameZH        string `json:"name_zh"`
+	Continent     string `json:"continent"`
+	ContinentName string `json:"continent_name"`
+	EU            bool   `json:"eu"`
+	CallingCode   string `json:"calling_code"`
+	Currency      string `json:"currency"`
+	Flag          string `json:"flag"`
+}
+
+var (
+	countriesOnce sync.Once
+	countries     []Country
+	countryIndex  map[string]*Country
+)
+
+func loadCountries() {
+	rows, err := csv.NewReader(bytes.NewReader(countriesCSV)).ReadAll()
+	if err != nil {
+		panic("countries.csv: " + err.Error())
+	}
+	countries = make([]Country, 0, len(rows))
+	for _, r := range rows[1:] {
+		c := Country{Alpha2: r[0], Alpha3: r[1], Numeric: r[2], Continent: r[3], EU: r[4] == "1", Currency: r[6], Name: r[7], NameZH: r[8]}
+		c.ContinentName = continentNames[c.Continent]
+		if r[5] != "" {
+			c.CallingCode = "+" + r[5]
+		}
+		c.Flag = flagEmoji(c.Alpha2)
+		countries = append(countries, c)
+	}
+	countryIndex = make(map[string]*Country, len(countries)*5)
+	for i := range countries {
+		c := &countries[i]
+		for _, k := range []string{c.Alpha2, c.Alpha3, c.Numeric, c.Name, c.NameZH} {
+			countryIndex[strings.ToUpper(k)] = c
+		}
+	}
+}
+
+// flagEmoji maps an alpha-2 code onto its pair of regional indicator symbols.
+func flagEmoji(alpha2 string) string {
+	if len(alpha2) != 2 {
+		return ""
+	}
+	var b strings.Builder
+	for _, r := range strings.ToUpper(alpha2) {
+		if r < 'A' || r > 'Z' {
+			return ""
+		}
+		b.WriteRune(0x1F1E6 + r - 'A')
+	}
+	return b.String()
+}
+
+// Countries returns every ISO 3166-1 entry ordered by alpha-2 code.
+func Countries() []Country {
+	countriesOnce.Do(loadCountries)
+	return countries
+}
+
+// LookupCountry resolves an alpha-2, alpha-3 or numeric code, or an English/Chinese name.
+func LookupCountry(q string) (*Country, bool) {
+	countriesOnce.Do(loadCountries)
+	q = strings.ToUpper(strings.TrimSpace(q))
+	if q == "UK" {
+		q = "GB"
+	}
+	if n, err := strconv.Atoi(q); err == nil && n >= 0 && n < 1000 {
+		q = fmt.Sprintf("%03d", n)
+	}
+	c, ok := countryIndex[q]
+	return c, ok
+}
+
+func countryName(code string) string {
+	if c, ok := LookupCountry(code); ok {
+		return c.Name
+	}
+	return ""
+}
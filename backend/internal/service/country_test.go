@@ -0,0 +1,22 @@
+package service
+
+import "testing"
+
+func TestLookupCountry(t *testing.T) {
+	if n := len(Countries()); n != 249 {
+		t.Fatalf("expected 249 entries, got %d", n)
+	}
+	for _, q := range []string{"CN", "chn", "156", "China", "中国"} {
+		c, ok := LookupCountry(q)
+		if !ok || c.Alpha2 != "CN" {
+			t.Fatalf("lookup %q failed", q)
+		}
+	}
+	de, _ := LookupCountry("DE")
+	if !de.EU || de.Currency != "EUR" || de.CallingCode != "+49" || de.Flag != "\U0001F1E9\U0001F1EA" {
+		t.Fatalf("unexpected DE metadata: %+v", de)
+	}
+	if _, ok := LookupCountry("ZZ"); ok {
+		t.Fatal("ZZ should not resolve")
+	}
+}
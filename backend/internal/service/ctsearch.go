@@ -0,0 +1,121 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"engtools/backend/internal/config"
+)
+
+// maxCTSearchResponse bounds what is read from the search source; popular domains have
+// tens of thousands of entries.
+const maxCTSearchResponse = 32 << 20
+
+var ErrInvalidDomain = errors.New("invalid domain")
+
+// CTSearchService looks up logged certificates through a crt.sh compatible JSON API.
+type CTSearchService struct {
+	base   string
+	client *http.Client
+}
+
+func NewCTSearchService(cfg *config.Config) *CTSearchService {
+	return &CTSearchService{base: cfg.CTSearchURL, client: &http.Client{Timeout: 60 * time.Second}}
+}
+
+// CTEntry is one logged certificate or precertificate.
+type CTEntry struct {
+	ID         int64      `json:"id"`
+	LoggedAt   *time.Time `json:"logged_at,omitempty"`
+	NotBefore  *time.Time `json:"not_before,omitempty"`
+	NotAfter   *time.Time `json:"not_after,omitempty"`
+	Serial     string     `json:"serial"`
+	Issuer     string     `json:"issuer"`
+	CommonName string     `json:"common_name"`
+	Names      []string   `json:"names"`
+}
+
+type crtshEntry struct {
+	ID             int64  `json:"id"`
+	IssuerName     string `json:"issuer_name"`
+	CommonName     string `json:"common_name"`
+	NameValue      string `json:"name_value"`
+	EntryTimestamp string `json:"entry_timestamp"`
+	NotBefore      string `json:"not_before"`
+	NotAfter       string `json:"not_after"`
+	SerialNumber   string `json:"serial_number"`
+}
+
+// crt.sh reports UTC times without a zone
+func crtshTime(s string) *time.Time {
+	for _, layout := range []string{"2006-01-02T15:04:05.999999999", time.RFC3339Nano} {
+		if t, err := time.ParseInLocation(layout, s, time.UTC); err == nil {
+			t = t.UTC()
+			return &t
+		}
+	}
+	return nil
+}
+
+// Search lists certificates logged for domain, newest first. With subdomains set the
+// search covers every name below domain as well.
+func (s *CTSearchService) Search(ctx context.Context, domain string, subdomains, excludeExpired bool) ([]CTEntry, error) {
+	domain = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")
+	domain = strings.TrimPrefix(domain, "*.")
+	if domain == "" || strings.ContainsAny(domain, " %/?*") {
+		return nil, ErrInvalidDomain
+	}
+	q := url.Values{"output": {"json"}, "deduplicate": {"Y"}, "q": {domain}}
+	if subdomains {
+		q.Set("q", "%."+domain)
+	}
+	if excludeExpired {
+		q.Set("exclude", "expired")
+	}
+	u, err := url.Parse(s.base)
+	if err != nil {
+		return nil, err
+	}
+	u.RawQuery = q.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("ct search returned http %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxCTSearchResponse+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > maxCTSearchResponse {
+		return nil, errors.New("ct search response too large")
+	}
+	var raw []crtshEntry
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return nil, fmt.Errorf("ct search returned malformed json: %w", err)
+	}
+	out := make([]CTEntry, 0, len(raw))
+	for _, r := range raw {
+		out = append(out, CTEntry{
+			ID: r.ID, LoggedAt: crtshTime(r.EntryTimestamp), NotBefore: crtshTime(r.NotBefore), NotAfter: crtshTime(r.NotAfter),
+			Serial: r.SerialNumber, Issuer: r.IssuerName, CommonName: r.CommonName, Names: strings.Fields(r.NameValue),
+		})
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].ID > out[j].ID })
+	return out, nil
+}
@@ -0,0 +1,163 @@
+package service
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"engtools/backend/internal/config"
+	"engtools/backend/internal/pki"
+	"engtools/backend/internal/tlsinspect"
+	"github.com/miekg/dns"
+)
+
+// fallbackResolver is used when neither DNS_RESOLVER nor resolv.conf name one.
+const fallbackResolver = "223.5.5.5:53"
+
+// DANEService looks up TLSA records and checks them against the chain a server presents.
+type DANEService struct {
+	resolver string
+	client   *dns.Client
+}
+
+func NewDANEService(cfg *config.Config) *DANEService {
+	resolver := cfg.DNSResolver
+	if resolver == "" {
+		resolver = fallbackResolver
+		if rc, err := dns.ClientConfigFromFile("/etc/resolv.conf"); err == nil && len(rc.Servers) > 0 {
+			resolver = net.JoinHostPort(rc.Servers[0], rc.Port)
+		}
+	} else if _, _, err := net.SplitHostPort(resolver); err != nil {
+		resolver = net.JoinHostPort(resolver, "53")
+	}
+	return &DANEService{resolver: resolver, client: &dns.Client{Timeout: 5 * time.Second}}
+}
+
+// TLSALookup is the answer for one TLSA owner name. Secure is the resolver's AD bit, so it
+// only means something with a validating resolver.
+type TLSALookup struct {
+	Name     string     `json:"name"`
+	Resolver string     `json:"resolver"`
+	Rcode    string     `json:"rcode"`
+	Secure   bool       `json:"dnssec_secure"`
+	Records  []pki.TLSA `json:"records"`
+}
+
+// LookupTLSA queries owner, e.g. _25._tcp.mail.example.com, following CNAMEs as the
+// resolver returns them.
+func (s *DANEService) LookupTLSA(ctx context.Context, owner string) (*TLSALookup, error) {
+	m := new(dns.Msg)
+	m.SetQuestion(dns.Fqdn(owner), dns.TypeTLSA)
+	m.RecursionDesired = true
+	m.SetEdns0(4096, true) // DO, so a validating resolver sets AD
+	r, _, err := s.client.ExchangeContext(ctx, m, s.resolver)
+	if err == nil && r.Truncated {
+		tcp := *s.client
+		tcp.Net = "tcp"
+		r, _, err = tcp.ExchangeContext(ctx, m, s.resolver)
+	}
+	if err != nil {
+		return nil, err
+	}
+	out := &TLSALookup{
+		Name: strings.TrimSuffix(dns.Fqdn(owner), "."), Resolver: s.resolver, Rcode: dns.RcodeToString[r.Rcode],
+		Secure: r.AuthenticatedData, Records: []pki.TLSA{},
+	}
+	for _, rr := range r.Answer {
+		t, ok := rr.(*dns.TLSA)
+		if !ok {
+			continue
+		}
+		data, _ := hex.DecodeString(t.Certificate) // already hex from the wire format
+		out.Records = append(out.Records, pki.NewTLSA("", t.Usage, t.Selector, t.MatchingType, data))
+	}
+	return out, nil
+}
+
+// DANEResult reports which published records match the served chain. Valid needs a match
+// and a DNSSEC authenticated answer, as clients ignore insecure TLSA records.
+type DANEResult struct {
+	TLSALookup
+	Matched     bool              `json:"matched"`
+	Valid       bool              `json:"valid"`
+	Matches     []pki.TLSAMatch   `json:"matches"`
+	Connection  *DANEConnection   `json:"connection,omitempty"`
+	Certs       []pki.CertSummary `json:"certs"`
+	PKIX        bool              `json:"pkix_valid"`
+	PKIXError   string            `json:"pkix_error,omitempty"`
+	Diagnostics []string          `json:"diagnostics"`
+}
+
+// DANEConnection summarises the handshake the chain was taken from.
+type DANEConnection struct {
+	Host        string `json:"host"`
+	Port        int    `json:"port"`
+	IP          string `json:"ip"`
+	StartTLS    string `json:"starttls,omitempty"`
+	TLSVersion  string `json:"tls_version"`
+	CipherSuite string `json:"cipher_suite"`
+}
+
+// Verify looks up the TLSA records for the endpoint in opts and, when there are any,
+// connects with TLSInspect and matches each record against the presented chain.
+func (s *DANEService) Verify(ctx context.Context, opts tlsinspect.Options, proto string) (*DANEResult, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	if opts.Port == 0 {
+		opts.Port = tlsinspect.DefaultPort(opts.StartTLS)
+	}
+	if opts.MinVersion == 0 {
+		opts.MinVersion = tls.VersionTLS10 // plenty of mail servers still run old stacks
+	}
+	name := opts.ServerName
+	if name == "" {
+		name = opts.Host
+	}
+	if name == "" {
+		return nil, errors.New("host or server_name required")
+	}
+	lookup, err := s.LookupTLSA(ctx, pki.TLSAOwner(name, opts.Port, proto))
+	if err != nil {
+		return nil, fmt.Errorf("tlsa lookup: %w", err)
+	}
+	res := &DANEResult{TLSALookup: *lookup, Matches: []pki.TLSAMatch{}, Certs: []pki.CertSummary{}, Diagnostics: []string{}}
+	switch {
+	case lookup.Rcode == "SERVFAIL":
+		res.Diagnostics = append(res.Diagnostics, "resolver returned SERVFAIL; a validating resolver does so for bogus DNSSEC")
+		return res, nil
+	case len(lookup.Records) == 0:
+		res.Diagnostics = append(res.Diagnostics, "no TLSA records published at "+lookup.Name)
+		return res, nil
+	case !lookup.Secure:
+		res.Diagnostics = append(res.Diagnostics, "answer not DNSSEC authenticated; clients must ignore insecure TLSA records")
+	}
+
+	insp, err := tlsinspect.Inspect(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	res.Connection = &DANEConnection{
+		Host: insp.Host, Port: insp.Port, IP: insp.IP, StartTLS: insp.StartTLS,
+		TLSVersion: insp.TLSVersion, CipherSuite: insp.CipherSuite,
+	}
+	for _, crt := range insp.Peer {
+		res.Certs = append(res.Certs, pki.Summarize(crt, "presented"))
+	}
+	res.PKIX, res.PKIXError = insp.Verification.OK, insp.Verification.Error
+	for _, rec := range lookup.Records {
+		m := pki.MatchTLSA(rec, insp.Peer, insp.Verification.Raw, name)
+		res.Matched = res.Matched || m.Matched
+		res.Matches = append(res.Matches, m)
+	}
+	res.Valid = res.Matched && lookup.Secure
+	if !res.Matched {
+		res.Diagnostics = append(res.Diagnostics, "no TLSA record matches the presented chain")
+	}
+	return res, nil
+}
@@ -5,8 +5,10 @@ import (
     "encoding/json"
     "fmt"
     "net/http"
+    "net/netip"
     "time"
     "engtools/backend/internal/config"
+    "engtools/backend/internal/ipcalc"
 )
 
 type GeoService struct {
@@ -46,9 +48,30 @@ type GeoResult struct {
     Timezone  string  `json:"timezone"`
     Anycast   bool    `json:"anycast"`
     Source    string  `json:"source"`
+    CountryInfo *Country `json:"country_info,omitempty"`
+    Bogon     bool    `json:"bogon,omitempty"`
+    Classification *ipcalc.Classification `json:"classification,omitempty"`
+}
+
+// fillCountry normalizes the country fields from the embedded ISO 3166 table so results
+// look the same whichever provider produced them.
+func (g *GeoResult) fillCountry() {
+    code := g.CountryCode
+    if code == "" { code = g.Country }
+    c, ok := LookupCountry(code)
+    if !ok { return }
+    g.CountryCode = c.Alpha2
+    g.Country = c.Name
+    g.CountryInfo = c
 }
 
 func (s *GeoService) Lookup(ctx context.Context, ip string) (*GeoResult, error) {
+    // special-purpose addresses have no location; answer offline instead of asking ipinfo
+    if a, err := netip.ParseAddr(ip); err == nil {
+        if cls := ipcalc.Classify(a); cls.Bogon {
+            return &GeoResult{IP: cls.IP, Bogon: true, Source: "local", Classification: cls}, nil
+        }
+    }
     url := fmt.Sprintf("https://ipinfo.io/%s", ip)
     if s.token != "" { url = url + "?token=" + s.token }
     req, _ := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
@@ -74,12 +97,12 @@ func (s *GeoService) Lookup(ctx context.Context, ip string) (*GeoResult, error)
             if r.Org[i] == ' ' { asn = r.Org[:i]; break }
         }
     }
-    return &GeoResult{
+    res := &GeoResult{
         IP: r.IP,
         Hostname: r.Hostname,
         City: r.City,
         Region: r.Region,
-        Country: countryName(r.Country),
+        Country: r.Country,
         CountryCode: r.Country,
         Latitude: lat,
         Longitude: lon,
@@ -89,10 +112,7 @@ func (s *GeoService) Lookup(ctx context.Context, ip string) (*GeoResult, error)
         Timezone: r.Timezone,
         Anycast: r.Anycast,
         Source: "ipinfo",
-    }, nil
-}
-
-func countryName(code string) string {
-    // minimal mapping fallback: return code for now; client can interpret code
-    return code
+    }
+    res.fillCountry()
+    return res, nil
 }
\ No newline at end of file
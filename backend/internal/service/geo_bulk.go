@@ -0,0 +1,238 @@
+package service
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"engtools/backend/internal/ipcalc"
+)
+
+const (
+	// MaxBulkIPs caps how many distinct addresses a single bulk request may enrich.
+	MaxBulkIPs = 10000
+	// MaxBulkConcurrency caps the number of upstream lookups in flight at once.
+	MaxBulkConcurrency = 32
+)
+
+var (
+	ipv4Candidate = regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b`)
+	ipv6Candidate = regexp.MustCompile(`[0-9A-Fa-f:]*:[0-9A-Fa-f:.]+`)
+)
+
+type BulkOptions struct {
+	Concurrency int
+	ReverseDNS  bool
+	Geo         bool
+}
+
+type BulkItem struct {
+	IP      string     `json:"ip"`
+	Version int        `json:"version"`
+	Private bool       `json:"private"`
+	Bogon   bool       `json:"bogon"`
+	Class   string     `json:"class,omitempty"`
+	Count   int        `json:"count"`
+	PTR     []string   `json:"ptr,omitempty"`
+	Geo     *GeoResult `json:"geo,omitempty"`
+	Error   string     `json:"error,omitempty"`
+}
+
+type BulkBucket struct {
+	Key  string `json:"key"`
+	Name string `json:"name,omitempty"`
+	IPs  int    `json:"ips"`
+	Hits int    `json:"hits"`
+}
+
+type BulkResult struct {
+	Total     int          `json:"total"`
+	Unique    int          `json:"unique"`
+	Invalid   []string     `json:"invalid,omitempty"`
+	Truncated bool         `json:"truncated"`
+	Items     []BulkItem   `json:"items"`
+	ByCountry []BulkBucket `json:"by_country"`
+	ByASN     []BulkBucket `json:"by_asn"`
+	Elapsed   string       `json:"elapsed"`
+}
+
+// ExtractIPs pulls every IPv4/IPv6 literal out of free-form text such as access logs, in
+// the order they appear. Candidates are validated with netip so timestamps like 12:30:01
+// are not mistaken for IPv6, and IPv4-mapped IPv6 addresses are reported unmapped so
+// "::ffff:1.2.3.4" counts once as 1.2.3.4 rather than also matching as a dotted quad.
+func ExtractIPs(text string) []string {
+	type hit struct {
+		start, end int
+		addr       netip.Addr
+	}
+	var v6 []hit
+	for _, m := range ipv6Candidate.FindAllStringIndex(text, -1) {
+		c := strings.Trim(text[m[0]:m[1]], ".:")
+		if strings.Count(c, ":") < 2 {
+			continue
+		}
+		if a, err := netip.ParseAddr(c); err == nil && a.Is6() {
+			v6 = append(v6, hit{m[0], m[1], a.Unmap()})
+		}
+	}
+	hits := v6
+	for _, m := range ipv4Candidate.FindAllStringIndex(text, -1) {
+		a, err := netip.ParseAddr(text[m[0]:m[1]])
+		if err != nil || !a.Is4() {
+			continue
+		}
+		dup := false
+		for _, h := range v6 {
+			if m[0] >= h.start && m[1] <= h.end && h.addr == a {
+				dup = true
+				break
+			}
+		}
+		if !dup {
+			hits = append(hits, hit{m[0], m[1], a})
+		}
+	}
+	sort.SliceStable(hits, func(i, j int) bool { return hits[i].start < hits[j].start })
+	out := make([]string, 0, len(hits))
+	for _, h := range hits {
+		out = append(out, h.addr.String())
+	}
+	return out
+}
+
+// BulkLookup enriches a list of addresses with classification, reverse DNS and geo data.
+// Duplicates are collapsed (the per-address hit count is kept) and upstream lookups are
+// bounded by opts.Concurrency.
+func (s *GeoService) BulkLookup(ctx context.Context, ips []string, opts BulkOptions) *BulkResult {
+	start := time.Now()
+	res := &BulkResult{Total: len(ips)}
+	counts := map[netip.Addr]int{}
+	var order []netip.Addr
+	for _, raw := range ips {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		a, err := netip.ParseAddr(raw)
+		if err != nil {
+			res.Invalid = append(res.Invalid, raw)
+			continue
+		}
+		a = a.Unmap()
+		if _, ok := counts[a]; !ok {
+			if len(order) >= MaxBulkIPs {
+				res.Truncated = true
+				continue
+			}
+			order = append(order, a)
+		}
+		counts[a]++
+	}
+	res.Unique = len(order)
+
+	workers := opts.Concurrency
+	if workers <= 0 {
+		workers = 8
+	}
+	if workers > MaxBulkConcurrency {
+		workers = MaxBulkConcurrency
+	}
+	items := make([]BulkItem, len(order))
+	sem := make(chan struct{}, workers)
+	var wg sync.WaitGroup
+	for i, a := range order {
+		items[i] = BulkItem{IP: a.String(), Version: 4, Count: counts[a]}
+		if a.Is6() {
+			items[i].Version = 6
+		}
+		cls := ipcalc.Classify(a)
+		items[i].Private, items[i].Bogon, items[i].Class = cls.Private, cls.Bogon, cls.Category
+		if !opts.ReverseDNS && (!opts.Geo || items[i].Bogon) {
+			continue
+		}
+		wg.Add(1)
+		go func(it *BulkItem) {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				it.Error = ctx.Err().Error()
+				return
+			}
+			defer func() { <-sem }()
+			if opts.ReverseDNS {
+				rctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+				names, err := net.DefaultResolver.LookupAddr(rctx, it.IP)
+				cancel()
+				if err == nil {
+					it.PTR = names
+				}
+			}
+			if opts.Geo && !it.Bogon {
+				gctx, cancel := context.WithTimeout(ctx, 1500*time.Millisecond)
+				g, err := s.Lookup(gctx, it.IP)
+				cancel()
+				if err != nil {
+					it.Error = err.Error()
+				} else {
+					it.Geo = g
+				}
+			}
+		}(&items[i])
+	}
+	wg.Wait()
+	res.Items = items
+	res.ByCountry, res.ByASN = aggregateBulk(items)
+	res.Elapsed = time.Since(start).Round(time.Millisecond).String()
+	return res
+}
+
+func aggregateBulk(items []BulkItem) (byCountry, byASN []BulkBucket) {
+	countries := map[string]*BulkBucket{}
+	asns := map[string]*BulkBucket{}
+	add := func(m map[string]*BulkBucket, key, name string, hits int) {
+		b, ok := m[key]
+		if !ok {
+			b = &BulkBucket{Key: key, Name: name}
+			m[key] = b
+		}
+		b.IPs++
+		b.Hits += hits
+	}
+	for _, it := range items {
+		country, asn, org := "unknown", "unknown", ""
+		if it.Bogon {
+			country, asn = "bogon", "bogon"
+		}
+		if it.Geo != nil {
+			if it.Geo.CountryCode != "" {
+				country = it.Geo.CountryCode
+			}
+			if it.Geo.ASN != "" {
+				asn = it.Geo.ASN
+				org = strings.TrimSpace(strings.TrimPrefix(it.Geo.Org, it.Geo.ASN))
+			}
+		}
+		add(countries, country, countryName(country), it.Count)
+		add(asns, asn, org, it.Count)
+	}
+	flatten := func(m map[string]*BulkBucket) []BulkBucket {
+		out := make([]BulkBucket, 0, len(m))
+		for _, b := range m {
+			out = append(out, *b)
+		}
+		sort.Slice(out, func(i, j int) bool {
+			if out[i].Hits != out[j].Hits {
+				return out[i].Hits > out[j].Hits
+			}
+			return out[i].Key < out[j].Key
+		})
+		return out
+	}
+	return flatten(countries), flatten(asns)
+}
@@ -0,0 +1,27 @@
+package service
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestExtractIPs(t *testing.T) {
+	for _, tc := range []struct {
+		name, text string
+		want       []string
+	}{
+		{"ipv4", "GET / from 203.0.113.7 and 10.0.0.1", []string{"203.0.113.7", "10.0.0.1"}},
+		{"repeats kept", "1.2.3.4 1.2.3.4", []string{"1.2.3.4", "1.2.3.4"}},
+		{"ipv6", "peer [2001:db8::1]:443 closed", []string{"2001:db8::1"}},
+		{"mapped counted once", "client ::ffff:1.2.3.4 connected", []string{"1.2.3.4"}},
+		{"mapped then plain", "::ffff:1.2.3.4 then 1.2.3.4", []string{"1.2.3.4", "1.2.3.4"}},
+		{"order", "fe80::1 before 192.0.2.1 before 2001:db8::2", []string{"fe80::1", "192.0.2.1", "2001:db8::2"}},
+		{"timestamps", "2024-01-02 12:30:01 ok", nil},
+		{"port", "10.0.0.5:8080", []string{"10.0.0.5"}},
+		{"out of range", "999.1.1.1", nil},
+	} {
+		if got := ExtractIPs(tc.text); !slices.Equal(got, tc.want) && len(got)+len(tc.want) > 0 {
+			t.Errorf("%s: got %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,538 @@
+package service
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"math"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"engtools/backend/internal/config"
+	"engtools/backend/internal/repository/model"
+	"engtools/backend/internal/tlsinspect"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrEndpointNotFound = errors.New("endpoint not found")
+	ErrEndpointExists   = errors.New("endpoint name already in use")
+	ErrChannelNotFound  = errors.New("channel not found")
+	ErrChannelExists    = errors.New("channel name already in use")
+)
+
+const (
+	defaultCheckInterval = 360 // minutes
+	defaultThresholds    = "30,14,7,3,1"
+	checkTimeout         = 15 * time.Second
+)
+
+var (
+	certExpiryDays = &expiryCollector{
+		desc: prometheus.NewDesc("engtools_cert_expiry_days",
+			"Days until the certificate of a monitored endpoint expires; negative once expired.",
+			[]string{"endpoint", "host", "port"}, nil),
+		notAfter: map[[3]string]time.Time{},
+	}
+	certCheckSuccess = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "engtools_cert_check_success",
+		Help: "Whether the last check of a monitored endpoint could fetch its certificate.",
+	}, []string{"endpoint", "host", "port"})
+)
+
+func init() {
+	prometheus.MustRegister(certExpiryDays, certCheckSuccess)
+}
+
+// expiryCollector reports days to expiry relative to the scrape, so the gauge keeps
+// counting down between checks instead of freezing at the value of the last one.
+type expiryCollector struct {
+	desc     *prometheus.Desc
+	mu       sync.Mutex
+	notAfter map[[3]string]time.Time // keyed by endpoint, host and port
+}
+
+func (c *expiryCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *expiryCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	for l, na := range c.notAfter {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, na.Sub(now).Hours()/24, l[:]...)
+	}
+}
+
+func (c *expiryCollector) set(labels []string, notAfter time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.notAfter[[3]string(labels)] = notAfter
+}
+
+func (c *expiryCollector) drop(endpoint string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for l := range c.notAfter {
+		if l[0] == endpoint {
+			delete(c.notAfter, l)
+		}
+	}
+}
+
+// MonitorService keeps a registry of TLS endpoints, checks their certificates on a
+// schedule and notifies channels as expiry thresholds are crossed.
+type MonitorService struct {
+	db      *gorm.DB
+	cfg     *config.Config
+	client  *http.Client
+	inspect func(context.Context, tlsinspect.Options) (*tlsinspect.Result, error)
+	// mu serialises checks so the scheduler and manual checks do not interleave updates
+	mu sync.Mutex
+}
+
+func NewMonitorService(cfg *config.Config, db *gorm.DB) *MonitorService {
+	return &MonitorService{db: db, cfg: cfg, client: &http.Client{Timeout: 10 * time.Second}, inspect: tlsinspect.Inspect}
+}
+
+// ParseThresholds reads a comma separated list of days and returns it deduplicated in
+// descending order.
+func ParseThresholds(s string) ([]int, error) {
+	var out []int
+	for _, f := range strings.Split(s, ",") {
+		if f = strings.TrimSpace(f); f == "" {
+			continue
+		}
+		n, err := strconv.Atoi(f)
+		if err != nil || n < 0 || n > 3650 {
+			return nil, errors.New("invalid threshold: " + f)
+		}
+		if !slices.Contains(out, n) {
+			out = append(out, n)
+		}
+	}
+	slices.Sort(out)
+	slices.Reverse(out)
+	return out, nil
+}
+
+func formatThresholds(days []int) string {
+	parts := make([]string, len(days))
+	for i, d := range days {
+		parts[i] = strconv.Itoa(d)
+	}
+	return strings.Join(parts, ",")
+}
+
+// normalizeEndpoint applies defaults to an endpoint and checks that it can be inspected.
+func normalizeEndpoint(ep *model.MonitoredEndpoint) error {
+	ep.Name, ep.Host = strings.TrimSpace(ep.Name), strings.TrimSpace(ep.Host)
+	ep.StartTLS = strings.ToLower(strings.TrimSpace(ep.StartTLS))
+	if ep.Name == "" {
+		return errors.New("name required")
+	}
+	if ep.Port < 0 || ep.Port > 65535 {
+		return errors.New("invalid port")
+	}
+	if ep.Port == 0 {
+		ep.Port = tlsinspect.DefaultPort(ep.StartTLS)
+	}
+	if ep.IntervalMinutes == 0 {
+		ep.IntervalMinutes = defaultCheckInterval
+	}
+	if ep.IntervalMinutes < 1 {
+		return errors.New("interval must be at least one minute")
+	}
+	if strings.TrimSpace(ep.Thresholds) == "" {
+		ep.Thresholds = defaultThresholds
+	}
+	days, err := ParseThresholds(ep.Thresholds)
+	if err != nil {
+		return err
+	}
+	ep.Thresholds = formatThresholds(days)
+	return endpointOptions(ep).Validate()
+}
+
+// endpointOptions accepts TLS 1.0 so that certificates of legacy servers are still
+// watched; protocol hygiene is the scanner's job.
+func endpointOptions(ep *model.MonitoredEndpoint) tlsinspect.Options {
+	return tlsinspect.Options{
+		Host: ep.Host, Port: ep.Port, Address: ep.Address, ServerName: ep.ServerName,
+		StartTLS: ep.StartTLS, Timeout: checkTimeout, MinVersion: tls.VersionTLS10,
+	}
+}
+
+func (s *MonitorService) ListEndpoints() ([]model.MonitoredEndpoint, error) {
+	var list []model.MonitoredEndpoint
+	err := s.db.Order("name").Find(&list).Error
+	return list, err
+}
+
+func (s *MonitorService) GetEndpoint(name string) (*model.MonitoredEndpoint, error) {
+	var ep model.MonitoredEndpoint
+	if err := s.db.Where("name = ?", name).First(&ep).Error; err != nil {
+		return nil, ErrEndpointNotFound
+	}
+	return &ep, nil
+}
+
+// CreateEndpoint registers ep; it is checked on the scheduler's next tick.
+func (s *MonitorService) CreateEndpoint(ep *model.MonitoredEndpoint) error {
+	if err := normalizeEndpoint(ep); err != nil {
+		return err
+	}
+	var count int64
+	s.db.Model(&model.MonitoredEndpoint{}).Where("name = ?", ep.Name).Count(&count)
+	if count > 0 {
+		return ErrEndpointExists
+	}
+	ep.Status = "pending"
+	return s.db.Create(ep).Error
+}
+
+// UpdateEndpoint replaces the configuration of the named endpoint, keeping its check
+// state so a certificate change is still detected on the next check.
+func (s *MonitorService) UpdateEndpoint(name string, upd *model.MonitoredEndpoint) (*model.MonitoredEndpoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ep, err := s.GetEndpoint(name)
+	if err != nil {
+		return nil, err
+	}
+	if err := normalizeEndpoint(upd); err != nil {
+		return nil, err
+	}
+	if upd.Name != ep.Name {
+		var count int64
+		s.db.Model(&model.MonitoredEndpoint{}).Where("name = ?", upd.Name).Count(&count)
+		if count > 0 {
+			return nil, ErrEndpointExists
+		}
+	}
+	ep.Name, ep.Host, ep.Port, ep.Address, ep.ServerName = upd.Name, upd.Host, upd.Port, upd.Address, upd.ServerName
+	ep.StartTLS, ep.IntervalMinutes, ep.Thresholds, ep.Enabled = upd.StartTLS, upd.IntervalMinutes, upd.Thresholds, upd.Enabled
+	if err := s.db.Save(ep).Error; err != nil {
+		return nil, err
+	}
+	dropMetrics(name)
+	s.setMetrics(ep)
+	return ep, nil
+}
+
+// DeleteEndpoint removes the endpoint together with its history.
+func (s *MonitorService) DeleteEndpoint(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ep, err := s.GetEndpoint(name)
+	if err != nil {
+		return err
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("endpoint_id = ?", ep.ID).Delete(&model.CertCheck{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(ep).Error
+	})
+	if err == nil {
+		dropMetrics(name)
+	}
+	return err
+}
+
+// History returns the most recent checks of the named endpoint, newest first. With
+// changesOnly set it returns only checks that saw a new certificate.
+func (s *MonitorService) History(name string, limit int, changesOnly bool) ([]model.CertCheck, error) {
+	ep, err := s.GetEndpoint(name)
+	if err != nil {
+		return nil, err
+	}
+	q := s.db.Where("endpoint_id = ?", ep.ID)
+	if changesOnly {
+		q = q.Where("changed = ?", true)
+	}
+	var list []model.CertCheck
+	err = q.Order("checked_at desc, id desc").Limit(limit).Find(&list).Error
+	return list, err
+}
+
+// CheckOutcome is the result of one check: the recorded history entry, the events it
+// raised and any channel that could not be notified.
+type CheckOutcome struct {
+	Check        *model.CertCheck
+	Endpoint     *model.MonitoredEndpoint
+	Events       []MonitorEvent
+	NotifyErrors []string
+}
+
+// Check inspects the endpoint now, records the result and sends notifications.
+func (s *MonitorService) Check(ctx context.Context, name string) (*CheckOutcome, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ep, err := s.GetEndpoint(name)
+	if err != nil {
+		return nil, err
+	}
+	return s.check(ctx, ep)
+}
+
+func (s *MonitorService) check(ctx context.Context, ep *model.MonitoredEndpoint) (*CheckOutcome, error) {
+	res, inspectErr := s.inspect(ctx, endpointOptions(ep))
+	now := time.Now().UTC()
+	chk := &model.CertCheck{EndpointID: ep.ID, CheckedAt: now}
+	if inspectErr == nil && len(res.Certs) == 0 {
+		inspectErr = errors.New("no certificate presented")
+	}
+	base := MonitorEvent{Endpoint: ep.Name, Host: ep.Host, Port: ep.Port, Time: now}
+	var events []MonitorEvent
+	prevStatus := ep.Status
+	if inspectErr != nil {
+		chk.Status, chk.Error = "error", truncate(inspectErr.Error(), 512)
+		ep.Status, ep.LastError = chk.Status, chk.Error
+		if prevStatus != "error" {
+			ev := base
+			ev.Kind, ev.Error = "error", chk.Error
+			events = append(events, ev)
+		}
+	} else {
+		leaf := res.Certs[0]
+		days := int(math.Floor(leaf.NotAfter.Sub(now).Hours() / 24))
+		thresholds, _ := ParseThresholds(ep.Thresholds)
+		chk.IP, chk.Fingerprint, chk.Subject, chk.Issuer, chk.Serial = res.IP, leaf.Fingerprints.SHA256, leaf.Subject, leaf.Issuer, leaf.SerialHex
+		chk.NotAfter, chk.DaysRemaining = &leaf.NotAfter, &days
+		chk.VerifyOK, chk.VerifyError = res.Verification.OK, truncate(res.Verification.Error, 512)
+		chk.Status = expiryStatus(thresholds, days)
+		chk.Changed = ep.Fingerprint != "" && ep.Fingerprint != leaf.Fingerprints.SHA256
+		base.Subject, base.NotAfter, base.DaysRemaining, base.Fingerprint = leaf.Subject, &leaf.NotAfter, &days, leaf.Fingerprints.SHA256
+
+		if prevStatus == "error" {
+			ev := base
+			ev.Kind = "recovered"
+			events = append(events, ev)
+		}
+		if chk.Changed {
+			ev := base
+			ev.Kind, ev.PreviousFingerprint = "changed", ep.Fingerprint
+			events = append(events, ev)
+			ep.NotifiedThreshold = nil
+		}
+		if t, ok := crossedThreshold(thresholds, days, ep.NotifiedThreshold); ok {
+			ev := base
+			ev.Kind = "expiring"
+			if t < 0 {
+				ev.Kind = "expired"
+			} else {
+				ev.Threshold = &t
+			}
+			events = append(events, ev)
+			ep.NotifiedThreshold = &t
+		}
+		ep.Status, ep.LastError = chk.Status, ""
+		ep.Fingerprint, ep.Subject, ep.NotAfter, ep.DaysRemaining, ep.VerifyOK = chk.Fingerprint, chk.Subject, chk.NotAfter, chk.DaysRemaining, chk.VerifyOK
+	}
+	ep.LastCheckedAt = &now
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(chk).Error; err != nil {
+			return err
+		}
+		return tx.Save(ep).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.setMetrics(ep)
+
+	out := &CheckOutcome{Check: chk, Endpoint: ep}
+	for _, ev := range events {
+		ev.Text = eventText(ev)
+		out.Events = append(out.Events, ev)
+		out.NotifyErrors = append(out.NotifyErrors, s.broadcast(ctx, ev)...)
+	}
+	return out, nil
+}
+
+// expiryStatus is expired after NotAfter, warning once the first threshold is reached
+// and ok before that.
+func expiryStatus(thresholds []int, days int) string {
+	switch {
+	case days < 0:
+		return "expired"
+	case len(thresholds) > 0 && days <= thresholds[0]:
+		return "warning"
+	}
+	return "ok"
+}
+
+// crossedThreshold returns the lowest threshold days has reached, -1 once the
+// certificate has expired, if it is below the one already notified for this
+// certificate. Each threshold is therefore reported once, and a check that skips
+// several thresholds reports only the lowest.
+func crossedThreshold(thresholds []int, days int, notified *int) (int, bool) {
+	crossed, found := 0, false
+	if days < 0 {
+		crossed, found = -1, true
+	} else {
+		for _, t := range thresholds {
+			if days <= t {
+				crossed, found = t, true
+			}
+		}
+	}
+	if !found || (notified != nil && *notified <= crossed) {
+		return 0, false
+	}
+	return crossed, true
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
+
+func metricLabels(ep *model.MonitoredEndpoint) []string {
+	return []string{ep.Name, ep.Host, strconv.Itoa(ep.Port)}
+}
+
+func (s *MonitorService) setMetrics(ep *model.MonitoredEndpoint) {
+	if ep.LastCheckedAt == nil {
+		return
+	}
+	certCheckSuccess.WithLabelValues(metricLabels(ep)...).Set(boolGauge(ep.Status != "error"))
+	if ep.NotAfter != nil {
+		certExpiryDays.set(metricLabels(ep), *ep.NotAfter)
+	}
+}
+
+func dropMetrics(name string) {
+	certExpiryDays.drop(name)
+	certCheckSuccess.DeletePartialMatch(prometheus.Labels{"endpoint": name})
+}
+
+func boolGauge(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// broadcast sends ev to every enabled channel and returns the failures.
+func (s *MonitorService) broadcast(ctx context.Context, ev MonitorEvent) []string {
+	var channels []model.NotificationChannel
+	if err := s.db.Where("enabled = ?", true).Order("name").Find(&channels).Error; err != nil {
+		return []string{err.Error()}
+	}
+	var failed []string
+	for i := range channels {
+		if err := s.notify(ctx, &channels[i], ev); err != nil {
+			failed = append(failed, channels[i].Name+": "+err.Error())
+		}
+	}
+	return failed
+}
+
+// Run checks every enabled endpoint whose interval has elapsed, then again on each tick,
+// until ctx is done.
+func (s *MonitorService) Run(ctx context.Context, tick time.Duration, log *zap.Logger) {
+	if list, err := s.ListEndpoints(); err == nil {
+		for i := range list {
+			s.setMetrics(&list[i])
+		}
+	}
+	t := time.NewTicker(tick)
+	defer t.Stop()
+	for {
+		s.checkDue(ctx, log)
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+func (s *MonitorService) checkDue(ctx context.Context, log *zap.Logger) {
+	var due []model.MonitoredEndpoint
+	if err := s.db.Where("enabled = ?", true).Order("id").Find(&due).Error; err != nil {
+		log.Error("monitor: list endpoints", zap.Error(err))
+		return
+	}
+	for _, ep := range due {
+		if ctx.Err() != nil {
+			return
+		}
+		if ep.LastCheckedAt != nil && time.Since(*ep.LastCheckedAt) < time.Duration(ep.IntervalMinutes)*time.Minute {
+			continue
+		}
+		out, err := s.Check(ctx, ep.Name)
+		if err != nil {
+			log.Error("monitor: check", zap.String("endpoint", ep.Name), zap.Error(err))
+			continue
+		}
+		log.Info("monitor: checked", zap.String("endpoint", ep.Name), zap.String("status", out.Check.Status), zap.Int("events", len(out.Events)))
+		for _, e := range out.NotifyErrors {
+			log.Warn("monitor: notify", zap.String("endpoint", ep.Name), zap.String("error", e))
+		}
+	}
+}
+
+func (s *MonitorService) ListChannels() ([]model.NotificationChannel, error) {
+	var list []model.NotificationChannel
+	err := s.db.Order("name").Find(&list).Error
+	return list, err
+}
+
+// CreateChannel stores a notification channel after checking its target.
+func (s *MonitorService) CreateChannel(ch *model.NotificationChannel) error {
+	ch.Name, ch.Type, ch.Target = strings.TrimSpace(ch.Name), strings.ToLower(strings.TrimSpace(ch.Type)), strings.TrimSpace(ch.Target)
+	if ch.Name == "" {
+		return errors.New("name required")
+	}
+	if !slices.Contains(ChannelTypes, ch.Type) {
+		return errors.New("type must be one of " + strings.Join(ChannelTypes, ", "))
+	}
+	if ch.Type == "email" {
+		if len(splitAddresses(ch.Target)) == 0 {
+			return errors.New("target must list e-mail addresses")
+		}
+	} else if u, err := url.Parse(ch.Target); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("target must be an http or https URL")
+	}
+	var count int64
+	s.db.Model(&model.NotificationChannel{}).Where("name = ?", ch.Name).Count(&count)
+	if count > 0 {
+		return ErrChannelExists
+	}
+	return s.db.Create(ch).Error
+}
+
+func (s *MonitorService) DeleteChannel(name string) error {
+	res := s.db.Where("name = ?", name).Delete(&model.NotificationChannel{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrChannelNotFound
+	}
+	return nil
+}
+
+// TestChannel sends a test event to the named channel, enabled or not.
+func (s *MonitorService) TestChannel(ctx context.Context, name string) error {
+	var ch model.NotificationChannel
+	if err := s.db.Where("name = ?", name).First(&ch).Error; err != nil {
+		return ErrChannelNotFound
+	}
+	ev := MonitorEvent{Kind: "test", Endpoint: "example", Host: "example.com", Port: 443, Time: time.Now().UTC()}
+	ev.Text = eventText(ev)
+	return s.notify(ctx, &ch, ev)
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"engtools/backend/internal/repository/model"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCrossedThreshold(t *testing.T) {
+	thresholds, _ := ParseThresholds("7, 30,14,7")
+	if formatThresholds(thresholds) != "30,14,7" {
+		t.Fatalf("thresholds %v", thresholds)
+	}
+	ptr := func(n int) *int { return &n }
+	tests := []struct {
+		days     int
+		notified *int
+		want     int
+		ok       bool
+	}{
+		{45, nil, 0, false},
+		{30, nil, 30, true},
+		{20, ptr(30), 0, false},
+		{10, ptr(30), 14, true},
+		{3, nil, 7, true}, // skipped thresholds collapse into the lowest
+		{3, ptr(7), 0, false},
+		{-1, ptr(7), -1, true},
+		{-5, ptr(-1), 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := crossedThreshold(thresholds, tt.days, tt.notified)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("days %d notified %v: got %d %v", tt.days, tt.notified, got, ok)
+		}
+	}
+	if expiryStatus(thresholds, 31) != "ok" || expiryStatus(thresholds, 30) != "warning" || expiryStatus(thresholds, -1) != "expired" {
+		t.Error("unexpected expiry status")
+	}
+}
+
+func TestWebhookRequest(t *testing.T) {
+	ev := MonitorEvent{Kind: "test", Endpoint: "web", Host: "example.com", Port: 443, Time: time.Unix(1700000000, 0)}
+	ev.Text = eventText(ev)
+	tests := []struct {
+		typ, secret, query, body string
+	}{
+		{"slack", "", "", `"text":"[engtools] test notification`},
+		{"feishu", "s", "", `"sign":"` + feishuSign("1700000000", "s") + `"`},
+		{"dingtalk", "s", "timestamp=1700000000000&sign=", `"msgtype":"text"`},
+		{"webhook", "", "", `"kind":"test"`},
+	}
+	for _, tt := range tests {
+		req, err := webhookRequest(context.Background(), &model.NotificationChannel{Type: tt.typ, Target: "https://hooks.test/x", Secret: tt.secret}, ev)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(req.Body)
+		if !json.Valid(body) || !strings.Contains(string(body), tt.body) || !strings.Contains(req.URL.RawQuery, tt.query) {
+			t.Errorf("%s: %s?%s %s", tt.typ, req.URL.Path, req.URL.RawQuery, body)
+		}
+	}
+}
+
+func TestExpiryDaysAtScrape(t *testing.T) {
+	c := &expiryCollector{desc: certExpiryDays.desc, notAfter: map[[3]string]time.Time{}}
+	c.set([]string{"web", "a.test", "443"}, time.Now().Add(36*time.Hour))
+	if d := testutil.ToFloat64(c); d < 1.49 || d > 1.5 {
+		t.Errorf("days %v", d)
+	}
+	// a certificate that expired since the last check reads negative without a new check
+	c.set([]string{"web", "a.test", "443"}, time.Now().Add(-12*time.Hour))
+	if d := testutil.ToFloat64(c); d > -0.5 || d < -0.51 {
+		t.Errorf("days %v", d)
+	}
+	c.drop("web")
+	if n := testutil.CollectAndCount(c); n != 0 {
+		t.Errorf("%d series after drop", n)
+	}
+}
@@ -0,0 +1,182 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"engtools/backend/internal/repository/model"
+)
+
+// ChannelTypes are the supported notification channel types.
+var ChannelTypes = []string{"webhook", "slack", "feishu", "dingtalk", "email"}
+
+// MonitorEvent is what the monitor reports to notification channels. Generic webhooks
+// receive it as JSON; chat channels get Text.
+type MonitorEvent struct {
+	Kind                string     `json:"kind"` // expiring, expired, changed, error, recovered or test
+	Endpoint            string     `json:"endpoint"`
+	Host                string     `json:"host"`
+	Port                int        `json:"port"`
+	Subject             string     `json:"subject,omitempty"`
+	NotAfter            *time.Time `json:"not_after,omitempty"`
+	DaysRemaining       *int       `json:"days_remaining,omitempty"`
+	Threshold           *int       `json:"threshold,omitempty"`
+	Fingerprint         string     `json:"fingerprint,omitempty"`
+	PreviousFingerprint string     `json:"previous_fingerprint,omitempty"`
+	Error               string     `json:"error,omitempty"`
+	Text                string     `json:"text"`
+	Time                time.Time  `json:"time"`
+}
+
+// eventText renders the one-line summary used by chat channels and e-mail subjects.
+func eventText(ev MonitorEvent) string {
+	target := fmt.Sprintf("%s (%s:%d)", ev.Endpoint, ev.Host, ev.Port)
+	days := 0
+	if ev.DaysRemaining != nil {
+		days = *ev.DaysRemaining
+	}
+	switch ev.Kind {
+	case "expiring":
+		return fmt.Sprintf("[engtools] certificate of %s expires in %d days on %s", target, days, ev.NotAfter.Format(time.DateOnly))
+	case "expired":
+		return fmt.Sprintf("[engtools] certificate of %s expired on %s", target, ev.NotAfter.Format(time.DateOnly))
+	case "changed":
+		return fmt.Sprintf("[engtools] certificate of %s changed: %s, %d days remaining", target, ev.Subject, days)
+	case "error":
+		return fmt.Sprintf("[engtools] check of %s failed: %s", target, ev.Error)
+	case "recovered":
+		return fmt.Sprintf("[engtools] check of %s succeeds again", target)
+	}
+	return fmt.Sprintf("[engtools] test notification for %s", target)
+}
+
+// webhookRequest builds the POST for a URL channel. Slack, Feishu and DingTalk get their
+// bots' text message format, signed when the channel has a secret; anything else gets
+// the event as JSON.
+func webhookRequest(ctx context.Context, ch *model.NotificationChannel, ev MonitorEvent) (*http.Request, error) {
+	target := ch.Target
+	var payload any
+	switch ch.Type {
+	case "slack":
+		payload = map[string]any{"text": ev.Text}
+	case "feishu":
+		msg := map[string]any{"msg_type": "text", "content": map[string]string{"text": ev.Text}}
+		if ch.Secret != "" {
+			ts := strconv.FormatInt(ev.Time.Unix(), 10)
+			msg["timestamp"], msg["sign"] = ts, feishuSign(ts, ch.Secret)
+		}
+		payload = msg
+	case "dingtalk":
+		if ch.Secret != "" {
+			ts := strconv.FormatInt(ev.Time.UnixMilli(), 10)
+			sep := "?"
+			if strings.Contains(target, "?") {
+				sep = "&"
+			}
+			target += sep + "timestamp=" + ts + "&sign=" + url.QueryEscape(dingtalkSign(ts, ch.Secret))
+		}
+		payload = map[string]any{"msgtype": "text", "text": map[string]string{"content": ev.Text}}
+	default:
+		payload = ev
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return req, nil
+}
+
+// feishuSign follows the Feishu custom bot rule: HMAC-SHA256 keyed with
+// "timestamp\nsecret" over an empty message.
+func feishuSign(ts, secret string) string {
+	mac := hmac.New(sha256.New, []byte(ts+"\n"+secret))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// dingtalkSign follows the DingTalk robot rule: HMAC-SHA256 keyed with the secret over
+// "timestamp\nsecret".
+func dingtalkSign(ts, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts + "\n" + secret))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// notify delivers ev to one channel.
+func (s *MonitorService) notify(ctx context.Context, ch *model.NotificationChannel, ev MonitorEvent) error {
+	if ch.Type == "email" {
+		return s.sendMail(ch, ev)
+	}
+	req, err := webhookRequest(ctx, ch, ev)
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s returned %s: %s", ch.Type, resp.Status, strings.TrimSpace(string(body)))
+	}
+	// the chat bots answer 200 with an error code in the body
+	var result struct {
+		Code    *int   `json:"code"`
+		ErrCode *int   `json:"errcode"`
+		Msg     string `json:"msg"`
+		ErrMsg  string `json:"errmsg"`
+	}
+	if json.Unmarshal(body, &result) == nil {
+		if result.Code != nil && *result.Code != 0 {
+			return fmt.Errorf("%s error %d: %s", ch.Type, *result.Code, result.Msg)
+		}
+		if result.ErrCode != nil && *result.ErrCode != 0 {
+			return fmt.Errorf("%s error %d: %s", ch.Type, *result.ErrCode, result.ErrMsg)
+		}
+	}
+	return nil
+}
+
+func (s *MonitorService) sendMail(ch *model.NotificationChannel, ev MonitorEvent) error {
+	if s.cfg.SMTPAddr == "" || s.cfg.SMTPFrom == "" {
+		return errors.New("email notifications need SMTP_ADDR and SMTP_FROM")
+	}
+	to := splitAddresses(ch.Target)
+	if len(to) == 0 {
+		return errors.New("no recipients")
+	}
+	var auth smtp.Auth
+	if s.cfg.SMTPUser != "" {
+		host, _, _ := strings.Cut(s.cfg.SMTPAddr, ":")
+		auth = smtp.PlainAuth("", s.cfg.SMTPUser, s.cfg.SMTPPassword, host)
+	}
+	detail, _ := json.MarshalIndent(ev, "", "  ")
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\nTo: %s\r\nSubject: %s\r\nDate: %s\r\n", s.cfg.SMTPFrom, strings.Join(to, ", "), mime.QEncoding.Encode("utf-8", ev.Text), ev.Time.Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(ev.Text + "\r\n\r\n" + strings.ReplaceAll(string(detail), "\n", "\r\n") + "\r\n")
+	return smtp.SendMail(s.cfg.SMTPAddr, auth, s.cfg.SMTPFrom, to, []byte(msg.String()))
+}
+
+func splitAddresses(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ';' || r == ' ' })
+}
@@ -0,0 +1,164 @@
+package sshinspect
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Finding is one weakness of the server's configuration.
+type Finding struct {
+	ID       string `json:"id"`
+	Severity string `json:"severity"` // critical, warning or info
+	Message  string `json:"message"`
+}
+
+// Algorithm is an offered algorithm and how it rates. Severity is ok, info, warning or
+// critical.
+type Algorithm struct {
+	Name     string `json:"name"`
+	Severity string `json:"severity"`
+	Note     string `json:"note,omitempty"`
+}
+
+type rating struct{ severity, note string }
+
+// weakAlgorithms rates names exactly; weakPrefixes catch families. Anything else is ok.
+var weakAlgorithms = map[string]rating{
+	// key exchange
+	"diffie-hellman-group1-sha1":         {"critical", "1024-bit group with SHA-1"},
+	"diffie-hellman-group14-sha1":        {"warning", "SHA-1 exchange hash"},
+	"diffie-hellman-group-exchange-sha1": {"warning", "SHA-1 exchange hash"},
+	"rsa1024-sha1":                       {"critical", "1024-bit RSA with SHA-1"},
+	"ecdh-sha2-nistp256":                 {"info", "NIST curve"},
+	"ecdh-sha2-nistp384":                 {"info", "NIST curve"},
+	"ecdh-sha2-nistp521":                 {"info", "NIST curve"},
+	// host keys
+	"ssh-dss":                      {"critical", "DSA keys are 1024-bit and removed from OpenSSH"},
+	"ssh-dss-cert-v01@openssh.com": {"critical", "DSA keys are 1024-bit and removed from OpenSSH"},
+	"ssh-rsa":                      {"warning", "SHA-1 signatures"},
+	"ssh-rsa-cert-v01@openssh.com": {"warning", "SHA-1 signatures"},
+	"x509v3-sign-dss":              {"critical", "DSA keys are 1024-bit"},
+	"x509v3-sign-rsa":              {"warning", "SHA-1 signatures"},
+	// ciphers
+	"none":                        {"critical", "no encryption or integrity"},
+	"3des-cbc":                    {"critical", "64-bit block cipher (Sweet32)"},
+	"blowfish-cbc":                {"critical", "64-bit block cipher (Sweet32)"},
+	"cast128-cbc":                 {"critical", "64-bit block cipher (Sweet32)"},
+	"idea-cbc":                    {"critical", "64-bit block cipher (Sweet32)"},
+	"rijndael-cbc@lysator.liu.se": {"warning", "CBC mode"},
+	"aes128-cbc":                  {"warning", "CBC mode"},
+	"aes192-cbc":                  {"warning", "CBC mode"},
+	"aes256-cbc":                  {"warning", "CBC mode"},
+	// MACs
+	"hmac-sha1":                      {"warning", "SHA-1"},
+	"hmac-sha1-etm@openssh.com":      {"warning", "SHA-1"},
+	"hmac-sha1-96":                   {"warning", "SHA-1, truncated to 96 bits"},
+	"hmac-sha1-96-etm@openssh.com":   {"warning", "SHA-1, truncated to 96 bits"},
+	"umac-64@openssh.com":            {"warning", "64-bit tag"},
+	"umac-64-etm@openssh.com":        {"warning", "64-bit tag"},
+	"hmac-ripemd160":                 {"warning", "deprecated"},
+	"hmac-ripemd160@openssh.com":     {"warning", "deprecated"},
+	"hmac-ripemd160-etm@openssh.com": {"warning", "deprecated"},
+}
+
+var weakPrefixes = []struct {
+	prefix string
+	rating
+}{
+	{"arcfour", rating{"critical", "RC4 is broken"}},
+	{"des-", rating{"critical", "single DES"}},
+	{"hmac-md5", rating{"critical", "MD5"}},
+	{"gss-group1-sha1-", rating{"critical", "1024-bit group with SHA-1"}},
+	{"gss-group14-sha1-", rating{"warning", "SHA-1 exchange hash"}},
+	{"gss-gex-sha1-", rating{"warning", "SHA-1 exchange hash"}},
+}
+
+// markers are pseudo-algorithms that advertise protocol extensions.
+var markers = map[string]string{
+	"ext-info-s":                   "server supports extension negotiation (RFC 8308)",
+	"kex-strict-s-v00@openssh.com": "strict key exchange, the Terrapin countermeasure",
+}
+
+func rate(name string) Algorithm {
+	if r, ok := weakAlgorithms[name]; ok {
+		return Algorithm{Name: name, Severity: r.severity, Note: r.note}
+	}
+	for _, p := range weakPrefixes {
+		if strings.HasPrefix(name, p.prefix) {
+			return Algorithm{Name: name, Severity: p.severity, Note: p.note}
+		}
+	}
+	if note, ok := markers[name]; ok {
+		return Algorithm{Name: name, Severity: "info", Note: note}
+	}
+	return Algorithm{Name: name, Severity: "ok"}
+}
+
+func rateAll(names []string) []Algorithm {
+	out := make([]Algorithm, 0, len(names))
+	for _, n := range names {
+		out = append(out, rate(n))
+	}
+	return out
+}
+
+// assess lists the weaknesses of a scanned server.
+func assess(r *Result) {
+	add := func(id, severity, msg string, args ...any) {
+		r.Findings = append(r.Findings, Finding{ID: id, Severity: severity, Message: fmt.Sprintf(msg, args...)})
+	}
+	if strings.HasPrefix(r.Banner, "SSH-1.99-") {
+		add("protocol.ssh1", "critical", "server also accepts SSH protocol 1")
+	}
+	for _, group := range []struct {
+		id    string
+		what  string
+		algos []Algorithm
+	}{
+		{"kex", "key exchange", r.KexAlgorithms},
+		{"hostkey", "host key algorithm", r.HostKeyAlgorithms},
+		{"cipher", "cipher", r.Ciphers},
+		{"mac", "MAC", r.MACs},
+	} {
+		for _, severity := range []string{"critical", "warning"} {
+			var names []string
+			for _, a := range group.algos {
+				if a.Severity == severity {
+					names = append(names, fmt.Sprintf("%s (%s)", a.Name, a.Note))
+				}
+			}
+			if len(names) > 0 {
+				add(group.id+".weak", severity, "weak %s offered: %s", group.what, strings.Join(names, ", "))
+			}
+		}
+	}
+	for _, hk := range r.HostKeys {
+		if hk.Key != nil && strings.Contains(hk.Key.Type, "rsa") && hk.Key.Bits < 2048 {
+			add("hostkey.rsa_size", "critical", "%d-bit RSA host key", hk.Key.Bits)
+		}
+	}
+
+	// Terrapin (CVE-2023-48795) is practical against ChaCha20-Poly1305 and against CBC
+	// ciphers with EtM MACs; strict key exchange closes it.
+	strict := false
+	for _, k := range r.KexAlgorithms {
+		strict = strict || k.Name == "kex-strict-s-v00@openssh.com"
+	}
+	var exposed []string
+	cbc, etm := false, false
+	for _, c := range r.Ciphers {
+		if c.Name == "chacha20-poly1305@openssh.com" {
+			exposed = append(exposed, c.Name)
+		}
+		cbc = cbc || strings.HasSuffix(c.Name, "-cbc") || strings.HasSuffix(c.Name, "-cbc@lysator.liu.se")
+	}
+	for _, m := range r.MACs {
+		etm = etm || strings.HasSuffix(m.Name, "-etm@openssh.com")
+	}
+	if cbc && etm {
+		exposed = append(exposed, "CBC ciphers with EtM MACs")
+	}
+	if !strict && len(exposed) > 0 {
+		add("terrapin", "warning", "vulnerable to the Terrapin prefix truncation attack (CVE-2023-48795): %s without strict key exchange", strings.Join(exposed, " and "))
+	}
+}
@@ -0,0 +1,225 @@
+// Package sshinspect connects to SSH servers and reports their identification, offered
+// algorithms and host keys without authenticating.
+package sshinspect
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"engtools/backend/internal/sshkey"
+	"golang.org/x/crypto/ssh"
+)
+
+// Options selects the server to scan.
+type Options struct {
+	Host    string
+	Port    int           // default 22
+	Timeout time.Duration // per connection, default 5s
+}
+
+// Result lists what the server offered in its first SSH_MSG_KEXINIT. Ciphers and MACs are
+// the client to server lists; servers offer the same in both directions in practice and
+// the other direction is only reported when it differs.
+type Result struct {
+	Host string `json:"host"`
+	Port int    `json:"port"`
+	IP   string `json:"ip"`
+	// Banner is the identification line, e.g. SSH-2.0-OpenSSH_9.6p1 Ubuntu-3ubuntu13.
+	Banner            string      `json:"banner"`
+	Software          string      `json:"software"`
+	PreBanner         []string    `json:"pre_banner,omitempty"` // text sent before the banner
+	KexAlgorithms     []Algorithm `json:"kex_algorithms"`
+	HostKeyAlgorithms []Algorithm `json:"host_key_algorithms"`
+	Ciphers           []Algorithm `json:"ciphers"`
+	MACs              []Algorithm `json:"macs"`
+	CiphersServer     []Algorithm `json:"ciphers_server_to_client,omitempty"`
+	MACsServer        []Algorithm `json:"macs_server_to_client,omitempty"`
+	Compression       []string    `json:"compression"`
+	HostKeys          []HostKey   `json:"host_keys"`
+	Findings          []Finding   `json:"findings"`
+	Duration          float64     `json:"duration_ms"`
+}
+
+// HostKey is one key the server proved it holds, with the host key algorithms that
+// yielded it. Key is nil when no algorithm could be negotiated to fetch it.
+type HostKey struct {
+	Algorithms []string        `json:"algorithms"`
+	Key        *sshkey.KeyInfo `json:"key,omitempty"`
+	Error      string          `json:"error,omitempty"`
+}
+
+// Every algorithm x/crypto/ssh implements, legacy ones included, so that a handshake
+// with an old server gets as far as the host key.
+var (
+	clientKex = []string{
+		"curve25519-sha256", "curve25519-sha256@libssh.org", "ecdh-sha2-nistp256", "ecdh-sha2-nistp384",
+		"ecdh-sha2-nistp521", "diffie-hellman-group-exchange-sha256", "diffie-hellman-group16-sha512",
+		"diffie-hellman-group14-sha256", "diffie-hellman-group14-sha1", "diffie-hellman-group-exchange-sha1",
+		"diffie-hellman-group1-sha1",
+	}
+	clientCiphers = []string{
+		"aes128-gcm@openssh.com", "aes256-gcm@openssh.com", "chacha20-poly1305@openssh.com", "aes128-ctr",
+		"aes192-ctr", "aes256-ctr", "aes128-cbc", "3des-cbc", "arcfour256", "arcfour128", "arcfour",
+	}
+	clientMACs = []string{
+		"hmac-sha2-256-etm@openssh.com", "hmac-sha2-512-etm@openssh.com", "hmac-sha2-256", "hmac-sha2-512",
+		"hmac-sha1", "hmac-sha1-96",
+	}
+	// keyFormats groups the host key algorithms x/crypto/ssh can verify by the key they
+	// are made with, so each key is fetched once.
+	keyFormats = map[string]string{
+		ssh.KeyAlgoRSASHA512:     ssh.KeyAlgoRSA,
+		ssh.KeyAlgoRSASHA256:     ssh.KeyAlgoRSA,
+		ssh.KeyAlgoRSA:           ssh.KeyAlgoRSA,
+		ssh.KeyAlgoDSA:           ssh.KeyAlgoDSA,
+		ssh.KeyAlgoED25519:       ssh.KeyAlgoED25519,
+		ssh.KeyAlgoECDSA256:      ssh.KeyAlgoECDSA256,
+		ssh.KeyAlgoECDSA384:      ssh.KeyAlgoECDSA384,
+		ssh.KeyAlgoECDSA521:      ssh.KeyAlgoECDSA521,
+		ssh.CertAlgoRSASHA512v01: ssh.CertAlgoRSAv01,
+		ssh.CertAlgoRSASHA256v01: ssh.CertAlgoRSAv01,
+		ssh.CertAlgoRSAv01:       ssh.CertAlgoRSAv01,
+		ssh.CertAlgoDSAv01:       ssh.CertAlgoDSAv01,
+		ssh.CertAlgoED25519v01:   ssh.CertAlgoED25519v01,
+		ssh.CertAlgoECDSA256v01:  ssh.CertAlgoECDSA256v01,
+		ssh.CertAlgoECDSA384v01:  ssh.CertAlgoECDSA384v01,
+		ssh.CertAlgoECDSA521v01:  ssh.CertAlgoECDSA521v01,
+	}
+)
+
+// errCollected aborts a handshake once the host key is in hand.
+var errCollected = errors.New("host key collected")
+
+func (o Options) withDefaults() Options {
+	if o.Port == 0 {
+		o.Port = 22
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = 5 * time.Second
+	}
+	return o
+}
+
+// Scan reads the server's identification and KEXINIT, then runs one partial handshake
+// per host key type to collect every key. Nothing is authenticated.
+func Scan(ctx context.Context, opts Options) (*Result, error) {
+	opts = opts.withDefaults()
+	if opts.Host == "" {
+		return nil, errors.New("host required")
+	}
+	start := time.Now()
+	res := &Result{Host: opts.Host, Port: opts.Port, HostKeys: []HostKey{}, Findings: []Finding{}}
+	d := &net.Dialer{Timeout: opts.Timeout}
+	conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(opts.Host, strconv.Itoa(opts.Port)))
+	if err != nil {
+		return nil, err
+	}
+	// later connections go to the same address
+	addr := conn.RemoteAddr().String()
+	res.IP, _, _ = net.SplitHostPort(addr)
+	kex, err := readKexInit(conn, res, opts.Timeout)
+	conn.Close()
+	if err != nil {
+		return nil, err
+	}
+	if parts := strings.SplitN(res.Banner, "-", 3); len(parts) == 3 {
+		res.Software = parts[2]
+	}
+	res.KexAlgorithms = rateAll(kex.Kex)
+	res.HostKeyAlgorithms = rateAll(kex.HostKey)
+	res.Ciphers, res.MACs = rateAll(kex.CiphersCS), rateAll(kex.MACsCS)
+	if strings.Join(kex.CiphersSC, ",") != strings.Join(kex.CiphersCS, ",") {
+		res.CiphersServer = rateAll(kex.CiphersSC)
+	}
+	if strings.Join(kex.MACsSC, ",") != strings.Join(kex.MACsCS, ",") {
+		res.MACsServer = rateAll(kex.MACsSC)
+	}
+	res.Compression = kex.CompressCS
+
+	// one probe per key format, asking for the first algorithm the server listed
+	var formats []string
+	byFormat := map[string][]string{}
+	for _, alg := range kex.HostKey {
+		f, ok := keyFormats[alg]
+		if !ok {
+			f = alg // unsupported here; reported without a key
+		}
+		if _, seen := byFormat[f]; !seen {
+			formats = append(formats, f)
+		}
+		byFormat[f] = append(byFormat[f], alg)
+	}
+	for _, f := range formats {
+		algs := byFormat[f]
+		hk := HostKey{Algorithms: algs}
+		if _, ok := keyFormats[algs[0]]; !ok {
+			hk.Error = "algorithm not supported by the scanner"
+		} else if key, err := fetchHostKey(ctx, addr, algs[0], opts.Timeout); err != nil {
+			hk.Error = err.Error()
+		} else {
+			info := sshkey.Describe(key, "")
+			hk.Key = &info
+		}
+		res.HostKeys = append(res.HostKeys, hk)
+	}
+	assess(res)
+	res.Duration = float64(time.Since(start).Microseconds()) / 1000
+	return res, nil
+}
+
+// readKexInit exchanges identifications and reads the server's first packet.
+func readKexInit(conn net.Conn, res *Result, timeout time.Duration) (*kexInit, error) {
+	conn.SetDeadline(time.Now().Add(timeout))
+	if _, err := conn.Write([]byte(clientIdent)); err != nil {
+		return nil, err
+	}
+	r := bufio.NewReader(conn)
+	ident, pre, err := readIdent(r)
+	res.PreBanner = pre
+	if err != nil {
+		return nil, err
+	}
+	res.Banner = ident
+	if !strings.HasPrefix(ident, "SSH-2.0-") && !strings.HasPrefix(ident, "SSH-1.99-") {
+		return nil, errors.New("server does not speak SSH 2: " + ident)
+	}
+	payload, err := readPacket(r)
+	if err != nil {
+		return nil, err
+	}
+	return parseKexInit(payload)
+}
+
+// fetchHostKey handshakes far enough for the server to sign with its alg host key.
+func fetchHostKey(ctx context.Context, addr, alg string, timeout time.Duration) (ssh.PublicKey, error) {
+	d := &net.Dialer{Timeout: timeout}
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+	var key ssh.PublicKey
+	cfg := &ssh.ClientConfig{
+		User:              "engtools-scan",
+		HostKeyAlgorithms: []string{alg},
+		HostKeyCallback: func(_ string, _ net.Addr, k ssh.PublicKey) error {
+			key = k
+			return errCollected
+		},
+		Config: ssh.Config{KeyExchanges: clientKex, Ciphers: clientCiphers, MACs: clientMACs},
+	}
+	_, _, _, err = ssh.NewClientConn(conn, addr, cfg)
+	if key != nil {
+		return key, nil
+	}
+	if err == nil {
+		err = errors.New("no host key received")
+	}
+	return nil, err
+}
@@ -0,0 +1,85 @@
+package sshinspect
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"engtools/backend/internal/pki"
+	"golang.org/x/crypto/ssh"
+)
+
+// testServer accepts connections with x/crypto/ssh and the given host keys until the
+// listener closes.
+func testServer(t *testing.T, cfg *ssh.ServerConfig) (string, int) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				ssh.NewServerConn(conn, cfg)
+			}()
+		}
+	}()
+	a := ln.Addr().(*net.TCPAddr)
+	return a.IP.String(), a.Port
+}
+
+func TestScan(t *testing.T) {
+	cfg := &ssh.ServerConfig{
+		NoClientAuth:  true,
+		ServerVersion: "SSH-2.0-TestSSH_1.0",
+		Config:        ssh.Config{Ciphers: []string{"aes128-ctr", "aes128-cbc"}, MACs: []string{"hmac-sha2-256-etm@openssh.com", "hmac-sha1"}},
+	}
+	var want []string
+	for _, typ := range []string{"ed25519", "rsa"} {
+		key, _ := pki.GenerateKey(typ, 2048, "")
+		signer, err := ssh.NewSignerFromSigner(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg.AddHostKey(signer)
+		want = append(want, ssh.FingerprintSHA256(signer.PublicKey()))
+	}
+	host, port := testServer(t, cfg)
+
+	res, err := Scan(context.Background(), Options{Host: host, Port: port})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Banner != "SSH-2.0-TestSSH_1.0" || res.Software != "TestSSH_1.0" {
+		t.Errorf("banner %q, software %q", res.Banner, res.Software)
+	}
+	got := map[string][]string{}
+	for _, hk := range res.HostKeys {
+		if hk.Key == nil {
+			t.Fatalf("no key for %v: %s", hk.Algorithms, hk.Error)
+		}
+		got[hk.Key.SHA256] = hk.Algorithms
+	}
+	if len(got) != 2 || got[want[0]] == nil || len(got[want[1]]) != 3 {
+		t.Errorf("host keys %v, want %v", got, want)
+	}
+	findings := map[string]string{}
+	for _, f := range res.Findings {
+		findings[f.ID] = f.Severity
+	}
+	for _, id := range []string{"hostkey.weak", "cipher.weak", "mac.weak"} { // ssh-rsa, CBC, hmac-sha1
+		if findings[id] != "warning" {
+			t.Errorf("finding %s: %q in %+v", id, findings[id], res.Findings)
+		}
+	}
+	// CBC with EtM would be open to Terrapin, but x/crypto/ssh offers strict kex
+	if _, ok := findings["terrapin"]; ok {
+		t.Errorf("terrapin reported despite strict kex: %+v", res.KexAlgorithms)
+	}
+}
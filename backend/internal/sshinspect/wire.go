@@ -0,0 +1,94 @@
+package sshinspect
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	msgKexInit = 20
+	// maxPacket is the largest packet RFC 4253 requires implementations to accept.
+	maxPacket = 35000
+	// maxPreBanner bounds the lines a server may send before its identification.
+	maxPreBanner = 32
+)
+
+// clientIdent is sent before the server's KEXINIT is read.
+const clientIdent = "SSH-2.0-engtools_scan\r\n"
+
+// readIdent reads the server's identification line, SSH-protoversion-softwareversion,
+// and any text lines sent before it (RFC 4253 section 4.2).
+func readIdent(r *bufio.Reader) (ident string, pre []string, err error) {
+	for len(pre) <= maxPreBanner {
+		line, err := r.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			return "", pre, fmt.Errorf("no SSH identification received: %w", err)
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if strings.HasPrefix(line, "SSH-") {
+			return line, pre, nil
+		}
+		pre = append(pre, line)
+	}
+	return "", pre, errors.New("no SSH identification received")
+}
+
+// kexInit holds the algorithm name-lists of an SSH_MSG_KEXINIT.
+type kexInit struct {
+	Kex, HostKey           []string
+	CiphersCS, CiphersSC   []string
+	MACsCS, MACsSC         []string
+	CompressCS, CompressSC []string
+	FirstKexFollows        bool
+}
+
+// readPacket reads one unencrypted binary packet and returns its payload.
+func readPacket(r io.Reader) ([]byte, error) {
+	var hdr [5]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return nil, err
+	}
+	length, padding := binary.BigEndian.Uint32(hdr[:4]), int(hdr[4])
+	if length < 5 || length > maxPacket {
+		return nil, fmt.Errorf("invalid packet length %d", length)
+	}
+	body := make([]byte, length-1)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+	if padding >= len(body) {
+		return nil, errors.New("invalid packet padding")
+	}
+	return body[:len(body)-padding], nil
+}
+
+func parseKexInit(payload []byte) (*kexInit, error) {
+	if len(payload) < 17 || payload[0] != msgKexInit {
+		return nil, errors.New("expected SSH_MSG_KEXINIT")
+	}
+	b := payload[17:] // message type and cookie
+	lists := make([][]string, 10)
+	for i := range lists {
+		if len(b) < 4 {
+			return nil, errors.New("truncated SSH_MSG_KEXINIT")
+		}
+		n := binary.BigEndian.Uint32(b)
+		if uint64(n) > uint64(len(b)-4) {
+			return nil, errors.New("truncated SSH_MSG_KEXINIT")
+		}
+		if n > 0 {
+			lists[i] = strings.Split(string(b[4:4+n]), ",")
+		}
+		b = b[4+n:]
+	}
+	k := &kexInit{
+		Kex: lists[0], HostKey: lists[1], CiphersCS: lists[2], CiphersSC: lists[3],
+		MACsCS: lists[4], MACsSC: lists[5], CompressCS: lists[6], CompressSC: lists[7],
+	}
+	k.FirstKexFollows = len(b) > 0 && b[0] != 0
+	return k, nil
+}
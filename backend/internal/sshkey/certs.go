@@ -0,0 +1,210 @@
+package sshkey
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"maps"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// defaultUserExtensions are the permissions ssh-keygen grants user certificates.
+var defaultUserExtensions = map[string]string{
+	"permit-X11-forwarding":   "",
+	"permit-agent-forwarding": "",
+	"permit-port-forwarding":  "",
+	"permit-pty":              "",
+	"permit-user-rc":          "",
+}
+
+// CertInfo describes an OpenSSH certificate.
+type CertInfo struct {
+	Type            string            `json:"type"` // user or host
+	KeyID           string            `json:"key_id"`
+	Serial          uint64            `json:"serial"`
+	Principals      []string          `json:"principals"` // empty means any
+	ValidAfter      *time.Time        `json:"valid_after,omitempty"`
+	ValidBefore     *time.Time        `json:"valid_before,omitempty"` // nil is forever
+	Status          string            `json:"status"`                 // valid, expired or not_yet_valid
+	CriticalOptions map[string]string `json:"critical_options"`
+	Extensions      map[string]string `json:"extensions"`
+	Key             string            `json:"key"` // certified key, type and SHA256 fingerprint
+	SignatureKey    string            `json:"signature_key"`
+	SignatureAlg    string            `json:"signature_alg"`
+	SignatureValid  bool              `json:"signature_valid"`
+}
+
+func certTime(v uint64) *time.Time {
+	if v == 0 || v == ssh.CertTimeInfinity {
+		return nil
+	}
+	t := time.Unix(int64(v), 0).UTC()
+	return &t
+}
+
+// NewCertInfo describes cert and checks its signature against the embedded CA key.
+func NewCertInfo(cert *ssh.Certificate) CertInfo {
+	info := CertInfo{
+		Type: "user", KeyID: cert.KeyId, Serial: cert.Serial, Principals: cert.ValidPrincipals,
+		ValidAfter: certTime(cert.ValidAfter), ValidBefore: certTime(cert.ValidBefore), Status: "valid",
+		CriticalOptions: cert.CriticalOptions, Extensions: cert.Extensions,
+		Key:          cert.Key.Type() + " " + ssh.FingerprintSHA256(cert.Key),
+		SignatureKey: cert.SignatureKey.Type() + " " + ssh.FingerprintSHA256(cert.SignatureKey),
+	}
+	if cert.CertType == ssh.HostCert {
+		info.Type = "host"
+	}
+	if info.Principals == nil {
+		info.Principals = []string{}
+	}
+	if info.CriticalOptions == nil {
+		info.CriticalOptions = map[string]string{}
+	}
+	if info.Extensions == nil {
+		info.Extensions = map[string]string{}
+	}
+	now := time.Now()
+	switch {
+	case info.ValidAfter != nil && now.Before(*info.ValidAfter):
+		info.Status = "not_yet_valid"
+	case info.ValidBefore != nil && !now.Before(*info.ValidBefore):
+		info.Status = "expired"
+	}
+	if cert.Signature != nil {
+		info.SignatureAlg = cert.Signature.Format
+		info.SignatureValid = cert.SignatureKey.Verify(signedBytes(cert), cert.Signature) == nil
+	}
+	return info
+}
+
+// signedBytes is the certificate without its signature, the part the CA signs.
+func signedBytes(cert *ssh.Certificate) []byte {
+	c := *cert
+	c.Signature = nil
+	out := c.Marshal()
+	return out[:len(out)-4] // drop the empty signature's length prefix
+}
+
+// ParseCertificate reads a certificate in authorized_keys form, e.g. id_ed25519-cert.pub.
+func ParseCertificate(s string) (*ssh.Certificate, string, error) {
+	pub, comment, err := ParsePublicKey(s)
+	if err != nil {
+		return nil, "", err
+	}
+	cert, ok := pub.(*ssh.Certificate)
+	if !ok {
+		return nil, "", errors.New("public key, not a certificate")
+	}
+	return cert, comment, nil
+}
+
+// CertSpec are the fields a CA sets when signing, as in ssh-keygen -s.
+type CertSpec struct {
+	Type       string   `json:"type"` // user (default) or host
+	KeyID      string   `json:"key_id"`
+	Serial     uint64   `json:"serial"`
+	Principals []string `json:"principals"`
+	// ValidAfter defaults to now minus a minute for clock skew. ValidFor is a duration
+	// such as 24h or 52w; zero with no ValidBefore means forever.
+	ValidAfter  *time.Time `json:"valid_after"`
+	ValidBefore *time.Time `json:"valid_before"`
+	ValidFor    string     `json:"valid_for"`
+	// CriticalOptions such as force-command and source-address.
+	CriticalOptions map[string]string `json:"critical_options"`
+	// Extensions default to the usual permit-* set for user certificates.
+	Extensions map[string]string `json:"extensions"`
+}
+
+// knownCriticalOptions are those OpenSSH understands; it refuses certificates with others.
+var knownCriticalOptions = map[string]bool{"force-command": true, "source-address": true, "verify-required": true}
+
+// parseValidFor accepts Go durations plus ssh-keygen's d and w suffixes.
+func parseValidFor(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if n, ok := strings.CutSuffix(s, suffix); ok {
+			v, err := strconv.Atoi(n)
+			if err != nil || v <= 0 {
+				return 0, fmt.Errorf("invalid validity %q", s)
+			}
+			return time.Duration(v) * unit, nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid validity %q", s)
+	}
+	return d, nil
+}
+
+// Sign issues a certificate for pub signed by ca. RSA CAs sign with rsa-sha2-512.
+func Sign(pub ssh.PublicKey, ca ssh.Signer, spec CertSpec) (*ssh.Certificate, error) {
+	if _, ok := pub.(*ssh.Certificate); ok {
+		return nil, errors.New("cannot certify a certificate")
+	}
+	cert := &ssh.Certificate{
+		Key: pub, Serial: spec.Serial, KeyId: spec.KeyID, ValidPrincipals: spec.Principals, CertType: ssh.UserCert,
+		Permissions: ssh.Permissions{CriticalOptions: spec.CriticalOptions, Extensions: spec.Extensions},
+	}
+	switch strings.ToLower(spec.Type) {
+	case "", "user":
+		if cert.Extensions == nil {
+			cert.Extensions = maps.Clone(defaultUserExtensions)
+		}
+	case "host":
+		cert.CertType = ssh.HostCert
+		if len(spec.CriticalOptions) > 0 || len(spec.Extensions) > 0 {
+			return nil, errors.New("host certificates carry no critical options or extensions")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported certificate type %q", spec.Type)
+	}
+	var unknown []string
+	for name := range spec.CriticalOptions {
+		if !knownCriticalOptions[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("unknown critical options: %s", strings.Join(unknown, ", "))
+	}
+
+	after := time.Now().Add(-time.Minute)
+	if spec.ValidAfter != nil {
+		after = *spec.ValidAfter
+	}
+	cert.ValidAfter, cert.ValidBefore = uint64(after.Unix()), ssh.CertTimeInfinity
+	switch {
+	case spec.ValidBefore != nil && spec.ValidFor != "":
+		return nil, errors.New("set valid_before or valid_for, not both")
+	case spec.ValidBefore != nil:
+		cert.ValidBefore = uint64(spec.ValidBefore.Unix())
+	case spec.ValidFor != "":
+		d, err := parseValidFor(spec.ValidFor)
+		if err != nil {
+			return nil, err
+		}
+		cert.ValidBefore = uint64(after.Add(d).Unix())
+	}
+	if cert.ValidBefore <= cert.ValidAfter {
+		return nil, errors.New("valid_before must be after valid_after")
+	}
+	if as, ok := ca.(ssh.AlgorithmSigner); ok && ca.PublicKey().Type() == ssh.KeyAlgoRSA {
+		// as ssh-keygen does; signers from ParsePrivateKey would pick rsa-sha2-256
+		signer, err := ssh.NewSignerWithAlgorithms(as, []string{ssh.KeyAlgoRSASHA512})
+		if err != nil {
+			return nil, err
+		}
+		ca = signer
+	}
+	if err := cert.SignCert(rand.Reader, ca); err != nil {
+		return nil, err
+	}
+	return cert, nil
+}
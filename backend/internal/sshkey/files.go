@@ -0,0 +1,149 @@
+package sshkey
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// AuthorizedKey is one line of an authorized_keys file.
+type AuthorizedKey struct {
+	Line    int      `json:"line"`
+	Options []string `json:"options,omitempty"` // e.g. from="10.0.0.0/8", no-pty
+	Key     *KeyInfo `json:"key,omitempty"`
+	Error   string   `json:"error,omitempty"`
+}
+
+// ParseAuthorizedKeys parses every non-comment line, reporting bad lines in place.
+func ParseAuthorizedKeys(text string) []AuthorizedKey {
+	out := []AuthorizedKey{}
+	for i, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		entry := AuthorizedKey{Line: i + 1}
+		pub, comment, options, _, err := ssh.ParseAuthorizedKey([]byte(line))
+		if err != nil {
+			entry.Error = err.Error()
+		} else {
+			info := Describe(pub, comment)
+			entry.Options, entry.Key = options, &info
+		}
+		out = append(out, entry)
+	}
+	return out
+}
+
+// KnownHost is one line of a known_hosts file.
+type KnownHost struct {
+	Line   int      `json:"line"`
+	Marker string   `json:"marker,omitempty"` // cert-authority or revoked
+	Hosts  []string `json:"hosts"`
+	Hashed bool     `json:"hashed"`
+	// Matches says whether the entry applies to the host that was asked about.
+	Matches *bool    `json:"matches,omitempty"`
+	Key     *KeyInfo `json:"key,omitempty"`
+	Error   string   `json:"error,omitempty"`
+}
+
+// ParseKnownHosts parses every non-comment line. When host (name or name:port) is given,
+// each entry says whether it applies to it, hashed names included.
+func ParseKnownHosts(text, host string) []KnownHost {
+	if host != "" {
+		// name:port becomes [name]:port unless port 22; ssh lower-cases the name before
+		// hashing it
+		host = strings.ToLower(knownhosts.Normalize(host))
+	}
+	out := []KnownHost{}
+	for i, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		entry := KnownHost{Line: i + 1, Hosts: []string{}}
+		marker, hosts, pub, comment, _, err := ssh.ParseKnownHosts([]byte(line))
+		if err != nil {
+			entry.Error = err.Error()
+			out = append(out, entry)
+			continue
+		}
+		info := Describe(pub, comment)
+		entry.Marker, entry.Hosts, entry.Key = marker, hosts, &info
+		for _, h := range hosts {
+			entry.Hashed = entry.Hashed || strings.HasPrefix(h, "|1|")
+		}
+		if host != "" {
+			m := hostsMatch(hosts, host)
+			entry.Matches = &m
+		}
+		out = append(out, entry)
+	}
+	return out
+}
+
+// hostsMatch applies a known_hosts host list to a normalized address: any positive
+// pattern or hash must match and no negated pattern may.
+func hostsMatch(patterns []string, addr string) bool {
+	matched := false
+	for _, p := range patterns {
+		if salt, hash, ok := hashedHost(p); ok {
+			mac := hmac.New(sha1.New, salt)
+			mac.Write([]byte(addr))
+			matched = matched || hmac.Equal(mac.Sum(nil), hash)
+			continue
+		}
+		negate := strings.HasPrefix(p, "!")
+		p = strings.TrimPrefix(p, "!")
+		if !wildcardMatch(p, addr) {
+			continue
+		}
+		if negate {
+			return false
+		}
+		matched = true
+	}
+	return matched
+}
+
+// hashedHost splits a HashKnownHosts entry, |1|base64 salt|base64 hash.
+func hashedHost(s string) (salt, hash []byte, ok bool) {
+	parts := strings.Split(s, "|")
+	if len(parts) != 4 || parts[0] != "" || parts[1] != "1" {
+		return nil, nil, false
+	}
+	salt, err1 := base64.StdEncoding.DecodeString(parts[2])
+	hash, err2 := base64.StdEncoding.DecodeString(parts[3])
+	return salt, hash, err1 == nil && err2 == nil
+}
+
+// wildcardMatch matches * and ? the way OpenSSH host patterns do, ignoring case. On a
+// mismatch it only backtracks to the most recent *, so it runs in O(len(pat)*len(s)).
+func wildcardMatch(pat, s string) bool {
+	pat, s = strings.ToLower(pat), strings.ToLower(s)
+	pi, si := 0, 0
+	star, mark := -1, 0
+	for si < len(s) {
+		switch {
+		case pi < len(pat) && pat[pi] == '*':
+			star, mark = pi, si
+			pi++
+		case pi < len(pat) && (pat[pi] == '?' || pat[pi] == s[si]):
+			pi++
+			si++
+		case star >= 0:
+			mark++
+			pi, si = star+1, mark
+		default:
+			return false
+		}
+	}
+	for pi < len(pat) && pat[pi] == '*' {
+		pi++
+	}
+	return pi == len(pat)
+}
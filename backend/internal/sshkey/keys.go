@@ -0,0 +1,193 @@
+// Package sshkey handles OpenSSH key material: key pairs, authorized_keys and known_hosts
+// entries, fingerprints and certificates.
+package sshkey
+
+import (
+	"crypto"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+
+	"engtools/backend/internal/pki"
+	"golang.org/x/crypto/ssh"
+)
+
+// ErrPassphraseRequired is returned for an encrypted private key given without a passphrase.
+var ErrPassphraseRequired = errors.New("private key is encrypted, passphrase required")
+
+// maxKDFRounds bounds the bcrypt rounds of an uploaded OpenSSH key. ssh-keygen writes 16
+// by default and -a 100 is the usual hardening advice; each round costs about one bcrypt
+// hash.
+const maxKDFRounds = 1024
+
+// KeyInfo describes a public key. For certificates the fingerprints and randomart are of
+// the certified key, as ssh-keygen -l prints them.
+type KeyInfo struct {
+	Type    string `json:"type"` // ssh-ed25519, ecdsa-sha2-nistp256, ssh-rsa or a certificate type
+	Bits    int    `json:"bits"`
+	Comment string `json:"comment,omitempty"`
+	SHA256  string `json:"fingerprint_sha256"`
+	MD5     string `json:"fingerprint_md5"`
+	// Randomart is the ssh-keygen -lv picture of the SHA256 fingerprint.
+	Randomart string `json:"randomart"`
+	// AuthorizedKey is the key as one authorized_keys line.
+	AuthorizedKey string    `json:"authorized_key"`
+	Certificate   *CertInfo `json:"certificate,omitempty"`
+}
+
+// Describe fingerprints pub.
+func Describe(pub ssh.PublicKey, comment string) KeyInfo {
+	info := KeyInfo{Type: pub.Type(), Comment: comment}
+	line := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(pub)))
+	if comment != "" {
+		line += " " + comment
+	}
+	info.AuthorizedKey = line
+	plain := pub
+	if cert, ok := pub.(*ssh.Certificate); ok {
+		plain = cert.Key
+		ci := NewCertInfo(cert)
+		info.Certificate = &ci
+	}
+	info.Bits = keyBits(plain)
+	info.SHA256 = ssh.FingerprintSHA256(plain)
+	info.MD5 = "MD5:" + ssh.FingerprintLegacyMD5(plain)
+	info.Randomart = Randomart(plain)
+	return info
+}
+
+func keyBits(pub ssh.PublicKey) int {
+	if cp, ok := pub.(ssh.CryptoPublicKey); ok {
+		_, bits, _ := pki.KeyDescription(cp.CryptoPublicKey())
+		return bits
+	}
+	return 0
+}
+
+// ParsePublicKey accepts an authorized_keys style line or a bare base64 key blob.
+func ParsePublicKey(s string) (ssh.PublicKey, string, error) {
+	s = strings.TrimSpace(s)
+	if pub, comment, _, _, err := ssh.ParseAuthorizedKey([]byte(s)); err == nil {
+		return pub, comment, nil
+	}
+	if blob, err := base64.StdEncoding.DecodeString(s); err == nil {
+		if pub, err := ssh.ParsePublicKey(blob); err == nil {
+			return pub, "", nil
+		}
+	}
+	return nil, "", errors.New("not an OpenSSH public key")
+}
+
+// ParsePrivateKey reads OpenSSH, PKCS#1, PKCS#8 and SEC1 private keys, decrypting them with
+// passphrase when they are encrypted.
+func ParsePrivateKey(s, passphrase string) (ssh.Signer, error) {
+	if err := checkKDFRounds(s); err != nil {
+		return nil, err
+	}
+	var signer ssh.Signer
+	var err error
+	if passphrase != "" {
+		signer, err = ssh.ParsePrivateKeyWithPassphrase([]byte(s), []byte(passphrase))
+	} else {
+		signer, err = ssh.ParsePrivateKey([]byte(s))
+	}
+	var missing *ssh.PassphraseMissingError
+	if errors.As(err, &missing) {
+		return nil, ErrPassphraseRequired
+	}
+	return signer, err
+}
+
+// checkKDFRounds rejects an openssh-key-v1 key whose bcrypt KDF asks for more than
+// maxKDFRounds, before x/crypto runs it. Anything it cannot parse is left to x/crypto.
+func checkKDFRounds(s string) error {
+	block, _ := pem.Decode([]byte(s))
+	const magic = "openssh-key-v1\x00"
+	if block == nil || block.Type != "OPENSSH PRIVATE KEY" || !strings.HasPrefix(string(block.Bytes), magic) {
+		return nil
+	}
+	var key struct {
+		CipherName string
+		KDFName    string
+		KDFOpts    string
+		Rest       []byte `ssh:"rest"`
+	}
+	if err := ssh.Unmarshal(block.Bytes[len(magic):], &key); err != nil || key.KDFName != "bcrypt" {
+		return nil
+	}
+	var opts struct {
+		Salt   string
+		Rounds uint32
+	}
+	if err := ssh.Unmarshal([]byte(key.KDFOpts), &opts); err != nil {
+		return nil
+	}
+	if opts.Rounds > maxKDFRounds {
+		return fmt.Errorf("private key asks for %d bcrypt KDF rounds, at most %d are accepted", opts.Rounds, maxKDFRounds)
+	}
+	return nil
+}
+
+// GenerateSpec selects the key to generate. Bits is the RSA size (default 3072) or the
+// ECDSA curve size, 256 (default), 384 or 521.
+type GenerateSpec struct {
+	Type       string `json:"type"` // ed25519 (default), ecdsa or rsa
+	Bits       int    `json:"bits"`
+	Comment    string `json:"comment"`
+	Passphrase string `json:"passphrase"` // encrypts the private key (bcrypt KDF, aes256-ctr)
+}
+
+type KeyPair struct {
+	KeyInfo
+	PrivateKey string `json:"private_key"` // OpenSSH format
+	Encrypted  bool   `json:"encrypted"`
+}
+
+// Generate creates a key pair in the formats ssh-keygen writes.
+func Generate(spec GenerateSpec) (*KeyPair, error) {
+	var key crypto.Signer
+	var err error
+	switch strings.ToLower(spec.Type) {
+	case "", "ed25519":
+		key, err = pki.GenerateKey("ed25519", 0, "")
+	case "ecdsa", "ec":
+		if spec.Bits == 0 {
+			spec.Bits = 256
+		}
+		if spec.Bits != 256 && spec.Bits != 384 && spec.Bits != 521 {
+			return nil, errors.New("ecdsa bits must be 256, 384 or 521")
+		}
+		key, err = pki.GenerateKey("ec", 0, fmt.Sprintf("P%d", spec.Bits))
+	case "rsa":
+		if spec.Bits == 0 {
+			spec.Bits = 3072
+		}
+		if spec.Bits < 2048 {
+			return nil, errors.New("rsa keys below 2048 bits are not generated")
+		}
+		key, err = pki.GenerateKey("rsa", spec.Bits, "")
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", spec.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var block *pem.Block
+	if spec.Passphrase != "" {
+		block, err = ssh.MarshalPrivateKeyWithPassphrase(key, spec.Comment, []byte(spec.Passphrase))
+	} else {
+		block, err = ssh.MarshalPrivateKey(key, spec.Comment)
+	}
+	if err != nil {
+		return nil, err
+	}
+	pub, err := ssh.NewPublicKey(key.Public())
+	if err != nil {
+		return nil, err
+	}
+	return &KeyPair{
+		KeyInfo: Describe(pub, spec.Comment), PrivateKey: string(pem.EncodeToMemory(block)), Encrypted: spec.Passphrase != "",
+	}, nil
+}
@@ -0,0 +1,89 @@
+package sshkey
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// The randomart field and symbols of OpenSSH's sshkey_fingerprint_randomart.
+const (
+	artWidth   = 17
+	artHeight  = 9
+	artSymbols = " .o+=*BOX@%&#/^SE"
+)
+
+// artTypes are the key names ssh-keygen prints in the randomart header.
+var artTypes = map[string]string{
+	ssh.KeyAlgoRSA:        "RSA",
+	ssh.KeyAlgoDSA:        "DSA",
+	ssh.KeyAlgoECDSA256:   "ECDSA",
+	ssh.KeyAlgoECDSA384:   "ECDSA",
+	ssh.KeyAlgoECDSA521:   "ECDSA",
+	ssh.KeyAlgoED25519:    "ED25519",
+	ssh.KeyAlgoSKECDSA256: "ECDSA-SK",
+	ssh.KeyAlgoSKED25519:  "ED25519-SK",
+}
+
+// Randomart draws the SHA256 fingerprint of pub the way ssh-keygen -lv does: a "drunken
+// bishop" walks the field two bits at a time and each square shows how often it was
+// visited.
+func Randomart(pub ssh.PublicKey) string {
+	sum := sha256.Sum256(pub.Marshal())
+	var field [artWidth][artHeight]int
+	x, y := artWidth/2, artHeight/2
+	maxVisits := len(artSymbols) - 3 // the last two symbols mark start and end
+	for _, b := range sum {
+		for i := 0; i < 4; i++ {
+			if b&1 != 0 {
+				x++
+			} else {
+				x--
+			}
+			if b&2 != 0 {
+				y++
+			} else {
+				y--
+			}
+			x = min(max(x, 0), artWidth-1)
+			y = min(max(y, 0), artHeight-1)
+			if field[x][y] < maxVisits {
+				field[x][y]++
+			}
+			b >>= 2
+		}
+	}
+	field[artWidth/2][artHeight/2] = len(artSymbols) - 2
+	field[x][y] = len(artSymbols) - 1
+
+	typ := artTypes[pub.Type()]
+	if typ == "" {
+		typ = strings.ToUpper(pub.Type())
+	}
+	var sb strings.Builder
+	title := fmt.Sprintf("[%s %d]", typ, keyBits(pub))
+	if len(title) > artWidth {
+		title = "[" + typ + "]"
+	}
+	sb.WriteString(artBorder(title))
+	for y := 0; y < artHeight; y++ {
+		sb.WriteByte('|')
+		for x := 0; x < artWidth; x++ {
+			sb.WriteByte(artSymbols[field[x][y]])
+		}
+		sb.WriteString("|\n")
+	}
+	sb.WriteString(artBorder("[SHA256]"))
+	return sb.String()
+}
+
+// artBorder centres title in a border line.
+func artBorder(title string) string {
+	if len(title) > artWidth {
+		title = title[:artWidth]
+	}
+	pad := (artWidth - len(title)) / 2
+	return "+" + strings.Repeat("-", pad) + title + strings.Repeat("-", artWidth-pad-len(title)) + "+\n"
+}
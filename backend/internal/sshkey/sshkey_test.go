@@ -0,0 +1,160 @@
+package sshkey
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/pem"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+const testKey = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIEXkBcRZ1hZzG+C5quhNKZDU1wkQsgY8ug0j4WQTEaP8 me@x"
+
+// as printed by ssh-keygen -lv
+const testRandomart = `+--[ED25519 256]--+
+|   E =.    .=++o |
+|    = ..   .+B...|
+|   .    . +.=.= .|
+|         = oo* * |
+|        S   +.* *|
+|           o . =o|
+|            o ...|
+|             Oo.o|
+|            +o*=.|
++----[SHA256]-----+
+`
+
+func TestDescribe(t *testing.T) {
+	pub, comment, err := ParsePublicKey(testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := Describe(pub, comment)
+	if info.SHA256 != "SHA256:pVUznD5RE9K9Tvsup/hs1QFLuj/WYtDNMZhjpAigZSM" || info.MD5 != "MD5:4b:9e:69:cb:d0:57:da:20:8b:3f:c0:60:f4:0d:e6:4f" {
+		t.Errorf("fingerprints %s %s", info.SHA256, info.MD5)
+	}
+	if info.Randomart != testRandomart {
+		t.Errorf("randomart\n%s", info.Randomart)
+	}
+	if info.AuthorizedKey != testKey || info.Bits != 256 {
+		t.Errorf("%+v", info)
+	}
+}
+
+func TestSign(t *testing.T) {
+	ca, err := Generate(GenerateSpec{Type: "rsa", Bits: 2048, Passphrase: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParsePrivateKey(ca.PrivateKey, ""); err != ErrPassphraseRequired {
+		t.Fatalf("encrypted key without passphrase: %v", err)
+	}
+	signer, err := ParsePrivateKey(ca.PrivateKey, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, _, _ := ParsePublicKey(testKey)
+	cert, err := Sign(pub, signer, CertSpec{KeyID: "alice", Principals: []string{"alice"}, ValidFor: "1d",
+		CriticalOptions: map[string]string{"force-command": "/bin/true"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := NewCertInfo(cert)
+	if !info.SignatureValid || info.SignatureAlg != "rsa-sha2-512" || info.Status != "valid" || info.Extensions["permit-pty"] != "" {
+		t.Errorf("%+v", info)
+	}
+	if info.ValidBefore.Sub(*info.ValidAfter).Hours() != 24 {
+		t.Errorf("validity %v to %v", info.ValidAfter, info.ValidBefore)
+	}
+	cert.KeyId = "mallory"
+	if NewCertInfo(cert).SignatureValid {
+		t.Error("tampered certificate verified")
+	}
+	if _, err := Sign(pub, signer, CertSpec{CriticalOptions: map[string]string{"bogus": ""}}); err == nil {
+		t.Error("unknown critical option accepted")
+	}
+}
+
+func TestParsePrivateKeyKDFRounds(t *testing.T) {
+	kp, err := Generate(GenerateSpec{Passphrase: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// kdfoptions follow "bcrypt": length, salt length, salt, rounds
+	block, _ := pem.Decode([]byte(kp.PrivateKey))
+	i := bytes.Index(block.Bytes, []byte("bcrypt")) + len("bcrypt") + 4
+	i += 4 + int(binary.BigEndian.Uint32(block.Bytes[i:]))
+	if got := binary.BigEndian.Uint32(block.Bytes[i:]); got != 16 {
+		t.Fatalf("generated key has %d rounds", got)
+	}
+	binary.BigEndian.PutUint32(block.Bytes[i:], 1<<20)
+	start := time.Now()
+	if _, err := ParsePrivateKey(string(pem.EncodeToMemory(block)), "secret"); err == nil || !strings.Contains(err.Error(), "KDF rounds") {
+		t.Fatalf("1M rounds: %v", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("rejecting the key took %v", d)
+	}
+}
+
+func TestParseKnownHosts(t *testing.T) {
+	key := strings.TrimSuffix(testKey, " me@x")
+	text := strings.Join([]string{
+		"# comment",
+		"*.example.com,!bad.example.com " + key,
+		knownhosts.HashHostname("[git.test]:2222") + " " + key,
+		"@cert-authority *.corp " + key,
+		"broken line",
+	}, "\n")
+	entries := ParseKnownHosts(text, "git.test:2222")
+	if len(entries) != 4 || entries[3].Error == "" || entries[3].Line != 5 {
+		t.Fatalf("%+v", entries)
+	}
+	if !entries[1].Hashed || !*entries[1].Matches || *entries[0].Matches || entries[2].Marker != "cert-authority" {
+		t.Errorf("%+v", entries)
+	}
+	if !*ParseKnownHosts(text, "Git.Test:2222")[1].Matches {
+		t.Error("hashed entry should match the host name in any case")
+	}
+	for host, want := range map[string]bool{"www.example.com": true, "WWW.Example.com": true, "bad.example.com": false, "Bad.Example.Com": false, "example.com": false} {
+		if got := *ParseKnownHosts(text, host)[0].Matches; got != want {
+			t.Errorf("%s matched %v", host, got)
+		}
+	}
+}
+
+func TestWildcardMatch(t *testing.T) {
+	for _, tc := range []struct {
+		pat, s string
+		want   bool
+	}{
+		{"*", "", true},
+		{"*.example.com", "www.example.com", true},
+		{"*.example.com", "example.com", false},
+		{"web?.corp", "web1.corp", true},
+		{"web?.corp", "web10.corp", false},
+		{"a*b*c", "axxbyyc", true},
+		{"a*b*c", "axxbyy", false},
+		{"*a*", "bab", true},
+		{"10.0.*.1", "10.0.200.1", true},
+		{"*.Example.COM", "WWW.example.com", true},
+		{"WEB?.corp", "web1.CORP", true},
+	} {
+		if got := wildcardMatch(tc.pat, tc.s); got != tc.want {
+			t.Errorf("%q ~ %q = %v", tc.pat, tc.s, got)
+		}
+	}
+
+	// a backtracking matcher is exponential in the number of stars here
+	pat, host := strings.Repeat("*a", 12)+"b", strings.Repeat("a", 60)
+	start := time.Now()
+	if wildcardMatch(pat, host) {
+		t.Error("pathological pattern matched")
+	}
+	if d := time.Since(start); d > 100*time.Millisecond {
+		t.Errorf("pathological pattern took %v", d)
+	}
+}
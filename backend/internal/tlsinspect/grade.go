@@ -0,0 +1,199 @@
+package tlsinspect
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Finding is one reason behind a scan grade. Cap is the best grade the server can get
+// while the finding stands; T marks a trust problem and is reported separately.
+type Finding struct {
+	ID       string `json:"id"`
+	Severity string `json:"severity"` // critical, warning or info
+	Message  string `json:"message"`
+	Cap      string `json:"cap,omitempty"`
+}
+
+var gradeRank = map[string]int{"A+": 0, "A": 1, "A-": 2, "B": 3, "C": 4, "F": 5}
+
+// minHSTSAge is the max-age an A+ requires, 180 days.
+const minHSTSAge = 180 * 24 * 3600
+
+// assess turns a scan into findings and a grade. The rules loosely follow the SSL Labs
+// server rating guide: protocol and cipher weaknesses cap the grade, an untrusted
+// certificate turns it into T, and A+ needs a long-lived HSTS policy on top of an A.
+func assess(r *ScanResult) {
+	add := func(id, severity, limit, msg string, args ...any) {
+		r.Findings = append(r.Findings, Finding{ID: id, Severity: severity, Message: fmt.Sprintf(msg, args...), Cap: limit})
+	}
+
+	supported := map[string]bool{}
+	for _, p := range r.Protocols {
+		supported[p.Version] = p.Supported
+	}
+	if supported["SSLv3"] {
+		add("protocol.ssl3", "critical", "F", "SSL 3.0 is accepted and is broken by POODLE")
+	}
+	var legacy []string
+	for _, v := range []string{"TLS 1.0", "TLS 1.1"} {
+		if supported[v] {
+			legacy = append(legacy, v)
+		}
+	}
+	if len(legacy) > 0 {
+		add("protocol.legacy", "warning", "B", "%s accepted; deprecated by RFC 8996", strings.Join(legacy, " and "))
+	}
+	if !supported["TLS 1.2"] && !supported["TLS 1.3"] {
+		add("protocol.no_modern", "critical", "C", "neither TLS 1.2 nor TLS 1.3 is supported")
+	} else if !supported["TLS 1.3"] {
+		add("protocol.no_tls13", "info", "A-", "TLS 1.3 is not supported")
+	}
+
+	insecure := map[string][]string{}
+	var rc4, tripleDES, staticRSA, cbc []string
+	forwardSecret := false
+	weakDH, minDH := false, 0
+	var order []string
+	for _, ps := range r.CipherSuites {
+		if ps.ServerPreference != nil && !*ps.ServerPreference && ps.Version != "TLS 1.3" {
+			order = append(order, ps.Version)
+		}
+		for _, s := range ps.Suites {
+			switch {
+			case strings.Contains(s.Name, "_RC4_"):
+				rc4 = appendOnce(rc4, s.Name)
+			case s.Strength == "insecure" && s.DHBits == 0:
+				insecure[s.Reason] = appendOnce(insecure[s.Reason], s.Name)
+			case strings.Contains(s.Name, "_3DES_"):
+				tripleDES = appendOnce(tripleDES, s.Name)
+			}
+			switch s.KeyExchange {
+			case "ECDHE", "DHE", "hybrid":
+				forwardSecret = true
+			case "RSA":
+				staticRSA = appendOnce(staticRSA, s.Name)
+			}
+			if ps.Version == "TLS 1.3" {
+				forwardSecret = true
+			}
+			if s.DHBits > 0 && s.DHBits < 2048 {
+				weakDH = true
+				if minDH == 0 || s.DHBits < minDH {
+					minDH = s.DHBits
+				}
+			}
+			if strings.Contains(s.Name, "_CBC_") {
+				cbc = appendOnce(cbc, s.Name)
+			}
+		}
+	}
+	reasons := make([]string, 0, len(insecure))
+	for reason := range insecure {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	for _, reason := range reasons {
+		names := insecure[reason]
+		add("cipher.insecure", "critical", "F", "insecure suites accepted (%s): %s", reason, strings.Join(names, ", "))
+	}
+	if len(rc4) > 0 {
+		add("cipher.rc4", "critical", "C", "RC4 suites accepted: %s", strings.Join(rc4, ", "))
+	}
+	if len(tripleDES) > 0 {
+		add("cipher.3des", "warning", "C", "3DES suites accepted (Sweet32): %s", strings.Join(tripleDES, ", "))
+	}
+	if !forwardSecret && len(r.CipherSuites) > 0 {
+		add("kex.no_forward_secrecy", "critical", "B", "no suite with forward secrecy is accepted")
+	} else if len(staticRSA) > 0 {
+		add("kex.static_rsa", "warning", "", "static RSA key exchange accepted: %s", strings.Join(staticRSA, ", "))
+	}
+	if weakDH {
+		limit := "B"
+		if minDH < 1024 {
+			limit = "F"
+		}
+		add("kex.weak_dh", "critical", limit, "DHE uses %d-bit parameters; 2048 bits is the minimum", minDH)
+	}
+	if len(cbc) > 0 {
+		add("cipher.cbc", "info", "", "CBC suites accepted: %d", len(cbc))
+	}
+	if len(order) > 0 {
+		add("cipher.client_order", "info", "", "the client's suite order is used for %s", strings.Join(order, ", "))
+	}
+
+	if hs := r.Handshake; hs == nil {
+		add("certificate.unchecked", "warning", "", "certificate not assessed, crypto/tls could not connect: %s", r.HandshakeError)
+	} else {
+		assessHandshake(hs, add)
+	}
+
+	switch {
+	case r.HSTS == nil && r.HSTSError != "":
+		add("hsts.missing", "warning", "", "no HSTS policy, the HTTP request failed: %s", r.HSTSError)
+	case r.HSTS == nil:
+		add("hsts.missing", "warning", "", "no Strict-Transport-Security header")
+	case r.HSTS.MaxAge < minHSTSAge:
+		add("hsts.short", "warning", "", "HSTS max-age %d is below 180 days", r.HSTS.MaxAge)
+	}
+
+	grade, trust := "A", false
+	for _, f := range r.Findings {
+		if f.Cap == "T" {
+			trust = true
+		} else if f.Cap != "" && gradeRank[f.Cap] > gradeRank[grade] {
+			grade = f.Cap
+		}
+	}
+	if grade == "A" && r.HSTS != nil && r.HSTS.MaxAge >= minHSTSAge {
+		grade = "A+"
+	}
+	r.GradeIgnoringTrust, r.Grade = grade, grade
+	if trust {
+		r.Grade = "T"
+	}
+}
+
+func assessHandshake(hs *Result, add func(id, severity, limit, msg string, args ...any)) {
+	v := hs.Verification
+	switch {
+	case v.OK:
+	case v.Reason == "unknown_authority":
+		add("certificate.untrusted", "critical", "T", "chain does not lead to a trusted root: %s", v.Error)
+	default:
+		add("certificate."+v.Reason, "critical", "F", "certificate does not verify: %s", v.Error)
+	}
+	leaf := hs.Certs[0]
+	switch {
+	case leaf.KeyAlg == "RSA" && leaf.KeyBits < 2048:
+		add("certificate.weak_key", "critical", "F", "%d-bit RSA key", leaf.KeyBits)
+	case leaf.KeyAlg == "EC" && leaf.KeyBits < 256:
+		add("certificate.weak_key", "critical", "F", "%d-bit EC key", leaf.KeyBits)
+	}
+	if leaf.Status == "valid" && leaf.DaysRemaining < 30 {
+		add("certificate.expiring", "warning", "", "certificate expires in %d days", leaf.DaysRemaining)
+	}
+	switch {
+	case hs.OCSPStaple == nil:
+		add("ocsp.no_stapling", "warning", "", "no OCSP response is stapled")
+		if leaf.MustStaple {
+			add("ocsp.must_staple", "critical", "F", "certificate requires OCSP stapling but none was sent")
+		}
+	case hs.OCSPStaple.CertStatus == "revoked":
+		add("ocsp.revoked", "critical", "F", "stapled OCSP response says the certificate is revoked")
+	case hs.OCSPStaple.SignatureValid != nil && !*hs.OCSPStaple.SignatureValid:
+		add("ocsp.bad_staple", "warning", "", "stapled OCSP response has an invalid signature")
+	}
+	if !hs.Resumption.Supported {
+		add("session.no_resumption", "info", "", "session resumption is not supported")
+	}
+}
+
+func appendOnce(list []string, s string) []string {
+	for _, x := range list {
+		if x == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
@@ -0,0 +1,365 @@
+// Package tlsinspect connects to TLS endpoints and reports what was negotiated.
+package tlsinspect
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"engtools/backend/internal/pki"
+)
+
+// Options selects the endpoint to inspect.
+type Options struct {
+	Host string
+	Port int // default 443, or the protocol's port with StartTLS
+	// Address, when set, is dialled instead of Host, e.g. to inspect one backend behind a
+	// load balancer. Host remains the name sent and verified.
+	Address string
+	// ServerName overrides Host as the SNI and verification name. NoSNI sends no SNI at
+	// all; the chain is still verified against the name.
+	ServerName string
+	NoSNI      bool
+	// StartTLS upgrades a plaintext session first: smtp, imap, pop3, ftp, ldap, xmpp,
+	// postgres or mysql.
+	StartTLS string
+	// ClientCert is presented when the server asks for one.
+	ClientCert *tls.Certificate
+	ALPN       []string      // protocols offered, default h2 and http/1.1 without StartTLS
+	Timeout    time.Duration // per connection attempt, default 5s
+	// MinVersion lowers what crypto/tls accepts; zero keeps its default of TLS 1.2.
+	MinVersion uint16
+	// CTLogs, when set, names the logs of every SCT and verifies their signatures.
+	CTLogs *pki.CTLogList
+}
+
+func (o Options) withDefaults() Options {
+	o.StartTLS = strings.ToLower(o.StartTLS)
+	if o.Port == 0 {
+		o.Port = DefaultPort(o.StartTLS)
+	}
+	if o.ALPN == nil && o.StartTLS == "" {
+		o.ALPN = []string{"h2", "http/1.1"}
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = 5 * time.Second
+	}
+	return o
+}
+
+// name is the name the certificate is expected to carry.
+func (o Options) name() string {
+	if o.ServerName != "" {
+		return o.ServerName
+	}
+	return o.Host
+}
+
+// validate checks the options after defaults are applied.
+func (o Options) validate() error {
+	if o.Host == "" && o.Address == "" {
+		return errors.New("host required")
+	}
+	if _, ok := starttlsPorts[o.StartTLS]; o.StartTLS != "" && !ok {
+		return errors.New("unsupported starttls protocol: " + o.StartTLS)
+	}
+	return nil
+}
+
+// Validate reports whether Inspect would accept the options, without connecting.
+func (o Options) Validate() error {
+	return o.withDefaults().validate()
+}
+
+// tlsConfig builds the client configuration shared by every connection to the endpoint.
+func (o Options) tlsConfig(auth *ClientAuth) *tls.Config {
+	cfg := &tls.Config{
+		NextProtos:         o.ALPN,
+		InsecureSkipVerify: true, // verified separately, after the certificates are captured
+		ClientSessionCache: tls.NewLRUClientSessionCache(1),
+		MinVersion:         o.MinVersion,
+		CipherSuites:       goSuites(),
+		GetClientCertificate: func(req *tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			auth.Requested = true
+			auth.AcceptableCAs = auth.AcceptableCAs[:0]
+			for _, raw := range req.AcceptableCAs {
+				var rdn pkix.RDNSequence
+				if _, err := asn1.Unmarshal(raw, &rdn); err == nil {
+					var name pkix.Name
+					name.FillFromRDNSequence(&rdn)
+					auth.AcceptableCAs = append(auth.AcceptableCAs, name.String())
+				}
+			}
+			if o.ClientCert == nil {
+				return &tls.Certificate{}, nil
+			}
+			auth.Sent = true
+			return o.ClientCert, nil
+		},
+	}
+	if !o.NoSNI {
+		cfg.ServerName = o.name()
+	}
+	return cfg
+}
+
+// Timing splits the first connection into its phases, in milliseconds.
+type Timing struct {
+	DNS       float64 `json:"dns_ms"`
+	Connect   float64 `json:"connect_ms"`
+	Handshake float64 `json:"handshake_ms"`
+	StartTLS  float64 `json:"starttls_ms,omitempty"`
+	Total     float64 `json:"total_ms"`
+}
+
+// ClientAuth reports the server's certificate request, if any. AcceptableCAs are the
+// issuer names the server said it trusts; an empty list means any.
+type ClientAuth struct {
+	Requested     bool     `json:"requested"`
+	AcceptableCAs []string `json:"acceptable_cas,omitempty"`
+	Sent          bool     `json:"sent"`
+	// Error is a rejection that TLS 1.3 servers only report after the handshake.
+	Error string `json:"error,omitempty"`
+}
+
+// Resumption reports whether a second connection resumed the first one's session.
+type Resumption struct {
+	Supported bool   `json:"supported"`
+	Error     string `json:"error,omitempty"`
+}
+
+type Result struct {
+	Host        string   `json:"host"`
+	Port        int      `json:"port"`
+	ServerName  string   `json:"server_name"` // the SNI sent, empty with no_sni
+	StartTLS    string   `json:"starttls,omitempty"`
+	IP          string   `json:"ip"`        // the address the handshake was made with
+	Addresses   []string `json:"addresses"` // everything the name resolved to
+	DialErrors  []string `json:"dial_errors,omitempty"`
+	TLSVersion  string   `json:"tls_version"`
+	CipherSuite string   `json:"cipher_suite"`
+	ALPN        string   `json:"alpn,omitempty"`
+	KeyExchange string   `json:"key_exchange,omitempty"` // ECDHE, DHE, hybrid or RSA
+	Group       string   `json:"group,omitempty"`
+	DHBits      int      `json:"dh_bits,omitempty"`
+	// OCSPStaple is the stapled response, checked against the presented issuer.
+	OCSPStaple      *pki.OCSPInfo `json:"ocsp_staple,omitempty"`
+	OCSPStapleError string        `json:"ocsp_staple_error,omitempty"`
+	// SCTs are those delivered in the TLS extension; embedded ones are listed on the leaf.
+	SCTs       []pki.SCT   `json:"scts,omitempty"`
+	ClientAuth *ClientAuth `json:"client_auth,omitempty"`
+	Resumption Resumption  `json:"resumption"`
+	Timing     Timing      `json:"timing"`
+	// Verification checks the presented chain against the system roots and server name.
+	Verification pki.VerifyResult `json:"verification"`
+	Certs        []pki.CertInfo   `json:"certs"`
+	// Peer holds the presented certificates for follow-up checks such as DANE.
+	Peer []*x509.Certificate `json:"-"`
+}
+
+// session is one completed handshake.
+type session struct {
+	conn      *tls.Conn
+	flight    serverFlight
+	connect   time.Duration
+	starttls  time.Duration
+	handshake time.Duration
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// Inspect resolves the host, handshakes with the first address that answers and then
+// reconnects once to test session resumption. Certificate verification is done after the
+// handshake so that broken chains can still be inspected.
+func Inspect(ctx context.Context, opts Options) (*Result, error) {
+	opts = opts.withDefaults()
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	res := &Result{Host: opts.Host, Port: opts.Port, StartTLS: opts.StartTLS}
+	auth := &ClientAuth{}
+	cfg := opts.tlsConfig(auth)
+	res.ServerName = cfg.ServerName
+	target := opts.Address
+	if target == "" {
+		target = opts.Host
+	}
+	start := time.Now()
+	ips, err := resolve(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	res.Timing.DNS = ms(time.Since(start))
+
+	var sess *session
+	var addr string
+	for _, ip := range ips {
+		res.Addresses = append(res.Addresses, ip.IP.String())
+		if sess != nil {
+			continue
+		}
+		a := net.JoinHostPort(ip.IP.String(), strconv.Itoa(opts.Port))
+		s, err := handshake(ctx, a, cfg, opts)
+		if err != nil {
+			res.DialErrors = append(res.DialErrors, ip.IP.String()+": "+err.Error())
+			continue
+		}
+		sess, addr, res.IP = s, a, ip.IP.String()
+	}
+	if sess == nil {
+		return nil, errors.New("dial failed: " + strings.Join(res.DialErrors, "; "))
+	}
+	res.Timing.Connect = ms(sess.connect)
+	res.Timing.StartTLS = ms(sess.starttls)
+	res.Timing.Handshake = ms(sess.handshake)
+	res.Timing.Total = ms(time.Since(start))
+
+	state := sess.conn.ConnectionState()
+	if state.Version == tls.VersionTLS13 {
+		// TLS 1.3 tickets, and the rejection of a client certificate, arrive after the
+		// handshake and are only processed on read
+		sess.conn.SetReadDeadline(time.Now().Add(500 * time.Millisecond))
+		_, err := sess.conn.Read(make([]byte, 1))
+		var ne net.Error
+		if err != nil && !(errors.As(err, &ne) && ne.Timeout()) && auth.Requested {
+			auth.Error = err.Error()
+		}
+	}
+	if auth.Requested {
+		res.ClientAuth = auth
+	}
+	sess.conn.Close()
+	if len(state.PeerCertificates) == 0 {
+		return nil, errors.New("no peer certificates")
+	}
+
+	res.TLSVersion = tls.VersionName(state.Version)
+	res.CipherSuite = SuiteName(state.CipherSuite)
+	res.ALPN = state.NegotiatedProtocol
+	res.KeyExchange = keyExchange(sess.flight)
+	if sess.flight.Group != 0 {
+		res.Group = GroupName(sess.flight.Group)
+	}
+	res.DHBits = sess.flight.DHBits
+
+	now := time.Now()
+	leaf := state.PeerCertificates[0]
+	res.Peer = state.PeerCertificates
+	for _, crt := range state.PeerCertificates {
+		res.Certs = append(res.Certs, pki.NewCertInfo(crt, now))
+	}
+	res.Verification = pki.Verify(ctx, leaf, pki.VerifyOptions{Intermediates: state.PeerCertificates[1:], DNSName: opts.name(), CurrentTime: now})
+
+	issuer := issuerOf(leaf, state.PeerCertificates[1:], res.Verification.Raw)
+	if len(state.OCSPResponse) > 0 {
+		info, err := pki.ParseOCSPResponse(state.OCSPResponse, leaf, issuer)
+		if err != nil {
+			res.OCSPStapleError = err.Error()
+		} else {
+			res.OCSPStaple = info
+		}
+	}
+	for _, raw := range state.SignedCertificateTimestamps {
+		if sct, err := pki.ParseSCT(raw); err == nil {
+			res.SCTs = append(res.SCTs, *sct)
+		}
+	}
+	res.SCTs = pki.SCTsFrom(res.SCTs, "tls")
+	if opts.CTLogs != nil {
+		opts.CTLogs.Annotate(res.Certs[0].SCTs, leaf, issuer)
+		opts.CTLogs.Annotate(res.SCTs, leaf, issuer)
+		if res.OCSPStaple != nil {
+			opts.CTLogs.Annotate(res.OCSPStaple.SCTs, leaf, issuer)
+		}
+	}
+
+	again, err := handshake(ctx, addr, cfg, opts)
+	if err != nil {
+		res.Resumption.Error = err.Error()
+	} else {
+		res.Resumption.Supported = again.conn.ConnectionState().DidResume
+		again.conn.Close()
+	}
+	return res, nil
+}
+
+// goSuites lists every suite crypto/tls implements. Setting them explicitly enables the
+// static RSA and 3DES suites it no longer offers by default, so that legacy servers can
+// still be inspected; crypto/tls orders them by strength regardless.
+func goSuites() []uint16 {
+	var ids []uint16
+	for _, s := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		ids = append(ids, s.ID)
+	}
+	return ids
+}
+
+// resolve looks up host, IPv4 addresses first.
+func resolve(ctx context.Context, host string) ([]net.IPAddr, error) {
+	ips, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(ips, func(i, j int) bool { return ips[i].IP.To4() != nil && ips[j].IP.To4() == nil })
+	return ips, nil
+}
+
+// handshake dials addr, runs the STARTTLS negotiation if any and completes a TLS
+// handshake, recording the server's flight.
+func handshake(ctx context.Context, addr string, cfg *tls.Config, opts Options) (*session, error) {
+	d := &net.Dialer{Timeout: opts.Timeout}
+	t0 := time.Now()
+	raw, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	sess := &session{connect: time.Since(t0)}
+	if opts.StartTLS != "" {
+		t1 := time.Now()
+		raw.SetDeadline(t1.Add(opts.Timeout))
+		if err := startTLS(raw, opts.StartTLS, opts.name()); err != nil {
+			raw.Close()
+			return nil, err
+		}
+		raw.SetDeadline(time.Time{})
+		sess.starttls = time.Since(t1)
+	}
+	t2 := time.Now()
+	rec := &recorder{Conn: raw}
+	sess.conn = tls.Client(rec, cfg)
+	hctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+	if err := sess.conn.HandshakeContext(hctx); err != nil {
+		raw.Close()
+		return nil, err
+	}
+	rec.stop = true
+	sess.flight = parseServerFlight(rec.buf)
+	sess.handshake = time.Since(t2)
+	return sess, nil
+}
+
+// issuerOf finds the leaf's issuer, preferring the verified chain.
+func issuerOf(leaf *x509.Certificate, presented []*x509.Certificate, chains [][]*x509.Certificate) *x509.Certificate {
+	for _, chain := range chains {
+		if len(chain) > 1 {
+			return chain[1]
+		}
+	}
+	for _, crt := range presented {
+		if leaf.CheckSignatureFrom(crt) == nil {
+			return crt
+		}
+	}
+	return nil
+}
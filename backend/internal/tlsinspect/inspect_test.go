@@ -0,0 +1,73 @@
+package tlsinspect
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"testing"
+
+	"engtools/backend/internal/pki"
+	"engtools/backend/internal/pki/pkitest"
+)
+
+// serve runs an HTTPS server on a loopback port until the test ends. hsts, when set, is
+// sent as the Strict-Transport-Security header.
+func serve(t *testing.T, keyType string, cfg *tls.Config, hsts string) int {
+	t.Helper()
+	crt := pkitest.Issue(t, pki.CertSpec{NameSpec: pki.NameSpec{CN: "localhost"}, SANSpec: pki.SANSpec{IP: []string{"127.0.0.1"}}}, pkitest.Key(t, keyType), nil)
+	cfg.Certificates = []tls.Certificate{crt.TLS()}
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if hsts != "" {
+				w.Header().Set("Strict-Transport-Security", hsts)
+			}
+		}),
+		// probes abandon handshakes, which the server would otherwise log
+		ErrorLog: log.New(io.Discard, "", 0),
+	}
+	go srv.Serve(ln)
+	t.Cleanup(func() { srv.Close() })
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+func TestInspect(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     *tls.Config
+		version string
+		kx      string
+		group   string
+		alpn    string
+	}{
+		{"tls13", &tls.Config{CurvePreferences: []tls.CurveID{tls.X25519}, NextProtos: []string{"h2"}}, "TLS 1.3", "ECDHE", "X25519", "h2"},
+		{"tls12 p384", &tls.Config{MaxVersion: tls.VersionTLS12, CurvePreferences: []tls.CurveID{tls.CurveP384}}, "TLS 1.2", "ECDHE", "P-384", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			port := serve(t, "ec", tt.cfg, "")
+			res, err := Inspect(context.Background(), Options{Host: "127.0.0.1", Port: port})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.TLSVersion != tt.version || res.KeyExchange != tt.kx || res.Group != tt.group || res.ALPN != tt.alpn {
+				t.Errorf("got %s %s %s alpn %q", res.TLSVersion, res.KeyExchange, res.Group, res.ALPN)
+			}
+			if res.IP != "127.0.0.1" || len(res.Certs) != 1 {
+				t.Errorf("ip %q, %d certs", res.IP, len(res.Certs))
+			}
+			if !res.Resumption.Supported {
+				t.Errorf("resumption not detected: %+v", res.Resumption)
+			}
+			if res.Verification.OK || res.Verification.Reason != "unknown_authority" {
+				t.Errorf("self-signed chain verified: %+v", res.Verification)
+			}
+		})
+	}
+}
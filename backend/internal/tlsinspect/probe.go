@@ -0,0 +1,175 @@
+package tlsinspect
+
+import (
+	"context"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/tls"
+	"errors"
+	"net"
+	"time"
+
+	"golang.org/x/crypto/cryptobyte"
+)
+
+// VersionSSL30 is not defined by crypto/tls any more but is still probed for.
+const VersionSSL30 = 0x0300
+
+// errRejected means the server answered the probe with an alert or by closing the
+// connection, i.e. it does not accept the offered version and suites.
+var errRejected = errors.New("rejected")
+
+// probeGroups are offered in supported_groups. Finite-field groups are left out so that
+// DHE suites reveal the parameters the server is configured with.
+var probeGroups = []tls.CurveID{tls.X25519, tls.CurveP256, tls.CurveP384, tls.CurveP521}
+
+// probeSigAlgs is deliberately broad so that no certificate type is excluded.
+var probeSigAlgs = []tls.SignatureScheme{
+	tls.ECDSAWithP256AndSHA256, tls.ECDSAWithP384AndSHA384, tls.ECDSAWithP521AndSHA512, tls.Ed25519,
+	tls.PSSWithSHA256, tls.PSSWithSHA384, tls.PSSWithSHA512,
+	tls.PKCS1WithSHA256, tls.PKCS1WithSHA384, tls.PKCS1WithSHA512,
+	tls.ECDSAWithSHA1, tls.PKCS1WithSHA1,
+}
+
+// clientHello builds a ClientHello record offering suites at version. For TLS 1.3 the
+// version goes in supported_versions with an X25519 key share; SSL 3.0 hellos carry no
+// extensions, as servers of that era did not expect them.
+func clientHello(serverName string, version uint16, suites []uint16) ([]byte, error) {
+	random := make([]byte, 32)
+	session := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return nil, err
+	}
+	if _, err := rand.Read(session); err != nil {
+		return nil, err
+	}
+	legacy := version
+	if version == tls.VersionTLS13 {
+		legacy = tls.VersionTLS12
+	}
+	var share []byte
+	if version == tls.VersionTLS13 {
+		key, err := ecdh.X25519().GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		share = key.PublicKey().Bytes()
+	}
+	recordVersion := uint16(tls.VersionTLS10)
+	if version == VersionSSL30 {
+		recordVersion = VersionSSL30
+	}
+
+	var b cryptobyte.Builder
+	b.AddUint8(recordHandshake)
+	b.AddUint16(recordVersion)
+	b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddUint8(msgClientHello)
+		b.AddUint24LengthPrefixed(func(b *cryptobyte.Builder) {
+			b.AddUint16(legacy)
+			b.AddBytes(random)
+			b.AddUint8LengthPrefixed(func(b *cryptobyte.Builder) { b.AddBytes(session) })
+			b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+				for _, s := range suites {
+					b.AddUint16(s)
+				}
+			})
+			b.AddUint8LengthPrefixed(func(b *cryptobyte.Builder) { b.AddUint8(0) })
+			if version == VersionSSL30 {
+				return
+			}
+			b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+				ext := func(typ uint16, body func(b *cryptobyte.Builder)) {
+					b.AddUint16(typ)
+					b.AddUint16LengthPrefixed(body)
+				}
+				if serverName != "" && net.ParseIP(serverName) == nil {
+					ext(extServerName, func(b *cryptobyte.Builder) {
+						b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+							b.AddUint8(0) // host_name
+							b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) { b.AddBytes([]byte(serverName)) })
+						})
+					})
+				}
+				ext(extSupportedGroups, func(b *cryptobyte.Builder) {
+					b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+						for _, g := range probeGroups {
+							b.AddUint16(uint16(g))
+						}
+					})
+				})
+				ext(extECPointFormats, func(b *cryptobyte.Builder) {
+					b.AddUint8LengthPrefixed(func(b *cryptobyte.Builder) { b.AddUint8(0) })
+				})
+				ext(extSignatureAlgs, func(b *cryptobyte.Builder) {
+					b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+						for _, s := range probeSigAlgs {
+							b.AddUint16(uint16(s))
+						}
+					})
+				})
+				ext(extExtendedMaster, func(b *cryptobyte.Builder) {})
+				ext(extRenegotiationInfo, func(b *cryptobyte.Builder) { b.AddUint8(0) })
+				if version == tls.VersionTLS13 {
+					ext(extSupportedVersions, func(b *cryptobyte.Builder) {
+						b.AddUint8LengthPrefixed(func(b *cryptobyte.Builder) { b.AddUint16(tls.VersionTLS13) })
+					})
+					ext(extPSKModes, func(b *cryptobyte.Builder) {
+						b.AddUint8LengthPrefixed(func(b *cryptobyte.Builder) { b.AddUint8(1) }) // psk_dhe_ke
+					})
+					ext(extKeyShare, func(b *cryptobyte.Builder) {
+						b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+							b.AddUint16(uint16(tls.X25519))
+							b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) { b.AddBytes(share) })
+						})
+					})
+				}
+			})
+		})
+	})
+	return b.Bytes()
+}
+
+// probe sends a hand-built ClientHello and reads the server's answer up to the end of
+// its first flight. The handshake is never completed, which is what lets a scan offer
+// versions and suites crypto/tls does not implement.
+func probe(ctx context.Context, addr, serverName string, version uint16, suites []uint16, timeout time.Duration) (serverFlight, error) {
+	hello, err := clientHello(serverName, version, suites)
+	if err != nil {
+		return serverFlight{}, err
+	}
+	d := &net.Dialer{Timeout: timeout}
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return serverFlight{}, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+	if _, err := conn.Write(hello); err != nil {
+		return serverFlight{}, err
+	}
+	var buf []byte
+	chunk := make([]byte, 16<<10)
+	for {
+		n, err := conn.Read(chunk)
+		buf = append(buf, chunk[:n]...)
+		f := parseServerFlight(buf)
+		switch {
+		case f.Alert >= 0:
+			return f, errRejected
+		case f.HelloRetry, f.HasHello && (f.Done || f.Version == tls.VersionTLS13):
+			return f, nil
+		}
+		var ne net.Error
+		if errors.As(err, &ne) && ne.Timeout() {
+			return f, err
+		}
+		if err != nil || len(buf) > maxRecorded {
+			// a reset or close ends the flight; without a ServerHello it is a refusal
+			if f.HasHello {
+				return f, nil
+			}
+			return f, errRejected
+		}
+	}
+}
@@ -0,0 +1,241 @@
+package tlsinspect
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// scanVersions are probed oldest first.
+var scanVersions = []uint16{VersionSSL30, tls.VersionTLS10, tls.VersionTLS11, tls.VersionTLS12, tls.VersionTLS13}
+
+// ProtocolSupport is the outcome of probing one protocol version. Error is set when the
+// probe failed for a reason other than the server refusing, such as a timeout.
+type ProtocolSupport struct {
+	Version   string `json:"version"`
+	Supported bool   `json:"supported"`
+	Error     string `json:"error,omitempty"`
+}
+
+// SuiteResult is one accepted cipher suite with the key exchange the server chose for it.
+type SuiteResult struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	KeyExchange string `json:"key_exchange,omitempty"`
+	Group       string `json:"group,omitempty"`
+	DHBits      int    `json:"dh_bits,omitempty"`
+	Strength    string `json:"strength"` // strong, weak or insecure
+	Reason      string `json:"reason,omitempty"`
+}
+
+// ProtocolSuites lists the suites a version accepts, in the server's order when it
+// enforces one and in our offer order otherwise.
+type ProtocolSuites struct {
+	Version          string        `json:"version"`
+	ServerPreference *bool         `json:"server_preference,omitempty"` // nil with fewer than two suites
+	Suites           []SuiteResult `json:"suites"`
+}
+
+// HSTS is the parsed Strict-Transport-Security header.
+type HSTS struct {
+	Header            string `json:"header"`
+	MaxAge            int64  `json:"max_age"`
+	IncludeSubDomains bool   `json:"include_subdomains"`
+	Preload           bool   `json:"preload"`
+}
+
+type ScanResult struct {
+	Host  string `json:"host"`
+	Port  int    `json:"port"`
+	IP    string `json:"ip"`
+	Grade string `json:"grade"`
+	// GradeIgnoringTrust is the grade the server would get with a publicly trusted
+	// certificate, which is the useful number for internal CAs.
+	GradeIgnoringTrust string            `json:"grade_ignoring_trust"`
+	Findings           []Finding         `json:"findings"`
+	Protocols          []ProtocolSupport `json:"protocols"`
+	CipherSuites       []ProtocolSuites  `json:"cipher_suites"`
+	HSTS               *HSTS             `json:"hsts,omitempty"`
+	HSTSError          string            `json:"hsts_error,omitempty"`
+	// Handshake is a full crypto/tls connection, the source of the certificate, OCSP
+	// stapling and resumption checks.
+	Handshake      *Result `json:"handshake,omitempty"`
+	HandshakeError string  `json:"handshake_error,omitempty"`
+	Duration       float64 `json:"duration_ms"`
+}
+
+// Scan assesses the server's TLS configuration: protocol versions, accepted cipher
+// suites and their order, key exchange strength, the certificate, OCSP stapling and
+// HSTS. All probes go to the single address the handshake used.
+func Scan(ctx context.Context, opts Options) (*ScanResult, error) {
+	opts = opts.withDefaults()
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	if opts.StartTLS != "" {
+		return nil, errors.New("scans support implicit TLS only")
+	}
+	start := time.Now()
+	res := &ScanResult{Host: opts.Host, Port: opts.Port, Findings: []Finding{}}
+	opts.MinVersion = tls.VersionTLS10
+	hs, err := Inspect(ctx, opts)
+	if err != nil {
+		res.HandshakeError = err.Error()
+		target := opts.Address
+		if target == "" {
+			target = opts.Host
+		}
+		ips, err := resolve(ctx, target)
+		if err != nil {
+			return nil, err
+		}
+		res.IP = ips[0].IP.String()
+	} else {
+		res.Handshake, res.IP = hs, hs.IP
+	}
+	addr := net.JoinHostPort(res.IP, strconv.Itoa(opts.Port))
+	sni := opts.name()
+	if opts.NoSNI {
+		sni = ""
+	}
+
+	anySupported := false
+	for _, v := range scanVersions {
+		ps := ProtocolSupport{Version: tls.VersionName(v)}
+		suites, pref, err := enumerate(ctx, addr, sni, v, opts.Timeout)
+		if err != nil {
+			ps.Error = err.Error()
+		}
+		if len(suites) > 0 {
+			ps.Supported, anySupported = true, true
+			res.CipherSuites = append(res.CipherSuites, ProtocolSuites{Version: ps.Version, ServerPreference: pref, Suites: suites})
+		}
+		res.Protocols = append(res.Protocols, ps)
+	}
+	if !anySupported && res.Handshake == nil {
+		return nil, errors.New("no TLS version accepted: " + res.HandshakeError)
+	}
+
+	host := opts.name()
+	if host == "" {
+		host = res.IP
+	}
+	res.HSTS, err = fetchHSTS(ctx, addr, net.JoinHostPort(host, strconv.Itoa(opts.Port)), sni, opts.Timeout)
+	if err != nil {
+		res.HSTSError = err.Error()
+	}
+	assess(res)
+	res.Duration = ms(time.Since(start))
+	return res, nil
+}
+
+// enumerate finds the suites accepted at version by repeatedly offering everything not
+// yet picked. It then offers the first two picks in reverse; a server that still picks
+// the first one enforces its own order.
+func enumerate(ctx context.Context, addr, serverName string, version uint16, timeout time.Duration) ([]SuiteResult, *bool, error) {
+	offer := legacySuites
+	if version == tls.VersionTLS13 {
+		offer = tls13Suites
+	}
+	remaining := slices.Clone(offer)
+	var picked []uint16
+	var out []SuiteResult
+	for len(remaining) > 0 {
+		f, err := probe(ctx, addr, serverName, version, remaining, timeout)
+		if errors.Is(err, errRejected) {
+			break
+		}
+		if err != nil {
+			if len(out) == 0 {
+				return nil, nil, err
+			}
+			break
+		}
+		if f.Version != version || !slices.Contains(remaining, f.CipherSuite) {
+			break
+		}
+		out = append(out, suiteResult(f))
+		picked = append(picked, f.CipherSuite)
+		remaining = slices.DeleteFunc(remaining, func(s uint16) bool { return s == f.CipherSuite })
+	}
+	if len(picked) < 2 {
+		return out, nil, nil
+	}
+	f, err := probe(ctx, addr, serverName, version, []uint16{picked[1], picked[0]}, timeout)
+	if err != nil {
+		return out, nil, nil
+	}
+	pref := f.CipherSuite == picked[0]
+	return out, &pref, nil
+}
+
+func suiteResult(f serverFlight) SuiteResult {
+	name := SuiteName(f.CipherSuite)
+	r := SuiteResult{ID: fmt.Sprintf("0x%04x", f.CipherSuite), Name: name, KeyExchange: keyExchange(f), DHBits: f.DHBits}
+	if f.Group != 0 {
+		r.Group = GroupName(f.Group)
+	}
+	r.Strength, r.Reason = suiteStrength(name)
+	switch {
+	case f.DHBits > 0 && f.DHBits < 1024:
+		r.Strength, r.Reason = "insecure", fmt.Sprintf("%d-bit DH parameters", f.DHBits)
+	case f.DHBits > 0 && f.DHBits < 2048 && r.Strength == "strong":
+		r.Strength, r.Reason = "weak", fmt.Sprintf("%d-bit DH parameters", f.DHBits)
+	}
+	return r
+}
+
+// fetchHSTS requests / over TLS from addr and parses the Strict-Transport-Security
+// header. A nil result without error means the server answered without one.
+func fetchHSTS(ctx context.Context, addr, hostPort, serverName string, timeout time.Duration) (*HSTS, error) {
+	d := &net.Dialer{Timeout: timeout}
+	tr := &http.Transport{
+		TLSClientConfig: &tls.Config{ServerName: serverName, InsecureSkipVerify: true, MinVersion: tls.VersionTLS10, CipherSuites: goSuites()},
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return d.DialContext(ctx, network, addr)
+		},
+	}
+	defer tr.CloseIdleConnections()
+	client := &http.Client{
+		Transport:     tr,
+		Timeout:       timeout,
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://"+hostPort+"/", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	header := resp.Header.Get("Strict-Transport-Security")
+	if header == "" {
+		return nil, nil
+	}
+	return parseHSTS(header), nil
+}
+
+func parseHSTS(header string) *HSTS {
+	h := &HSTS{Header: header}
+	for _, d := range strings.Split(header, ";") {
+		name, value, _ := strings.Cut(strings.TrimSpace(d), "=")
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "max-age":
+			h.MaxAge, _ = strconv.ParseInt(strings.Trim(strings.TrimSpace(value), `"`), 10, 64)
+		case "includesubdomains":
+			h.IncludeSubDomains = true
+		case "preload":
+			h.Preload = true
+		}
+	}
+	return h
+}
@@ -0,0 +1,66 @@
+package tlsinspect
+
+import (
+	"context"
+	"crypto/tls"
+	"testing"
+	"time"
+)
+
+func TestScan(t *testing.T) {
+	tests := []struct {
+		name      string
+		keyType   string
+		cfg       *tls.Config
+		hsts      string
+		protocols map[string]bool
+		grade     string
+		findings  []string
+	}{
+		{
+			name: "modern", keyType: "ec", cfg: &tls.Config{}, hsts: "max-age=31536000; includeSubDomains",
+			protocols: map[string]bool{"SSLv3": false, "TLS 1.0": false, "TLS 1.1": false, "TLS 1.2": true, "TLS 1.3": true},
+			grade:     "A+", findings: []string{"certificate.untrusted", "ocsp.no_stapling"},
+		},
+		{
+			name: "legacy", keyType: "rsa", hsts: "max-age=300",
+			cfg: &tls.Config{MinVersion: tls.VersionTLS10, MaxVersion: tls.VersionTLS12, CipherSuites: []uint16{
+				tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, tls.TLS_RSA_WITH_AES_128_CBC_SHA, tls.TLS_RSA_WITH_3DES_EDE_CBC_SHA,
+			}},
+			protocols: map[string]bool{"TLS 1.0": true, "TLS 1.1": true, "TLS 1.2": true, "TLS 1.3": false},
+			grade:     "C", findings: []string{"protocol.legacy", "protocol.no_tls13", "cipher.3des", "kex.static_rsa", "hsts.short"},
+		},
+		{
+			name: "no forward secrecy", keyType: "rsa",
+			cfg:       &tls.Config{MaxVersion: tls.VersionTLS12, CipherSuites: []uint16{tls.TLS_RSA_WITH_AES_128_GCM_SHA256, tls.TLS_RSA_WITH_AES_256_GCM_SHA384}},
+			protocols: map[string]bool{"TLS 1.2": true, "TLS 1.3": false},
+			grade:     "B", findings: []string{"kex.no_forward_secrecy", "hsts.missing"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			port := serve(t, tt.keyType, tt.cfg, tt.hsts)
+			res, err := Scan(context.Background(), Options{Host: "127.0.0.1", Port: port, Timeout: 2 * time.Second})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, p := range res.Protocols {
+				if want, ok := tt.protocols[p.Version]; ok && p.Supported != want {
+					t.Errorf("%s supported = %v, want %v (%s)", p.Version, p.Supported, want, p.Error)
+				}
+			}
+			if res.Grade != "T" || res.GradeIgnoringTrust != tt.grade {
+				t.Errorf("grade %s/%s, want T/%s: %+v", res.Grade, res.GradeIgnoringTrust, tt.grade, res.Findings)
+			}
+			ids := map[string]bool{}
+			for _, f := range res.Findings {
+				ids[f.ID] = true
+			}
+			for _, id := range tt.findings {
+				if !ids[id] {
+					t.Errorf("missing finding %s: %+v", id, res.Findings)
+				}
+			}
+		})
+	}
+}
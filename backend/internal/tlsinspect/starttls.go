@@ -0,0 +1,342 @@
+package tlsinspect
+
+import (
+	"bufio"
+	"encoding/asn1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+)
+
+// starttlsPorts are the default ports of the protocols that upgrade in band.
+var starttlsPorts = map[string]int{
+	"smtp": 25, "imap": 143, "pop3": 110, "ftp": 21, "ldap": 389, "xmpp": 5222, "postgres": 5432, "mysql": 3306,
+}
+
+// DefaultPort is the port Inspect connects to when none is given: 443 for implicit TLS,
+// otherwise the standard port of the STARTTLS protocol.
+func DefaultPort(starttls string) int {
+	if p, ok := starttlsPorts[strings.ToLower(starttls)]; ok {
+		return p
+	}
+	return 443
+}
+
+// maxBanner bounds how much plaintext a server may send before the upgrade.
+const maxBanner = 64 << 10
+
+// startTLS runs the plaintext part of proto on conn up to the point where the server
+// expects a ClientHello. domain is announced where the protocol needs one (SMTP EHLO,
+// XMPP stream header).
+func startTLS(conn net.Conn, proto, domain string) error {
+	r := bufio.NewReader(io.LimitReader(conn, maxBanner))
+	var err error
+	switch proto {
+	case "smtp":
+		err = smtpStartTLS(conn, r, domain)
+	case "imap":
+		err = imapStartTLS(conn, r)
+	case "pop3":
+		err = pop3StartTLS(conn, r)
+	case "ftp":
+		err = ftpStartTLS(conn, r)
+	case "ldap":
+		err = ldapStartTLS(conn, r)
+	case "xmpp":
+		err = xmppStartTLS(conn, r, domain)
+	case "postgres":
+		err = postgresStartTLS(conn, r)
+	case "mysql":
+		err = mysqlStartTLS(conn, r)
+	default:
+		return errors.New("unsupported starttls protocol: " + proto)
+	}
+	if err != nil {
+		return fmt.Errorf("starttls %s: %w", proto, err)
+	}
+	if r.Buffered() > 0 {
+		return fmt.Errorf("starttls %s: server sent data before the handshake", proto)
+	}
+	return nil
+}
+
+// readReply reads a numbered reply as used by SMTP and FTP, following "code-" lines
+// to the final "code " line.
+func readReply(r *bufio.Reader) (string, string, error) {
+	var text []string
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return "", "", err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if len(line) < 3 {
+			return "", "", errors.New("malformed reply: " + line)
+		}
+		text = append(text, line[min(4, len(line)):])
+		if len(line) == 3 || line[3] == ' ' {
+			return line[:3], strings.Join(text, "\n"), nil
+		}
+	}
+}
+
+func expectReply(r *bufio.Reader, code string) (string, error) {
+	got, text, err := readReply(r)
+	if err != nil {
+		return "", err
+	}
+	if got != code {
+		return "", fmt.Errorf("expected %s, got %s %s", code, got, text)
+	}
+	return text, nil
+}
+
+func smtpStartTLS(w io.Writer, r *bufio.Reader, domain string) error {
+	if _, err := expectReply(r, "220"); err != nil {
+		return err
+	}
+	if domain == "" || net.ParseIP(domain) != nil {
+		domain = "localhost"
+	}
+	if _, err := fmt.Fprintf(w, "EHLO %s\r\n", domain); err != nil {
+		return err
+	}
+	caps, err := expectReply(r, "250")
+	if err != nil {
+		return err
+	}
+	if !strings.Contains(strings.ToUpper(caps), "STARTTLS") {
+		return errors.New("server does not offer STARTTLS")
+	}
+	if _, err := io.WriteString(w, "STARTTLS\r\n"); err != nil {
+		return err
+	}
+	_, err = expectReply(r, "220")
+	return err
+}
+
+func ftpStartTLS(w io.Writer, r *bufio.Reader) error {
+	if _, err := expectReply(r, "220"); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, "AUTH TLS\r\n"); err != nil {
+		return err
+	}
+	_, err := expectReply(r, "234")
+	return err
+}
+
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	return strings.TrimRight(line, "\r\n"), err
+}
+
+func imapStartTLS(w io.Writer, r *bufio.Reader) error {
+	line, err := readLine(r)
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(line, "* OK") {
+		return errors.New("unexpected greeting: " + line)
+	}
+	if _, err := io.WriteString(w, "a1 STARTTLS\r\n"); err != nil {
+		return err
+	}
+	for {
+		line, err := readLine(r)
+		if err != nil {
+			return err
+		}
+		switch {
+		case strings.HasPrefix(line, "a1 OK"):
+			return nil
+		case strings.HasPrefix(line, "a1 "):
+			return errors.New(line)
+		}
+	}
+}
+
+func pop3StartTLS(w io.Writer, r *bufio.Reader) error {
+	line, err := readLine(r)
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(line, "+OK") {
+		return errors.New("unexpected greeting: " + line)
+	}
+	if _, err := io.WriteString(w, "STLS\r\n"); err != nil {
+		return err
+	}
+	if line, err = readLine(r); err != nil {
+		return err
+	}
+	if !strings.HasPrefix(line, "+OK") {
+		return errors.New(line)
+	}
+	return nil
+}
+
+// ldapStartTLS sends the StartTLS extended operation (RFC 4511 section 4.14).
+func ldapStartTLS(w io.Writer, r *bufio.Reader) error {
+	const oid = "1.3.6.1.4.1.1466.20037"
+	req, err := asn1.Marshal(struct {
+		ID  int
+		Ext asn1.RawValue
+	}{1, asn1.RawValue{Class: asn1.ClassApplication, Tag: 23, IsCompound: true,
+		Bytes: append([]byte{0x80, byte(len(oid))}, oid...)}})
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(req); err != nil {
+		return err
+	}
+	msg, err := readTLV(r)
+	if err != nil {
+		return err
+	}
+	var resp struct {
+		ID  int
+		Ext asn1.RawValue
+	}
+	if _, err := asn1.Unmarshal(msg, &resp); err != nil || resp.Ext.Tag != 24 {
+		return errors.New("malformed extended response")
+	}
+	var code asn1.Enumerated
+	if _, err := asn1.Unmarshal(resp.Ext.Bytes, &code); err != nil {
+		return errors.New("malformed extended response")
+	}
+	if code != 0 {
+		return fmt.Errorf("server refused with result code %d", code)
+	}
+	return nil
+}
+
+// readTLV reads one complete BER element with a definite length.
+func readTLV(r *bufio.Reader) ([]byte, error) {
+	hdr := make([]byte, 2)
+	if _, err := io.ReadFull(r, hdr); err != nil {
+		return nil, err
+	}
+	n := int(hdr[1])
+	if n&0x80 != 0 {
+		k := n & 0x7f
+		if k == 0 || k > 3 {
+			return nil, errors.New("unsupported length encoding")
+		}
+		lb := make([]byte, k)
+		if _, err := io.ReadFull(r, lb); err != nil {
+			return nil, err
+		}
+		hdr = append(hdr, lb...)
+		n = 0
+		for _, b := range lb {
+			n = n<<8 | int(b)
+		}
+	}
+	body := make([]byte, n)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+	return append(hdr, body...), nil
+}
+
+// xmppStartTLS negotiates per RFC 6120 section 5.4. The stream is read as text up to
+// the elements of interest rather than parsed as XML.
+func xmppStartTLS(w io.Writer, r *bufio.Reader, domain string) error {
+	header := "<?xml version='1.0'?><stream:stream to='%s' xmlns='jabber:client' " +
+		"xmlns:stream='http://etherx.jabber.org/streams' version='1.0'>"
+	if _, err := fmt.Fprintf(w, header, domain); err != nil {
+		return err
+	}
+	features, err := readUntil(r, "</stream:features>")
+	if err != nil {
+		return err
+	}
+	if !strings.Contains(features, "<starttls") {
+		return errors.New("server does not offer STARTTLS")
+	}
+	if _, err := io.WriteString(w, "<starttls xmlns='urn:ietf:params:xml:ns:xmpp-tls'/>"); err != nil {
+		return err
+	}
+	reply, err := readUntil(r, "/>")
+	if err != nil {
+		return err
+	}
+	if !strings.Contains(reply, "<proceed") {
+		return errors.New("server refused: " + reply)
+	}
+	return nil
+}
+
+func readUntil(r *bufio.Reader, marker string) (string, error) {
+	var sb strings.Builder
+	for !strings.HasSuffix(sb.String(), marker) {
+		b, err := r.ReadByte()
+		if err != nil {
+			return sb.String(), err
+		}
+		sb.WriteByte(b)
+	}
+	return sb.String(), nil
+}
+
+// postgresStartTLS sends an SSLRequest; the server answers S to proceed or N.
+func postgresStartTLS(w io.Writer, r *bufio.Reader) error {
+	req := binary.BigEndian.AppendUint32(binary.BigEndian.AppendUint32(nil, 8), 80877103)
+	if _, err := w.Write(req); err != nil {
+		return err
+	}
+	b, err := r.ReadByte()
+	if err != nil {
+		return err
+	}
+	if b != 'S' {
+		return errors.New("server does not accept SSL")
+	}
+	return nil
+}
+
+// MySQL capability flags used in the SSL request.
+const (
+	mysqlLongPassword     = 0x00000001
+	mysqlProtocol41       = 0x00000200
+	mysqlSSL              = 0x00000800
+	mysqlSecureConnection = 0x00008000
+)
+
+// mysqlStartTLS reads the server greeting and answers with an SSLRequest packet, after
+// which the server expects the ClientHello.
+func mysqlStartTLS(w io.Writer, r *bufio.Reader) error {
+	hdr := make([]byte, 4)
+	if _, err := io.ReadFull(r, hdr); err != nil {
+		return err
+	}
+	n := int(hdr[0]) | int(hdr[1])<<8 | int(hdr[2])<<16
+	greeting := make([]byte, n)
+	if _, err := io.ReadFull(r, greeting); err != nil {
+		return err
+	}
+	if n == 0 || greeting[0] == 0xff {
+		return errors.New("server sent an error instead of a greeting")
+	}
+	// protocol version, NUL-terminated server version, connection id, 8 bytes of
+	// auth data and a filler byte precede the lower capability flags
+	end := strings.IndexByte(string(greeting[1:]), 0)
+	off := 1 + end + 1 + 4 + 8 + 1
+	if end < 0 || len(greeting) < off+2 {
+		return errors.New("malformed greeting")
+	}
+	if binary.LittleEndian.Uint16(greeting[off:])&mysqlSSL == 0 {
+		return errors.New("server does not support SSL")
+	}
+	payload := binary.LittleEndian.AppendUint32(nil, mysqlLongPassword|mysqlProtocol41|mysqlSSL|mysqlSecureConnection)
+	payload = binary.LittleEndian.AppendUint32(payload, 1<<24) // max packet size
+	payload = append(payload, 33)                              // utf8_general_ci
+	payload = append(payload, make([]byte, 23)...)
+	pkt := []byte{byte(len(payload)), 0, 0, hdr[3] + 1}
+	_, err := w.Write(append(pkt, payload...))
+	return err
+}
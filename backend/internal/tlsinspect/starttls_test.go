@@ -0,0 +1,118 @@
+package tlsinspect
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	"engtools/backend/internal/pki"
+	"engtools/backend/internal/pki/pkitest"
+)
+
+// plainServer accepts connections, runs upgrade on the plaintext stream and then serves
+// TLS, recording the SNI the client sent.
+func plainServer(t *testing.T, cfg *tls.Config, upgrade func(net.Conn, *bufio.Reader) error) (int, chan string) {
+	t.Helper()
+	cfg.Certificates = []tls.Certificate{pkitest.Leaf(t, "mail.test", nil).TLS()}
+	sni := make(chan string, 4)
+	cfg.GetConfigForClient = func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+		sni <- hello.ServerName
+		return nil, nil
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				if err := upgrade(conn, bufio.NewReader(conn)); err != nil {
+					return
+				}
+				tc := tls.Server(conn, cfg)
+				if tc.Handshake() == nil {
+					io.Copy(io.Discard, tc)
+				}
+			}()
+		}
+	}()
+	return ln.Addr().(*net.TCPAddr).Port, sni
+}
+
+func TestStartTLS(t *testing.T) {
+	tests := []struct {
+		proto   string
+		upgrade func(net.Conn, *bufio.Reader) error
+	}{
+		{"smtp", func(c net.Conn, r *bufio.Reader) error {
+			io.WriteString(c, "220 mail.test ESMTP\r\n")
+			if line, _ := r.ReadString('\n'); !strings.HasPrefix(line, "EHLO ") {
+				return io.ErrUnexpectedEOF
+			}
+			io.WriteString(c, "250-mail.test\r\n250-PIPELINING\r\n250 STARTTLS\r\n")
+			r.ReadString('\n')
+			_, err := io.WriteString(c, "220 go ahead\r\n")
+			return err
+		}},
+		{"imap", func(c net.Conn, r *bufio.Reader) error {
+			io.WriteString(c, "* OK [CAPABILITY IMAP4rev1 STARTTLS] ready\r\n")
+			r.ReadString('\n')
+			_, err := io.WriteString(c, "a1 OK Begin TLS negotiation now\r\n")
+			return err
+		}},
+		{"postgres", func(c net.Conn, r *bufio.Reader) error {
+			io.ReadFull(r, make([]byte, 8))
+			_, err := c.Write([]byte{'S'})
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.proto, func(t *testing.T) {
+			port, sni := plainServer(t, &tls.Config{}, tt.upgrade)
+			res, err := Inspect(context.Background(), Options{Host: "mail.test", Address: "127.0.0.1", Port: port, StartTLS: tt.proto})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := <-sni; got != "mail.test" {
+				t.Errorf("sni %q, want mail.test", got)
+			}
+			if res.IP != "127.0.0.1" || res.Verification.Reason != "unknown_authority" {
+				t.Errorf("ip %s, verification %+v", res.IP, res.Verification)
+			}
+		})
+	}
+}
+
+func TestInspectNoSNIAndClientCert(t *testing.T) {
+	port, sni := plainServer(t, &tls.Config{ClientAuth: tls.RequireAnyClientCert}, func(net.Conn, *bufio.Reader) error { return nil })
+	// TLS 1.3 servers reject a missing certificate only after the handshake
+	res, err := Inspect(context.Background(), Options{Host: "mail.test", Address: "127.0.0.1", Port: port, NoSNI: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.ClientAuth == nil || !res.ClientAuth.Requested || res.ClientAuth.Sent || res.ClientAuth.Error == "" {
+		t.Errorf("missing client certificate not reported: %+v", res.ClientAuth)
+	}
+	if got := <-sni; got != "" {
+		t.Errorf("sni %q sent with NoSNI", got)
+	}
+
+	cert := pkitest.Issue(t, pki.CertSpec{NameSpec: pki.NameSpec{CN: "client"}}, pkitest.Key(t, "ec"), nil).TLS()
+	res, err = Inspect(context.Background(), Options{Host: "mail.test", Address: "127.0.0.1", Port: port, ClientCert: &cert})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.ClientAuth == nil || !res.ClientAuth.Requested || !res.ClientAuth.Sent || res.ClientAuth.Error != "" {
+		t.Errorf("client auth %+v", res.ClientAuth)
+	}
+}
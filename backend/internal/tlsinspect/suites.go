@@ -0,0 +1,121 @@
+package tlsinspect
+
+import (
+	"crypto/tls"
+	"strings"
+)
+
+// tls13Suites are offered when probing TLS 1.3.
+var tls13Suites = []uint16{0x1301, 0x1302, 0x1303, 0x1304, 0x1305}
+
+// legacySuites are offered when probing SSL 3.0 to TLS 1.2. The list covers what
+// servers still deploy, including the broken suites a scan has to detect; the order is
+// only the client preference and has no other meaning.
+var legacySuites = []uint16{
+	0xc02c, 0xc02b, 0xcca9, 0xc030, 0xc02f, 0xcca8, 0x009f, 0x009e, 0xccaa, 0x00a3, 0x00a2,
+	0xc0ad, 0xc0ac, 0xc09f, 0xc09e, 0xc09d, 0xc09c,
+	0xc024, 0xc023, 0xc028, 0xc027, 0xc00a, 0xc009, 0xc014, 0xc013,
+	0x006b, 0x0067, 0x006a, 0x0040, 0x0039, 0x0033, 0x0038, 0x0032, 0x0088, 0x0045,
+	0x009d, 0x009c, 0x003d, 0x003c, 0x0035, 0x002f, 0x0084, 0x0041, 0x0096,
+	0xc008, 0xc012, 0x0016, 0x0013, 0x000a,
+	0xc007, 0xc011, 0x0005, 0x0004,
+	0x0015, 0x0012, 0x0009,
+	0x0014, 0x0011, 0x0008, 0x0006, 0x0003,
+	0xc019, 0xc018, 0xc017, 0xc016, 0x00a7, 0x00a6, 0x003a, 0x0034, 0x001b, 0x001a, 0x0018, 0x0017,
+	0xc015, 0xc010, 0xc006, 0x003b, 0x0002, 0x0001,
+}
+
+// suiteNames are the IANA names of the suites crypto/tls does not know.
+var suiteNames = map[uint16]string{
+	0x1304: "TLS_AES_128_CCM_SHA256",
+	0x1305: "TLS_AES_128_CCM_8_SHA256",
+	0x0001: "TLS_RSA_WITH_NULL_MD5",
+	0x0002: "TLS_RSA_WITH_NULL_SHA",
+	0x0003: "TLS_RSA_EXPORT_WITH_RC4_40_MD5",
+	0x0004: "TLS_RSA_WITH_RC4_128_MD5",
+	0x0006: "TLS_RSA_EXPORT_WITH_RC2_CBC_40_MD5",
+	0x0008: "TLS_RSA_EXPORT_WITH_DES40_CBC_SHA",
+	0x0009: "TLS_RSA_WITH_DES_CBC_SHA",
+	0x0011: "TLS_DHE_DSS_EXPORT_WITH_DES40_CBC_SHA",
+	0x0012: "TLS_DHE_DSS_WITH_DES_CBC_SHA",
+	0x0013: "TLS_DHE_DSS_WITH_3DES_EDE_CBC_SHA",
+	0x0014: "TLS_DHE_RSA_EXPORT_WITH_DES40_CBC_SHA",
+	0x0015: "TLS_DHE_RSA_WITH_DES_CBC_SHA",
+	0x0016: "TLS_DHE_RSA_WITH_3DES_EDE_CBC_SHA",
+	0x0017: "TLS_DH_anon_EXPORT_WITH_RC4_40_MD5",
+	0x0018: "TLS_DH_anon_WITH_RC4_128_MD5",
+	0x001a: "TLS_DH_anon_WITH_DES_CBC_SHA",
+	0x001b: "TLS_DH_anon_WITH_3DES_EDE_CBC_SHA",
+	0x0032: "TLS_DHE_DSS_WITH_AES_128_CBC_SHA",
+	0x0033: "TLS_DHE_RSA_WITH_AES_128_CBC_SHA",
+	0x0034: "TLS_DH_anon_WITH_AES_128_CBC_SHA",
+	0x0038: "TLS_DHE_DSS_WITH_AES_256_CBC_SHA",
+	0x0039: "TLS_DHE_RSA_WITH_AES_256_CBC_SHA",
+	0x003a: "TLS_DH_anon_WITH_AES_256_CBC_SHA",
+	0x003b: "TLS_RSA_WITH_NULL_SHA256",
+	0x003d: "TLS_RSA_WITH_AES_256_CBC_SHA256",
+	0x0040: "TLS_DHE_DSS_WITH_AES_128_CBC_SHA256",
+	0x0041: "TLS_RSA_WITH_CAMELLIA_128_CBC_SHA",
+	0x0045: "TLS_DHE_RSA_WITH_CAMELLIA_128_CBC_SHA",
+	0x0067: "TLS_DHE_RSA_WITH_AES_128_CBC_SHA256",
+	0x006a: "TLS_DHE_DSS_WITH_AES_256_CBC_SHA256",
+	0x006b: "TLS_DHE_RSA_WITH_AES_256_CBC_SHA256",
+	0x0084: "TLS_RSA_WITH_CAMELLIA_256_CBC_SHA",
+	0x0088: "TLS_DHE_RSA_WITH_CAMELLIA_256_CBC_SHA",
+	0x0096: "TLS_RSA_WITH_SEED_CBC_SHA",
+	0x009e: "TLS_DHE_RSA_WITH_AES_128_GCM_SHA256",
+	0x009f: "TLS_DHE_RSA_WITH_AES_256_GCM_SHA384",
+	0x00a2: "TLS_DHE_DSS_WITH_AES_128_GCM_SHA256",
+	0x00a3: "TLS_DHE_DSS_WITH_AES_256_GCM_SHA384",
+	0x00a6: "TLS_DH_anon_WITH_AES_128_GCM_SHA256",
+	0x00a7: "TLS_DH_anon_WITH_AES_256_GCM_SHA384",
+	0xc006: "TLS_ECDHE_ECDSA_WITH_NULL_SHA",
+	0xc008: "TLS_ECDHE_ECDSA_WITH_3DES_EDE_CBC_SHA",
+	0xc010: "TLS_ECDHE_RSA_WITH_NULL_SHA",
+	0xc015: "TLS_ECDH_anon_WITH_NULL_SHA",
+	0xc016: "TLS_ECDH_anon_WITH_RC4_128_SHA",
+	0xc017: "TLS_ECDH_anon_WITH_3DES_EDE_CBC_SHA",
+	0xc018: "TLS_ECDH_anon_WITH_AES_128_CBC_SHA",
+	0xc019: "TLS_ECDH_anon_WITH_AES_256_CBC_SHA",
+	0xc024: "TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA384",
+	0xc028: "TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA384",
+	0xc09c: "TLS_RSA_WITH_AES_128_CCM",
+	0xc09d: "TLS_RSA_WITH_AES_256_CCM",
+	0xc09e: "TLS_DHE_RSA_WITH_AES_128_CCM",
+	0xc09f: "TLS_DHE_RSA_WITH_AES_256_CCM",
+	0xc0ac: "TLS_ECDHE_ECDSA_WITH_AES_128_CCM",
+	0xc0ad: "TLS_ECDHE_ECDSA_WITH_AES_256_CCM",
+	0xccaa: "TLS_DHE_RSA_WITH_CHACHA20_POLY1305_SHA256",
+}
+
+// SuiteName names a cipher suite, falling back to the hex code point.
+func SuiteName(id uint16) string {
+	if name, ok := suiteNames[id]; ok {
+		return name
+	}
+	return tls.CipherSuiteName(id)
+}
+
+// suiteStrength rates a suite as strong, weak or insecure, with the reason for anything
+// below strong. Key exchange parameters are rated separately.
+func suiteStrength(name string) (string, string) {
+	switch {
+	case strings.Contains(name, "_NULL_"):
+		return "insecure", "no encryption"
+	case strings.Contains(name, "_anon_"):
+		return "insecure", "anonymous key exchange, no authentication"
+	case strings.Contains(name, "_EXPORT_"):
+		return "insecure", "export-grade encryption"
+	case strings.Contains(name, "_RC4_"):
+		return "insecure", "RC4 keystream biases"
+	case strings.Contains(name, "_WITH_DES_"):
+		return "insecure", "56-bit DES"
+	case strings.Contains(name, "_3DES_"):
+		return "weak", "64-bit block cipher (Sweet32)"
+	case strings.HasPrefix(name, "TLS_RSA_"):
+		return "weak", "static RSA key exchange, no forward secrecy"
+	case strings.Contains(name, "_CBC_"):
+		return "weak", "CBC mode"
+	}
+	return "strong", ""
+}
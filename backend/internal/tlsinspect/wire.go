@@ -0,0 +1,230 @@
+package tlsinspect
+
+import (
+	"bytes"
+	"crypto/tls"
+	"math/big"
+	"net"
+	"strings"
+
+	"golang.org/x/crypto/cryptobyte"
+)
+
+// recorder keeps a copy of what the server sends during the handshake. crypto/tls does
+// not expose the negotiated key exchange group, but it is readable in the plaintext
+// ServerHello (TLS 1.3) or ServerKeyExchange (TLS 1.2 and earlier).
+type recorder struct {
+	net.Conn
+	buf  []byte
+	stop bool
+}
+
+// maxRecorded bounds the copy; a server flight with a long chain is well below this.
+const maxRecorded = 256 << 10
+
+func (r *recorder) Read(p []byte) (int, error) {
+	n, err := r.Conn.Read(p)
+	if !r.stop && n > 0 {
+		if len(r.buf)+n > maxRecorded {
+			r.stop = true
+		} else {
+			r.buf = append(r.buf, p[:n]...)
+		}
+	}
+	return n, err
+}
+
+const (
+	recordChangeCipherSpec = 20
+	recordAlert            = 21
+	recordHandshake        = 22
+
+	msgClientHello       = 1
+	msgServerHello       = 2
+	msgServerKeyExchange = 12
+	msgServerHelloDone   = 14
+
+	extServerName        = 0
+	extSupportedGroups   = 10
+	extECPointFormats    = 11
+	extSignatureAlgs     = 13
+	extExtendedMaster    = 23
+	extSupportedVersions = 43
+	extPSKModes          = 45
+	extKeyShare          = 51
+	extRenegotiationInfo = 0xff01
+)
+
+// helloRetryRandom is the fixed ServerHello.random marking a HelloRetryRequest (RFC 8446
+// section 4.1.3).
+var helloRetryRandom = []byte{
+	0xcf, 0x21, 0xad, 0x74, 0xe5, 0x9a, 0x61, 0x11, 0xbe, 0x1d, 0x8c, 0x02, 0x1e, 0x65, 0xb8, 0x91,
+	0xc2, 0xa2, 0x11, 0x16, 0x7a, 0xbb, 0x8c, 0x5e, 0x07, 0x9e, 0x09, 0xe2, 0xc8, 0xa8, 0x33, 0x9c,
+}
+
+// serverFlight is what we could read from the server's plaintext handshake messages.
+type serverFlight struct {
+	Version     uint16
+	CipherSuite uint16
+	Group       tls.CurveID // zero for RSA key transport
+	DHBits      int         // prime size of finite-field DHE in TLS 1.2 and earlier
+	HelloRetry  bool
+	HasHello    bool
+	Done        bool // ServerHelloDone seen, the server flight is complete
+	Alert       int  // alert description when the server refused, otherwise -1
+}
+
+// parseServerFlight walks the recorded server bytes up to the first encrypted record.
+// Anything truncated or malformed simply ends the walk; the caller reports what was
+// found.
+func parseServerFlight(data []byte) serverFlight {
+	f := serverFlight{Alert: -1}
+	var hs []byte
+	s := cryptobyte.String(data)
+	for !s.Empty() {
+		var typ uint8
+		var version uint16
+		var body cryptobyte.String
+		if !s.ReadUint8(&typ) || !s.ReadUint16(&version) || !s.ReadUint16LengthPrefixed(&body) {
+			break
+		}
+		if typ == recordChangeCipherSpec {
+			// TLS 1.3 middlebox compatibility sends one after a HelloRetryRequest, and
+			// the real ServerHello follows in the clear. Otherwise encryption starts here.
+			if f.HelloRetry && !f.HasHello {
+				continue
+			}
+			break
+		}
+		if typ == recordAlert {
+			var level, desc uint8
+			if body.ReadUint8(&level) && body.ReadUint8(&desc) {
+				f.Alert = int(desc)
+			}
+			break
+		}
+		if typ != recordHandshake {
+			break
+		}
+		hs = append(hs, body...)
+		hs = f.consume(hs)
+		if f.HasHello && f.Version == tls.VersionTLS13 {
+			break
+		}
+	}
+	return f
+}
+
+// consume parses complete handshake messages from hs and returns the unparsed rest.
+func (f *serverFlight) consume(hs []byte) []byte {
+	for len(hs) >= 4 {
+		n := int(hs[1])<<16 | int(hs[2])<<8 | int(hs[3])
+		if len(hs) < 4+n {
+			break
+		}
+		msg := hs[4 : 4+n]
+		switch hs[0] {
+		case msgServerHello:
+			f.parseServerHello(msg)
+		case msgServerKeyExchange:
+			f.parseServerKeyExchange(msg)
+		case msgServerHelloDone:
+			f.Done = true
+		}
+		hs = hs[4+n:]
+	}
+	return hs
+}
+
+func (f *serverFlight) parseServerHello(msg []byte) {
+	s := cryptobyte.String(msg)
+	var version, suite uint16
+	var random []byte
+	var session cryptobyte.String
+	var compression uint8
+	if !s.ReadUint16(&version) || !s.ReadBytes(&random, 32) || !s.ReadUint8LengthPrefixed(&session) ||
+		!s.ReadUint16(&suite) || !s.ReadUint8(&compression) {
+		return
+	}
+	retry := bytes.Equal(random, helloRetryRandom)
+	f.Version, f.CipherSuite = version, suite
+	var exts cryptobyte.String
+	if !s.Empty() && !s.ReadUint16LengthPrefixed(&exts) {
+		return
+	}
+	for !exts.Empty() {
+		var typ uint16
+		var data cryptobyte.String
+		if !exts.ReadUint16(&typ) || !exts.ReadUint16LengthPrefixed(&data) {
+			return
+		}
+		switch typ {
+		case extSupportedVersions:
+			data.ReadUint16(&f.Version)
+		case extKeyShare:
+			// a HelloRetryRequest names only the group; a ServerHello adds the share
+			var group uint16
+			if data.ReadUint16(&group) {
+				f.Group = tls.CurveID(group)
+			}
+		}
+	}
+	if retry {
+		f.HelloRetry = true
+		return
+	}
+	f.HasHello = true
+}
+
+func (f *serverFlight) parseServerKeyExchange(msg []byte) {
+	s := cryptobyte.String(msg)
+	name := SuiteName(f.CipherSuite)
+	switch {
+	case strings.Contains(name, "_ECDHE_"):
+		var curveType uint8
+		var group uint16
+		// 3 is named_curve; explicit curves were deprecated and are not reported
+		if s.ReadUint8(&curveType) && curveType == 3 && s.ReadUint16(&group) {
+			f.Group = tls.CurveID(group)
+		}
+	case strings.Contains(name, "_DHE_"):
+		var p cryptobyte.String
+		if s.ReadUint16LengthPrefixed(&p) {
+			f.DHBits = new(big.Int).SetBytes(p).BitLen()
+		}
+	}
+}
+
+// groupNames uses the short names tools such as openssl print, and covers groups
+// crypto/tls does not name itself.
+var groupNames = map[tls.CurveID]string{
+	23: "P-256", 24: "P-384", 25: "P-521",
+	256: "ffdhe2048", 257: "ffdhe3072", 258: "ffdhe4096", 259: "ffdhe6144", 260: "ffdhe8192",
+	4588: "X25519MLKEM768", 4587: "SecP256r1MLKEM768", 4589: "SecP384r1MLKEM1024",
+}
+
+// GroupName names a key exchange group.
+func GroupName(id tls.CurveID) string {
+	if name, ok := groupNames[id]; ok {
+		return name
+	}
+	return id.String()
+}
+
+// keyExchange classifies the negotiated exchange: ECDHE, DHE, hybrid (post-quantum
+// hybrid groups) or RSA key transport.
+func keyExchange(f serverFlight) string {
+	switch {
+	case f.Group >= 4587 && f.Group <= 4589:
+		return "hybrid"
+	case f.Group >= 256 && f.Group <= 511, f.DHBits > 0:
+		return "DHE"
+	case f.Group != 0:
+		return "ECDHE"
+	case f.Version == tls.VersionTLS13:
+		return ""
+	case strings.HasPrefix(SuiteName(f.CipherSuite), "TLS_RSA_"):
+		return "RSA"
+	}
+	return ""
+}
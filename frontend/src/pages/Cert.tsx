@@ -147,7 +147,7 @@ export default function Cert() {
                           <TableCell>{ci.is_ca ? 'Yes' : 'No'}</TableCell>
                           <TableCell>{Array.isArray(ci.key_usage) && ci.key_usage.length ? ci.key_usage.join(', ') : '-'}</TableCell>
                           <TableCell>{Array.isArray(ci.ext_key_usage) && ci.ext_key_usage.length ? ci.ext_key_usage.join(', ') : '-'}</TableCell>
-                          <TableCell sx={{ fontFamily:'monospace' }}>{ci.fingerprint_sha256}</TableCell>
+                          <TableCell sx={{ fontFamily:'monospace' }}>{ci.fingerprints?.sha256}</TableCell>
                         </TableRow>
                       ))}
                     </TableBody>
@@ -201,7 +201,7 @@ export default function Cert() {
                             <TableCell>{ci.issuer}</TableCell>
                             <TableCell>{new Date(ci.not_before).toLocaleString()}</TableCell>
                             <TableCell>{new Date(ci.not_after).toLocaleString()}</TableCell>
-                            <TableCell sx={{ fontFamily:'monospace' }}>{ci.fingerprint_sha256}</TableCell>
+                            <TableCell sx={{ fontFamily:'monospace' }}>{ci.fingerprints?.sha256}</TableCell>
                           </TableRow>
                         ))}
                       </TableBody>